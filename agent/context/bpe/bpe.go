@@ -0,0 +1,116 @@
+// Package bpe 实现 tiktoken 兼容的字节级 BPE 分词（cl100k_base / o200k_base），
+// 用于精确计算上下文的 Token 数。
+package bpe
+
+import "math"
+
+// Encoding 一种 BPE 编码：预分词规则加合并词表
+type Encoding struct {
+	name  string
+	ranks map[string]int
+	split func(text string) []string
+}
+
+// NewEncoding 使用给定词表创建编码；split 为空时按 cl100k_base 规则预分词
+func NewEncoding(name string, ranks map[string]int, split func(string) []string) *Encoding {
+	if split == nil {
+		split = splitCL100K
+	}
+	return &Encoding{name: name, ranks: ranks, split: split}
+}
+
+// Name 返回编码名称
+func (e *Encoding) Name() string {
+	return e.name
+}
+
+// Encode 将文本编码为 Token ID；词表中不存在的字节记为 -1
+func (e *Encoding) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range e.split(text) {
+		tokens = e.encodePiece([]byte(piece), tokens)
+	}
+	return tokens
+}
+
+// Count 返回文本的 Token 数
+func (e *Encoding) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	count := 0
+	for _, piece := range e.split(text) {
+		if _, ok := e.ranks[piece]; ok {
+			count++
+			continue
+		}
+		count += len(bytePairMerge([]byte(piece), e.ranks)) - 1
+	}
+	return count
+}
+
+func (e *Encoding) encodePiece(piece []byte, out []int) []int {
+	if rank, ok := e.ranks[string(piece)]; ok {
+		return append(out, rank)
+	}
+	bounds := bytePairMerge(piece, e.ranks)
+	for i := 0; i < len(bounds)-1; i++ {
+		rank, ok := e.ranks[string(piece[bounds[i]:bounds[i+1]])]
+		if !ok {
+			rank = -1
+		}
+		out = append(out, rank)
+	}
+	return out
+}
+
+// bytePairMerge 反复合并相邻字节序列中排名最低的一对，返回各 Token 的边界
+func bytePairMerge(piece []byte, ranks map[string]int) []int {
+	type part struct {
+		start int
+		rank  int // 与下一部分合并后的排名
+	}
+	parts := make([]part, len(piece)+1)
+	for i := range parts {
+		parts[i] = part{start: i, rank: math.MaxInt}
+	}
+
+	pairRank := func(i int) int {
+		if i+2 >= len(parts) {
+			return math.MaxInt
+		}
+		if rank, ok := ranks[string(piece[parts[i].start:parts[i+2].start])]; ok {
+			return rank
+		}
+		return math.MaxInt
+	}
+	for i := 0; i < len(parts)-2; i++ {
+		parts[i].rank = pairRank(i)
+	}
+
+	for len(parts) > 2 {
+		minIdx := -1
+		minRank := math.MaxInt
+		for i := 0; i < len(parts)-1; i++ {
+			if parts[i].rank < minRank {
+				minRank = parts[i].rank
+				minIdx = i
+			}
+		}
+		if minIdx < 0 {
+			break
+		}
+
+		parts = append(parts[:minIdx+1], parts[minIdx+2:]...)
+		parts[minIdx].rank = pairRank(minIdx)
+		if minIdx > 0 {
+			parts[minIdx-1].rank = pairRank(minIdx - 1)
+		}
+	}
+
+	bounds := make([]int, len(parts))
+	for i, p := range parts {
+		bounds[i] = p.start
+	}
+	return bounds
+}
@@ -0,0 +1,130 @@
+package bpe
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitCL100K(t *testing.T) {
+	tests := map[string][]string{
+		"Hello world":    {"Hello", " world"},
+		"I'm 12345 ok":   {"I", "'m", " ", "123", "45", " ok"},
+		"foo  bar":       {"foo", " ", " bar"},
+		"a\n\nb":         {"a", "\n\n", "b"},
+		"x = y;\n":       {"x", " =", " y", ";\n"},
+		"func main() {}": {"func", " main", "()", " {}"},
+		"trailing  ":     {"trailing", "  "},
+	}
+	for input, want := range tests {
+		if got := splitCL100K(input); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitCL100K(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestSplitO200K(t *testing.T) {
+	tests := map[string][]string{
+		"HelloWorld": {"Hello", "World"},
+		"I'm here":   {"I'm", " here"},
+		"path/to/":   {"path", "/to", "/"},
+		"ABCdef":     {"ABCdef"},
+	}
+	for input, want := range tests {
+		if got := splitO200K(input); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitO200K(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	ranks := map[string]int{"a": 0, "b": 1, "c": 2, "ab": 3, "abc": 4, "bc": 5}
+	enc := NewEncoding("test", ranks, nil)
+
+	tests := map[string][]int{
+		"abc":   {4},
+		"cab":   {2, 3},
+		"abcab": {4, 3},
+	}
+	for input, want := range tests {
+		if got := enc.Encode(input); !reflect.DeepEqual(got, want) {
+			t.Errorf("Encode(%q) = %v, want %v", input, got, want)
+		}
+		if got := enc.Count(input); got != len(want) {
+			t.Errorf("Count(%q) = %d, want %d", input, got, len(want))
+		}
+	}
+}
+
+func TestLoadRanks(t *testing.T) {
+	var sb strings.Builder
+	for i, token := range []string{"a", "b", " ab"} {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), i)
+	}
+
+	ranks, err := LoadRanks(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("LoadRanks failed: %v", err)
+	}
+	if ranks[" ab"] != 2 || len(ranks) != 3 {
+		t.Errorf("unexpected ranks: %v", ranks)
+	}
+
+	if _, err := LoadRanks(strings.NewReader("not-a-rank-line\n")); err == nil {
+		t.Error("expected error for malformed line")
+	}
+}
+
+func TestEncodingForModel(t *testing.T) {
+	tests := map[string]string{
+		"gpt-4o-mini":   O200KBase,
+		"openai/o3":     O200KBase,
+		"gpt-4-turbo":   CL100KBase,
+		"deepseek-chat": CL100KBase,
+	}
+	for model, want := range tests {
+		if got := EncodingForModel(model); got != want {
+			t.Errorf("EncodingForModel(%q) = %s, want %s", model, got, want)
+		}
+	}
+}
+
+// TestReferenceCounts compares counts with tiktoken for the real
+// vocabularies, embedded or in the repository's .mscli/tokenizers. It is
+// skipped when they are not installed.
+func TestReferenceCounts(t *testing.T) {
+	dir := filepath.Join("..", "..", "..", ".mscli", "tokenizers")
+	tests := map[string]map[string]int{
+		CL100KBase: {
+			"hello world":        2,
+			"Hello, world!":      4,
+			"tiktoken is great!": 6,
+			"The quick brown fox jumps over the lazy dog.": 10,
+		},
+		O200KBase: {
+			"hello world":   2,
+			"Hello, world!": 4,
+			"The quick brown fox jumps over the lazy dog.": 10,
+		},
+	}
+	for name, counts := range tests {
+		enc, err := Load(name, dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			t.Logf("skipping %s: %v", name, err)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Load(%s) failed: %v", name, err)
+		}
+		for input, want := range counts {
+			if got := enc.Count(input); got != want {
+				t.Errorf("%s: Count(%q) = %d, want %d", name, input, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package bpe
+
+import (
+	"bufio"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 支持的编码
+const (
+	CL100KBase = "cl100k_base"
+	O200KBase  = "o200k_base"
+)
+
+// vocabFS 内置的词表，见 vocab/README.md
+//
+//go:embed vocab
+var vocabFS embed.FS
+
+var splitters = map[string]func(string) []string{
+	CL100KBase: splitCL100K,
+	O200KBase:  splitO200K,
+}
+
+var (
+	loadMu sync.Mutex
+	loaded = make(map[string]*Encoding)
+)
+
+// EncodingForModel 根据模型名选择编码；非 OpenAI 模型使用 cl100k_base 近似，
+// 由调用方按实际用量校准
+func EncodingForModel(model string) string {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, prefix := range []string{"gpt-4o", "chatgpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "o1", "o3", "o4"} {
+		if strings.HasPrefix(name, prefix) {
+			return O200KBase
+		}
+	}
+	return CL100KBase
+}
+
+// ForModel 加载模型对应的编码
+func ForModel(model string, dirs ...string) (*Encoding, error) {
+	return Load(EncodingForModel(model), dirs...)
+}
+
+// Load 加载编码：优先使用内置词表，其次依次查找 dirs 中的 <name>.tiktoken；
+// 加载结果会被缓存
+func Load(name string, dirs ...string) (*Encoding, error) {
+	split, ok := splitters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+
+	loadMu.Lock()
+	defer loadMu.Unlock()
+	if enc, ok := loaded[name]; ok {
+		return enc, nil
+	}
+
+	ranks, err := loadVocab(name, dirs)
+	if err != nil {
+		return nil, err
+	}
+	enc := NewEncoding(name, ranks, split)
+	loaded[name] = enc
+	return enc, nil
+}
+
+func loadVocab(name string, dirs []string) (map[string]int, error) {
+	file := name + ".tiktoken"
+	if f, err := vocabFS.Open("vocab/" + file); err == nil {
+		defer f.Close()
+		return LoadRanks(f)
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, file))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		defer f.Close()
+		return LoadRanks(f)
+	}
+	return nil, fmt.Errorf("vocabulary %s not found: %w", file, fs.ErrNotExist)
+}
+
+// LoadRanks 读取 tiktoken 格式的词表：每行为 base64 编码的 Token 和其排名
+func LoadRanks(r io.Reader) (map[string]int, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		token, rank, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected token and rank", line)
+		}
+		raw, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranks[string(raw)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("empty vocabulary")
+	}
+	return ranks, nil
+}
@@ -0,0 +1,205 @@
+package bpe
+
+import "unicode"
+
+// 预分词规则与 tiktoken 的正则一致。Go 的 regexp 不支持 (?!\S) 这类前瞻，
+// 因此按正则的回溯语义逐个分支手写匹配。
+
+// splitCL100K 按 cl100k_base 规则预分词：
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}|
+//	 ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func splitCL100K(text string) []string {
+	return split(text, func(r []rune, i int) int {
+		if n := matchContraction(r, i); n > 0 {
+			return n
+		}
+		if n := matchLetters(r, i); n > 0 {
+			return n
+		}
+		if n := matchDigits(r, i); n > 0 {
+			return n
+		}
+		if n := matchPunct(r, i, false); n > 0 {
+			return n
+		}
+		return matchSpace(r, i)
+	})
+}
+
+// splitO200K 按 o200k_base 规则预分词：
+//
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func splitO200K(text string) []string {
+	return split(text, func(r []rune, i int) int {
+		if n := matchCasedWord(r, i, true); n > 0 {
+			return n
+		}
+		if n := matchCasedWord(r, i, false); n > 0 {
+			return n
+		}
+		if n := matchDigits(r, i); n > 0 {
+			return n
+		}
+		if n := matchPunct(r, i, true); n > 0 {
+			return n
+		}
+		return matchSpace(r, i)
+	})
+}
+
+// split 从左到右反复取最先匹配的分支
+func split(text string, match func(r []rune, i int) int) []string {
+	r := []rune(text)
+	var pieces []string
+	for i := 0; i < len(r); {
+		n := match(r, i)
+		if n <= 0 {
+			n = 1
+		}
+		pieces = append(pieces, string(r[i:i+n]))
+		i += n
+	}
+	return pieces
+}
+
+// matchContraction 匹配 (?i:'s|'t|'re|'ve|'m|'ll|'d)
+func matchContraction(r []rune, i int) int {
+	if i+1 >= len(r) || r[i] != '\'' {
+		return 0
+	}
+	switch unicode.ToLower(r[i+1]) {
+	case 's', 't', 'm', 'd':
+		return 2
+	}
+	if i+2 < len(r) {
+		switch string([]rune{unicode.ToLower(r[i+1]), unicode.ToLower(r[i+2])}) {
+		case "re", "ve", "ll":
+			return 3
+		}
+	}
+	return 0
+}
+
+// matchLetters 匹配 [^\r\n\p{L}\p{N}]?\p{L}+
+func matchLetters(r []rune, i int) int {
+	j := i
+	if isPrefix(r[j]) && j+1 < len(r) && unicode.IsLetter(r[j+1]) {
+		j++
+	}
+	k := j
+	for k < len(r) && unicode.IsLetter(r[k]) {
+		k++
+	}
+	if k == j {
+		return 0
+	}
+	return k - i
+}
+
+// matchCasedWord 匹配 o200k 的两个单词分支：
+// lower 为真时是 UPPER*LOWER+，否则是 UPPER+LOWER*，其后可跟缩写
+func matchCasedWord(r []rune, i int, lower bool) int {
+	try := func(j int) int {
+		u := j
+		for u < len(r) && isUpperClass(r[u]) {
+			u++
+		}
+		for ; u >= j; u-- {
+			if !lower && u == j {
+				return 0
+			}
+			l := u
+			for l < len(r) && isLowerClass(r[l]) {
+				l++
+			}
+			if lower && l == u {
+				continue
+			}
+			return l + matchContraction(r, l) - i
+		}
+		return 0
+	}
+
+	if isPrefix(r[i]) && i+1 < len(r) {
+		if n := try(i + 1); n > 0 {
+			return n
+		}
+	}
+	return try(i)
+}
+
+// matchDigits 匹配 \p{N}{1,3}
+func matchDigits(r []rune, i int) int {
+	n := 0
+	for n < 3 && i+n < len(r) && unicode.IsNumber(r[i+n]) {
+		n++
+	}
+	return n
+}
+
+// matchPunct 匹配 ` ?[^\s\p{L}\p{N}]+[\r\n]*`；slash 为真时结尾还可包含 '/'
+func matchPunct(r []rune, i int, slash bool) int {
+	j := i
+	if r[j] == ' ' && j+1 < len(r) && isPunct(r[j+1]) {
+		j++
+	}
+	k := j
+	for k < len(r) && isPunct(r[k]) {
+		k++
+	}
+	if k == j {
+		return 0
+	}
+	for k < len(r) && (isNewline(r[k]) || (slash && r[k] == '/')) {
+		k++
+	}
+	return k - i
+}
+
+// matchSpace 依次匹配 \s*[\r\n]+、\s+(?!\S) 和 \s+
+func matchSpace(r []rune, i int) int {
+	end := i
+	for end < len(r) && unicode.IsSpace(r[end]) {
+		end++
+	}
+	if end == i {
+		return 0
+	}
+	for j := end - 1; j >= i; j-- {
+		if isNewline(r[j]) {
+			return j + 1 - i
+		}
+	}
+	if end == len(r) || end-i == 1 {
+		return end - i
+	}
+	// 留下最后一个空白，与其后的单词合为一段
+	return end - 1 - i
+}
+
+func isNewline(c rune) bool {
+	return c == '\r' || c == '\n'
+}
+
+// isPrefix 判断是否属于 [^\r\n\p{L}\p{N}]
+func isPrefix(c rune) bool {
+	return !isNewline(c) && !unicode.IsLetter(c) && !unicode.IsNumber(c)
+}
+
+// isPunct 判断是否属于 [^\s\p{L}\p{N}]
+func isPunct(c rune) bool {
+	return !unicode.IsSpace(c) && !unicode.IsLetter(c) && !unicode.IsNumber(c)
+}
+
+// isUpperClass 判断是否属于 [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]
+func isUpperClass(c rune) bool {
+	return unicode.In(c, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isLowerClass 判断是否属于 [\p{Ll}\p{Lm}\p{Lo}\p{M}]
+func isLowerClass(c rune) bool {
+	return unicode.In(c, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
@@ -1,9 +1,10 @@
 package context
 
 import (
+	"context"
 	"fmt"
-	"sort"
 	"strings"
+	"time"
 
 	"github.com/vigo999/ms-cli/integrations/llm"
 )
@@ -14,7 +15,7 @@ type CompactStrategy int
 const (
 	// CompactStrategySimple 简单策略：直接丢弃旧消息
 	CompactStrategySimple CompactStrategy = iota
-	// CompactStrategySummarize 摘要策略：将旧消息摘要为一句话
+	// CompactStrategySummarize 摘要策略：由模型将旧消息写成结构化摘要
 	CompactStrategySummarize
 	// CompactStrategyPriority 优先级策略：基于优先级保留消息
 	CompactStrategyPriority
@@ -60,12 +61,16 @@ type Compactor struct {
 	scorer          *PriorityScorer
 	tokenizer       *Tokenizer
 	maxKeepMessages int // 最大保留消息数
+	provider        llm.Provider  // 生成摘要的模型，为空时使用启发式摘要
+	summaryTimeout  time.Duration // 生成摘要的超时
 }
 
 // CompactorConfig 压缩器配置
 type CompactorConfig struct {
 	Strategy        CompactStrategy
 	MaxKeepMessages int
+	Provider        llm.Provider
+	SummaryTimeout  time.Duration
 }
 
 // NewCompactor 创建新的压缩器
@@ -73,11 +78,16 @@ func NewCompactor(cfg CompactorConfig) *Compactor {
 	if cfg.MaxKeepMessages <= 0 {
 		cfg.MaxKeepMessages = 20
 	}
+	if cfg.SummaryTimeout <= 0 {
+		cfg.SummaryTimeout = defaultSummaryTimeout
+	}
 	return &Compactor{
 		strategy:        cfg.Strategy,
 		scorer:          NewPriorityScorer(),
 		tokenizer:       NewTokenizer(),
 		maxKeepMessages: cfg.MaxKeepMessages,
+		provider:        cfg.Provider,
+		summaryTimeout:  cfg.SummaryTimeout,
 	}
 }
 
@@ -86,8 +96,34 @@ func (c *Compactor) SetStrategy(s CompactStrategy) {
 	c.strategy = s
 }
 
+// SetProvider 设置生成摘要的模型
+func (c *Compactor) SetProvider(p llm.Provider) {
+	c.provider = p
+}
+
 // Compact 执行压缩
 func (c *Compactor) Compact(messages []llm.Message, systemMsg *llm.Message) ([]llm.Message, CompactResult) {
+	return c.CompactContext(context.Background(), messages, systemMsg)
+}
+
+// CompactContext 执行压缩，模型摘要在 ctx 下进行
+func (c *Compactor) CompactContext(ctx context.Context, messages []llm.Message, systemMsg *llm.Message) ([]llm.Message, CompactResult) {
+	return c.compact(messages, systemMsg, func(msgs []llm.Message) (string, error) {
+		return c.summarize(ctx, msgs)
+	})
+}
+
+// compactHeuristic 执行压缩，只使用启发式摘要，不调用模型
+func (c *Compactor) compactHeuristic(messages []llm.Message, systemMsg *llm.Message) ([]llm.Message, CompactResult) {
+	return c.compact(messages, systemMsg, func(msgs []llm.Message) (string, error) {
+		return c.generateSummary(msgs), nil
+	})
+}
+
+// summarizeFunc 生成被丢弃消息的摘要
+type summarizeFunc func(messages []llm.Message) (string, error)
+
+func (c *Compactor) compact(messages []llm.Message, systemMsg *llm.Message, summarize summarizeFunc) ([]llm.Message, CompactResult) {
 	if len(messages) <= c.maxKeepMessages {
 		return messages, CompactResult{Kept: len(messages), Removed: 0}
 	}
@@ -96,11 +132,11 @@ func (c *Compactor) Compact(messages []llm.Message, systemMsg *llm.Message) ([]l
 	case CompactStrategySimple:
 		return c.compactSimple(messages, systemMsg)
 	case CompactStrategySummarize:
-		return c.compactSummarize(messages, systemMsg)
+		return c.compactSummarize(messages, systemMsg, summarize)
 	case CompactStrategyPriority:
 		return c.compactPriority(messages, systemMsg)
 	case CompactStrategyHybrid:
-		return c.compactHybrid(messages, systemMsg)
+		return c.compactHybrid(messages, systemMsg, summarize)
 	default:
 		return c.compactSimple(messages, systemMsg)
 	}
@@ -118,8 +154,10 @@ func (c *Compactor) compactSimple(messages []llm.Message, systemMsg *llm.Message
 		return messages, CompactResult{Kept: len(messages), Removed: 0}
 	}
 	
-	removed := len(messages) - keepCount
-	result := messages[len(messages)-keepCount:]
+	// 不拆开工具调用与其结果
+	cut := alignCut(messages, len(messages)-keepCount)
+	removed := cut
+	result := messages[cut:]
 	
 	return result, CompactResult{
 		Kept:      len(result),
@@ -130,7 +168,7 @@ func (c *Compactor) compactSimple(messages []llm.Message, systemMsg *llm.Message
 }
 
 // compactSummarize 摘要压缩策略
-func (c *Compactor) compactSummarize(messages []llm.Message, systemMsg *llm.Message) ([]llm.Message, CompactResult) {
+func (c *Compactor) compactSummarize(messages []llm.Message, systemMsg *llm.Message, summarize summarizeFunc) ([]llm.Message, CompactResult) {
 	// 保留最近的消息
 	keepCount := c.maxKeepMessages - 2 // 留出位置给摘要和系统消息
 	if keepCount < 4 {
@@ -141,146 +179,123 @@ func (c *Compactor) compactSummarize(messages []llm.Message, systemMsg *llm.Mess
 		return messages, CompactResult{Kept: len(messages), Removed: 0}
 	}
 	
-	// 需要摘要的消息
-	toSummarize := messages[:len(messages)-keepCount]
+	// 需要摘要的消息，不拆开工具调用与其结果
+	cut := alignCut(messages, len(messages)-keepCount)
+	if cut == 0 {
+		return messages, CompactResult{Kept: len(messages), Removed: 0}
+	}
+	toSummarize := messages[:cut]
 	
 	// 生成摘要
-	summary := c.generateSummary(toSummarize)
+	summary, err := summarize(toSummarize)
 	summaryMsg := llm.NewSystemMessage(summary)
 	
 	// 保留的消息
-	result := append([]llm.Message{summaryMsg}, messages[len(messages)-keepCount:]...)
+	result := append([]llm.Message{summaryMsg}, messages[cut:]...)
 	
 	return result, CompactResult{
-		Kept:      len(result),
-		Removed:   len(toSummarize),
-		Strategy:  CompactStrategySummarize,
-		Summary:   summary,
+		Kept:       len(result),
+		Removed:    len(toSummarize),
+		Strategy:   CompactStrategySummarize,
+		Summary:    summary,
+		SummaryErr: err,
 	}
 }
 
 // compactPriority 优先级压缩策略
 func (c *Compactor) compactPriority(messages []llm.Message, systemMsg *llm.Message) ([]llm.Message, CompactResult) {
-	// 为所有消息评分
-	prioritized := make([]PrioritizedMessage, len(messages))
-	for i, msg := range messages {
-		prioritized[i] = PrioritizedMessage{
-			Message:  msg,
-			Priority: c.scorer.ScoreMessage(msg, i, len(messages)),
-			Index:    i,
-		}
-	}
-	
-	// 按优先级排序
-	sort.Sort(ByPriority(prioritized))
-	
-	// 保留优先级最高的消息
-	keepCount := c.maxKeepMessages
-	if keepCount > len(prioritized) {
-		keepCount = len(prioritized)
-	}
-	
-	// 获取要保留的消息
-	kept := prioritized[:keepCount]
-	removed := prioritized[keepCount:]
-	
-	// 按原始索引排序，保持顺序
-	sort.Slice(kept, func(i, j int) bool {
-		return kept[i].Index < kept[j].Index
-	})
-	
+	// 保留优先级最高的消息，工具调用与其结果作为整体保留或丢弃
+	kept, removed := selectUnits(c.scoreMessages(messages), c.maxKeepMessages)
+
 	// 提取消息
 	result := make([]llm.Message, len(kept))
 	for i, pm := range kept {
 		result[i] = pm.Message
 	}
-	
+
 	return result, CompactResult{
-		Kept:      len(result),
-		Removed:   len(removed),
-		Strategy:  CompactStrategyPriority,
-		Summary:   fmt.Sprintf("Kept %d high-priority messages, removed %d", len(result), len(removed)),
+		Kept:     len(result),
+		Removed:  len(removed),
+		Strategy: CompactStrategyPriority,
+		Summary:  fmt.Sprintf("Kept %d high-priority messages, removed %d", len(result), len(removed)),
 	}
 }
 
 // compactHybrid 混合压缩策略
-func (c *Compactor) compactHybrid(messages []llm.Message, systemMsg *llm.Message) ([]llm.Message, CompactResult) {
+func (c *Compactor) compactHybrid(messages []llm.Message, systemMsg *llm.Message, summarize summarizeFunc) ([]llm.Message, CompactResult) {
 	// 策略：
 	// 1. 保留最近的几条消息（高优先级）
 	// 2. 基于优先级选择保留的较旧消息
 	// 3. 将其他旧消息摘要
-	
+	// 工具调用与其结果始终作为整体处理
+
 	recentCount := c.maxKeepMessages / 2 // 保留一半给最新消息
 	if recentCount < 3 {
 		recentCount = 3
 	}
-	
+
 	if len(messages) <= c.maxKeepMessages {
 		return messages, CompactResult{Kept: len(messages), Removed: 0}
 	}
-	
+
 	// 保留最近的消息
-	recentMessages := messages[len(messages)-recentCount:]
-	
+	cut := alignCut(messages, len(messages)-recentCount)
+	recentMessages := messages[cut:]
+
 	// 处理旧消息
-	oldMessages := messages[:len(messages)-recentCount]
-	
-	// 对旧消息进行优先级评分
-	prioritized := make([]PrioritizedMessage, len(oldMessages))
-	for i, msg := range oldMessages {
-		prioritized[i] = PrioritizedMessage{
-			Message:  msg,
-			Priority: c.scorer.ScoreMessage(msg, i, len(oldMessages)),
-			Index:    i,
-		}
-	}
-	
-	// 按优先级排序
-	sort.Sort(ByPriority(prioritized))
-	
+	oldMessages := messages[:cut]
+
 	// 保留高优先级的旧消息
-	oldKeepCount := c.maxKeepMessages - recentCount - 1 // 留出位置给摘要
+	oldKeepCount := c.maxKeepMessages - len(recentMessages) - 1 // 留出位置给摘要
 	if oldKeepCount < 0 {
 		oldKeepCount = 0
 	}
-	
+	keptOld, dropped := selectUnits(c.scoreMessages(oldMessages), oldKeepCount)
+
 	var result []llm.Message
-	
+	var summaryErr error
+
 	// 如果有需要摘要的旧消息，添加摘要
-	if len(prioritized) > oldKeepCount {
-		toSummarize := make([]llm.Message, len(prioritized)-oldKeepCount)
-		for i := oldKeepCount; i < len(prioritized); i++ {
-			toSummarize[i-oldKeepCount] = prioritized[i].Message
+	if len(dropped) > 0 {
+		toSummarize := make([]llm.Message, len(dropped))
+		for i, pm := range dropped {
+			toSummarize[i] = pm.Message
 		}
-		summary := c.generateSummary(toSummarize)
+		var summary string
+		summary, summaryErr = summarize(toSummarize)
 		result = append(result, llm.NewSystemMessage(summary))
 	}
-	
+
 	// 添加保留的高优先级旧消息
-	if oldKeepCount > 0 {
-		highPriorityOld := prioritized[:oldKeepCount]
-		// 按原始索引排序
-		sort.Slice(highPriorityOld, func(i, j int) bool {
-			return highPriorityOld[i].Index < highPriorityOld[j].Index
-		})
-		for _, pm := range highPriorityOld {
-			result = append(result, pm.Message)
-		}
+	for _, pm := range keptOld {
+		result = append(result, pm.Message)
 	}
-	
+
 	// 添加最近的消息
 	result = append(result, recentMessages...)
-	
-	removed := len(messages) - len(result) + 1 // +1 for summary
-	
+
 	return result, CompactResult{
-		Kept:      len(result),
-		Removed:   removed,
-		Strategy:  CompactStrategyHybrid,
-		Summary:   fmt.Sprintf("Hybrid compact: kept %d messages including %d recent", len(result), recentCount),
+		Kept:       len(result),
+		Removed:    len(dropped),
+		Strategy:   CompactStrategyHybrid,
+		Summary:    fmt.Sprintf("Hybrid compact: kept %d messages including %d recent", len(result), len(recentMessages)),
+		SummaryErr: summaryErr,
 	}
 }
 
+// scoreMessages 为所有消息评分
+func (c *Compactor) scoreMessages(messages []llm.Message) []PrioritizedMessage {
+	prioritized := make([]PrioritizedMessage, len(messages))
+	for i, msg := range messages {
+		prioritized[i] = PrioritizedMessage{
+			Message:  msg,
+			Priority: c.scorer.ScoreMessage(msg, i, len(messages)),
+			Index:    i,
+		}
+	}
+	return prioritized
+}
+
 // generateSummary 生成消息摘要
 func (c *Compactor) generateSummary(messages []llm.Message) string {
 	userCount := 0
@@ -298,7 +313,7 @@ func (c *Compactor) generateSummary(messages []llm.Message) string {
 		}
 	}
 	
-	parts := []string{"[Context Summary]"}
+	parts := []string{summaryPrefix}
 	parts = append(parts, fmt.Sprintf("Earlier conversation: %d messages", len(messages)))
 	
 	if userCount > 0 {
@@ -320,6 +335,9 @@ type CompactResult struct {
 	Removed  int
 	Strategy CompactStrategy
 	Summary  string
+
+	// SummaryErr 模型摘要失败的原因（此时已退回启发式摘要）
+	SummaryErr error
 }
 
 // String 返回压缩结果的字符串表示
@@ -1,6 +1,7 @@
 package context
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -49,6 +50,12 @@ type Manager struct {
 	tokenizer *Tokenizer
 	compactor *Compactor
 	scorer    *PriorityScorer
+	onCompact func(CompactResult)
+
+	// gen 在消息被删除或替换时递增，用于丢弃过期的压缩结果
+	gen int
+	// compacting 为真时模型摘要正在锁外进行
+	compacting bool
 
 	// 统计
 	stats Stats
@@ -149,15 +156,43 @@ func (m *Manager) GetSystemPrompt() *llm.Message {
 
 // AddMessage adds a message to the context.
 func (m *Manager) AddMessage(msg llm.Message) error {
+	return m.AddMessageContext(context.Background(), msg)
+}
+
+// AddMessageContext adds a message to the context. A compaction it triggers
+// summarizes with the model under ctx, without holding the manager lock.
+func (m *Manager) AddMessageContext(ctx context.Context, msg llm.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.addLocked(ctx, msg, true)
+}
+
+// Restore replaces the history with msgs, e.g. when resuming a session.
+// Compactions use the heuristic summary, so replaying a long session does
+// not call the model.
+func (m *Manager) Restore(msgs []llm.Message) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.clearLocked()
+	for _, msg := range msgs {
+		if err := m.addLocked(context.Background(), msg, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addLocked adds a message (must hold lock). With useModel, a compaction
+// releases the lock while the model writes the summary.
+func (m *Manager) addLocked(ctx context.Context, msg llm.Message, useModel bool) error {
 	// 估算新消息的 Token
 	msgTokens := m.tokenizer.EstimateMessage(msg)
 
 	// 检查是否需要压缩
 	if m.shouldCompactLocked(msgTokens) {
-		if err := m.compactLocked(); err != nil {
+		if err := m.compactLocked(ctx, useModel); err != nil {
 			return fmt.Errorf("compact context: %w", err)
 		}
 	}
@@ -222,7 +257,12 @@ func (m *Manager) Clear() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.clearLocked()
+}
+
+func (m *Manager) clearLocked() {
 	m.messages = make([]llm.Message, 0)
+	m.gen++
 	if m.budget != nil {
 		m.budget.SetHistoryUsage(0)
 	}
@@ -231,10 +271,16 @@ func (m *Manager) Clear() {
 
 // Compact manually triggers context compaction.
 func (m *Manager) Compact() error {
+	return m.CompactContext(context.Background())
+}
+
+// CompactContext manually triggers context compaction; the model summary
+// runs under ctx.
+func (m *Manager) CompactContext(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	return m.compactLocked()
+	return m.compactLocked(ctx, true)
 }
 
 // TokenUsage returns current token usage.
@@ -250,6 +296,44 @@ func (m *Manager) EstimateTokens(msgs []llm.Message) int {
 	return m.tokenizer.EstimateMessages(msgs)
 }
 
+// EstimateText estimates the tokens of text with the manager's counter and
+// calibration.
+func (m *Manager) EstimateText(text string) int {
+	return m.tokenizer.EstimateText(text)
+}
+
+// SetTokenCounter sets the tokenizer used to count tokens; nil falls back to
+// the heuristic estimate. Usage and budget are recounted with it.
+func (m *Manager) SetTokenCounter(c TokenCounter) {
+	m.tokenizer.SetCounter(c)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recountLocked()
+}
+
+// Calibrate adjusts token estimates towards the prompt tokens the provider
+// reported for a request built from msgs and tools.
+func (m *Manager) Calibrate(msgs []llm.Message, tools []llm.Tool, promptTokens int) {
+	estimated := m.tokenizer.EstimateMessages(msgs) + m.tokenizer.EstimateTools(tools)
+	m.tokenizer.Calibrate(estimated, promptTokens)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recountLocked()
+}
+
+// recountLocked recounts budget and usage after the tokenizer changed (must hold lock).
+func (m *Manager) recountLocked() {
+	if m.budget != nil {
+		if m.system != nil {
+			m.budget.SetSystemUsage(m.tokenizer.EstimateMessage(*m.system))
+		}
+		m.budget.SetHistoryUsage(m.tokenizer.EstimateMessages(m.messages))
+	}
+	m.recalculateUsage()
+}
+
 // IsWithinBudget checks if adding a message would exceed budget.
 func (m *Manager) IsWithinBudget(msg llm.Message) bool {
 	m.mu.RLock()
@@ -318,6 +402,10 @@ func (m *Manager) GetDetailedStats() map[string]any {
 		},
 		"budget": budgetStats,
 		"stats":  m.stats,
+		"tokenizer": map[string]any{
+			"name":        m.tokenizer.CounterName(),
+			"calibration": m.tokenizer.Calibration(),
+		},
 	}
 
 	return stats
@@ -335,28 +423,61 @@ func (m *Manager) shouldCompactLocked(additionalTokens int) bool {
 	return float64(estimatedTokens) > float64(m.config.MaxTokens)*m.config.CompactionThreshold
 }
 
-// compactLocked compacts the context (must hold lock).
-func (m *Manager) compactLocked() error {
+// compactLocked compacts the context (must hold lock). With useModel the
+// summary is written by the model, and the lock is released meanwhile:
+// messages added in the meantime are kept after the compacted ones, and the
+// result is dropped if the history was cleared or cut instead.
+func (m *Manager) compactLocked(ctx context.Context, useModel bool) error {
 	if len(m.messages) <= m.config.MaxHistoryRounds {
 		return nil
 	}
 
 	// 使用智能压缩
 	if m.config.EnableSmartCompact && m.compactor != nil {
-		compacted, result := m.compactor.Compact(m.messages, m.system)
-		m.messages = compacted
+		if m.compacting {
+			// 另一次压缩正在进行
+			return nil
+		}
+		base := append([]llm.Message(nil), m.messages...)
+		gen := m.gen
+		compactor := *m.compactor
+
+		var compacted []llm.Message
+		var result CompactResult
+		if useModel {
+			var system *llm.Message
+			if m.system != nil {
+				msg := *m.system
+				system = &msg
+			}
+			m.compacting = true
+			m.mu.Unlock()
+			compacted, result = compactor.CompactContext(ctx, base, system)
+			m.mu.Lock()
+			m.compacting = false
+			if gen != m.gen {
+				return nil
+			}
+		} else {
+			compacted, result = compactor.compactHeuristic(base, m.system)
+		}
+
+		m.messages = append(compacted, m.messages[len(base):]...)
+		m.gen++
 		m.stats.CompactCount++
 		now := time.Now()
 		m.stats.LastCompactAt = &now
-		_ = result // 可以在日志中记录
+		if m.onCompact != nil && result.Removed > 0 {
+			m.onCompact(result)
+		}
 	} else {
 		// 简单压缩
 		keepCount := m.config.MaxHistoryRounds * 2
-		if keepCount < len(m.messages) {
-			removed := len(m.messages) - keepCount
+		if removed := alignCut(m.messages, len(m.messages)-keepCount); removed > 0 {
 			summary := fmt.Sprintf("[Earlier conversation: %d messages summarized]", removed)
 			summaryMsg := llm.NewSystemMessage(summary)
 			m.messages = append([]llm.Message{summaryMsg}, m.messages[removed:]...)
+			m.gen++
 			m.stats.CompactCount++
 			now := time.Now()
 			m.stats.LastCompactAt = &now
@@ -381,8 +502,9 @@ func (m *Manager) emergencyCompactLocked() error {
 	}
 
 	if len(m.messages) > keepCount {
-		removed := len(m.messages) - keepCount
+		removed := alignCut(m.messages, len(m.messages)-keepCount)
 		m.messages = m.messages[removed:]
+		m.gen++
 		m.stats.CompactCount++
 		now := time.Now()
 		m.stats.LastCompactAt = &now
@@ -435,6 +557,26 @@ func (m *Manager) SetCompactStrategy(s CompactStrategy) {
 	}
 }
 
+// SetSummaryProvider sets the model that summarizes compacted messages.
+// Without one, or when it fails, a heuristic summary is used.
+func (m *Manager) SetSummaryProvider(p llm.Provider) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.compactor != nil {
+		m.compactor.SetProvider(p)
+	}
+}
+
+// SetCompactHook sets a function called after each smart compaction. It
+// runs with the manager locked and must not call back into it.
+func (m *Manager) SetCompactHook(fn func(CompactResult)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.onCompact = fn
+}
+
 // GetMessagePriority returns the priority of a message.
 func (m *Manager) GetMessagePriority(index int) Priority {
 	m.mu.RLock()
@@ -448,6 +590,7 @@ func (m *Manager) GetMessagePriority(index int) Priority {
 }
 
 // TruncateTo truncates messages to the specified count (keeping the most recent).
+// It keeps extra messages when needed so tool results stay with their call.
 func (m *Manager) TruncateTo(count int) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -459,6 +602,7 @@ func (m *Manager) TruncateTo(count int) {
 		return
 	}
 
-	m.messages = m.messages[len(m.messages)-count:]
+	m.messages = m.messages[alignCut(m.messages, len(m.messages)-count):]
+	m.gen++
 	m.recalculateUsage()
 }
@@ -0,0 +1,120 @@
+package context
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+const (
+	// summaryPrefix 标记压缩产生的摘要消息
+	summaryPrefix = "[Context Summary]"
+
+	defaultSummaryTimeout   = 60 * time.Second
+	defaultSummaryMaxTokens = 1024
+
+	// maxSummaryInput 发送给模型的对话记录的最大字符数
+	maxSummaryInput = 48000
+	// maxSummaryMessage 单条消息的初始截断长度
+	maxSummaryMessage = 4000
+	// minSummaryMessage 单条消息至少保留的长度
+	minSummaryMessage = 200
+)
+
+const summaryPrompt = `The conversation below between a user and a coding agent is being removed from the agent's context to save space. Write a summary the agent can continue from without the original messages.
+
+Use exactly these sections, with short bullet points:
+
+## Goals
+What the user asked for and what the agent is working towards.
+
+## Files touched
+Files read, created or changed, with what was done to each.
+
+## Decisions
+Choices made and the reasons, including approaches that were ruled out.
+
+## Open TODOs
+Work that is unfinished, failing or still to be checked.
+
+Write "- none" for an empty section. Keep exact names of files, functions, commands and errors. An earlier summary may appear at the start of the conversation; merge it in.
+
+Conversation:
+%s`
+
+// summarize 生成被丢弃消息的摘要：有模型时由模型生成，失败时退回启发式摘要
+func (c *Compactor) summarize(ctx context.Context, messages []llm.Message) (string, error) {
+	if c.provider == nil {
+		return c.generateSummary(messages), nil
+	}
+
+	summary, err := c.summarizeWithLLM(ctx, messages)
+	if err != nil {
+		return c.generateSummary(messages), err
+	}
+	return summaryPrefix + "\n" + summary, nil
+}
+
+// summarizeWithLLM 调用模型生成结构化摘要
+func (c *Compactor) summarizeWithLLM(ctx context.Context, messages []llm.Message) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.summaryTimeout)
+	defer cancel()
+
+	resp, err := c.provider.Complete(ctx, &llm.CompletionRequest{
+		Messages:    []llm.Message{llm.NewUserMessage(fmt.Sprintf(summaryPrompt, renderTranscript(messages)))},
+		Temperature: 0,
+		MaxTokens:   defaultSummaryMaxTokens,
+	})
+	if err != nil {
+		return "", fmt.Errorf("summarize context: %w", err)
+	}
+
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return "", fmt.Errorf("summarize context: empty summary")
+	}
+	return summary, nil
+}
+
+// renderTranscript 将消息渲染为文本，超长时逐步缩短每条消息
+func renderTranscript(messages []llm.Message) string {
+	for limit := maxSummaryMessage; ; limit /= 2 {
+		text := renderMessages(messages, limit)
+		if len(text) <= maxSummaryInput || limit <= minSummaryMessage {
+			return text
+		}
+	}
+}
+
+func renderMessages(messages []llm.Message, limit int) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		role := strings.ToUpper(msg.Role)
+		if msg.Role == "tool" {
+			role = "TOOL RESULT"
+		}
+		if content := strings.TrimSpace(msg.Content); content != "" {
+			fmt.Fprintf(&sb, "%s: %s\n", role, clip(content, limit))
+		}
+		for _, call := range msg.ToolCalls {
+			fmt.Fprintf(&sb, "%s: calls %s(%s)\n", role, call.Function.Name, clip(string(call.Function.Arguments), limit))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// clip 截断过长的文本，保留开头
+func clip(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	r := []rune(s)
+	if len(r) <= limit {
+		return s
+	}
+	return string(r[:limit]) + fmt.Sprintf(" ... [%d characters omitted]", len(r)-limit)
+}
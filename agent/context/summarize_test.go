@@ -0,0 +1,217 @@
+package context
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+// summaryProvider 返回固定摘要或错误的模型
+type summaryProvider struct {
+	summary string
+	err     error
+	prompt  string
+}
+
+func (p *summaryProvider) Name() string { return "summary" }
+
+func (p *summaryProvider) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	if len(req.Messages) > 0 {
+		p.prompt = req.Messages[0].Content
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &llm.CompletionResponse{Content: p.summary}, nil
+}
+
+func (p *summaryProvider) CompleteStream(ctx context.Context, req *llm.CompletionRequest) (llm.StreamIterator, error) {
+	return nil, errors.New("not supported")
+}
+
+func (p *summaryProvider) SupportsTools() bool { return false }
+
+func (p *summaryProvider) AvailableModels() []llm.ModelInfo { return nil }
+
+func summarizeMessages(n int) []llm.Message {
+	var messages []llm.Message
+	for i := 0; i < n; i++ {
+		messages = append(messages, llm.NewUserMessage("edit main.go"), llm.NewAssistantMessage("done"))
+	}
+	return messages
+}
+
+func TestCompactSummarizeWithProvider(t *testing.T) {
+	provider := &summaryProvider{summary: "## Goals\n- edit main.go"}
+	compactor := NewCompactor(CompactorConfig{
+		Strategy:        CompactStrategySummarize,
+		MaxKeepMessages: 4,
+		Provider:        provider,
+	})
+
+	result, info := compactor.Compact(summarizeMessages(6), nil)
+	if info.SummaryErr != nil {
+		t.Fatalf("unexpected summary error: %v", info.SummaryErr)
+	}
+	if len(result) != 5 {
+		t.Fatalf("expected summary plus 4 kept messages, got %d", len(result))
+	}
+	if !strings.HasPrefix(result[0].Content, summaryPrefix) || !strings.Contains(result[0].Content, "edit main.go") {
+		t.Errorf("unexpected summary message: %q", result[0].Content)
+	}
+	if !strings.Contains(provider.prompt, "USER: edit main.go") {
+		t.Errorf("prompt does not contain the transcript: %q", provider.prompt)
+	}
+}
+
+func TestCompactSummarizeFallsBack(t *testing.T) {
+	compactor := NewCompactor(CompactorConfig{
+		Strategy:        CompactStrategySummarize,
+		MaxKeepMessages: 4,
+		Provider:        &summaryProvider{err: errors.New("rate limited")},
+	})
+
+	result, info := compactor.Compact(summarizeMessages(6), nil)
+	if info.SummaryErr == nil {
+		t.Fatal("expected summary error")
+	}
+	if !strings.Contains(result[0].Content, "Earlier conversation: 8 messages") {
+		t.Errorf("expected heuristic summary, got %q", result[0].Content)
+	}
+}
+
+// blockingProvider 在 release 关闭或 ctx 结束前阻塞
+type blockingProvider struct {
+	summaryProvider
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProvider) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	close(p.started)
+	select {
+	case <-p.release:
+		return &llm.CompletionResponse{Content: "## Goals\n- edit main.go"}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func newSummarizingManager(provider llm.Provider) *Manager {
+	cfg := DefaultManagerConfig()
+	cfg.MaxHistoryRounds = 2
+	cfg.CompactStrategy = CompactStrategySummarize
+	mgr := NewManager(cfg)
+	mgr.SetSummaryProvider(provider)
+	return mgr
+}
+
+func TestCompactSummaryRunsOutsideLock(t *testing.T) {
+	provider := &blockingProvider{started: make(chan struct{}), release: make(chan struct{})}
+	mgr := newSummarizingManager(provider)
+	if err := mgr.Restore(summarizeMessages(5)); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() { done <- mgr.CompactContext(context.Background()) }()
+	<-provider.started
+
+	added := make(chan error)
+	go func() { added <- mgr.AddMessage(llm.NewUserMessage("added while summarizing")) }()
+	select {
+	case err := <-added:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("AddMessage blocked while the model was summarizing")
+	}
+
+	close(provider.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	messages := mgr.GetNonSystemMessages()
+	if !strings.Contains(messages[0].Content, "edit main.go") {
+		t.Errorf("first message is not the model summary: %q", messages[0].Content)
+	}
+	if last := messages[len(messages)-1].Content; last != "added while summarizing" {
+		t.Errorf("message added during the summary was lost; last is %q", last)
+	}
+}
+
+func TestCompactSummaryCancelled(t *testing.T) {
+	provider := &blockingProvider{started: make(chan struct{}), release: make(chan struct{})}
+	mgr := newSummarizingManager(provider)
+	if err := mgr.Restore(summarizeMessages(5)); err != nil {
+		t.Fatal(err)
+	}
+	var result CompactResult
+	mgr.SetCompactHook(func(r CompactResult) { result = r })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- mgr.CompactContext(ctx) }()
+	<-provider.started
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cancelling ctx did not stop the summary")
+	}
+	if !errors.Is(result.SummaryErr, context.Canceled) {
+		t.Errorf("SummaryErr = %v, want context.Canceled", result.SummaryErr)
+	}
+	if messages := mgr.GetNonSystemMessages(); !strings.Contains(messages[0].Content, "Earlier conversation") {
+		t.Errorf("expected heuristic summary, got %q", messages[0].Content)
+	}
+}
+
+func TestRestoreDoesNotCallModel(t *testing.T) {
+	provider := &summaryProvider{summary: "## Goals\n- edit main.go"}
+	cfg := DefaultManagerConfig()
+	cfg.MaxTokens = 200
+	cfg.MaxHistoryRounds = 2
+	cfg.CompactStrategy = CompactStrategySummarize
+	mgr := NewManager(cfg)
+	mgr.SetSummaryProvider(provider)
+
+	if err := mgr.Restore(summarizeMessages(30)); err != nil {
+		t.Fatal(err)
+	}
+	if provider.prompt != "" {
+		t.Error("Restore called the summary model")
+	}
+	if mgr.stats.CompactCount == 0 {
+		t.Error("Restore did not compact a long history")
+	}
+}
+
+func TestCompactDroppedAfterClear(t *testing.T) {
+	provider := &blockingProvider{started: make(chan struct{}), release: make(chan struct{})}
+	mgr := newSummarizingManager(provider)
+	if err := mgr.Restore(summarizeMessages(5)); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() { done <- mgr.CompactContext(context.Background()) }()
+	<-provider.started
+	mgr.Clear()
+	close(provider.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := len(mgr.GetNonSystemMessages()); n != 0 {
+		t.Errorf("compaction result replaced the cleared history: %d messages", n)
+	}
+}
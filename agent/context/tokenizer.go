@@ -1,20 +1,45 @@
 package context
 
 import (
+	"encoding/json"
 	"regexp"
 	"strings"
+	"sync"
 	"unicode/utf8"
 
 	"github.com/vigo999/ms-cli/integrations/llm"
 )
 
+// TokenCounter 精确计算文本 Token 数的分词器，如 bpe.Encoding
+type TokenCounter interface {
+	Name() string
+	Count(text string) int
+}
+
+const (
+	// maxCountCache 缓存的文本 Token 数上限，超过后清空
+	maxCountCache = 4096
+	// calibrationWeight 每次校准时新样本的权重
+	calibrationWeight = 0.3
+	// 校准系数的范围，避免个别异常样本带偏估算
+	minCalibration = 0.5
+	maxCalibration = 2.0
+)
+
 // Tokenizer 提供 Token 估算功能
-// 注意：这是基于启发式的估算，不是精确的 tiktoken 计算
+// 设置 TokenCounter 时按分词器精确计数，否则基于启发式估算；
+// 两种方式都可按模型实际报告的 Token 数校准
 type Tokenizer struct {
 	// 配置参数
 	charsPerToken    float64 // 平均每个 Token 的字符数
 	wordsPerToken    float64 // 平均每个 Token 的单词数
 	codeCharsPerToken float64 // 代码的平均每个 Token 字符数
+
+	mu      sync.Mutex
+	counter TokenCounter
+	cache   map[string]int
+	scale   float64 // 校准系数
+	samples int     // 校准样本数
 }
 
 // NewTokenizer 创建新的 Tokenizer
@@ -23,7 +48,103 @@ func NewTokenizer() *Tokenizer {
 		charsPerToken:     4.0,  // 英文文本约 4 字符/Token
 		wordsPerToken:     0.75, // 约 0.75 单词/Token
 		codeCharsPerToken: 3.5,  // 代码通常更密集
+		scale:             1,
+	}
+}
+
+// NewTokenizerWithCounter 创建使用指定分词器计数的 Tokenizer
+func NewTokenizerWithCounter(counter TokenCounter) *Tokenizer {
+	t := NewTokenizer()
+	t.counter = counter
+	return t
+}
+
+// SetCounter 设置分词器，为空时使用启发式估算；同时清除缓存和校准
+func (t *Tokenizer) SetCounter(counter TokenCounter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.counter = counter
+	t.cache = nil
+	t.scale = 1
+	t.samples = 0
+}
+
+// CounterName 返回分词器名称，启发式估算时为 "heuristic"
+func (t *Tokenizer) CounterName() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.counter == nil {
+		return "heuristic"
 	}
+	return t.counter.Name()
+}
+
+// Calibrate 根据模型报告的实际 Token 数校准估算；estimated 为同一请求的估算值
+func (t *Tokenizer) Calibrate(estimated, actual int) {
+	if estimated <= 0 || actual <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	target := t.scale * float64(actual) / float64(estimated)
+	if target < minCalibration {
+		target = minCalibration
+	} else if target > maxCalibration {
+		target = maxCalibration
+	}
+	if t.samples == 0 {
+		t.scale = target
+	} else {
+		t.scale += calibrationWeight * (target - t.scale)
+	}
+	t.samples++
+}
+
+// Calibration 返回当前校准系数
+func (t *Tokenizer) Calibration() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.scale
+}
+
+// count 计算文本的未校准 Token 数；ratio 为启发式估算使用的字符/Token 比例
+func (t *Tokenizer) count(text string, ratio float64) int {
+	t.mu.Lock()
+	counter := t.counter
+	if counter != nil {
+		if n, ok := t.cache[text]; ok {
+			t.mu.Unlock()
+			return n
+		}
+	}
+	t.mu.Unlock()
+
+	if counter == nil {
+		return int(float64(utf8.RuneCountInString(text)) / ratio)
+	}
+
+	n := counter.Count(text)
+	t.mu.Lock()
+	if t.counter == counter {
+		if t.cache == nil || len(t.cache) >= maxCountCache {
+			t.cache = make(map[string]int)
+		}
+		t.cache[text] = n
+	}
+	t.mu.Unlock()
+	return n
+}
+
+// scaled 按校准系数调整 Token 数
+func (t *Tokenizer) scaled(n int) int {
+	t.mu.Lock()
+	scale := t.scale
+	t.mu.Unlock()
+	if scale == 0 {
+		scale = 1
+	}
+	return int(float64(n)*scale + 0.5)
 }
 
 // SetCharsPerToken 设置字符/Token 比例
@@ -37,9 +158,7 @@ func (t *Tokenizer) EstimateText(text string) int {
 		return 0
 	}
 
-	// 基于字符数的估算
-	charCount := utf8.RuneCountInString(text)
-	return int(float64(charCount) / t.charsPerToken)
+	return t.scaled(t.count(text, t.charsPerToken))
 }
 
 // EstimateCode 估算代码的 Token 数
@@ -49,8 +168,7 @@ func (t *Tokenizer) EstimateCode(code string) int {
 	}
 
 	// 代码通常更密集，使用更小的比例
-	charCount := utf8.RuneCountInString(code)
-	return int(float64(charCount) / t.codeCharsPerToken)
+	return t.scaled(t.count(code, t.codeCharsPerToken))
 }
 
 // EstimateMessage 估算单个消息的 Token 数
@@ -92,9 +210,9 @@ func (t *Tokenizer) EstimateMessages(msgs []llm.Message) int {
 func (t *Tokenizer) estimateToolCall(tc llm.ToolCall) int {
 	total := 0
 	// Tool call ID
-	total += len(tc.ID) / 4
+	total += t.EstimateText(tc.ID)
 	// Function name
-	total += len(tc.Function.Name) / 4
+	total += t.EstimateText(tc.Function.Name)
 	// Arguments
 	total += t.EstimateText(string(tc.Function.Arguments))
 	// 格式开销
@@ -102,6 +220,18 @@ func (t *Tokenizer) estimateToolCall(tc llm.ToolCall) int {
 	return total
 }
 
+// EstimateTools 估算工具定义的 Token 数
+func (t *Tokenizer) EstimateTools(tools []llm.Tool) int {
+	if len(tools) == 0 {
+		return 0
+	}
+	data, err := json.Marshal(tools)
+	if err != nil {
+		return 0
+	}
+	return t.EstimateCode(string(data))
+}
+
 // EstimateWithDetails 返回详细的估算信息
 type EstimateDetails struct {
 	TotalTokens    int
@@ -0,0 +1,58 @@
+package context
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+// wordCounter 按空白分词计数
+type wordCounter struct{}
+
+func (wordCounter) Name() string          { return "words" }
+func (wordCounter) Count(text string) int { return len(strings.Fields(text)) }
+
+func TestTokenizerUsesCounter(t *testing.T) {
+	tok := NewTokenizerWithCounter(wordCounter{})
+	if got := tok.EstimateText("one two three"); got != 3 {
+		t.Errorf("expected 3 tokens, got %d", got)
+	}
+	if tok.CounterName() != "words" {
+		t.Errorf("unexpected counter name %q", tok.CounterName())
+	}
+
+	tok.SetCounter(nil)
+	if tok.CounterName() != "heuristic" {
+		t.Errorf("expected heuristic counter, got %q", tok.CounterName())
+	}
+}
+
+func TestTokenizerCalibrate(t *testing.T) {
+	tok := NewTokenizerWithCounter(wordCounter{})
+	text := strings.Repeat("word ", 100)
+
+	tok.Calibrate(tok.EstimateText(text), 150)
+	if got := tok.EstimateText(text); got != 150 {
+		t.Errorf("expected calibrated estimate 150, got %d", got)
+	}
+
+	// 异常样本被限制在校准范围内
+	tok.Calibrate(tok.EstimateText(text), 100000)
+	if scale := tok.Calibration(); scale > maxCalibration {
+		t.Errorf("calibration %f exceeds limit", scale)
+	}
+}
+
+func TestManagerCalibrate(t *testing.T) {
+	mgr := NewManager(DefaultManagerConfig())
+	mgr.SetTokenCounter(wordCounter{})
+	mgr.AddMessage(llm.NewUserMessage(strings.Repeat("word ", 200)))
+
+	before := mgr.TokenUsage().Current
+	mgr.Calibrate(mgr.GetMessages(), nil, before*3/2)
+	after := mgr.TokenUsage().Current
+	if after <= before {
+		t.Errorf("expected usage to grow after calibration, got %d -> %d", before, after)
+	}
+}
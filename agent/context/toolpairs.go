@@ -0,0 +1,161 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+// missingToolResult 补齐缺失工具结果时使用的内容
+const missingToolResult = "[Tool result removed from context]"
+
+// messageUnit 压缩时不可拆分的一组连续消息 messages[start:end]
+type messageUnit struct {
+	start    int
+	end      int
+	priority Priority
+}
+
+// groupToolUnits 将带工具调用的助手消息与其后的工具结果分为一组，其余消息各自成组
+func groupToolUnits(messages []llm.Message) []messageUnit {
+	var units []messageUnit
+	for i := 0; i < len(messages); {
+		end := i + 1
+		if ids := toolCallIDs(messages[i]); len(ids) > 0 {
+			for end < len(messages) && messages[end].Role == "tool" && ids[messages[end].ToolCallID] {
+				end++
+			}
+		}
+		units = append(units, messageUnit{start: i, end: end})
+		i = end
+	}
+	return units
+}
+
+// selectUnits 按组优先级选择不超过 keep 条的消息，返回按原始顺序排列的保留和丢弃消息；
+// 组的优先级取组内最高优先级
+func selectUnits(scored []PrioritizedMessage, keep int) (kept, dropped []PrioritizedMessage) {
+	messages := make([]llm.Message, len(scored))
+	for i, pm := range scored {
+		messages[i] = pm.Message
+	}
+	units := groupToolUnits(messages)
+	for i := range units {
+		for _, pm := range scored[units[i].start:units[i].end] {
+			if pm.Priority > units[i].priority {
+				units[i].priority = pm.Priority
+			}
+		}
+	}
+
+	// 同优先级时优先保留较新的消息
+	order := make([]int, len(units))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ua, ub := units[order[a]], units[order[b]]
+		if ua.priority != ub.priority {
+			return ua.priority > ub.priority
+		}
+		return ua.start > ub.start
+	})
+
+	keepUnit := make([]bool, len(units))
+	count := 0
+	for _, u := range order {
+		size := units[u].end - units[u].start
+		if count+size <= keep {
+			keepUnit[u] = true
+			count += size
+		}
+	}
+
+	for i, u := range units {
+		if keepUnit[i] {
+			kept = append(kept, scored[u.start:u.end]...)
+		} else {
+			dropped = append(dropped, scored[u.start:u.end]...)
+		}
+	}
+	return kept, dropped
+}
+
+// alignCut 调整截断位置，使保留的 messages[cut:] 不以孤立的工具结果开头
+func alignCut(messages []llm.Message, cut int) int {
+	for cut > 0 && cut < len(messages) && messages[cut].Role == "tool" {
+		cut--
+	}
+	return cut
+}
+
+// ValidateToolPairs 检查每个工具调用都紧跟着对应的结果，且每个工具结果都有对应的调用
+func ValidateToolPairs(messages []llm.Message) error {
+	_, fixes := RepairToolPairs(messages)
+	if len(fixes) > 0 {
+		return fmt.Errorf("invalid tool call history: %s", fixes[0])
+	}
+	return nil
+}
+
+// RepairToolPairs 修复工具调用历史：删除没有对应调用的工具结果，
+// 为没有结果的调用补上占位结果；返回修复后的消息和修复说明
+func RepairToolPairs(messages []llm.Message) ([]llm.Message, []string) {
+	var (
+		fixes  []string
+		result = make([]llm.Message, 0, len(messages))
+	)
+	for i := 0; i < len(messages); {
+		msg := messages[i]
+		if msg.Role == "tool" {
+			fixes = append(fixes, fmt.Sprintf("tool result %q has no matching tool call", msg.ToolCallID))
+			i++
+			continue
+		}
+		result = append(result, msg)
+		i++
+
+		ids := toolCallIDs(msg)
+		if len(ids) == 0 {
+			continue
+		}
+		answered := make(map[string]bool, len(ids))
+		for ; i < len(messages) && messages[i].Role == "tool"; i++ {
+			id := messages[i].ToolCallID
+			switch {
+			case !ids[id]:
+				fixes = append(fixes, fmt.Sprintf("tool result %q has no matching tool call", id))
+			case answered[id]:
+				fixes = append(fixes, fmt.Sprintf("tool call %q has more than one result", id))
+			default:
+				answered[id] = true
+				result = append(result, messages[i])
+			}
+		}
+		for _, call := range msg.ToolCalls {
+			if !answered[call.ID] {
+				fixes = append(fixes, fmt.Sprintf("tool call %q has no result", call.ID))
+				result = append(result, llm.NewToolMessage(call.ID, missingToolResult))
+				answered[call.ID] = true
+			}
+		}
+	}
+
+	if len(fixes) == 0 {
+		return messages, nil
+	}
+	return result, fixes
+}
+
+// toolCallIDs 返回助手消息中的工具调用 ID
+func toolCallIDs(msg llm.Message) map[string]bool {
+	if msg.Role != "assistant" || len(msg.ToolCalls) == 0 {
+		return nil
+	}
+	ids := make(map[string]bool, len(msg.ToolCalls))
+	for _, call := range msg.ToolCalls {
+		ids[call.ID] = true
+	}
+	return ids
+}
@@ -0,0 +1,87 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+func toolCallMessage(ids ...string) llm.Message {
+	msg := llm.NewAssistantMessage("")
+	for _, id := range ids {
+		msg.ToolCalls = append(msg.ToolCalls, llm.ToolCall{ID: id, Type: "function"})
+	}
+	return msg
+}
+
+func TestRepairToolPairs(t *testing.T) {
+	messages := []llm.Message{
+		llm.NewToolMessage("gone", "orphaned result"),
+		llm.NewUserMessage("run the tests"),
+		toolCallMessage("a", "b"),
+		llm.NewToolMessage("a", "ok"),
+		llm.NewUserMessage("next"),
+	}
+	if err := ValidateToolPairs(messages); err == nil {
+		t.Fatal("expected invalid history")
+	}
+
+	repaired, fixes := RepairToolPairs(messages)
+	if len(fixes) != 2 {
+		t.Fatalf("expected 2 fixes, got %v", fixes)
+	}
+	if err := ValidateToolPairs(repaired); err != nil {
+		t.Fatalf("repaired history is invalid: %v", err)
+	}
+	if len(repaired) != 5 || repaired[0].Role != "user" {
+		t.Fatalf("unexpected repaired history: %+v", repaired)
+	}
+	if repaired[3].ToolCallID != "b" || repaired[3].Content != missingToolResult {
+		t.Errorf("expected placeholder result for b, got %+v", repaired[3])
+	}
+}
+
+func TestCompactKeepsToolPairs(t *testing.T) {
+	var messages []llm.Message
+	for i := 0; i < 6; i++ {
+		messages = append(messages,
+			llm.NewUserMessage("check the error"),
+			toolCallMessage("x", "y"),
+			llm.NewToolMessage("x", "result"),
+			llm.NewToolMessage("y", "failed"),
+		)
+	}
+
+	for _, strategy := range []CompactStrategy{
+		CompactStrategySimple,
+		CompactStrategySummarize,
+		CompactStrategyPriority,
+		CompactStrategyHybrid,
+	} {
+		for keep := 3; keep <= 12; keep++ {
+			compactor := NewCompactor(CompactorConfig{Strategy: strategy, MaxKeepMessages: keep})
+			result, _ := compactor.Compact(messages, nil)
+			if err := ValidateToolPairs(result); err != nil {
+				t.Errorf("strategy %d keep %d: %v", strategy, keep, err)
+			}
+		}
+	}
+}
+
+func TestTruncateToKeepsToolPairs(t *testing.T) {
+	mgr := NewManager(DefaultManagerConfig())
+	mgr.AddMessage(llm.NewUserMessage("run"))
+	mgr.AddMessage(toolCallMessage("a", "b"))
+	mgr.AddMessage(llm.NewToolMessage("a", "ok"))
+	mgr.AddMessage(llm.NewToolMessage("b", "ok"))
+
+	mgr.TruncateTo(1)
+
+	messages := mgr.GetNonSystemMessages()
+	if len(messages) != 3 {
+		t.Fatalf("expected the tool call and its results, got %d messages", len(messages))
+	}
+	if err := ValidateToolPairs(messages); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,70 @@
+// Package cost converts LLM token usage into dollars and enforces spend
+// limits.
+package cost
+
+import (
+	"strings"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+// Pricing is the price of one model in USD per million tokens.
+type Pricing struct {
+	InputPerMTok  float64
+	OutputPerMTok float64
+}
+
+// Cost returns the dollar cost of usage at this price.
+func (p Pricing) Cost(usage llm.Usage) float64 {
+	return (float64(usage.PromptTokens)*p.InputPerMTok +
+		float64(usage.CompletionTokens)*p.OutputPerMTok) / 1_000_000
+}
+
+// DefaultPricing returns list prices for common hosted models. Entries match
+// by prefix, so "claude-sonnet-4" also prices "claude-sonnet-4-20250514".
+func DefaultPricing() map[string]Pricing {
+	return map[string]Pricing{
+		"gpt-4o":            {InputPerMTok: 2.50, OutputPerMTok: 10.00},
+		"gpt-4o-mini":       {InputPerMTok: 0.15, OutputPerMTok: 0.60},
+		"gpt-4-turbo":       {InputPerMTok: 10.00, OutputPerMTok: 30.00},
+		"gpt-4":             {InputPerMTok: 30.00, OutputPerMTok: 60.00},
+		"gpt-3.5-turbo":     {InputPerMTok: 0.50, OutputPerMTok: 1.50},
+		"gpt-4.1":           {InputPerMTok: 2.00, OutputPerMTok: 8.00},
+		"gpt-4.1-mini":      {InputPerMTok: 0.40, OutputPerMTok: 1.60},
+		"claude-opus-4":     {InputPerMTok: 15.00, OutputPerMTok: 75.00},
+		"claude-sonnet-4":   {InputPerMTok: 3.00, OutputPerMTok: 15.00},
+		"claude-3-7-sonnet": {InputPerMTok: 3.00, OutputPerMTok: 15.00},
+		"claude-3-5-sonnet": {InputPerMTok: 3.00, OutputPerMTok: 15.00},
+		"claude-3-5-haiku":  {InputPerMTok: 0.80, OutputPerMTok: 4.00},
+	}
+}
+
+// PricingTable looks up model prices.
+type PricingTable map[string]Pricing
+
+// Lookup returns the price of model. Exact names win; otherwise the longest
+// table entry that prefixes the model name is used. A "vendor/" prefix, as
+// used by routers, is ignored. Unknown models are reported as not found.
+func (t PricingTable) Lookup(model string) (Pricing, bool) {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	if model == "" {
+		return Pricing{}, false
+	}
+	if p, ok := t[model]; ok {
+		return p, true
+	}
+
+	best := ""
+	for name := range t {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Pricing{}, false
+	}
+	return t[best], true
+}
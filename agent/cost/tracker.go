@@ -0,0 +1,241 @@
+package cost
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+// keepDays is how many days of spend history the store retains.
+const keepDays = 31
+
+// ErrBudgetExceeded is returned when a request would exceed a spend limit.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Config configures a Tracker.
+type Config struct {
+	// MaxCostUSD caps the spend of one session; 0 disables the cap.
+	MaxCostUSD float64
+	// DailyLimitUSD caps the spend of one calendar day; 0 disables the cap.
+	DailyLimitUSD float64
+	// Pricing overrides and extends DefaultPricing.
+	Pricing map[string]Pricing
+	// Model prices responses that do not name their model.
+	Model string
+	// StorePath is the YAML file daily spend is persisted to. Empty keeps
+	// daily spend in memory only.
+	StorePath string
+}
+
+// DaySpend is the spend recorded for one day.
+type DaySpend struct {
+	CostUSD          float64 `yaml:"cost_usd"`
+	PromptTokens     int     `yaml:"prompt_tokens"`
+	CompletionTokens int     `yaml:"completion_tokens"`
+}
+
+type spendFile struct {
+	Days map[string]DaySpend `yaml:"days"`
+}
+
+// Tracker accumulates the cost of LLM calls for a session and per day.
+type Tracker struct {
+	mu      sync.Mutex
+	cfg     Config
+	pricing PricingTable
+	session float64
+	days    map[string]DaySpend
+	now     func() time.Time
+}
+
+// NewTracker creates a tracker and loads persisted daily spend.
+func NewTracker(cfg Config) (*Tracker, error) {
+	pricing := PricingTable(DefaultPricing())
+	for name, p := range cfg.Pricing {
+		pricing[name] = p
+	}
+
+	t := &Tracker{
+		cfg:     cfg,
+		pricing: pricing,
+		days:    make(map[string]DaySpend),
+		now:     time.Now,
+	}
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// SetModel changes the model used for responses that do not name one.
+func (t *Tracker) SetModel(model string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cfg.Model = model
+}
+
+// Cost returns the dollar cost of usage on model. Models missing from the
+// pricing table, such as local ones, cost nothing; see Unpriced.
+func (t *Tracker) Cost(model string, usage llm.Usage) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cost(model, usage)
+}
+
+func (t *Tracker) cost(model string, usage llm.Usage) float64 {
+	if model == "" {
+		model = t.cfg.Model
+	}
+	p, ok := t.pricing.Lookup(model)
+	if !ok {
+		return 0
+	}
+	return p.Cost(usage)
+}
+
+// Unpriced returns the configured model when a spend limit is set but the
+// model has no price, so its calls count as free and the limits never stop
+// them.
+func (t *Tracker) Unpriced() (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cfg.MaxCostUSD <= 0 && t.cfg.DailyLimitUSD <= 0 || t.cfg.Model == "" {
+		return "", false
+	}
+	if _, ok := t.pricing.Lookup(t.cfg.Model); ok {
+		return "", false
+	}
+	return t.cfg.Model, true
+}
+
+// Check reports whether a request sending promptTokens can be made without
+// exceeding the session or daily limit. The returned error wraps
+// ErrBudgetExceeded.
+func (t *Tracker) Check(model string, promptTokens int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	next := t.cost(model, llm.Usage{PromptTokens: promptTokens})
+	if limit := t.cfg.MaxCostUSD; limit > 0 && t.session+next > limit {
+		return fmt.Errorf("%w: session spend $%.4f plus next request ~$%.4f exceeds max_cost_usd $%.2f",
+			ErrBudgetExceeded, t.session, next, limit)
+	}
+	if limit := t.cfg.DailyLimitUSD; limit > 0 {
+		today := t.days[t.today()].CostUSD
+		if today+next > limit {
+			return fmt.Errorf("%w: today's spend $%.4f plus next request ~$%.4f exceeds daily_limit $%.2f",
+				ErrBudgetExceeded, today, next, limit)
+		}
+	}
+	return nil
+}
+
+// Record adds the cost of a completed call to the session and to today's
+// spend, persisting the latter. It returns the cost of the call.
+func (t *Tracker) Record(model string, usage llm.Usage) (float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := t.cost(model, usage)
+	t.session += c
+
+	// Reload first so concurrent sessions in the same project add up. When
+	// the file cannot be read, the spend is still counted in memory so the
+	// daily limit holds, and the file is left alone.
+	loadErr := t.loadLocked()
+	day := t.today()
+	spend := t.days[day]
+	spend.CostUSD += c
+	spend.PromptTokens += usage.PromptTokens
+	spend.CompletionTokens += usage.CompletionTokens
+	t.days[day] = spend
+
+	if loadErr != nil {
+		return c, loadErr
+	}
+	return c, t.saveLocked()
+}
+
+// SessionCost returns the spend of this session.
+func (t *Tracker) SessionCost() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.session
+}
+
+// DailyCost returns today's spend across sessions.
+func (t *Tracker) DailyCost() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.days[t.today()].CostUSD
+}
+
+func (t *Tracker) today() string {
+	return t.now().Format("2006-01-02")
+}
+
+func (t *Tracker) load() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.loadLocked()
+}
+
+func (t *Tracker) loadLocked() error {
+	if t.cfg.StorePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(t.cfg.StorePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("read spend file: %w", err)
+	}
+
+	var f spendFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("parse spend file: %w", err)
+	}
+	t.days = f.Days
+	if t.days == nil {
+		t.days = make(map[string]DaySpend)
+	}
+	return nil
+}
+
+func (t *Tracker) saveLocked() error {
+	if t.cfg.StorePath == "" {
+		return nil
+	}
+
+	// Drop the oldest days beyond the retention window.
+	days := make([]string, 0, len(t.days))
+	for day := range t.days {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	for len(days) > keepDays {
+		delete(t.days, days[0])
+		days = days[1:]
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.cfg.StorePath), 0755); err != nil {
+		return fmt.Errorf("create spend directory: %w", err)
+	}
+	data, err := yaml.Marshal(spendFile{Days: t.days})
+	if err != nil {
+		return fmt.Errorf("marshal spend: %w", err)
+	}
+	if err := os.WriteFile(t.cfg.StorePath, data, 0600); err != nil {
+		return fmt.Errorf("write spend file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package cost
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPricingLookup(t *testing.T) {
+	table := PricingTable(DefaultPricing())
+
+	tests := []struct {
+		model string
+		want  float64 // input price
+		found bool
+	}{
+		{"gpt-4o", 2.50, true},
+		{"gpt-4o-mini", 0.15, true},
+		{"gpt-4o-mini-2024-07-18", 0.15, true},
+		{"claude-sonnet-4-20250514", 3.00, true},
+		{"openai/gpt-4o", 2.50, true},
+		{"qwen2.5:7b", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		p, ok := table.Lookup(tt.model)
+		if ok != tt.found || p.InputPerMTok != tt.want {
+			t.Errorf("Lookup(%q) = %v, %v; want input %v, %v", tt.model, p, ok, tt.want, tt.found)
+		}
+	}
+}
+
+func TestTrackerRecordsAndPersistsDailySpend(t *testing.T) {
+	store := filepath.Join(t.TempDir(), ".mscli", "spend.yaml")
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+
+	tracker, err := NewTracker(Config{
+		Pricing:   map[string]Pricing{"house-model": {InputPerMTok: 1, OutputPerMTok: 2}},
+		Model:     "house-model",
+		StorePath: store,
+	})
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+	tracker.now = func() time.Time { return day }
+
+	c, err := tracker.Record("", llm.Usage{PromptTokens: 1_000_000, CompletionTokens: 500_000})
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if !approx(c, 2) || !approx(tracker.SessionCost(), 2) {
+		t.Fatalf("cost = %v, session = %v; want 2", c, tracker.SessionCost())
+	}
+	if c := tracker.Cost("qwen2.5:7b", llm.Usage{PromptTokens: 1_000_000}); c != 0 {
+		t.Fatalf("unpriced models should be free, got %v", c)
+	}
+
+	// A second session in the same project sees today's spend but starts
+	// its own session total.
+	other, err := NewTracker(Config{StorePath: store})
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+	other.now = func() time.Time { return day }
+	if !approx(other.DailyCost(), 2) || other.SessionCost() != 0 {
+		t.Fatalf("daily = %v, session = %v; want 2, 0", other.DailyCost(), other.SessionCost())
+	}
+
+	other.now = func() time.Time { return day.AddDate(0, 0, 1) }
+	if other.DailyCost() != 0 {
+		t.Fatalf("spend should reset on a new day, got %v", other.DailyCost())
+	}
+}
+
+func TestTrackerCheckEnforcesLimits(t *testing.T) {
+	pricing := map[string]Pricing{"house-model": {InputPerMTok: 10, OutputPerMTok: 10}}
+
+	session, err := NewTracker(Config{MaxCostUSD: 1, Pricing: pricing, Model: "house-model"})
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+	if err := session.Check("", 50_000); err != nil {
+		t.Fatalf("request within budget rejected: %v", err)
+	}
+	session.Record("", llm.Usage{PromptTokens: 50_000, CompletionTokens: 40_000})
+	if err := session.Check("", 20_000); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded for session cap, got %v", err)
+	}
+
+	daily, err := NewTracker(Config{
+		DailyLimitUSD: 1,
+		Pricing:       pricing,
+		Model:         "house-model",
+		StorePath:     filepath.Join(t.TempDir(), "spend.yaml"),
+	})
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+	daily.Record("", llm.Usage{PromptTokens: 100_000})
+	if err := daily.Check("", 1); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded for daily limit, got %v", err)
+	}
+}
+
+func TestTrackerCountsSpendWhenFileIsCorrupt(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "spend.yaml")
+	tracker, err := NewTracker(Config{
+		DailyLimitUSD: 1.5,
+		Pricing:       map[string]Pricing{"house-model": {InputPerMTok: 1, OutputPerMTok: 1}},
+		Model:         "house-model",
+		StorePath:     store,
+	})
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+	if _, err := tracker.Record("", llm.Usage{PromptTokens: 1_000_000}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	if err := os.WriteFile(store, []byte("days: [not, a, map"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracker.Record("", llm.Usage{PromptTokens: 1_000_000}); err == nil {
+		t.Fatal("expected an error for the corrupt spend file")
+	}
+	if !approx(tracker.DailyCost(), 2) {
+		t.Errorf("daily = %v, want 2 counted in memory", tracker.DailyCost())
+	}
+	if err := tracker.Check("", 1); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected the daily limit to hold, got %v", err)
+	}
+	if data, _ := os.ReadFile(store); string(data) != "days: [not, a, map" {
+		t.Errorf("the unreadable file should be left alone, got %q", data)
+	}
+}
+
+func TestTrackerReportsUnpricedModelUnderLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		model string
+	}{
+		{name: "unpriced with session limit", cfg: Config{MaxCostUSD: 10, Model: "qwen2.5:7b"}, model: "qwen2.5:7b"},
+		{name: "unpriced with daily limit", cfg: Config{DailyLimitUSD: 5, Model: "o3-mini"}, model: "o3-mini"},
+		{name: "unpriced without limits", cfg: Config{Model: "qwen2.5:7b"}},
+		{name: "priced", cfg: Config{MaxCostUSD: 10, Model: "gpt-4o"}},
+		{name: "priced by override", cfg: Config{MaxCostUSD: 10, Model: "qwen2.5:7b", Pricing: map[string]Pricing{"qwen": {}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, err := NewTracker(tt.cfg)
+			if err != nil {
+				t.Fatalf("NewTracker failed: %v", err)
+			}
+			model, ok := tracker.Unpriced()
+			if ok != (tt.model != "") || model != tt.model {
+				t.Errorf("Unpriced() = %q, %v; want %q", model, ok, tt.model)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package loop
+
+import (
+	"context"
+
+	ctxmanager "github.com/vigo999/ms-cli/agent/context"
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+// summaryProvider is the engine's provider as used for context summaries:
+// calls are checked against the budget and billed to the session.
+type summaryProvider struct {
+	llm.Provider
+	engine  *Engine
+	counter *ctxmanager.Manager // counts the prompt like every other budget check
+}
+
+func (p *summaryProvider) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	if p.engine.cost != nil {
+		if err := p.engine.cost.Check("", p.counter.EstimateTokens(req.Messages)); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := p.Provider.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	p.engine.recordStageCost("compact", resp)
+	return resp, nil
+}
+
+// planProvider is the engine's provider as used by the planner: plan
+// generation and refinement are checked against the budget and billed to the
+// session like any other call.
+type planProvider struct {
+	llm.Provider
+	engine *Engine
+}
+
+func (p *planProvider) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	if p.engine.cost != nil {
+		if err := p.engine.cost.Check("", p.engine.ctxManager.EstimateTokens(req.Messages)); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := p.Provider.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	p.engine.recordStageCost("plan", resp)
+	return resp, nil
+}
+
+// wireCompaction lets the context manager summarize dropped messages with
+// the engine's provider and traces each compaction.
+func (e *Engine) wireCompaction(cm *ctxmanager.Manager) {
+	if e.provider != nil {
+		cm.SetSummaryProvider(&summaryProvider{Provider: e.provider, engine: e, counter: cm})
+	}
+	cm.SetCompactHook(func(r ctxmanager.CompactResult) {
+		payload := map[string]any{
+			"strategy": r.Strategy.String(),
+			"kept":     r.Kept,
+			"removed":  r.Removed,
+		}
+		if r.SummaryErr != nil {
+			// The heuristic summary was used instead.
+			payload["summary_error"] = r.SummaryErr.Error()
+		}
+		e.writeTrace("context_compacted", payload)
+	})
+}
+
+// recordStageCost prices a call made outside the main loop, such as a
+// summary, and adds it to the spend totals.
+func (e *Engine) recordStageCost(stage string, resp *llm.CompletionResponse) {
+	if e.cost == nil {
+		return
+	}
+	c, err := e.cost.Record(resp.Model, resp.Usage)
+	if err != nil {
+		e.writeTrace("cost_error", map[string]any{"stage": stage, "error": err.Error()})
+	}
+	e.writeTrace("llm_cost", map[string]any{
+		"stage":            stage,
+		"model":            resp.Model,
+		"cost_usd":         c,
+		"session_cost_usd": e.cost.SessionCost(),
+	})
+}
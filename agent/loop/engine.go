@@ -3,15 +3,20 @@ package loop
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	ctxmanager "github.com/vigo999/ms-cli/agent/context"
+	"github.com/vigo999/ms-cli/agent/cost"
+	"github.com/vigo999/ms-cli/agent/memory"
 	"github.com/vigo999/ms-cli/agent/plan"
 	"github.com/vigo999/ms-cli/integrations/llm"
 	"github.com/vigo999/ms-cli/permission"
 	"github.com/vigo999/ms-cli/tools"
+	"github.com/vigo999/ms-cli/tools/artifact"
 	"github.com/vigo999/ms-cli/trace"
 )
 
@@ -23,8 +28,24 @@ type EngineConfig struct {
 	TimeoutPerTurn time.Duration
 	SystemPrompt   string
 
+	// MaxConcurrency bounds how many read-only tool calls from one assistant
+	// turn run in parallel. Values below 2 execute every call serially.
+	MaxConcurrency int
+
+	// Stream consumes provider output incrementally via CompleteStream and
+	// emits reply/thinking deltas to the event sink as they arrive.
+	Stream bool
+
 	// Plan Mode 配置
 	ModeConfig plan.ModeConfig
+
+	// MemoryTopK is how many relevant memories are added to the system
+	// prompt per task, and MemoryBudget the most tokens they may use.
+	MemoryTopK   int
+	MemoryBudget int
+	// MemoryExtract makes an extra model call after each completed task to
+	// extract facts and decisions worth saving.
+	MemoryExtract bool
 }
 
 // Engine drives task execution and emits events.
@@ -35,13 +56,26 @@ type Engine struct {
 	ctxManager *ctxmanager.Manager
 	permission permission.PermissionService
 	trace      trace.Writer
+	eventSink  func(Event)
+	msgSink    func(llm.Message)
+	cost       *cost.Tracker
+	memory     *memory.Manager
+	artifacts  *artifact.Store
+	// unpricedWarned is the model last reported as having no price.
+	unpricedWarned string
 
 	// Plan Mode 组件
 	planner      *plan.Planner
 	planExecutor *plan.PlanExecutor
 	modeCallback plan.ModeCallback
+	planApprover plan.PlanApprover
+	planStore    *plan.Store
 }
 
+// errPlanRejected is returned when the user rejects a plan without asking
+// for a revision.
+var errPlanRejected = errors.New("plan rejected")
+
 // NewEngine creates a new engine.
 func NewEngine(cfg EngineConfig, provider llm.Provider, tools *tools.Registry) *Engine {
 	// MaxIterations = 0 means no limit
@@ -50,6 +84,11 @@ func NewEngine(cfg EngineConfig, provider llm.Provider, tools *tools.Registry) *
 	}
 	if cfg.SystemPrompt == "" {
 		cfg.SystemPrompt = defaultSystemPrompt()
+		if tools != nil {
+			if _, ok := tools.Get("remember"); ok {
+				cfg.SystemPrompt += memoryToolsPrompt
+			}
+		}
 	}
 	if cfg.ModeConfig.Mode == 0 && cfg.ModeConfig.PlanConfig.MaxSteps == 0 {
 		cfg.ModeConfig = plan.DefaultModeConfig()
@@ -68,12 +107,13 @@ func NewEngine(cfg EngineConfig, provider llm.Provider, tools *tools.Registry) *
 		ReserveTokens: 4000,
 	})
 	engine.ctxManager.SetSystemPrompt(cfg.SystemPrompt)
+	engine.wireCompaction(engine.ctxManager)
 
 	// Default permission service
 	engine.permission = permission.NewNoOpPermissionService()
 
 	// Initialize Plan Mode components
-	engine.planner = plan.NewPlanner(provider, plan.DefaultPlannerConfig())
+	engine.planner = plan.NewPlanner(&planProvider{Provider: provider, engine: engine}, plan.DefaultPlannerConfig())
 	engine.planExecutor = plan.NewPlanExecutor(&toolRegistryAdapter{tools: tools}, engine.modeCallback, plan.DefaultExecutionConfig())
 	engine.planExecutor.SetPermissionService(engine.permission)
 
@@ -95,6 +135,7 @@ func (e *Engine) SetContextManager(cm *ctxmanager.Manager) {
 			cm.SetSystemPrompt(e.config.SystemPrompt)
 		}
 	}
+	e.wireCompaction(cm)
 	e.ctxManager = cm
 }
 
@@ -112,16 +153,60 @@ func (e *Engine) SetModeCallback(cb plan.ModeCallback) {
 	e.planExecutor.SetCallback(cb)
 }
 
+// SetPlanStore persists plans as they are generated and executed, so they
+// can be resumed after a restart.
+func (e *Engine) SetPlanStore(s *plan.Store) {
+	e.planStore = s
+	e.planExecutor.SetStore(s, func(p *plan.Plan, err error) {
+		e.writeTrace("plan_persist_error", map[string]any{"plan_id": p.ID, "error": err.Error()})
+	})
+}
+
+// SetPlanApprover sets who approves plans in Plan Mode. Without one, plans
+// are approved automatically.
+func (e *Engine) SetPlanApprover(a plan.PlanApprover) {
+	e.planApprover = a
+}
+
 // SetRunMode sets the run mode.
 func (e *Engine) SetRunMode(mode plan.RunMode) {
 	e.config.ModeConfig.Mode = mode
 }
 
+// RunMode returns the current run mode.
+func (e *Engine) RunMode() plan.RunMode {
+	return e.config.ModeConfig.Mode
+}
+
 // SetTraceWriter sets the trace writer.
 func (e *Engine) SetTraceWriter(w trace.Writer) {
 	e.trace = w
 }
 
+// SetCostTracker sets the tracker that prices LLM calls and enforces spend
+// limits. Without one, calls are neither priced nor limited.
+func (e *Engine) SetCostTracker(t *cost.Tracker) {
+	e.cost = t
+}
+
+// SetArtifactStore sets where oversized tool outputs are saved. Without
+// one, outputs enter the context in full.
+func (e *Engine) SetArtifactStore(s *artifact.Store) {
+	e.artifacts = s
+}
+
+// SetEventSink sets a function that receives every event as soon as it is
+// emitted, including streaming deltas that are not part of Run's result.
+func (e *Engine) SetEventSink(sink func(Event)) {
+	e.eventSink = sink
+}
+
+// SetMessageSink sets a function that receives every message added to the
+// conversation context, so the conversation can be persisted.
+func (e *Engine) SetMessageSink(sink func(llm.Message)) {
+	e.msgSink = sink
+}
+
 // Run executes a task and returns events.
 func (e *Engine) Run(task Task) ([]Event, error) {
 	ctx := context.Background()
@@ -142,6 +227,8 @@ func (e *Engine) RunWithContext(ctx context.Context, task Task) ([]Event, error)
 		"timeout_turn": e.config.TimeoutPerTurn.String(),
 	})
 
+	e.injectMemories(task)
+
 	var (
 		events []Event
 		err    error
@@ -166,6 +253,7 @@ func (e *Engine) RunWithContext(ctx context.Context, task Task) ([]Event, error)
 		finishedTrace["error"] = err.Error()
 	}
 	e.writeTrace("run_finished", finishedTrace)
+	e.rememberTask(ctx, task, events, err)
 	return events, err
 }
 
@@ -185,7 +273,7 @@ func (e *Engine) runWithPlanMode(ctx context.Context, task Task) ([]Event, error
 	events := make([]Event, 0)
 	appendEvent := func(ev Event) {
 		events = append(events, ev)
-		e.writeTrace("event", ev)
+		e.emit(ev)
 	}
 
 	appendEvent(NewEvent(EventTaskStarted, fmt.Sprintf("Task (Plan Mode): %s", task.Description)))
@@ -199,26 +287,65 @@ func (e *Engine) runWithPlanMode(ctx context.Context, task Task) ([]Event, error
 	}
 	e.writeTrace("plan_generated", p)
 
-	appendEvent(NewEvent(EventLLMResponse, fmt.Sprintf("Plan created with %d steps", len(p.Steps))))
+	// 2-3. 通知计划创建并等待批准；被拒绝时按反馈修订后重新审批
+	for revision := 0; ; revision++ {
+		appendEvent(NewEvent(EventLLMResponse, fmt.Sprintf("Plan created with %d steps", len(p.Steps))))
+		if err := e.modeCallback.OnPlanCreated(p); err != nil {
+			appendEvent(NewEvent(EventTaskFailed, fmt.Sprintf("Plan callback error: %v", err)))
+			return events, err
+		}
 
-	// 2. 通知计划创建
-	if err := e.modeCallback.OnPlanCreated(p); err != nil {
-		appendEvent(NewEvent(EventTaskFailed, fmt.Sprintf("Plan callback error: %v", err)))
-		return events, err
-	}
+		if !e.config.ModeConfig.PlanConfig.RequireApproval || e.planApprover == nil {
+			p.Approve()
+			break
+		}
 
-	// 3. 等待批准（如果需要）
-	if e.config.ModeConfig.PlanConfig.RequireApproval {
 		p.Status = plan.PlanStatusPendingApproval
-		// 这里应该有一个阻塞调用等待用户批准
-		// 简化实现：直接批准
-		p.Approve()
-		if err := e.modeCallback.OnPlanApproved(p); err != nil {
+		decision, err := e.planApprover.AwaitApproval(ctx, p)
+		if err != nil {
 			appendEvent(NewEvent(EventTaskFailed, fmt.Sprintf("Plan approval error: %v", err)))
 			return events, err
 		}
-	} else {
-		p.Approve()
+		if decision.Approved {
+			p.Approve()
+			// OnPlanApproved is reported by the plan executor when it starts.
+			e.writeTrace("plan_approved", map[string]any{"plan_id": p.ID, "revision": revision})
+			break
+		}
+
+		p.Reject()
+		e.writeTrace("plan_rejected", map[string]any{
+			"plan_id":  p.ID,
+			"revision": revision,
+			"feedback": decision.Feedback,
+		})
+		// 没有反馈或修订次数用尽时取消计划，回调据此提示任务已取消
+		noFeedback := strings.TrimSpace(decision.Feedback) == ""
+		limit := e.config.ModeConfig.PlanConfig.MaxRevisions
+		if noFeedback || limit > 0 && revision+1 >= limit {
+			e.cancelPlan(p)
+		}
+		if err := e.modeCallback.OnPlanRejected(p, decision.Feedback); err != nil {
+			appendEvent(NewEvent(EventTaskFailed, fmt.Sprintf("Plan rejection error: %v", err)))
+			return events, err
+		}
+		if p.Status == plan.PlanStatusCancelled {
+			msg := "Plan rejected; task cancelled."
+			if !noFeedback {
+				msg = fmt.Sprintf("Plan rejected %d times; task cancelled.", revision+1)
+			}
+			appendEvent(NewEvent(EventTaskFailed, msg))
+			return events, errPlanRejected
+		}
+
+		appendEvent(NewEvent(EventAgentThinking, "Revising plan..."))
+		refined, err := e.planner.RefinePlan(ctx, p, decision.Feedback)
+		if err != nil {
+			appendEvent(NewEvent(EventTaskFailed, fmt.Sprintf("Failed to revise plan: %v", err)))
+			return events, fmt.Errorf("refine plan: %w", err)
+		}
+		p = refined
+		e.writeTrace("plan_generated", p)
 	}
 
 	// 4. 执行计划
@@ -242,7 +369,7 @@ func (e *Engine) runWithReviewMode(ctx context.Context, task Task) ([]Event, err
 	events := make([]Event, 0)
 	appendEvent := func(ev Event) {
 		events = append(events, ev)
-		e.writeTrace("event", ev)
+		e.emit(ev)
 	}
 
 	appendEvent(NewEvent(EventTaskStarted, fmt.Sprintf("Task (Review Mode): %s", task.Description)))
@@ -284,6 +411,19 @@ func (e *Engine) runWithReviewMode(ctx context.Context, task Task) ([]Event, err
 	return events, nil
 }
 
+// emit records an event in the trace and forwards it to the event sink.
+func (e *Engine) emit(ev Event) {
+	e.writeTrace("event", ev)
+	e.notify(ev)
+}
+
+// notify forwards an event to the event sink without tracing it.
+func (e *Engine) notify(ev Event) {
+	if e.eventSink != nil {
+		e.eventSink(ev)
+	}
+}
+
 func (e *Engine) writeTrace(eventType string, payload any) {
 	if e.trace == nil {
 		return
@@ -301,9 +441,28 @@ func (e *Engine) getAvailableTools() []string {
 	return names
 }
 
+// cancelPlan 取消计划并保存其状态
+func (e *Engine) cancelPlan(p *plan.Plan) {
+	p.Cancel()
+	if e.planStore != nil {
+		if err := e.planStore.Save(p); err != nil {
+			e.writeTrace("plan_persist_error", map[string]any{"plan_id": p.ID, "error": err.Error()})
+		}
+	}
+}
+
 // GeneratePlan 生成计划（公开方法）
 func (e *Engine) GeneratePlan(ctx context.Context, goal string) (*plan.Plan, error) {
-	return e.planner.GeneratePlan(ctx, goal, e.getAvailableTools())
+	p, err := e.planner.GeneratePlan(ctx, goal, e.getAvailableTools())
+	if err != nil {
+		return nil, err
+	}
+	if e.planStore != nil {
+		if err := e.planStore.Save(p); err != nil {
+			e.writeTrace("plan_persist_error", map[string]any{"plan_id": p.ID, "error": err.Error()})
+		}
+	}
+	return p, nil
 }
 
 // ExecutePlan 执行计划（公开方法）
@@ -311,6 +470,21 @@ func (e *Engine) ExecutePlan(ctx context.Context, p *plan.Plan) error {
 	return e.planExecutor.Execute(ctx, p)
 }
 
+// ResumePlan 继续执行暂停的计划（公开方法）
+func (e *Engine) ResumePlan(ctx context.Context, p *plan.Plan) error {
+	return e.planExecutor.Resume(ctx, p)
+}
+
+// SkipPlanStep 跳过计划中的步骤（公开方法），index 从 0 开始
+func (e *Engine) SkipPlanStep(p *plan.Plan, index int) error {
+	return e.planExecutor.SkipStep(p, index)
+}
+
+// PlanReport 生成计划执行报告（公开方法）
+func (e *Engine) PlanReport(p *plan.Plan) *plan.ExecutionReport {
+	return e.planExecutor.GenerateReport(p)
+}
+
 // executor manages execution of a single task.
 type executor struct {
 	engine     *Engine
@@ -324,7 +498,7 @@ type executor struct {
 // run executes the ReAct loop.
 func (ex *executor) run(ctx context.Context) ([]Event, error) {
 	// Add initial user message
-	ex.engine.ctxManager.AddMessage(llm.NewUserMessage(ex.task.Description))
+	ex.addMessage(ctx, llm.NewUserMessage(ex.task.Description))
 
 	ex.engine.writeTrace("user_task", map[string]any{
 		"task_id":      ex.task.ID,
@@ -346,7 +520,7 @@ func (ex *executor) run(ctx context.Context) ([]Event, error) {
 		}
 
 		// Get messages for LLM
-		messages := ex.engine.ctxManager.GetMessages()
+		messages := ex.requestMessages()
 		tools := ex.engine.tools.ToLLMTools()
 
 		// Call LLM with timeout - use a separate context that doesn't affect tool execution
@@ -355,6 +529,12 @@ func (ex *executor) run(ctx context.Context) ([]Event, error) {
 			timeout = 180 * time.Second // Default 3 minutes
 		}
 
+		// Stop before a request that would push spend past the budget.
+		if err := ex.checkBudget(); err != nil {
+			ex.addEvent(NewEvent(EventTaskFailed, err.Error()))
+			return ex.events, err
+		}
+
 		llmCtx, cancel := context.WithTimeout(ctx, timeout)
 		req := &llm.CompletionRequest{
 			Model:       "", // Use provider default
@@ -366,7 +546,15 @@ func (ex *executor) run(ctx context.Context) ([]Event, error) {
 			"iteration": ex.iterCount,
 			"request":   req,
 		})
-		resp, err := ex.engine.provider.Complete(llmCtx, req)
+		var (
+			resp *llm.CompletionResponse
+			err  error
+		)
+		if ex.engine.config.Stream {
+			resp, err = ex.stream(llmCtx, req)
+		} else {
+			resp, err = ex.engine.provider.Complete(llmCtx, req)
+		}
 		cancel()
 
 		if err != nil {
@@ -392,6 +580,11 @@ func (ex *executor) run(ctx context.Context) ([]Event, error) {
 		ex.totalUsage.PromptTokens += resp.Usage.PromptTokens
 		ex.totalUsage.CompletionTokens += resp.Usage.CompletionTokens
 		ex.totalUsage.TotalTokens += resp.Usage.TotalTokens
+		ex.recordCost(resp)
+		if resp.Usage.PromptTokens > 0 {
+			ex.engine.ctxManager.Calibrate(messages, tools, resp.Usage.PromptTokens)
+		}
+		ex.addEvent(NewEvent(EventTokenUpdate, ""))
 
 		// Handle response - use original ctx (not the cancelled LLM ctx) for tool execution
 		continueLoop, err := ex.handleResponse(ctx, resp)
@@ -419,19 +612,35 @@ func (ex *executor) run(ctx context.Context) ([]Event, error) {
 func (ex *executor) handleResponse(ctx context.Context, resp *llm.CompletionResponse) (bool, error) {
 	// Add assistant message to context
 	assistantMsg := llm.Message{
-		Role:      "assistant",
-		Content:   resp.Content,
-		ToolCalls: resp.ToolCalls,
+		Role:           "assistant",
+		Content:        resp.Content,
+		ToolCalls:      resp.ToolCalls,
+		Thinking:       resp.Thinking,
+		ThinkingBlocks: resp.ThinkingBlocks,
 	}
-	ex.engine.ctxManager.AddMessage(assistantMsg)
+	ex.addMessage(ctx, assistantMsg)
 
 	// Handle tool calls
 	if len(resp.ToolCalls) > 0 {
-		// Execute tools
-		for _, tc := range resp.ToolCalls {
-			if err := ex.executeToolCall(ctx, tc); err != nil {
+		// Execute tools: runs of read-only calls go concurrently, everything
+		// else keeps its original order.
+		calls := resp.ToolCalls
+		for i := 0; i < len(calls); {
+			j := i
+			for j < len(calls) && isReadOnlyTool(calls[j].Function.Name) {
+				j++
+			}
+			if j-i > 1 && ex.engine.config.MaxConcurrency > 1 {
+				if err := ex.executeToolBatch(ctx, calls[i:j]); err != nil {
+					return false, err
+				}
+				i = j
+				continue
+			}
+			if err := ex.executeToolCall(ctx, calls[i]); err != nil {
 				return false, err
 			}
+			i++
 		}
 		return true, nil // Continue loop
 	}
@@ -444,17 +653,90 @@ func (ex *executor) handleResponse(ctx context.Context, resp *llm.CompletionResp
 	return false, nil // End loop
 }
 
+// readOnlyTools are tools without side effects that may run concurrently.
+var readOnlyTools = map[string]bool{
+	"read": true,
+	"grep": true,
+	"glob": true,
+}
+
+func isReadOnlyTool(name string) bool {
+	return readOnlyTools[name]
+}
+
+// toolOutcome holds the result of a tool call until it is recorded.
+type toolOutcome struct {
+	call    llm.ToolCall
+	content string  // tool result sent back to the model
+	events  []Event // events to add when the outcome is recorded
+}
+
 // executeToolCall executes a single tool call.
 func (ex *executor) executeToolCall(ctx context.Context, tc llm.ToolCall) error {
+	tool, out, err := ex.prepareToolCall(ctx, tc)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		ex.addEvent(NewEvent(EventToolStarted, fmt.Sprintf("Using tool: %s", tc.Function.Name)))
+		out = ex.invokeTool(ctx, tool, tc)
+	}
+	ex.recordToolOutcome(ctx, out)
+	return nil
+}
+
+// executeToolBatch runs read-only tool calls concurrently, bounded by
+// MaxConcurrency. Permission checks run serially beforehand and results are
+// recorded in the original call order.
+func (ex *executor) executeToolBatch(ctx context.Context, calls []llm.ToolCall) error {
+	outcomes := make([]*toolOutcome, len(calls))
+	pending := make([]tools.Tool, len(calls))
+	for i, tc := range calls {
+		tool, out, err := ex.prepareToolCall(ctx, tc)
+		if err != nil {
+			return err
+		}
+		outcomes[i] = out
+		pending[i] = tool
+	}
+
+	sem := make(chan struct{}, ex.engine.config.MaxConcurrency)
+	var wg sync.WaitGroup
+	for i, tc := range calls {
+		if outcomes[i] != nil {
+			continue
+		}
+		ex.addEvent(NewEvent(EventToolStarted, fmt.Sprintf("Using tool: %s", tc.Function.Name)))
+		wg.Add(1)
+		go func(i int, tc llm.ToolCall) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			outcomes[i] = ex.invokeTool(ctx, pending[i], tc)
+		}(i, tc)
+	}
+	wg.Wait()
+
+	for _, out := range outcomes {
+		ex.recordToolOutcome(ctx, out)
+	}
+	return nil
+}
+
+// prepareToolCall looks up the tool and checks permission. It returns a
+// finished outcome when the call must not run.
+func (ex *executor) prepareToolCall(ctx context.Context, tc llm.ToolCall) (tools.Tool, *toolOutcome, error) {
 	toolName := tc.Function.Name
 
 	// Find tool
 	tool, ok := ex.engine.tools.Get(toolName)
 	if !ok {
 		errMsg := fmt.Sprintf("Tool not found: %s", toolName)
-		ex.addEvent(NewEvent(EventToolError, errMsg))
-		ex.engine.ctxManager.AddToolResult(tc.ID, errMsg)
-		return nil
+		return nil, &toolOutcome{
+			call:    tc,
+			content: errMsg,
+			events:  []Event{NewEvent(EventToolError, errMsg)},
+		}, nil
 	}
 	ex.engine.writeTrace("tool_call", tc)
 
@@ -473,49 +755,60 @@ func (ex *executor) executeToolCall(ctx context.Context, tc llm.ToolCall) error
 	path := extractPathArg(tc.Function.Arguments)
 	granted, err := ex.engine.permission.Request(ctx, toolName, action, path)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	if !granted {
 		errMsg := fmt.Sprintf("Permission denied for tool: %s", toolName)
-		ex.addEvent(NewEvent(EventToolError, errMsg))
-		ex.engine.ctxManager.AddToolResult(tc.ID, errMsg)
 		ex.engine.writeTrace("tool_permission_denied", map[string]any{
 			"tool":    toolName,
 			"action":  action,
 			"path":    path,
 			"call_id": tc.ID,
 		})
-		return nil
+		return nil, &toolOutcome{
+			call:    tc,
+			content: errMsg,
+			events:  []Event{NewEvent(EventToolError, errMsg)},
+		}, nil
 	}
 
-	// Add event
-	ex.addEvent(NewEvent(EventToolStarted, fmt.Sprintf("Using tool: %s", toolName)))
+	return tool, nil, nil
+}
+
+// invokeTool executes a permitted tool call. It only touches the trace
+// writer, so it is safe to call from several goroutines.
+func (ex *executor) invokeTool(ctx context.Context, tool tools.Tool, tc llm.ToolCall) *toolOutcome {
+	toolName := tc.Function.Name
 
 	// Execute tool
 	result, err := tool.Execute(ctx, tc.Function.Arguments)
 	if err != nil {
 		errMsg := fmt.Sprintf("Tool execution error: %v", err)
-		ex.addEvent(NewEvent(EventToolError, errMsg))
-		ex.engine.ctxManager.AddToolResult(tc.ID, errMsg)
 		ex.engine.writeTrace("tool_exec_error", map[string]any{
 			"tool":    toolName,
 			"call_id": tc.ID,
 			"error":   err.Error(),
 		})
-		return nil
+		return &toolOutcome{
+			call:    tc,
+			content: errMsg,
+			events:  []Event{NewEvent(EventToolError, errMsg)},
+		}
 	}
 
 	// Handle error result
 	if result.Error != nil {
 		errMsg := result.Error.Error()
-		ex.addEvent(NewEvent(EventToolError, fmt.Sprintf("Tool %s failed: %s", toolName, errMsg)))
-		ex.engine.ctxManager.AddToolResult(tc.ID, errMsg)
 		ex.engine.writeTrace("tool_result_error", map[string]any{
 			"tool":    toolName,
 			"call_id": tc.ID,
 			"error":   errMsg,
 		})
-		return nil
+		return &toolOutcome{
+			call:    tc,
+			content: errMsg,
+			events:  []Event{NewEvent(EventToolError, fmt.Sprintf("Tool %s failed: %s", toolName, errMsg))},
+		}
 	}
 	ex.engine.writeTrace("tool_result", map[string]any{
 		"tool":    toolName,
@@ -524,17 +817,73 @@ func (ex *executor) executeToolCall(ctx context.Context, tc llm.ToolCall) error
 		"summary": result.Summary,
 	})
 
-	// Add tool event based on tool type
-	ex.addToolEvent(toolName, result)
+	return &toolOutcome{
+		call:    tc,
+		content: ex.spillOutput(toolName, tc.ID, result.Content),
+		events:  []Event{toolEvent(toolName, result)},
+	}
+}
+
+// spillOutput saves an oversized tool output as an artifact and returns the
+// preview that goes into the context instead.
+func (ex *executor) spillOutput(toolName, callID, content string) string {
+	if ex.engine.artifacts == nil || toolName == artifact.ReadToolName {
+		return content
+	}
+	preview, saved, err := ex.engine.artifacts.Spill(toolName, content)
+	if err != nil {
+		ex.engine.writeTrace("artifact_error", map[string]any{
+			"tool":    toolName,
+			"call_id": callID,
+			"error":   err.Error(),
+		})
+		return content
+	}
+	if saved != nil {
+		ex.engine.writeTrace("artifact_saved", map[string]any{
+			"tool":        toolName,
+			"call_id":     callID,
+			"artifact_id": saved.ID,
+			"bytes":       saved.Bytes,
+			"lines":       saved.Lines,
+		})
+	}
+	return preview
+}
 
-	// Add tool result to context
-	ex.engine.ctxManager.AddToolResult(tc.ID, result.Content)
+// recordToolOutcome adds the outcome's events and its tool result to context.
+func (ex *executor) recordToolOutcome(ctx context.Context, out *toolOutcome) {
+	for _, ev := range out.events {
+		ex.addEvent(ev)
+	}
+	ex.addMessage(ctx, llm.NewToolMessage(out.call.ID, out.content))
+}
 
-	return nil
+// addMessage adds a message to the context and passes it to the message sink.
+// A compaction it triggers summarizes under the task's ctx.
+func (ex *executor) addMessage(ctx context.Context, msg llm.Message) {
+	ex.engine.ctxManager.AddMessageContext(ctx, msg)
+	if ex.engine.msgSink != nil {
+		ex.engine.msgSink(msg)
+	}
+}
+
+// requestMessages returns the context for the next request. Tool results
+// without a matching call are dropped and calls without a result get a
+// placeholder, since providers reject histories with orphaned tool call IDs.
+func (ex *executor) requestMessages() []llm.Message {
+	messages, fixes := ctxmanager.RepairToolPairs(ex.engine.ctxManager.GetMessages())
+	if len(fixes) > 0 {
+		ex.engine.writeTrace("context_repaired", map[string]any{
+			"iteration": ex.iterCount,
+			"fixes":     fixes,
+		})
+	}
+	return messages
 }
 
-// addToolEvent adds an event based on tool type.
-func (ex *executor) addToolEvent(toolName string, result *tools.Result) {
+// toolEvent builds an event based on tool type.
+func toolEvent(toolName string, result *tools.Result) Event {
 	eventType := EventToolStarted
 	switch toolName {
 	case "read":
@@ -554,7 +903,51 @@ func (ex *executor) addToolEvent(toolName string, result *tools.Result) {
 	ev := NewEvent(eventType, result.Content)
 	ev.ToolName = toolName
 	ev.Summary = result.Summary
-	ex.addEvent(ev)
+	return ev
+}
+
+// checkBudget reports whether sending the current context stays within the
+// spend limits.
+func (ex *executor) checkBudget() error {
+	if ex.engine.cost == nil {
+		return nil
+	}
+	if model, ok := ex.engine.cost.Unpriced(); ok && ex.engine.unpricedWarned != model {
+		// Warn once per model rather than failing, as local models have no
+		// price either.
+		ex.engine.unpricedWarned = model
+		ev := NewEvent(EventToolError, fmt.Sprintf("Model %q has no price, so its calls count as $0 and max_cost_usd/daily_limit cannot stop them. Add it under budget.pricing to enforce the limits.", model))
+		ev.ToolName = "cost"
+		ex.addEvent(ev)
+	}
+	return ex.engine.cost.Check("", ex.engine.ctxManager.TokenUsage().Current)
+}
+
+// recordCost prices a completed call and adds it to the spend totals.
+func (ex *executor) recordCost(resp *llm.CompletionResponse) {
+	if ex.engine.cost == nil {
+		return
+	}
+	c, err := ex.engine.cost.Record(resp.Model, resp.Usage)
+	if err != nil {
+		ex.engine.writeTrace("cost_error", map[string]any{
+			"iteration": ex.iterCount,
+			"error":     err.Error(),
+		})
+	}
+	ex.engine.writeTrace("llm_cost", map[string]any{
+		"iteration":        ex.iterCount,
+		"model":            resp.Model,
+		"cost_usd":         c,
+		"session_cost_usd": ex.engine.cost.SessionCost(),
+	})
+}
+
+func (e *Engine) sessionCost() float64 {
+	if e.cost == nil {
+		return 0
+	}
+	return e.cost.SessionCost()
 }
 
 // addEvent adds an event to the list.
@@ -564,9 +957,10 @@ func (ex *executor) addEvent(ev Event) {
 	ev.CtxUsed = usage.Current
 	ev.CtxMax = usage.Max
 	ev.TokensUsed = ex.totalUsage.TotalTokens
+	ev.CostUSD = ex.engine.sessionCost()
 
 	ex.events = append(ex.events, ev)
-	ex.engine.writeTrace("event", ev)
+	ex.engine.emit(ev)
 }
 
 // defaultSystemPrompt returns the default system prompt.
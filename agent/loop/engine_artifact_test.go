@@ -0,0 +1,101 @@
+package loop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+	"github.com/vigo999/ms-cli/test/mocks"
+	"github.com/vigo999/ms-cli/tools"
+	"github.com/vigo999/ms-cli/tools/artifact"
+)
+
+// bigTool returns numbered lines.
+type bigTool struct {
+	lines int
+}
+
+func (t *bigTool) Name() string           { return "grep" }
+func (t *bigTool) Description() string    { return "grep" }
+func (t *bigTool) Schema() llm.ToolSchema { return llm.ToolSchema{Type: "object"} }
+
+func (t *bigTool) Execute(ctx context.Context, params json.RawMessage) (*tools.Result, error) {
+	var sb strings.Builder
+	for i := 1; i <= t.lines; i++ {
+		fmt.Fprintf(&sb, "match %d\n", i)
+	}
+	return tools.StringResult(sb.String()), nil
+}
+
+func runArtifactTest(t *testing.T, lines int) (*Engine, *artifact.Store) {
+	t.Helper()
+	registry := tools.NewRegistry()
+	registry.MustRegister(&bigTool{lines: lines})
+
+	provider := mocks.NewMockProvider()
+	provider.AddToolCallResponse([]llm.ToolCall{toolCall("c1", "grep", "x")})
+	provider.AddResponse("done")
+
+	store := artifact.NewStore(artifact.Config{Dir: t.TempDir(), Threshold: 4096})
+	engine := NewEngine(EngineConfig{MaxIterations: 3, MaxTokens: 8000}, provider, registry)
+	engine.SetArtifactStore(store)
+
+	if _, err := engine.Run(Task{ID: "spill", Description: "find matches"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	return engine, store
+}
+
+func toolResult(engine *Engine, callID string) string {
+	for _, msg := range engine.ctxManager.GetMessages() {
+		if msg.Role == "tool" && msg.ToolCallID == callID {
+			return msg.Content
+		}
+	}
+	return ""
+}
+
+func TestLargeToolOutputSpillsToArtifact(t *testing.T) {
+	engine, store := runArtifactTest(t, 5000)
+
+	preview := toolResult(engine, "c1")
+	if len(preview) > 4096 {
+		t.Fatalf("expected a short preview in the context, got %d bytes", len(preview))
+	}
+	if !strings.Contains(preview, "match 1\n") || !strings.Contains(preview, "match 5000") {
+		t.Errorf("preview should keep the head and tail: %q", preview)
+	}
+
+	id := regexp.MustCompile(`art_[0-9]+_[0-9]+`).FindString(preview)
+	if id == "" {
+		t.Fatalf("preview does not name the artifact: %q", preview)
+	}
+	full, err := store.Load(id)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !strings.Contains(full, "match 2500\n") {
+		t.Error("artifact should hold the full output")
+	}
+
+	result, err := artifact.NewReadTool(store).Execute(context.Background(),
+		json.RawMessage(fmt.Sprintf(`{"id":%q,"offset":2500,"limit":2}`, id)))
+	if err != nil || result.Error != nil {
+		t.Fatalf("artifact_read failed: %v %v", err, result.Error)
+	}
+	if !strings.Contains(result.Content, "match 2500\nmatch 2501\n") || strings.Contains(result.Content, "match 2502") {
+		t.Errorf("unexpected page: %q", result.Content)
+	}
+}
+
+func TestSmallToolOutputStaysInContext(t *testing.T) {
+	engine, _ := runArtifactTest(t, 10)
+
+	if got := toolResult(engine, "c1"); !strings.HasPrefix(got, "match 1\n") || strings.Contains(got, "artifact") {
+		t.Errorf("small output should not be spilled: %q", got)
+	}
+}
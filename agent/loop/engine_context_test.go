@@ -133,3 +133,26 @@ func TestRunUsesSystemPromptAfterContextManagerSwap(t *testing.T) {
 		t.Fatalf("expected second message content to be user task, got %q", second.Content)
 	}
 }
+
+func TestMessageSinkReceivesConversation(t *testing.T) {
+	engine := newEngineForContextTests(&captureProvider{})
+
+	var got []llm.Message
+	engine.SetMessageSink(func(msg llm.Message) {
+		got = append(got, msg)
+	})
+
+	if _, err := engine.Run(Task{ID: "t1", Description: "hello"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("expected user and assistant messages, got %d", len(got))
+	}
+	if got[0].Role != "user" || got[0].Content != "hello" {
+		t.Fatalf("unexpected user message: %+v", got[0])
+	}
+	if got[1].Role != "assistant" || got[1].Content != "ok" {
+		t.Fatalf("unexpected assistant message: %+v", got[1])
+	}
+}
@@ -0,0 +1,136 @@
+package loop
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vigo999/ms-cli/agent/cost"
+	"github.com/vigo999/ms-cli/agent/plan"
+	"github.com/vigo999/ms-cli/integrations/llm"
+	"github.com/vigo999/ms-cli/test/mocks"
+	"github.com/vigo999/ms-cli/tools"
+)
+
+func TestRunStopsWhenBudgetWouldBeExceeded(t *testing.T) {
+	provider := mocks.NewMockProvider()
+	provider.Responses = []llm.CompletionResponse{
+		{
+			Model: "house-model",
+			ToolCalls: []llm.ToolCall{{
+				ID:       "c1",
+				Type:     "function",
+				Function: llm.ToolCallFunc{Name: "missing", Arguments: json.RawMessage(`{}`)},
+			}},
+			FinishReason: llm.FinishToolCalls,
+			Usage:        llm.Usage{PromptTokens: 100_000, CompletionTokens: 50_000, TotalTokens: 150_000},
+		},
+		{Content: "never reached", FinishReason: llm.FinishStop},
+	}
+
+	tracker, err := cost.NewTracker(cost.Config{
+		MaxCostUSD: 1,
+		Pricing:    map[string]cost.Pricing{"house-model": {InputPerMTok: 5, OutputPerMTok: 10}},
+		Model:      "house-model",
+	})
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+
+	engine := NewEngine(EngineConfig{MaxTokens: 8000}, provider, tools.NewRegistry())
+	engine.SetCostTracker(tracker)
+
+	events, err := engine.Run(Task{ID: "budget", Description: "spend"})
+	if !errors.Is(err, cost.ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+	if provider.CallCount != 1 {
+		t.Fatalf("expected the second request to be blocked, got %d calls", provider.CallCount)
+	}
+
+	var failed, tokenUpdate *Event
+	for i := range events {
+		switch events[i].Type {
+		case EventTaskFailed:
+			failed = &events[i]
+		case EventTokenUpdate:
+			tokenUpdate = &events[i]
+		}
+	}
+	if failed == nil || !strings.Contains(failed.Message, "max_cost_usd") {
+		t.Fatalf("expected TaskFailed naming the budget, got %+v", failed)
+	}
+	if tokenUpdate == nil || tokenUpdate.CostUSD != 1 {
+		t.Fatalf("expected token update carrying $1 session cost, got %+v", tokenUpdate)
+	}
+}
+
+func TestPlanModeCallsAreBudgeted(t *testing.T) {
+	provider := mocks.NewMockProvider()
+	provider.Responses = []llm.CompletionResponse{
+		{
+			Model:        "house-model",
+			Content:      `[{"description": "Delete everything"}]`,
+			FinishReason: llm.FinishStop,
+			Usage:        llm.Usage{PromptTokens: 100_000, CompletionTokens: 50_000, TotalTokens: 150_000},
+		},
+		{Content: `[{"description": "never reached"}]`, FinishReason: llm.FinishStop},
+	}
+
+	tracker, err := cost.NewTracker(cost.Config{
+		MaxCostUSD: 1,
+		Pricing:    map[string]cost.Pricing{"house-model": {InputPerMTok: 5, OutputPerMTok: 10}},
+		Model:      "house-model",
+	})
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+
+	engine := newPlanModeEngine(provider)
+	engine.SetCostTracker(tracker)
+	engine.SetPlanApprover(&scriptedApprover{decisions: []plan.ApprovalDecision{{Feedback: "back up first"}}})
+
+	_, err = engine.Run(Task{ID: "plan-budget", Description: "clean up"})
+	if !errors.Is(err, cost.ErrBudgetExceeded) {
+		t.Fatalf("expected the refinement to hit the budget, got %v", err)
+	}
+	if provider.CallCount != 1 {
+		t.Fatalf("expected the refinement request to be blocked, got %d calls", provider.CallCount)
+	}
+	if got := tracker.SessionCost(); got != 1 {
+		t.Errorf("plan generation should be billed, session cost = %v", got)
+	}
+}
+
+func TestRunWarnsOnceAboutUnpricedModel(t *testing.T) {
+	provider := mocks.NewMockProvider()
+	provider.AddResponse("first")
+	provider.AddResponse("second")
+
+	tracker, err := cost.NewTracker(cost.Config{MaxCostUSD: 1, Model: "house-model"})
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+	engine := NewEngine(EngineConfig{MaxTokens: 8000}, provider, tools.NewRegistry())
+	engine.SetCostTracker(tracker)
+
+	warnings := 0
+	for _, id := range []string{"t1", "t2"} {
+		events, err := engine.Run(Task{ID: id, Description: "hello"})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		for _, ev := range events {
+			if ev.Type == EventToolError && ev.ToolName == "cost" {
+				warnings++
+				if !strings.Contains(ev.Message, `"house-model"`) || !strings.Contains(ev.Message, "budget.pricing") {
+					t.Errorf("unclear warning %q", ev.Message)
+				}
+			}
+		}
+	}
+	if warnings != 1 {
+		t.Errorf("expected one warning about the unpriced model, got %d", warnings)
+	}
+}
@@ -0,0 +1,213 @@
+package loop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vigo999/ms-cli/agent/cost"
+	"github.com/vigo999/ms-cli/agent/memory"
+	"github.com/vigo999/ms-cli/integrations/llm"
+	"github.com/vigo999/ms-cli/tools"
+	memorytools "github.com/vigo999/ms-cli/tools/memory"
+)
+
+// scriptedProvider returns its replies in order and records every request.
+type scriptedProvider struct {
+	replies []string
+	reqs    []*llm.CompletionRequest
+}
+
+func (p *scriptedProvider) Name() string {
+	return "scripted"
+}
+
+func (p *scriptedProvider) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	copied := *req
+	copied.Messages = append([]llm.Message(nil), req.Messages...)
+	p.reqs = append(p.reqs, &copied)
+
+	reply := "ok"
+	if len(p.replies) > 0 {
+		reply, p.replies = p.replies[0], p.replies[1:]
+	}
+	return &llm.CompletionResponse{Content: reply, FinishReason: llm.FinishStop}, nil
+}
+
+func (p *scriptedProvider) CompleteStream(ctx context.Context, req *llm.CompletionRequest) (llm.StreamIterator, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (p *scriptedProvider) SupportsTools() bool {
+	return true
+}
+
+func (p *scriptedProvider) AvailableModels() []llm.ModelInfo {
+	return nil
+}
+
+func newTestMemory(t *testing.T) *memory.Manager {
+	t.Helper()
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "memory.db"), memory.DefaultConfig())
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return memory.NewManager(store, memory.DefaultConfig())
+}
+
+func TestRunInjectsRelevantMemories(t *testing.T) {
+	mem := newTestMemory(t)
+	if err := mem.SaveFact("The project builds with make release", nil); err != nil {
+		t.Fatalf("SaveFact failed: %v", err)
+	}
+	if err := mem.SaveFact("Unrelated note about the office coffee", nil); err != nil {
+		t.Fatalf("SaveFact failed: %v", err)
+	}
+
+	provider := &scriptedProvider{replies: []string{"ok", `{"facts": [], "decisions": []}`}}
+	engine := newEngineForContextTests(provider)
+	engine.SetMemory(mem)
+
+	if _, err := engine.Run(Task{ID: "t1", Description: "run the release builds"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	system := provider.reqs[0].Messages[0]
+	if system.Role != "system" || !strings.Contains(system.Content, "make release") {
+		t.Fatalf("expected memory in system prompt, got %q", system.Content)
+	}
+	if strings.Contains(system.Content, "coffee") {
+		t.Errorf("unrelated memory should not be injected: %q", system.Content)
+	}
+}
+
+func TestRunSavesFactsAndTask(t *testing.T) {
+	mem := newTestMemory(t)
+	provider := &scriptedProvider{replies: []string{
+		"Tests pass with go test ./...",
+		`{"facts": ["Tests run with go test ./..."], "decisions": ["Use table-driven tests for parsers"]}`,
+	}}
+	engine := newEngineForContextTests(provider)
+	engine.config.MemoryExtract = true
+	engine.SetMemory(mem)
+
+	if _, err := engine.Run(Task{ID: "t1", Description: "run the tests"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	facts, err := mem.RetrieveByType(memory.MemoryTypeFact, 10)
+	if err != nil {
+		t.Fatalf("RetrieveByType failed: %v", err)
+	}
+	if len(facts) != 2 {
+		t.Fatalf("expected a fact and a decision, got %d", len(facts))
+	}
+	tasks, err := mem.RetrieveByType(memory.MemoryTypeTask, 10)
+	if err != nil {
+		t.Fatalf("RetrieveByType failed: %v", err)
+	}
+	if len(tasks) != 1 || !strings.Contains(tasks[0].Content, "run the tests") {
+		t.Fatalf("expected task record, got %+v", tasks)
+	}
+
+	// A second run does not save the same facts again.
+	provider.replies = []string{"ok", `{"facts": ["Tests run with go test ./..."], "decisions": []}`}
+	if _, err := engine.Run(Task{ID: "t2", Description: "run the tests"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if facts, _ := mem.RetrieveByType(memory.MemoryTypeFact, 10); len(facts) != 2 {
+		t.Errorf("expected duplicate fact to be skipped, got %d facts", len(facts))
+	}
+}
+
+func TestRunSkipsExtractionWhenDisabled(t *testing.T) {
+	mem := newTestMemory(t)
+	provider := &scriptedProvider{replies: []string{"Tests pass with go test ./..."}}
+	engine := newEngineForContextTests(provider)
+	engine.SetMemory(mem)
+
+	if _, err := engine.Run(Task{ID: "t1", Description: "run the tests"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(provider.reqs) != 1 {
+		t.Errorf("expected only the task's model call, got %d", len(provider.reqs))
+	}
+	if facts, _ := mem.RetrieveByType(memory.MemoryTypeFact, 10); len(facts) != 0 {
+		t.Errorf("expected no extracted facts, got %d", len(facts))
+	}
+	if tasks, _ := mem.RetrieveByType(memory.MemoryTypeTask, 10); len(tasks) != 1 {
+		t.Errorf("expected the task record, got %d", len(tasks))
+	}
+}
+
+// byteCounter counts one token per byte, far more than the heuristic.
+type byteCounter struct{}
+
+func (byteCounter) Name() string          { return "bytes" }
+func (byteCounter) Count(text string) int { return len(text) }
+
+func TestInjectMemoriesUsesContextCounter(t *testing.T) {
+	mem := newTestMemory(t)
+	if err := mem.SaveFact("The project builds with make release", nil); err != nil {
+		t.Fatalf("SaveFact failed: %v", err)
+	}
+
+	provider := &scriptedProvider{replies: []string{"ok"}}
+	engine := NewEngine(EngineConfig{MaxIterations: 1, MaxTokens: 8000, MemoryBudget: 20}, provider, tools.NewRegistry())
+	engine.ctxManager.SetTokenCounter(byteCounter{})
+	engine.SetMemory(mem)
+
+	if _, err := engine.Run(Task{ID: "t1", Description: "run the release builds"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	// About 10 tokens by the heuristic, but over 40 by the counter.
+	if system := provider.reqs[0].Messages[0]; strings.Contains(system.Content, "make release") {
+		t.Errorf("memory over the counted budget was injected: %q", system.Content)
+	}
+}
+
+func TestSystemPromptMentionsMemoryTools(t *testing.T) {
+	registry := tools.NewRegistry()
+	engine := NewEngine(EngineConfig{MaxTokens: 8000}, &scriptedProvider{}, registry)
+	if strings.Contains(engine.config.SystemPrompt, "remember:") {
+		t.Errorf("prompt mentions memory tools that are not registered")
+	}
+
+	mem := newTestMemory(t)
+	registry.MustRegister(memorytools.NewRememberTool(mem))
+	registry.MustRegister(memorytools.NewRecallTool(mem))
+	engine = NewEngine(EngineConfig{MaxTokens: 8000}, &scriptedProvider{}, registry)
+	prompt := engine.config.SystemPrompt
+	if !strings.HasPrefix(prompt, defaultSystemPrompt()) || !strings.Contains(prompt, "- remember:") || !strings.Contains(prompt, "- recall:") {
+		t.Errorf("prompt does not describe the memory tools:\n%s", prompt)
+	}
+}
+
+func TestExtractMemoriesBudgetUsesContextCounter(t *testing.T) {
+	// At $0.002 per token the extraction prompt fits in $1 when counted as
+	// bytes/4, but not when counted one token per byte.
+	tracker, err := cost.NewTracker(cost.Config{
+		MaxCostUSD: 1,
+		Pricing:    map[string]cost.Pricing{"house-model": {InputPerMTok: 2000}},
+		Model:      "house-model",
+	})
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+	provider := &scriptedProvider{replies: []string{`{"facts": [], "decisions": []}`}}
+	engine := newEngineForContextTests(provider)
+	engine.ctxManager.SetTokenCounter(byteCounter{})
+	engine.SetCostTracker(tracker)
+
+	_, _, err = engine.extractMemories(context.Background(), "run the tests", "Tests pass with go test ./...")
+	if !errors.Is(err, cost.ErrBudgetExceeded) {
+		t.Fatalf("expected the extraction to be over budget, got %v", err)
+	}
+	if len(provider.reqs) != 0 {
+		t.Errorf("expected no extraction request, got %d", len(provider.reqs))
+	}
+}
@@ -0,0 +1,134 @@
+package loop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vigo999/ms-cli/configs"
+	"github.com/vigo999/ms-cli/integrations/llm"
+	"github.com/vigo999/ms-cli/permission"
+	"github.com/vigo999/ms-cli/test/mocks"
+	"github.com/vigo999/ms-cli/tools"
+)
+
+// echoShell stands in for the shell tool.
+type echoShell struct{}
+
+func (echoShell) Name() string           { return "shell" }
+func (echoShell) Description() string    { return "shell" }
+func (echoShell) Schema() llm.ToolSchema { return llm.ToolSchema{Type: "object"} }
+
+func (echoShell) Execute(ctx context.Context, params json.RawMessage) (*tools.Result, error) {
+	return tools.StringResult("ok"), nil
+}
+
+// scriptedUI answers permission prompts with a fixed choice.
+type scriptedUI struct {
+	choice   permission.PermissionLevel
+	requests []permission.PermissionRequest
+}
+
+func (u *scriptedUI) RequestPermission(ctx context.Context, req permission.PermissionRequest) (permission.PermissionLevel, error) {
+	u.requests = append(u.requests, req)
+	return u.choice, nil
+}
+
+func shellCall(id, command string) llm.ToolCall {
+	return llm.ToolCall{
+		ID:   id,
+		Type: "function",
+		Function: llm.ToolCallFunc{
+			Name:      "shell",
+			Arguments: json.RawMessage(fmt.Sprintf(`{"command":%q}`, command)),
+		},
+	}
+}
+
+func runPermissionTest(t *testing.T, svc *permission.DefaultPermissionService, commands ...string) *Engine {
+	t.Helper()
+	registry := tools.NewRegistry()
+	registry.MustRegister(echoShell{})
+
+	provider := mocks.NewMockProvider()
+	for i, cmd := range commands {
+		provider.AddToolCallResponse([]llm.ToolCall{shellCall(fmt.Sprintf("c%d", i+1), cmd)})
+	}
+	provider.AddResponse("done")
+
+	engine := NewEngine(EngineConfig{MaxIterations: len(commands) + 2, MaxTokens: 8000}, provider, registry)
+	engine.SetPermissionService(svc)
+	if _, err := engine.Run(Task{ID: "perm", Description: "run commands"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	return engine
+}
+
+func TestPermissionAllowSessionAsksOncePerCommand(t *testing.T) {
+	ui := &scriptedUI{choice: permission.PermissionAllowSession}
+	svc := permission.NewDefaultPermissionService(configs.DefaultConfig().Permissions)
+	svc.SetUI(ui)
+
+	engine := runPermissionTest(t, svc, "git status", "git diff", "make test")
+
+	if len(ui.requests) != 2 {
+		t.Fatalf("expected prompts for git and make only, got %d", len(ui.requests))
+	}
+	if scope := ui.requests[0].Scope(); scope != "`git` commands" {
+		t.Errorf("unexpected scope %q", scope)
+	}
+	if got := toolResult(engine, "c2"); got != "ok" {
+		t.Errorf("second git command should run without asking, got %q", got)
+	}
+}
+
+func TestPermissionDenyBlocksCall(t *testing.T) {
+	ui := &scriptedUI{choice: permission.PermissionDeny}
+	svc := permission.NewDefaultPermissionService(configs.DefaultConfig().Permissions)
+	svc.SetUI(ui)
+
+	engine := runPermissionTest(t, svc, "rm -rf build")
+
+	if got := toolResult(engine, "c1"); !strings.Contains(got, "Permission denied") {
+		t.Errorf("expected the call to be denied, got %q", got)
+	}
+	if d := ui.requests[0].Danger; d == nil || d.Category != "destructive" {
+		t.Errorf("expected dangerous command info, got %+v", d)
+	}
+}
+
+func TestPermissionAllowAlwaysIsSaved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "permissions.json")
+	store, err := permission.NewFilePermissionStore(path)
+	if err != nil {
+		t.Fatalf("NewFilePermissionStore failed: %v", err)
+	}
+	ui := &scriptedUI{choice: permission.PermissionAllowAlways}
+	svc := permission.NewDefaultPermissionService(configs.DefaultConfig().Permissions)
+	svc.SetUI(ui)
+	svc.SetStore(store)
+
+	runPermissionTest(t, svc, "go test ./...")
+
+	decision := store.GetDecisionForCommand("go")
+	if decision == nil || decision.Level != permission.PermissionAllowAlways || decision.Action != "go" {
+		t.Fatalf("expected a saved always-allow decision for go, got %+v", decision)
+	}
+
+	// A new service loading the store no longer asks.
+	reloaded, err := permission.NewFilePermissionStore(path)
+	if err != nil {
+		t.Fatalf("reload store failed: %v", err)
+	}
+	next := &scriptedUI{choice: permission.PermissionDeny}
+	svc2 := permission.NewDefaultPermissionService(configs.DefaultConfig().Permissions)
+	svc2.SetUI(next)
+	svc2.SetStore(reloaded)
+	runPermissionTest(t, svc2, "go vet ./...")
+	if len(next.requests) != 0 {
+		t.Errorf("expected no prompt after always allow, got %d", len(next.requests))
+	}
+}
@@ -0,0 +1,168 @@
+package loop
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vigo999/ms-cli/agent/plan"
+	"github.com/vigo999/ms-cli/test/mocks"
+	"github.com/vigo999/ms-cli/tools"
+)
+
+// scriptedApprover answers approval requests from a fixed list.
+type scriptedApprover struct {
+	decisions []plan.ApprovalDecision
+	seen      []*plan.Plan
+}
+
+func (a *scriptedApprover) AwaitApproval(ctx context.Context, p *plan.Plan) (plan.ApprovalDecision, error) {
+	a.seen = append(a.seen, p)
+	if len(a.decisions) == 0 {
+		return plan.ApprovalDecision{}, errors.New("no decision scripted")
+	}
+	d := a.decisions[0]
+	a.decisions = a.decisions[1:]
+	return d, nil
+}
+
+// recordingCallback records plan callbacks.
+type recordingCallback struct {
+	plan.DefaultModeCallback
+	created   int
+	approved  int
+	rejected  []string
+	completed int
+}
+
+func (c *recordingCallback) OnPlanCreated(p *plan.Plan) error  { c.created++; return nil }
+func (c *recordingCallback) OnPlanApproved(p *plan.Plan) error { c.approved++; return nil }
+func (c *recordingCallback) OnPlanRejected(p *plan.Plan, reason string) error {
+	c.rejected = append(c.rejected, reason)
+	return nil
+}
+func (c *recordingCallback) OnStepCompleted(step *plan.PlanStep, index int, result string) error {
+	c.completed++
+	return nil
+}
+
+func newPlanModeEngine(provider *mocks.MockProvider) *Engine {
+	engine := NewEngine(EngineConfig{MaxTokens: 8000}, provider, tools.NewRegistry())
+	engine.SetRunMode(plan.ModePlan)
+	return engine
+}
+
+func TestPlanModeRefinesRejectedPlanUntilApproved(t *testing.T) {
+	provider := mocks.NewMockProvider()
+	provider.AddResponse(`[{"description": "Delete everything"}]`)
+	provider.AddResponse(`[{"description": "Back up files"}, {"description": "Delete temp files"}]`)
+
+	engine := newPlanModeEngine(provider)
+	cb := &recordingCallback{}
+	approver := &scriptedApprover{decisions: []plan.ApprovalDecision{
+		{Feedback: "only delete temp files, back up first"},
+		{Approved: true},
+	}}
+	engine.SetModeCallback(cb)
+	engine.SetPlanApprover(approver)
+
+	if _, err := engine.Run(Task{ID: "p1", Description: "clean up"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if cb.created != 2 || len(approver.seen) != 2 {
+		t.Fatalf("expected two plans shown and reviewed, got %d/%d", cb.created, len(approver.seen))
+	}
+	if len(cb.rejected) != 1 || cb.rejected[0] != "only delete temp files, back up first" {
+		t.Fatalf("expected rejection feedback to reach OnPlanRejected, got %v", cb.rejected)
+	}
+	if approver.seen[0].Status != plan.PlanStatusRejected {
+		t.Fatalf("first plan status = %s, want rejected", approver.seen[0].Status)
+	}
+	final := approver.seen[1]
+	if len(final.Steps) != 2 || final.Steps[0].Description != "Back up files" {
+		t.Fatalf("expected refined plan to be executed, got %s", final.ToMarkdown())
+	}
+	if cb.approved != 1 || cb.completed != 2 {
+		t.Fatalf("expected refined plan to run once with 2 steps, got approved=%d completed=%d", cb.approved, cb.completed)
+	}
+	if provider.CallCount != 2 {
+		t.Fatalf("expected generate + refine calls, got %d", provider.CallCount)
+	}
+}
+
+func TestPlanModeRejectionWithoutFeedbackCancels(t *testing.T) {
+	provider := mocks.NewMockProvider()
+	provider.AddResponse(`[{"description": "Rewrite the repo"}]`)
+
+	engine := newPlanModeEngine(provider)
+	cb := &recordingCallback{}
+	engine.SetModeCallback(cb)
+	engine.SetPlanApprover(&scriptedApprover{decisions: []plan.ApprovalDecision{{}}})
+
+	events, err := engine.Run(Task{ID: "p2", Description: "rewrite"})
+	if !errors.Is(err, errPlanRejected) {
+		t.Fatalf("expected errPlanRejected, got %v", err)
+	}
+	if len(cb.rejected) != 1 || cb.approved != 0 || cb.completed != 0 {
+		t.Fatalf("rejected plan must not run: rejected=%v approved=%d completed=%d", cb.rejected, cb.approved, cb.completed)
+	}
+	if last := events[len(events)-1]; last.Type != EventTaskFailed {
+		t.Fatalf("expected TaskFailed, got %s", last.Type)
+	}
+}
+
+func TestPlanModeRevisionLimitCancelsPlan(t *testing.T) {
+	provider := mocks.NewMockProvider()
+	provider.AddResponse(`[{"description": "Rewrite the repo"}]`)
+
+	store, err := plan.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	engine := newPlanModeEngine(provider)
+	engine.config.ModeConfig.PlanConfig.MaxRevisions = 1
+	engine.SetPlanStore(store)
+	cb := &recordingCallback{}
+	approver := &scriptedApprover{decisions: []plan.ApprovalDecision{{Feedback: "smaller steps"}}}
+	engine.SetModeCallback(cb)
+	engine.SetPlanApprover(approver)
+
+	events, err := engine.Run(Task{ID: "p4", Description: "rewrite"})
+	if !errors.Is(err, errPlanRejected) {
+		t.Fatalf("expected errPlanRejected, got %v", err)
+	}
+	if provider.CallCount != 1 || len(cb.rejected) != 1 || cb.approved != 0 {
+		t.Fatalf("plan must not be revised or run: calls=%d rejected=%v approved=%d", provider.CallCount, cb.rejected, cb.approved)
+	}
+	p := approver.seen[0]
+	if p.Status != plan.PlanStatusCancelled {
+		t.Errorf("plan status = %s, want cancelled", p.Status)
+	}
+	rec, err := store.Load(p.ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if rec.Plan.Status != plan.PlanStatusCancelled {
+		t.Errorf("stored plan status = %s, want cancelled", rec.Plan.Status)
+	}
+	if last := events[len(events)-1]; last.Type != EventTaskFailed {
+		t.Fatalf("expected TaskFailed, got %s", last.Type)
+	}
+}
+
+func TestPlanModeWithoutApproverAutoApproves(t *testing.T) {
+	provider := mocks.NewMockProvider()
+	provider.AddResponse(`[{"description": "Read the README"}]`)
+
+	engine := newPlanModeEngine(provider)
+	cb := &recordingCallback{}
+	engine.SetModeCallback(cb)
+
+	if _, err := engine.Run(Task{ID: "p3", Description: "read"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if cb.approved != 1 || cb.completed != 1 {
+		t.Fatalf("expected plan to run without approver, got approved=%d completed=%d", cb.approved, cb.completed)
+	}
+}
@@ -0,0 +1,138 @@
+package loop
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+	"github.com/vigo999/ms-cli/test/mocks"
+	"github.com/vigo999/ms-cli/tools"
+)
+
+func TestStreamingRunEmitsDeltasAndFinalReply(t *testing.T) {
+	provider := mocks.NewMockProvider()
+	provider.StreamResponses = []llm.StreamChunk{
+		{Thinking: "considering"},
+		{Content: "Hello"},
+		{Content: ", world"},
+		{FinishReason: llm.FinishStop, Usage: &llm.Usage{PromptTokens: 10, CompletionTokens: 3, TotalTokens: 13}},
+	}
+
+	engine := NewEngine(EngineConfig{
+		MaxIterations: 1,
+		MaxTokens:     8000,
+		Stream:        true,
+	}, provider, tools.NewRegistry())
+
+	var live []Event
+	engine.SetEventSink(func(ev Event) {
+		live = append(live, ev)
+	})
+
+	events, err := engine.Run(Task{ID: "stream", Description: "say hello"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var deltas []string
+	var thinking string
+	for _, ev := range live {
+		switch ev.Type {
+		case EventReplyDelta:
+			deltas = append(deltas, ev.Message)
+		case EventThinkingDelta:
+			thinking += ev.Message
+		}
+	}
+	if strings.Join(deltas, "") != "Hello, world" {
+		t.Fatalf("expected reply deltas to spell %q, got %q", "Hello, world", deltas)
+	}
+	if thinking != "considering" {
+		t.Fatalf("expected thinking delta %q, got %q", "considering", thinking)
+	}
+
+	var reply *Event
+	for i := range events {
+		if events[i].Type == EventReplyDelta {
+			t.Fatalf("deltas should not be kept in the task event list")
+		}
+		if events[i].Type == EventAgentReply {
+			reply = &events[i]
+		}
+	}
+	if reply == nil {
+		t.Fatal("expected final AgentReply event")
+	}
+	if reply.Message != "Hello, world" {
+		t.Fatalf("expected assembled reply %q, got %q", "Hello, world", reply.Message)
+	}
+	if reply.TokensUsed != 13 {
+		t.Fatalf("expected stream usage to be tracked, got %d tokens", reply.TokensUsed)
+	}
+	if len(live) < len(events) {
+		t.Fatalf("expected every task event to reach the sink, got %d of %d", len(live), len(events))
+	}
+}
+
+func TestStreamAccumulatorAssemblesToolCalls(t *testing.T) {
+	call := func(args string) llm.ToolCall {
+		return llm.ToolCall{
+			ID:   "call_1",
+			Type: "function",
+			Function: llm.ToolCallFunc{
+				Name:      "read",
+				Arguments: json.RawMessage(args),
+			},
+		}
+	}
+
+	acc := &streamAccumulator{}
+	acc.add(&llm.StreamChunk{Content: "Let me look."})
+	acc.add(&llm.StreamChunk{ToolCalls: []llm.ToolCall{call(`{"path":`)}})
+	acc.add(&llm.StreamChunk{ToolCalls: []llm.ToolCall{call(`{"path":"a.go"}`)}})
+	acc.add(&llm.StreamChunk{FinishReason: llm.FinishStop})
+
+	resp := acc.response()
+	if resp.Content != "Let me look." {
+		t.Fatalf("unexpected content %q", resp.Content)
+	}
+	if len(resp.ToolCalls) != 1 {
+		t.Fatalf("expected 1 tool call, got %d", len(resp.ToolCalls))
+	}
+	if string(resp.ToolCalls[0].Function.Arguments) != `{"path":"a.go"}` {
+		t.Fatalf("unexpected arguments %s", resp.ToolCalls[0].Function.Arguments)
+	}
+	if resp.FinishReason != llm.FinishToolCalls {
+		t.Fatalf("expected finish reason %q, got %q", llm.FinishToolCalls, resp.FinishReason)
+	}
+}
+
+func TestStreamAccumulatorKeepsThinkingBlocks(t *testing.T) {
+	acc := &streamAccumulator{}
+	acc.add(&llm.StreamChunk{Thinking: "fir"})
+	acc.add(&llm.StreamChunk{Thinking: "st"})
+	acc.add(&llm.StreamChunk{ThinkingSignature: "sig1"})
+	acc.add(&llm.StreamChunk{RedactedThinking: "opaque"})
+	acc.add(&llm.StreamChunk{Thinking: "second"})
+	acc.add(&llm.StreamChunk{ThinkingSignature: "sig2"})
+	acc.add(&llm.StreamChunk{Content: "Done."})
+
+	resp := acc.response()
+	want := []llm.ThinkingBlock{
+		{Thinking: "first", Signature: "sig1"},
+		{Data: "opaque"},
+		{Thinking: "second", Signature: "sig2"},
+	}
+	if len(resp.ThinkingBlocks) != len(want) {
+		t.Fatalf("thinking blocks = %+v, want %+v", resp.ThinkingBlocks, want)
+	}
+	for i := range want {
+		if resp.ThinkingBlocks[i] != want[i] {
+			t.Errorf("block %d = %+v, want %+v", i, resp.ThinkingBlocks[i], want[i])
+		}
+	}
+	if resp.Thinking != "firstsecond" {
+		t.Errorf("unexpected thinking text %q", resp.Thinking)
+	}
+}
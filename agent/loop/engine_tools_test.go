@@ -0,0 +1,147 @@
+package loop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+	"github.com/vigo999/ms-cli/test/mocks"
+	"github.com/vigo999/ms-cli/tools"
+)
+
+// slowTool records how many of its calls overlap.
+type slowTool struct {
+	name    string
+	delay   time.Duration
+	tracker *concurrencyTracker
+}
+
+type concurrencyTracker struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+	order   []string
+}
+
+func (t *slowTool) Name() string           { return t.name }
+func (t *slowTool) Description() string    { return t.name }
+func (t *slowTool) Schema() llm.ToolSchema { return llm.ToolSchema{Type: "object"} }
+
+func (t *slowTool) Execute(ctx context.Context, params json.RawMessage) (*tools.Result, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	_ = json.Unmarshal(params, &args)
+
+	t.tracker.mu.Lock()
+	t.tracker.running++
+	if t.tracker.running > t.tracker.peak {
+		t.tracker.peak = t.tracker.running
+	}
+	t.tracker.order = append(t.tracker.order, t.name+":"+args.Path)
+	t.tracker.mu.Unlock()
+
+	time.Sleep(t.delay)
+
+	t.tracker.mu.Lock()
+	t.tracker.running--
+	t.tracker.mu.Unlock()
+
+	return tools.StringResult(t.name + " " + args.Path), nil
+}
+
+func toolCall(id, name, path string) llm.ToolCall {
+	return llm.ToolCall{
+		ID:   id,
+		Type: "function",
+		Function: llm.ToolCallFunc{
+			Name:      name,
+			Arguments: json.RawMessage(fmt.Sprintf(`{"path":%q}`, path)),
+		},
+	}
+}
+
+func newToolTestEngine(maxConcurrency int, tracker *concurrencyTracker) (*Engine, *mocks.MockProvider) {
+	registry := tools.NewRegistry()
+	registry.MustRegister(&slowTool{name: "read", delay: 30 * time.Millisecond, tracker: tracker})
+	registry.MustRegister(&slowTool{name: "grep", delay: 10 * time.Millisecond, tracker: tracker})
+	registry.MustRegister(&slowTool{name: "write", delay: time.Millisecond, tracker: tracker})
+
+	provider := mocks.NewMockProvider()
+	provider.AddToolCallResponse([]llm.ToolCall{
+		toolCall("c1", "read", "a"),
+		toolCall("c2", "grep", "b"),
+		toolCall("c3", "read", "c"),
+		toolCall("c4", "write", "d"),
+		toolCall("c5", "read", "e"),
+	})
+	provider.AddResponse("done")
+
+	engine := NewEngine(EngineConfig{
+		MaxIterations:  3,
+		MaxTokens:      8000,
+		MaxConcurrency: maxConcurrency,
+	}, provider, registry)
+	return engine, provider
+}
+
+func TestReadOnlyToolsRunConcurrentlyAndKeepOrder(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	engine, _ := newToolTestEngine(2, tracker)
+
+	if _, err := engine.Run(Task{ID: "parallel", Description: "look around"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if tracker.peak != 2 {
+		t.Fatalf("expected read-only calls to overlap up to MaxConcurrency=2, peak was %d", tracker.peak)
+	}
+
+	var ids []string
+	var contents []string
+	for _, msg := range engine.ctxManager.GetMessages() {
+		if msg.Role == "tool" {
+			ids = append(ids, msg.ToolCallID)
+			contents = append(contents, msg.Content)
+		}
+	}
+	want := []string{"c1", "c2", "c3", "c4", "c5"}
+	if fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Fatalf("expected tool results in call order %v, got %v", want, ids)
+	}
+	if contents[0] != "read a" || contents[3] != "write d" {
+		t.Fatalf("tool results do not match their calls: %v", contents)
+	}
+
+	// The write must not start before the preceding read-only batch is done.
+	writeAt := -1
+	for i, name := range tracker.order {
+		if name == "write:d" {
+			writeAt = i
+		}
+	}
+	if writeAt != 3 {
+		t.Fatalf("expected write to run fourth, execution order was %v", tracker.order)
+	}
+}
+
+func TestToolsRunSeriallyWithoutConcurrency(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	engine, _ := newToolTestEngine(1, tracker)
+
+	if _, err := engine.Run(Task{ID: "serial", Description: "look around"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if tracker.peak != 1 {
+		t.Fatalf("expected serial execution, peak concurrency was %d", tracker.peak)
+	}
+	want := []string{"read:a", "grep:b", "read:c", "write:d", "read:e"}
+	if fmt.Sprint(tracker.order) != fmt.Sprint(want) {
+		t.Fatalf("expected execution order %v, got %v", want, tracker.order)
+	}
+}
@@ -0,0 +1,225 @@
+package loop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/vigo999/ms-cli/agent/memory"
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+const (
+	defaultMemoryTopK   = 5
+	defaultMemoryBudget = 800 // tokens
+
+	// maxMemoryItems bounds how many facts and decisions are kept per task.
+	maxMemoryItems = 5
+
+	// maxMemoryInput bounds the task summary sent for fact extraction.
+	maxMemoryInput = 4000
+)
+
+// memoryToolsPrompt is added to the default system prompt when the
+// remember and recall tools are registered.
+const memoryToolsPrompt = `
+
+You also have long-term memory that persists across sessions of this project:
+- remember: Save a fact about the codebase, a user preference, a decision and its reason, or a useful snippet
+- recall: Look up memories saved in earlier sessions
+
+Remember what would save time in a later session, such as build or test commands and conventions the user asked for. Recall before asking the user something they may have told you before.`
+
+// SetMemory enables cross-session memory: relevant memories are added to
+// the system prompt before each task, and a task record is saved after it,
+// along with extracted facts and decisions when MemoryExtract is set. A nil
+// manager disables memory.
+func (e *Engine) SetMemory(m *memory.Manager) {
+	e.memory = m
+}
+
+// injectMemories rebuilds the system prompt with the memories most relevant
+// to the task, within the configured token budget.
+func (e *Engine) injectMemories(task Task) {
+	if e.memory == nil {
+		return
+	}
+
+	topK := e.config.MemoryTopK
+	if topK <= 0 {
+		topK = defaultMemoryTopK
+	}
+	budget := e.config.MemoryBudget
+	if budget <= 0 {
+		budget = defaultMemoryBudget
+	}
+
+	// Pinned memories come first and are always considered.
+	pinned, err := e.memory.RetrievePinned(topK)
+	if err != nil {
+		e.writeTrace("memory_error", map[string]any{"stage": "retrieve", "error": err.Error()})
+	}
+	relevant, err := e.memory.RetrieveForContext(task.Description, topK)
+	if err != nil {
+		e.writeTrace("memory_error", map[string]any{"stage": "retrieve", "error": err.Error()})
+	}
+
+	seen := make(map[string]bool)
+	var lines []string
+	used := 0
+	for _, item := range append(pinned, relevant...) {
+		if item == nil || seen[item.ID] || len(lines) >= topK+len(pinned) {
+			continue
+		}
+		seen[item.ID] = true
+		line := fmt.Sprintf("- [%s] %s", item.Type, strings.TrimSpace(item.Content))
+		cost := e.ctxManager.EstimateText(line)
+		if used+cost > budget {
+			continue
+		}
+		used += cost
+		lines = append(lines, line)
+	}
+
+	prompt := e.config.SystemPrompt
+	if len(lines) > 0 {
+		prompt += "\n\n## Memories from earlier sessions\n\nThese may be relevant to the task. Prefer what you observe now if they conflict.\n" +
+			strings.Join(lines, "\n")
+	}
+	e.ctxManager.SetSystemPrompt(prompt)
+
+	e.writeTrace("memory_injected", map[string]any{
+		"task_id": task.ID,
+		"count":   len(lines),
+		"tokens":  used,
+	})
+}
+
+// rememberTask saves a record of the task and, if it completed and
+// extraction is enabled, the facts and decisions worth keeping for later
+// sessions.
+func (e *Engine) rememberTask(ctx context.Context, task Task, events []Event, runErr error) {
+	if e.memory == nil {
+		return
+	}
+
+	status := "completed"
+	if runErr != nil {
+		status = "failed"
+	}
+	reply := lastReply(events)
+
+	record := task.Description
+	if reply != "" {
+		record += "\nOutcome: " + truncate(reply, 500)
+	}
+	if err := e.memory.SaveTask(record, status); err != nil {
+		e.writeTrace("memory_error", map[string]any{"stage": "save_task", "error": err.Error()})
+	}
+
+	if !e.config.MemoryExtract || runErr != nil || reply == "" || e.provider == nil || ctx.Err() != nil {
+		return
+	}
+
+	facts, decisions, err := e.extractMemories(ctx, task.Description, reply)
+	if err != nil {
+		e.writeTrace("memory_error", map[string]any{"stage": "extract", "error": err.Error()})
+		return
+	}
+	saved := 0
+	for _, fact := range facts {
+		if e.saveFact(fact, nil) {
+			saved++
+		}
+	}
+	for _, decision := range decisions {
+		if e.saveFact(decision, []string{"decision"}) {
+			saved++
+		}
+	}
+	e.writeTrace("memory_saved", map[string]any{"task_id": task.ID, "count": saved})
+}
+
+// saveFact saves a fact unless an identical memory already exists.
+func (e *Engine) saveFact(content string, tags []string) bool {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return false
+	}
+	existing, err := e.memory.SaveIfNew(memory.NewFact(content, tags))
+	if err != nil {
+		e.writeTrace("memory_error", map[string]any{"stage": "save_fact", "error": err.Error()})
+		return false
+	}
+	return existing == nil
+}
+
+// extractMemories asks the model which facts and decisions from a finished
+// task are worth remembering.
+func (e *Engine) extractMemories(ctx context.Context, description, reply string) ([]string, []string, error) {
+	prompt := fmt.Sprintf(`A task in this project has just finished. Extract what would help with future tasks in the same project.
+
+Task: %s
+
+Result:
+%s
+
+Reply with JSON only, in this format:
+{"facts": ["..."], "decisions": ["..."]}
+
+Facts are stable truths about the project or the user, such as build commands, file locations or conventions. Decisions are choices that were made, with the reason. Use one sentence per item and at most %d items per list. Return empty lists if nothing is worth remembering.`,
+		description, truncate(reply, maxMemoryInput), maxMemoryItems)
+
+	if e.cost != nil {
+		if err := e.cost.Check("", e.ctxManager.EstimateText(prompt)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	resp, err := e.provider.Complete(ctx, &llm.CompletionRequest{
+		Messages:    []llm.Message{llm.NewUserMessage(prompt)},
+		Temperature: 0,
+		MaxTokens:   500,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	e.recordStageCost("memory", resp)
+
+	content := resp.Content
+	if start, end := strings.Index(content, "{"), strings.LastIndex(content, "}"); start >= 0 && end > start {
+		content = content[start : end+1]
+	}
+	var out struct {
+		Facts     []string `json:"facts"`
+		Decisions []string `json:"decisions"`
+	}
+	if err := json.Unmarshal([]byte(content), &out); err != nil {
+		return nil, nil, fmt.Errorf("parse memories: %w", err)
+	}
+	if len(out.Facts) > maxMemoryItems {
+		out.Facts = out.Facts[:maxMemoryItems]
+	}
+	if len(out.Decisions) > maxMemoryItems {
+		out.Decisions = out.Decisions[:maxMemoryItems]
+	}
+	return out.Facts, out.Decisions, nil
+}
+
+// lastReply returns the last agent reply of a run.
+func lastReply(events []Event) string {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type == EventAgentReply && strings.TrimSpace(events[i].Message) != "" {
+			return events[i].Message
+		}
+	}
+	return ""
+}
+
+func truncate(s string, max int) string {
+	if r := []rune(s); len(r) > max {
+		return string(r[:max]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,114 @@
+package loop
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+// stream performs a streaming completion and assembles the chunks into a
+// single response. Reply and thinking deltas are forwarded to the event sink
+// as they arrive so the UI can render tokens live.
+func (ex *executor) stream(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	it, err := ex.engine.provider.CompleteStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	acc := &streamAccumulator{}
+	for {
+		chunk, err := it.Next()
+		if chunk != nil {
+			acc.add(chunk)
+			if chunk.Thinking != "" {
+				ex.notify(EventThinkingDelta, chunk.Thinking)
+			}
+			if chunk.Content != "" {
+				ex.notify(EventReplyDelta, chunk.Content)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return acc.response(), nil
+}
+
+// notify sends a streaming delta to the event sink. Deltas are neither
+// traced nor kept in the task's event list; the assembled response is.
+func (ex *executor) notify(eventType, message string) {
+	ev := NewEvent(eventType, message)
+	usage := ex.engine.ctxManager.TokenUsage()
+	ev.CtxUsed = usage.Current
+	ev.CtxMax = usage.Max
+	ev.TokensUsed = ex.totalUsage.TotalTokens
+	ev.CostUSD = ex.engine.sessionCost()
+	ex.engine.notify(ev)
+}
+
+// streamAccumulator assembles stream chunks into a completion response.
+type streamAccumulator struct {
+	content   strings.Builder
+	thinking  strings.Builder
+	blocks    []llm.ThinkingBlock
+	block     strings.Builder // thinking of the block not yet signed
+	toolCalls []llm.ToolCall
+	finish    llm.FinishReason
+	usage     llm.Usage
+}
+
+// add merges a chunk into the accumulated response.
+func (a *streamAccumulator) add(chunk *llm.StreamChunk) {
+	a.content.WriteString(chunk.Content)
+	a.thinking.WriteString(chunk.Thinking)
+	a.block.WriteString(chunk.Thinking)
+	if chunk.ThinkingSignature != "" {
+		a.blocks = append(a.blocks, llm.ThinkingBlock{Thinking: a.block.String(), Signature: chunk.ThinkingSignature})
+		a.block.Reset()
+	}
+	if chunk.RedactedThinking != "" {
+		a.blocks = append(a.blocks, llm.ThinkingBlock{Data: chunk.RedactedThinking})
+	}
+
+	// ToolCalls is a snapshot of every call assembled so far; a later
+	// snapshot supersedes the earlier one.
+	if len(chunk.ToolCalls) > 0 {
+		a.toolCalls = append(a.toolCalls[:0], chunk.ToolCalls...)
+	}
+	if chunk.FinishReason != "" {
+		a.finish = chunk.FinishReason
+	}
+	if chunk.Usage != nil {
+		a.usage = *chunk.Usage
+	}
+}
+
+// response returns the assembled completion response.
+func (a *streamAccumulator) response() *llm.CompletionResponse {
+	resp := &llm.CompletionResponse{
+		Content:        a.content.String(),
+		FinishReason:   a.finish,
+		Usage:          a.usage,
+		Thinking:       a.thinking.String(),
+		ThinkingBlocks: a.blocks,
+	}
+	if a.block.Len() > 0 {
+		// Reasoning from providers that do not sign it.
+		resp.ThinkingBlocks = append(resp.ThinkingBlocks, llm.ThinkingBlock{Thinking: a.block.String()})
+	}
+	if len(a.toolCalls) > 0 {
+		resp.ToolCalls = a.toolCalls
+		resp.FinishReason = llm.FinishToolCalls
+	}
+	if resp.FinishReason == "" {
+		resp.FinishReason = llm.FinishStop
+	}
+	return resp
+}
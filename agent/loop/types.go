@@ -23,6 +23,7 @@ type Event struct {
 	CtxUsed    int
 	CtxMax     int
 	TokensUsed int
+	CostUSD    float64
 	Usage      llm.Usage
 	Timestamp  time.Time
 }
@@ -59,6 +60,8 @@ const (
 	EventCmdFinished   = "CmdFinished"
 	EventAgentReply    = "AgentReply"
 	EventAgentThinking = "AgentThinking"
+	EventReplyDelta    = "AgentReplyDelta"
+	EventThinkingDelta = "AgentThinkingDelta"
 	EventTokenUpdate   = "TokenUpdate"
 	EventToolRead      = "ToolRead"
 	EventToolGrep      = "ToolGrep"
@@ -0,0 +1,244 @@
+package memory
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// embeddingConcepts are the dimensions of the fake embedding server; words
+// in the same group share a dimension, so "ship" is close to "release".
+var embeddingConcepts = [][]string{
+	{"release", "ship", "deploy"},
+	{"test", "tests", "testing"},
+	{"coffee", "office"},
+}
+
+// newEmbeddingServer starts a fake OpenAI-compatible /embeddings endpoint
+// and counts the requests it receives.
+func newEmbeddingServer(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" || r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"message": "bad request"}}`))
+			return
+		}
+		requests.Add(1)
+
+		var req embeddingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		type datum struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		}
+		var resp struct {
+			Data []datum `json:"data"`
+		}
+		// Reply in reverse order to check that results follow the index.
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			vec := make([]float64, len(embeddingConcepts)+1)
+			vec[len(embeddingConcepts)] = 0.1
+			for _, word := range strings.Fields(strings.ToLower(req.Input[i])) {
+				for d, group := range embeddingConcepts {
+					for _, w := range group {
+						if strings.Trim(word, ".,?!") == w {
+							vec[d]++
+						}
+					}
+				}
+			}
+			resp.Data = append(resp.Data, datum{Index: i, Embedding: vec})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenAIEmbedderBatchesAndCaches(t *testing.T) {
+	var requests atomic.Int32
+	server := newEmbeddingServer(t, &requests)
+
+	embedder, err := NewOpenAIEmbedder(OpenAIEmbedderConfig{
+		URL:       server.URL + "/v1",
+		Key:       "test-key",
+		Model:     "test-embedding",
+		BatchSize: 2,
+		CacheSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("NewOpenAIEmbedder failed: %v", err)
+	}
+
+	texts := []string{"release", "tests", "coffee", "ship", "deploy"}
+	vectors, err := embedder.EmbedBatch(texts)
+	if err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("Expected 3 requests for 5 texts in batches of 2, got %d", got)
+	}
+	if vectors[0][0] != 1 || vectors[1][1] != 1 || vectors[2][2] != 1 {
+		t.Errorf("Vectors are out of order: %v", vectors)
+	}
+	if embedder.Dimension() != len(embeddingConcepts)+1 {
+		t.Errorf("Expected dimension %d, got %d", len(embeddingConcepts)+1, embedder.Dimension())
+	}
+
+	// Cached texts are not requested again.
+	if _, err := embedder.Embed("coffee"); err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("Expected cached embedding, got %d requests", got)
+	}
+}
+
+func TestOpenAIEmbedderReportsErrors(t *testing.T) {
+	var requests atomic.Int32
+	server := newEmbeddingServer(t, &requests)
+
+	embedder, _ := NewOpenAIEmbedder(OpenAIEmbedderConfig{
+		URL:   server.URL + "/v1",
+		Key:   "wrong-key",
+		Model: "test-embedding",
+	})
+	if _, err := embedder.Embed("release"); err == nil || !strings.Contains(err.Error(), "bad request") {
+		t.Errorf("Expected error with server message, got %v", err)
+	}
+}
+
+func TestSemanticRecall(t *testing.T) {
+	var requests atomic.Int32
+	server := newEmbeddingServer(t, &requests)
+	embedder, _ := NewOpenAIEmbedder(OpenAIEmbedderConfig{
+		URL:   server.URL + "/v1",
+		Key:   "test-key",
+		Model: "test-embedding",
+	})
+
+	tempDir := t.TempDir()
+	store, _ := NewSQLiteStore(tempDir+"/test.db", DefaultConfig())
+	mgr := NewManager(store, DefaultConfig())
+
+	// Saved before semantic recall is enabled, so they lack vectors.
+	mgr.SaveFact("Run make release before tagging", nil)
+	mgr.SaveFact("The office coffee machine is broken", nil)
+
+	indexPath := filepath.Join(tempDir, "test.hnsw")
+	mgr.SetSemantic(SemanticConfig{Embedder: embedder, IndexPath: indexPath, MinSimilarity: 0.5})
+
+	if stale, err := mgr.IndexStale(); err != nil || !stale {
+		t.Fatalf("Expected stale index, got %v (%v)", stale, err)
+	}
+	if n, err := mgr.Reindex(); err != nil || n != 2 {
+		t.Fatalf("Expected 2 indexed memories, got %d (%v)", n, err)
+	}
+	if stale, _ := mgr.IndexStale(); stale {
+		t.Error("Index should be up to date after Reindex")
+	}
+
+	// New memories are embedded when saved.
+	mgr.SaveFact("Testing uses go test", nil)
+
+	// "ship" shares no keyword with the release fact but has the same meaning.
+	items, err := mgr.RetrieveForContext("how do we ship", 5)
+	if err != nil {
+		t.Fatalf("RetrieveForContext failed: %v", err)
+	}
+	if len(items) != 1 || !strings.Contains(items[0].Content, "make release") {
+		t.Errorf("Expected the release fact, got %+v", items)
+	}
+
+	items, _ = mgr.Recall("testing", []MemoryType{MemoryTypeFact}, 5)
+	if len(items) != 1 || !strings.Contains(items[0].Content, "go test") {
+		t.Errorf("Expected the testing fact, got %+v", items)
+	}
+
+	// Close saves the index next to the database.
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(indexPath); err != nil {
+		t.Errorf("Expected saved index: %v", err)
+	}
+	loaded, err := LoadHNSWIndex(indexPath)
+	if err != nil || loaded.Len() != 3 {
+		t.Errorf("Expected 3 memories in saved index, got %v", err)
+	}
+}
+
+// blockingEmbedder embeds texts by length; EmbedBatch, used by Reindex,
+// waits for release so the test can change memories mid-rebuild.
+type blockingEmbedder struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (e *blockingEmbedder) Embed(text string) ([]float64, error) {
+	return []float64{1, float64(len(text))}, nil
+}
+
+func (e *blockingEmbedder) EmbedBatch(texts []string) ([][]float64, error) {
+	close(e.started)
+	<-e.release
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i], _ = e.Embed(text)
+	}
+	return vectors, nil
+}
+
+func (e *blockingEmbedder) Dimension() int { return 2 }
+
+func TestReindexKeepsChangesMadeDuringRebuild(t *testing.T) {
+	store, _ := NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), DefaultConfig())
+	mgr := NewManager(store, DefaultConfig())
+
+	old := NewMemoryItem(MemoryTypeFact, "Saved before semantic recall")
+	if err := mgr.Save(old); err != nil {
+		t.Fatal(err)
+	}
+	embedder := &blockingEmbedder{started: make(chan struct{}), release: make(chan struct{})}
+	mgr.SetSemantic(SemanticConfig{Embedder: embedder})
+	doomed := NewMemoryItem(MemoryTypeFact, "Deleted during the rebuild")
+	if err := mgr.Save(doomed); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := mgr.Reindex()
+		done <- err
+	}()
+	<-embedder.started
+
+	added := NewMemoryItem(MemoryTypeFact, "Remembered during the rebuild")
+	if err := mgr.Save(added); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Delete(doomed.ID); err != nil {
+		t.Fatal(err)
+	}
+	close(embedder.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+
+	if !mgr.index.Contains(old.ID) || !mgr.index.Contains(added.ID) {
+		t.Errorf("Expected old and added memories in the index")
+	}
+	if mgr.index.Contains(doomed.ID) {
+		t.Errorf("Deleted memory is back in the index")
+	}
+	if stale, err := mgr.IndexStale(); err != nil || stale {
+		t.Errorf("Index should be up to date after Reindex, got %v (%v)", stale, err)
+	}
+}
@@ -0,0 +1,458 @@
+package memory
+
+import (
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// HNSWConfig HNSW 索引参数
+type HNSWConfig struct {
+	M              int // 每层最大邻居数（第 0 层为 2M）
+	EfConstruction int // 构建时的候选集大小
+	EfSearch       int // 检索时的候选集大小
+}
+
+// DefaultHNSWConfig returns default index parameters.
+func DefaultHNSWConfig() HNSWConfig {
+	return HNSWConfig{
+		M:              16,
+		EfConstruction: 100,
+		EfSearch:       64,
+	}
+}
+
+// SearchResult 向量检索结果
+type SearchResult struct {
+	ID         string
+	Similarity float64 // 余弦相似度
+}
+
+// hnswNode 索引中的一个向量
+type hnswNode struct {
+	ID      string
+	Vector  []float32 // 已归一化
+	Level   int
+	Friends [][]int32 // 每层的邻居
+	Deleted bool
+}
+
+// HNSWIndex 基于 HNSW 图的近似最近邻索引（余弦相似度）
+type HNSWIndex struct {
+	mu        sync.RWMutex
+	cfg       HNSWConfig
+	levelMult float64
+	rng       *rand.Rand
+
+	nodes    []*hnswNode
+	ids      map[string]int32
+	entry    int32
+	maxLevel int
+	deleted  int
+	dim      int
+}
+
+// NewHNSWIndex 创建空索引
+func NewHNSWIndex(cfg HNSWConfig) *HNSWIndex {
+	def := DefaultHNSWConfig()
+	if cfg.M <= 1 {
+		cfg.M = def.M
+	}
+	if cfg.EfConstruction <= 0 {
+		cfg.EfConstruction = def.EfConstruction
+	}
+	if cfg.EfSearch <= 0 {
+		cfg.EfSearch = def.EfSearch
+	}
+	return &HNSWIndex{
+		cfg:       cfg,
+		levelMult: 1 / math.Log(float64(cfg.M)),
+		rng:       rand.New(rand.NewSource(rand.Int63())),
+		ids:       make(map[string]int32),
+		entry:     -1,
+	}
+}
+
+// Len 返回索引中的向量数
+func (h *HNSWIndex) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.ids)
+}
+
+// Contains 判断 ID 是否已被索引
+func (h *HNSWIndex) Contains(id string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.ids[id]
+	return ok
+}
+
+// Add 加入或替换一个向量
+func (h *HNSWIndex) Add(id string, vector []float32) error {
+	if len(vector) == 0 {
+		return fmt.Errorf("empty vector")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.dim == 0 {
+		h.dim = len(vector)
+	} else if len(vector) != h.dim {
+		return fmt.Errorf("vector has dimension %d, index has %d", len(vector), h.dim)
+	}
+
+	if old, ok := h.ids[id]; ok {
+		h.nodes[old].Deleted = true
+		h.deleted++
+	}
+	// 删除项过多时重建图，避免检索时绕行
+	if h.deleted > 0 && h.deleted >= len(h.nodes)/2 {
+		h.rebuild()
+	}
+
+	h.insert(id, normalize(vector))
+	return nil
+}
+
+// Remove 从索引中移除向量
+func (h *HNSWIndex) Remove(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	idx, ok := h.ids[id]
+	if !ok {
+		return
+	}
+	delete(h.ids, id)
+	h.nodes[idx].Deleted = true
+	h.deleted++
+}
+
+// Replace 用另一个索引的内容替换当前内容
+func (h *HNSWIndex) Replace(other *HNSWIndex) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.cfg, h.levelMult = other.cfg, other.levelMult
+	h.nodes, h.ids = other.nodes, other.ids
+	h.entry, h.maxLevel = other.entry, other.maxLevel
+	h.deleted, h.dim = other.deleted, other.dim
+}
+
+// Search 返回与查询最相似的 k 个向量
+func (h *HNSWIndex) Search(query []float32, k int) []SearchResult {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.entry < 0 || k <= 0 || len(query) != h.dim {
+		return nil
+	}
+	q := normalize(query)
+
+	ep := h.greedy(q, h.entry, h.maxLevel, 0)
+	ef := h.cfg.EfSearch
+	if ef < k {
+		ef = k
+	}
+	candidates := h.searchLayer(q, ep, ef, 0)
+
+	results := make([]SearchResult, 0, k)
+	for _, c := range candidates {
+		node := h.nodes[c.idx]
+		if node.Deleted {
+			continue
+		}
+		results = append(results, SearchResult{ID: node.ID, Similarity: 1 - float64(c.dist)})
+		if len(results) == k {
+			break
+		}
+	}
+	return results
+}
+
+// insert 插入已归一化的向量（必须持有锁）
+func (h *HNSWIndex) insert(id string, vector []float32) {
+	level := int(math.Floor(-math.Log(1-h.rng.Float64()) * h.levelMult))
+	idx := int32(len(h.nodes))
+	node := &hnswNode{
+		ID:      id,
+		Vector:  vector,
+		Level:   level,
+		Friends: make([][]int32, level+1),
+	}
+	h.nodes = append(h.nodes, node)
+	h.ids[id] = idx
+
+	if h.entry < 0 {
+		h.entry = idx
+		h.maxLevel = level
+		return
+	}
+
+	ep := h.greedy(vector, h.entry, h.maxLevel, level+1)
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(vector, ep, h.cfg.EfConstruction, l)
+		node.Friends[l] = h.selectNeighbors(candidates, h.maxFriends(l))
+		for _, friend := range node.Friends[l] {
+			h.link(friend, idx, l)
+		}
+		ep = candidates[0].idx
+	}
+
+	if level > h.maxLevel {
+		h.entry = idx
+		h.maxLevel = level
+	}
+}
+
+// link 为 from 在 level 层加入邻居 to，超出上限时只保留最近的
+func (h *HNSWIndex) link(from, to int32, level int) {
+	node := h.nodes[from]
+	node.Friends[level] = append(node.Friends[level], to)
+	limit := h.maxFriends(level)
+	if len(node.Friends[level]) <= limit {
+		return
+	}
+
+	candidates := make([]hnswCandidate, len(node.Friends[level]))
+	for i, f := range node.Friends[level] {
+		candidates[i] = hnswCandidate{idx: f, dist: distance(node.Vector, h.nodes[f].Vector)}
+	}
+	sortCandidates(candidates)
+	node.Friends[level] = h.selectNeighbors(candidates, limit)
+}
+
+// selectNeighbors 从按距离排序的候选中选出至多 m 个邻居
+func (h *HNSWIndex) selectNeighbors(candidates []hnswCandidate, m int) []int32 {
+	if len(candidates) > m {
+		candidates = candidates[:m]
+	}
+	friends := make([]int32, len(candidates))
+	for i, c := range candidates {
+		friends[i] = c.idx
+	}
+	return friends
+}
+
+func (h *HNSWIndex) maxFriends(level int) int {
+	if level == 0 {
+		return 2 * h.cfg.M
+	}
+	return h.cfg.M
+}
+
+// greedy 从 top 层贪心下降到 bottom 层，返回最近的入口点
+func (h *HNSWIndex) greedy(q []float32, ep int32, top, bottom int) int32 {
+	dist := distance(q, h.nodes[ep].Vector)
+	for l := top; l >= bottom; l-- {
+		for changed := true; changed; {
+			changed = false
+			node := h.nodes[ep]
+			if l >= len(node.Friends) {
+				continue
+			}
+			for _, f := range node.Friends[l] {
+				if d := distance(q, h.nodes[f].Vector); d < dist {
+					ep, dist = f, d
+					changed = true
+				}
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer 在某一层做 ef 宽度的最佳优先搜索，结果按距离升序
+func (h *HNSWIndex) searchLayer(q []float32, ep int32, ef, level int) []hnswCandidate {
+	visited := map[int32]bool{ep: true}
+	first := hnswCandidate{idx: ep, dist: distance(q, h.nodes[ep].Vector)}
+	candidates := &minHeap{first}
+	results := &maxHeap{first}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(hnswCandidate)
+		if c.dist > (*results)[0].dist && results.Len() >= ef {
+			break
+		}
+		node := h.nodes[c.idx]
+		if level >= len(node.Friends) {
+			continue
+		}
+		for _, f := range node.Friends[level] {
+			if visited[f] {
+				continue
+			}
+			visited[f] = true
+			d := distance(q, h.nodes[f].Vector)
+			if results.Len() < ef || d < (*results)[0].dist {
+				heap.Push(candidates, hnswCandidate{idx: f, dist: d})
+				heap.Push(results, hnswCandidate{idx: f, dist: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	out := make([]hnswCandidate, results.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(results).(hnswCandidate)
+	}
+	return out
+}
+
+// rebuild 丢弃已删除的节点并重建图（必须持有锁）
+func (h *HNSWIndex) rebuild() {
+	nodes := h.nodes
+	h.nodes = nil
+	h.ids = make(map[string]int32, len(nodes)-h.deleted)
+	h.entry = -1
+	h.maxLevel = 0
+	h.deleted = 0
+	for _, node := range nodes {
+		if !node.Deleted {
+			h.insert(node.ID, node.Vector)
+		}
+	}
+}
+
+// hnswFile 索引的持久化格式
+type hnswFile struct {
+	Config   HNSWConfig
+	Nodes    []*hnswNode
+	Entry    int32
+	MaxLevel int
+	Dim      int
+}
+
+// Save 将索引写入文件（先写临时文件再替换）
+func (h *HNSWIndex) Save(path string) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = gob.NewEncoder(tmp).Encode(hnswFile{
+		Config:   h.cfg,
+		Nodes:    h.nodes,
+		Entry:    h.entry,
+		MaxLevel: h.maxLevel,
+		Dim:      h.dim,
+	})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write index: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadHNSWIndex 从文件读取索引
+func LoadHNSWIndex(path string) (*HNSWIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var data hnswFile
+	if err := gob.NewDecoder(f).Decode(&data); err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+
+	h := NewHNSWIndex(data.Config)
+	h.nodes = data.Nodes
+	h.entry = data.Entry
+	h.maxLevel = data.MaxLevel
+	h.dim = data.Dim
+	for i, node := range h.nodes {
+		if node.Deleted {
+			h.deleted++
+			continue
+		}
+		h.ids[node.ID] = int32(i)
+	}
+	return h, nil
+}
+
+// normalize 返回单位长度的向量副本
+func normalize(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	out := make([]float32, len(v))
+	if norm == 0 {
+		return out
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i, x := range v {
+		out[i] = x * scale
+	}
+	return out
+}
+
+// distance 余弦距离（输入已归一化）
+func distance(a, b []float32) float32 {
+	var dot float32
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return 1 - dot
+}
+
+// hnswCandidate 搜索中的候选节点
+type hnswCandidate struct {
+	idx  int32
+	dist float32
+}
+
+func sortCandidates(c []hnswCandidate) {
+	sort.Slice(c, func(i, j int) bool { return c[i].dist < c[j].dist })
+}
+
+// minHeap 距离最小的在堆顶
+type minHeap []hnswCandidate
+
+func (h minHeap) Len() int           { return len(h) }
+func (h minHeap) Less(i, j int) bool { return h[i].dist < h[j].dist }
+func (h minHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x any)        { *h = append(*h, x.(hnswCandidate)) }
+func (h *minHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// maxHeap 距离最大的在堆顶
+type maxHeap []hnswCandidate
+
+func (h maxHeap) Len() int           { return len(h) }
+func (h maxHeap) Less(i, j int) bool { return h[i].dist > h[j].dist }
+func (h maxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x any)        { *h = append(*h, x.(hnswCandidate)) }
+func (h *maxHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func randomVectors(n, dim int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dim)
+		for j := range vectors[i] {
+			vectors[i][j] = rng.Float32()*2 - 1
+		}
+	}
+	return vectors
+}
+
+func bruteForce(vectors [][]float32, query []float32, k int) []string {
+	type hit struct {
+		id  string
+		sim float64
+	}
+	hits := make([]hit, len(vectors))
+	for i, v := range vectors {
+		hits[i] = hit{id: fmt.Sprintf("m%d", i), sim: Similarity(v, query)}
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].sim > hits[j].sim })
+	ids := make([]string, k)
+	for i := range ids {
+		ids[i] = hits[i].id
+	}
+	return ids
+}
+
+func TestHNSWRecall(t *testing.T) {
+	vectors := randomVectors(2000, 32, 1)
+	index := NewHNSWIndex(DefaultHNSWConfig())
+	for i, v := range vectors {
+		if err := index.Add(fmt.Sprintf("m%d", i), v); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	const k = 10
+	found, total := 0, 0
+	for _, q := range randomVectors(50, 32, 2) {
+		want := make(map[string]bool)
+		for _, id := range bruteForce(vectors, q, k) {
+			want[id] = true
+		}
+		for _, r := range index.Search(q, k) {
+			if want[r.ID] {
+				found++
+			}
+		}
+		total += k
+	}
+
+	if recall := float64(found) / float64(total); recall < 0.9 {
+		t.Errorf("Expected recall >= 0.9, got %.2f", recall)
+	}
+}
+
+func TestHNSWRemoveAndReplace(t *testing.T) {
+	index := NewHNSWIndex(DefaultHNSWConfig())
+	index.Add("a", []float32{1, 0})
+	index.Add("b", []float32{0, 1})
+
+	index.Remove("a")
+	if index.Contains("a") || index.Len() != 1 {
+		t.Fatalf("Expected only b in index, got %d items", index.Len())
+	}
+	if results := index.Search([]float32{1, 0}, 2); len(results) != 1 || results[0].ID != "b" {
+		t.Errorf("Removed item should not be returned, got %+v", results)
+	}
+
+	// Adding an existing ID replaces its vector.
+	index.Add("b", []float32{1, 0})
+	results := index.Search([]float32{1, 0}, 1)
+	if len(results) != 1 || results[0].ID != "b" || results[0].Similarity < 0.99 {
+		t.Errorf("Expected replaced vector, got %+v", results)
+	}
+
+	if err := index.Add("c", []float32{1, 0, 0}); err == nil {
+		t.Error("Expected dimension mismatch error")
+	}
+}
+
+func TestHNSWSaveLoad(t *testing.T) {
+	vectors := randomVectors(200, 16, 3)
+	index := NewHNSWIndex(DefaultHNSWConfig())
+	for i, v := range vectors {
+		index.Add(fmt.Sprintf("m%d", i), v)
+	}
+	index.Remove("m0")
+
+	path := filepath.Join(t.TempDir(), "memory.hnsw")
+	if err := index.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadHNSWIndex(path)
+	if err != nil {
+		t.Fatalf("LoadHNSWIndex failed: %v", err)
+	}
+
+	if loaded.Len() != index.Len() || loaded.Contains("m0") {
+		t.Fatalf("Expected %d items without m0, got %d", index.Len(), loaded.Len())
+	}
+	want := index.Search(vectors[5], 5)
+	got := loaded.Search(vectors[5], 5)
+	if len(got) != len(want) || got[0].ID != "m5" {
+		t.Errorf("Loaded index returns %+v, want %+v", got, want)
+	}
+}
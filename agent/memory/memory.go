@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,6 +17,13 @@ type Manager struct {
 	config    Config
 	retriever *Retriever
 
+	// 语义检索（可选）
+	embeddings *EmbeddingService
+	semantic   *SemanticRetriever
+	index      *HNSWIndex
+	indexPath  string
+	reindexing *indexChanges // Reindex 运行期间的索引变更，否则为空
+
 	// 缓存
 	cache     map[string]*MemoryItem
 	cacheSize int
@@ -73,12 +81,19 @@ func (m *Manager) Save(item *MemoryItem) error {
 		item.SetTTL(typeTTL)
 	}
 
+	// 向量化可能访问网络，不持有锁
+	m.embedItem(item)
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	if err := m.store.Save(item); err != nil {
 		return fmt.Errorf("save to store: %w", err)
 	}
+	if m.index != nil && len(item.Embedding) > 0 {
+		_ = m.index.Add(item.ID, item.Embedding)
+		m.reindexing.add(item.ID, item.Embedding)
+	}
 
 	// 更新缓存
 	m.updateCache(item)
@@ -93,6 +108,20 @@ func (m *Manager) Save(item *MemoryItem) error {
 	return nil
 }
 
+// SaveIfNew 保存记忆项，除非已有内容相同（忽略大小写）的记忆，
+// 因为重复的记忆只会干扰之后的检索。已存在时不保存，返回已有的记忆项
+func (m *Manager) SaveIfNew(item *MemoryItem) (*MemoryItem, error) {
+	if item == nil {
+		return nil, fmt.Errorf("item cannot be nil")
+	}
+	content := strings.TrimSpace(item.Content)
+	existing, err := m.Search(content, 1)
+	if err == nil && len(existing) > 0 && strings.EqualFold(existing[0].Content, content) {
+		return existing[0], nil
+	}
+	return nil, m.Save(item)
+}
+
 // SaveSessionMemory 保存会话记忆
 func (m *Manager) SaveSessionMemory(sessionID string, content string, importance int) error {
 	item := NewMemoryItem(MemoryTypeSession, content)
@@ -103,10 +132,15 @@ func (m *Manager) SaveSessionMemory(sessionID string, content string, importance
 
 // SaveFact 保存事实知识
 func (m *Manager) SaveFact(fact string, tags []string) error {
+	return m.Save(NewFact(fact, tags))
+}
+
+// NewFact 创建事实记忆项
+func NewFact(fact string, tags []string) *MemoryItem {
 	item := NewMemoryItem(MemoryTypeFact, fact)
 	item.Importance = 7
 	item.Tags = tags
-	return m.Save(item)
+	return item
 }
 
 // SaveTask 保存任务记录
@@ -195,9 +229,39 @@ func (m *Manager) RetrieveImportant(limit int) ([]*MemoryItem, error) {
 
 // RetrieveForContext 为上下文获取相关记忆
 func (m *Manager) RetrieveForContext(context string, limit int) ([]*MemoryItem, error) {
+	if items := m.retrieveSemantic(context, nil, limit); len(items) > 0 {
+		return items, nil
+	}
 	return m.retriever.RetrieveForContext(context, limit)
 }
 
+// retrieveSemantic 语义检索；未启用或失败时返回空，由调用方退回关键词检索
+func (m *Manager) retrieveSemantic(query string, types []MemoryType, limit int) []*MemoryItem {
+	if m.semantic == nil || strings.TrimSpace(query) == "" {
+		return nil
+	}
+	items, err := m.semantic.RetrieveFiltered(query, types, limit)
+	if err != nil {
+		return nil
+	}
+	return items
+}
+
+// Recall 按查询和类型检索相关记忆；查询为空时返回最重要的记忆
+func (m *Manager) Recall(query string, types []MemoryType, limit int) ([]*MemoryItem, error) {
+	if strings.TrimSpace(query) == "" {
+		q := DefaultQuery()
+		q.Types = types
+		q.Limit = limit
+		q.OrderBy = OrderByImportance
+		return m.Query(q)
+	}
+	if items := m.retrieveSemantic(query, types, limit); len(items) > 0 {
+		return items, nil
+	}
+	return m.retriever.RetrieveRelevant(query, types, limit)
+}
+
 // RetrieveByType 按类型获取记忆
 func (m *Manager) RetrieveByType(memType MemoryType, limit int) ([]*MemoryItem, error) {
 	return m.retriever.RetrieveByType(memType, limit)
@@ -208,6 +272,60 @@ func (m *Manager) Search(keyword string, limit int) ([]*MemoryItem, error) {
 	return m.retriever.RetrieveByKeyword(keyword, limit)
 }
 
+// List 列出最近的记忆
+func (m *Manager) List(limit int) ([]*MemoryItem, error) {
+	q := DefaultQuery()
+	q.Limit = limit
+	return m.Query(q)
+}
+
+// PinnedTag 标记固定记忆的标签
+const PinnedTag = "pinned"
+
+// RetrievePinned 获取固定的记忆
+func (m *Manager) RetrievePinned(limit int) ([]*MemoryItem, error) {
+	return m.retriever.RetrieveByTags([]string{PinnedTag}, limit)
+}
+
+// Pin 固定记忆：最高重要性、永不过期，并总是加入上下文
+func (m *Manager) Pin(id string) error {
+	return m.setPinned(id, true)
+}
+
+// Unpin 取消固定记忆，恢复该类型的默认 TTL
+func (m *Manager) Unpin(id string) error {
+	return m.setPinned(id, false)
+}
+
+func (m *Manager) setPinned(id string, pinned bool) error {
+	item, err := m.store.Get(id)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return fmt.Errorf("memory not found: %s", id)
+	}
+
+	if pinned {
+		item.AddTag(PinnedTag)
+		item.Importance = 10
+		item.ExpiresAt = nil
+	} else {
+		item.RemoveTag(PinnedTag)
+		if ttl := m.policy.GetTTLForType(item.Type); ttl > 0 {
+			item.SetTTL(ttl)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.store.Save(item); err != nil {
+		return fmt.Errorf("save to store: %w", err)
+	}
+	m.updateCache(item)
+	return nil
+}
+
 // Delete 删除记忆
 func (m *Manager) Delete(id string) error {
 	m.mu.Lock()
@@ -215,6 +333,10 @@ func (m *Manager) Delete(id string) error {
 
 	// 删除缓存
 	delete(m.cache, id)
+	if m.index != nil {
+		m.index.Remove(id)
+		m.reindexing.remove(id)
+	}
 
 	if err := m.store.Delete(id); err != nil {
 		return err
@@ -371,11 +493,22 @@ func (m *Manager) GetStoreStats() (MemoryStats, error) {
 	return extendedStore.GetStats()
 }
 
-// Close 关闭管理器
+// Close 关闭管理器，并保存向量索引
 func (m *Manager) Close() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	return m.store.Close()
+
+	var indexErr error
+	if m.index != nil && m.indexPath != "" {
+		indexErr = m.index.Save(m.indexPath)
+	}
+	if err := m.store.Close(); err != nil {
+		return err
+	}
+	if indexErr != nil {
+		return fmt.Errorf("save index: %w", indexErr)
+	}
+	return nil
 }
 
 // CreateMemoryFromSession 从会话创建记忆
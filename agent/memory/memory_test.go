@@ -392,3 +392,112 @@ func TestDeleteExpired(t *testing.T) {
 		t.Error("Expired item should be deleted")
 	}
 }
+
+func TestPin(t *testing.T) {
+	tempDir := t.TempDir()
+	store, _ := NewSQLiteStore(tempDir+"/test.db", DefaultConfig())
+	defer store.Close()
+
+	mgr := NewManager(store, DefaultConfig())
+	defer mgr.Close()
+
+	mgr.SaveFact("Pinned fact", nil)
+	mgr.SaveFact("Other fact", nil)
+	items, _ := mgr.Search("Pinned", 1)
+	if len(items) != 1 {
+		t.Fatalf("Expected 1 item, got %d", len(items))
+	}
+
+	if err := mgr.Pin(items[0].ID); err != nil {
+		t.Fatalf("Pin failed: %v", err)
+	}
+
+	pinned, err := mgr.RetrievePinned(10)
+	if err != nil {
+		t.Fatalf("RetrievePinned failed: %v", err)
+	}
+	if len(pinned) != 1 || pinned[0].ExpiresAt != nil || pinned[0].Importance != 10 {
+		t.Fatalf("Expected pinned item without expiry, got %+v", pinned)
+	}
+
+	if err := mgr.Pin("missing"); err == nil {
+		t.Error("Pin should fail for unknown id")
+	}
+}
+
+func TestRetrieveForContextMatchesAnyKeyword(t *testing.T) {
+	tempDir := t.TempDir()
+	store, _ := NewSQLiteStore(tempDir+"/test.db", DefaultConfig())
+	defer store.Close()
+
+	mgr := NewManager(store, DefaultConfig())
+	defer mgr.Close()
+
+	mgr.SaveFact("Release builds use make release", nil)
+	mgr.SaveFact("Unrelated note", nil)
+
+	items, err := mgr.RetrieveForContext("how do I run the release", 5)
+	if err != nil {
+		t.Fatalf("RetrieveForContext failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Content != "Release builds use make release" {
+		t.Errorf("Expected the release fact, got %+v", items)
+	}
+}
+
+func TestRecallFiltersByType(t *testing.T) {
+	tempDir := t.TempDir()
+	store, _ := NewSQLiteStore(tempDir+"/test.db", DefaultConfig())
+	defer store.Close()
+
+	mgr := NewManager(store, DefaultConfig())
+	defer mgr.Close()
+
+	mgr.SaveFact("Release builds use make release", nil)
+	decision := NewMemoryItem(MemoryTypeDecision, "Release builds are signed in CI")
+	decision.Importance = 7
+	mgr.Save(decision)
+
+	items, err := mgr.Recall("release builds", []MemoryType{MemoryTypeDecision}, 5)
+	if err != nil {
+		t.Fatalf("Recall failed: %v", err)
+	}
+	if len(items) != 1 || items[0].ID != decision.ID {
+		t.Errorf("Expected only the decision, got %+v", items)
+	}
+
+	// An empty query returns the most important memories.
+	items, err = mgr.Recall("", nil, 5)
+	if err != nil {
+		t.Fatalf("Recall failed: %v", err)
+	}
+	if len(items) != 2 {
+		t.Errorf("Expected 2 items, got %d", len(items))
+	}
+}
+
+func TestSaveIfNewSkipsDuplicates(t *testing.T) {
+	tempDir := t.TempDir()
+	store, _ := NewSQLiteStore(tempDir+"/test.db", DefaultConfig())
+	defer store.Close()
+
+	mgr := NewManager(store, DefaultConfig())
+	defer mgr.Close()
+
+	first := NewFact("Release builds use make release", nil)
+	if existing, err := mgr.SaveIfNew(first); err != nil || existing != nil {
+		t.Fatalf("SaveIfNew = %v, %v; want a new memory", existing, err)
+	}
+	existing, err := mgr.SaveIfNew(NewFact("release builds use MAKE release ", nil))
+	if err != nil || existing == nil || existing.ID != first.ID {
+		t.Fatalf("SaveIfNew = %v, %v; want the existing memory %s", existing, err, first.ID)
+	}
+	if existing, err := mgr.SaveIfNew(NewFact("Release builds use make dist", nil)); err != nil || existing != nil {
+		t.Errorf("SaveIfNew = %v, %v; want a different fact saved", existing, err)
+	}
+
+	items, _ := mgr.Search("release", 10)
+	if len(items) != 2 {
+		t.Errorf("expected 2 memories, got %d", len(items))
+	}
+}
@@ -0,0 +1,202 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// OpenAIEmbedderConfig OpenAI 兼容向量化接口配置
+type OpenAIEmbedderConfig struct {
+	URL        string // 基础地址，如 https://api.openai.com/v1
+	Key        string
+	Model      string
+	Dimensions int // 0 表示使用模型默认维度
+	BatchSize  int // 每次请求的最大文本数
+	CacheSize  int // 缓存的向量数，0 表示不缓存
+	Timeout    time.Duration
+	HTTPClient *http.Client
+}
+
+const (
+	defaultEmbeddingBatchSize = 64
+	defaultEmbeddingTimeout   = 30 * time.Second
+)
+
+// OpenAIEmbedder 调用 OpenAI 兼容的 /embeddings 接口
+type OpenAIEmbedder struct {
+	endpoint   string
+	key        string
+	model      string
+	dimensions int          // 请求的维度，0 表示模型默认
+	dimension  atomic.Int64 // 实际返回的维度
+	batchSize  int
+	cache      *EmbeddingCache
+	httpClient *http.Client
+}
+
+// NewOpenAIEmbedder 创建 OpenAI 兼容向量化器
+func NewOpenAIEmbedder(cfg OpenAIEmbedderConfig) (*OpenAIEmbedder, error) {
+	if strings.TrimSpace(cfg.URL) == "" {
+		return nil, fmt.Errorf("embedding URL is required")
+	}
+	if strings.TrimSpace(cfg.Model) == "" {
+		return nil, fmt.Errorf("embedding model is required")
+	}
+
+	e := &OpenAIEmbedder{
+		endpoint:   strings.TrimRight(cfg.URL, "/") + "/embeddings",
+		key:        cfg.Key,
+		model:      cfg.Model,
+		dimensions: cfg.Dimensions,
+		batchSize:  cfg.BatchSize,
+		httpClient: cfg.HTTPClient,
+	}
+	if e.batchSize <= 0 {
+		e.batchSize = defaultEmbeddingBatchSize
+	}
+	e.dimension.Store(int64(cfg.Dimensions))
+	if cfg.CacheSize > 0 {
+		e.cache = NewEmbeddingCache(cfg.CacheSize)
+	}
+	if e.httpClient == nil {
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = defaultEmbeddingTimeout
+		}
+		e.httpClient = &http.Client{Timeout: timeout}
+	}
+	return e, nil
+}
+
+// Embed 将文本转换为向量
+func (e *OpenAIEmbedder) Embed(text string) ([]float64, error) {
+	vectors, err := e.EmbedBatch([]string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// EmbedBatch 批量向量化，已缓存的文本不再请求
+func (e *OpenAIEmbedder) EmbedBatch(texts []string) ([][]float64, error) {
+	results := make([][]float64, len(texts))
+	var missing []int
+	for i, text := range texts {
+		if e.cache != nil {
+			if vec, ok := e.cache.Get(text); ok {
+				results[i] = vec
+				continue
+			}
+		}
+		missing = append(missing, i)
+	}
+
+	for start := 0; start < len(missing); start += e.batchSize {
+		end := start + e.batchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		batch := make([]string, end-start)
+		for j, idx := range missing[start:end] {
+			batch[j] = texts[idx]
+		}
+
+		vectors, err := e.request(batch)
+		if err != nil {
+			return nil, err
+		}
+		for j, idx := range missing[start:end] {
+			results[idx] = vectors[j]
+			if e.cache != nil {
+				e.cache.Set(texts[idx], vectors[j])
+			}
+		}
+	}
+	return results, nil
+}
+
+// Dimension 返回向量维度；未配置时为首次请求得到的维度
+func (e *OpenAIEmbedder) Dimension() int {
+	return int(e.dimension.Load())
+}
+
+type embeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// request 发送一次 /embeddings 请求
+func (e *OpenAIEmbedder) request(texts []string) ([][]float64, error) {
+	body, err := json.Marshal(embeddingRequest{
+		Model:      e.model,
+		Input:      texts,
+		Dimensions: e.dimensions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal embedding request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.key != "" {
+		req.Header.Set("Authorization", "Bearer "+e.key)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read embedding response: %w", err)
+	}
+
+	var out embeddingResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("embedding request failed: %s", resp.Status)
+		}
+		return nil, fmt.Errorf("parse embedding response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if out.Error != nil && out.Error.Message != "" {
+			return nil, fmt.Errorf("embedding request failed: %s: %s", resp.Status, out.Error.Message)
+		}
+		return nil, fmt.Errorf("embedding request failed: %s", resp.Status)
+	}
+	if len(out.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding response has %d vectors for %d inputs", len(out.Data), len(texts))
+	}
+
+	vectors := make([][]float64, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(texts) || vectors[d.Index] != nil {
+			return nil, fmt.Errorf("embedding response has invalid index %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	e.dimension.Store(int64(len(vectors[0])))
+	return vectors, nil
+}
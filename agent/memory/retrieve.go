@@ -123,12 +123,23 @@ func (r *Retriever) RetrieveImportant(minImportance int, limit int) ([]*MemoryIt
 
 // RetrieveForContext 为上下文检索相关记忆
 func (r *Retriever) RetrieveForContext(context string, limit int) ([]*MemoryItem, error) {
+	return r.RetrieveRelevant(context, nil, limit)
+}
+
+// RetrieveRelevant 检索与文本相关的记忆，可按类型过滤
+func (r *Retriever) RetrieveRelevant(context string, types []MemoryType, limit int) ([]*MemoryItem, error) {
 	// 提取关键词（简单实现：按空格分割）
 	keywords := extractKeywords(context)
 
+	if len(keywords) == 0 {
+		return nil, nil
+	}
+
 	q := DefaultQuery()
+	q.Types = types
 	q.Keywords = keywords
-	q.Limit = limit * 2 // 获取更多，然后评分排序
+	q.AnyKeyword = true
+	q.Limit = limit * 4 // 获取更多，然后评分排序
 	q.OrderBy = OrderByImportance
 	q.OrderDesc = true
 
@@ -137,13 +148,16 @@ func (r *Retriever) RetrieveForContext(context string, limit int) ([]*MemoryItem
 		return nil, err
 	}
 
-	// 评分排序
-	scored := make([]scoredItem, len(items))
-	for i, item := range items {
-		scored[i] = scoredItem{
+	// 评分排序（跳过过期项）
+	scored := make([]scoredItem, 0, len(items))
+	for _, item := range items {
+		if item.IsExpired() {
+			continue
+		}
+		scored = append(scored, scoredItem{
 			item:  item,
 			score: r.calculateRelevance(item, context, keywords),
-		}
+		})
 	}
 
 	// 按相关性排序
@@ -198,44 +212,79 @@ func (r *Retriever) calculateRelevance(item *MemoryItem, context string, keyword
 	return score
 }
 
+// stopWords 检索时忽略的常见词
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "that": true,
+	"this": true, "from": true, "into": true, "are": true, "was": true,
+	"can": true, "you": true, "how": true, "what": true, "why": true,
+	"please": true, "should": true, "would": true, "could": true, "have": true,
+	"not": true, "all": true, "use": true, "make": true, "then": true,
+}
+
 // extractKeywords 提取关键词
 func extractKeywords(text string) []string {
-	// 简单实现：按空格分割，过滤短词
+	// 简单实现：按空格分割，过滤短词和常见词
 	words := strings.Fields(text)
+	seen := make(map[string]bool, len(words))
 	var keywords []string
 	for _, word := range words {
-		word = strings.Trim(word, ".,!?;:()[]{}\"'")
-		if len(word) >= 3 {
-			keywords = append(keywords, word)
+		word = strings.Trim(word, ".,!?;:()[]{}\"'`")
+		lower := strings.ToLower(word)
+		if len(word) < 3 || stopWords[lower] || seen[lower] {
+			continue
 		}
+		seen[lower] = true
+		keywords = append(keywords, word)
 	}
 	return keywords
 }
 
-// SemanticRetriever 语义检索器（预留接口）
+// SemanticRetriever 语义检索器
 type SemanticRetriever struct {
-	store     Store
-	embedder  Embedder
+	store         Store
+	embedder      Embedder
+	index         *HNSWIndex
+	minSimilarity float64
 }
 
 // NewSemanticRetriever 创建语义检索器
 func NewSemanticRetriever(store Store, embedder Embedder) *SemanticRetriever {
 	return &SemanticRetriever{
-		store:     store,
-		embedder:  embedder,
+		store:    store,
+		embedder: embedder,
 	}
 }
 
+// SetIndex 使用向量索引代替全表扫描
+func (sr *SemanticRetriever) SetIndex(index *HNSWIndex) {
+	sr.index = index
+}
+
+// SetMinSimilarity 设置结果的最低相似度
+func (sr *SemanticRetriever) SetMinSimilarity(min float64) {
+	sr.minSimilarity = min
+}
+
 // Retrieve 基于语义相似度检索
 func (sr *SemanticRetriever) Retrieve(query string, limit int) ([]*MemoryItem, error) {
+	return sr.RetrieveFiltered(query, nil, limit)
+}
+
+// RetrieveFiltered 基于语义相似度检索，可按类型过滤
+func (sr *SemanticRetriever) RetrieveFiltered(query string, types []MemoryType, limit int) ([]*MemoryItem, error) {
 	// 获取查询的 embedding
 	queryEmbedding, err := sr.embedder.Embed(query)
 	if err != nil {
 		return nil, err
 	}
 
-	// 获取所有记忆项（这里应该有一个更高效的实现）
+	if sr.index != nil {
+		return sr.retrieveIndexed(embeddingToFloat32(queryEmbedding), types, limit)
+	}
+
+	// 没有索引时全量扫描
 	q := DefaultQuery()
+	q.Types = types
 	q.Limit = 1000 // 获取大量数据然后排序
 
 	items, err := sr.store.Query(q)
@@ -251,9 +300,11 @@ func (sr *SemanticRetriever) Retrieve(query string, limit int) ([]*MemoryItem, e
 
 	scoredItems := make([]scoredItem, 0, len(items))
 	for _, item := range items {
-		if len(item.Embedding) > 0 {
+		if len(item.Embedding) > 0 && !item.IsExpired() {
 			sim := cosineSimilarity(queryEmbedding, embeddingToFloat64(item.Embedding))
-			scoredItems = append(scoredItems, scoredItem{item: item, similarity: sim})
+			if sim >= sr.minSimilarity {
+				scoredItems = append(scoredItems, scoredItem{item: item, similarity: sim})
+			}
 		}
 	}
 
@@ -274,6 +325,50 @@ func (sr *SemanticRetriever) Retrieve(query string, limit int) ([]*MemoryItem, e
 	return result, nil
 }
 
+// retrieveIndexed 通过向量索引检索，再从存储中取出记忆项
+func (sr *SemanticRetriever) retrieveIndexed(query []float32, types []MemoryType, limit int) ([]*MemoryItem, error) {
+	// 多取一些，过滤掉过期、已删除和类型不符的项
+	hits := sr.index.Search(query, limit*4)
+
+	var result []*MemoryItem
+	for _, hit := range hits {
+		if len(result) >= limit || hit.Similarity < sr.minSimilarity {
+			break
+		}
+		item, err := sr.store.Get(hit.ID)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil || item.IsExpired() || !typeAllowed(item.Type, types) {
+			continue
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// typeAllowed 判断类型是否在过滤列表中（空列表表示不过滤）
+func typeAllowed(t MemoryType, types []MemoryType) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, allowed := range types {
+		if t == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// embeddingToFloat32 将 float64 embedding 转换为 float32
+func embeddingToFloat32(emb []float64) []float32 {
+	result := make([]float32, len(emb))
+	for i, v := range emb {
+		result[i] = float32(v)
+	}
+	return result
+}
+
 // embeddingToFloat64 将 float32 embedding 转换为 float64
 func embeddingToFloat64(emb []float32) []float64 {
 	result := make([]float64, len(emb))
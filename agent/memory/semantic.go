@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"fmt"
+)
+
+// reindexBatchSize 重建索引时每批处理的记忆数
+const reindexBatchSize = 200
+
+// SemanticConfig 语义检索配置
+type SemanticConfig struct {
+	Embedder      Embedder
+	Index         *HNSWIndex // 为空时创建新索引
+	IndexPath     string     // 关闭时保存索引的位置，为空则不保存
+	MinSimilarity float64    // 低于该相似度的结果被忽略
+}
+
+// embeddingCounter 可统计带向量记忆数的存储
+type embeddingCounter interface {
+	CountEmbedded() (embedded, total int64, err error)
+}
+
+// SetSemantic 启用语义检索：保存时生成向量并加入索引，
+// 检索时优先按语义相似度，失败或无结果时退回关键词检索
+func (m *Manager) SetSemantic(cfg SemanticConfig) {
+	index := cfg.Index
+	if index == nil {
+		index = NewHNSWIndex(DefaultHNSWConfig())
+	}
+	semantic := NewSemanticRetriever(m.store, cfg.Embedder)
+	semantic.SetIndex(index)
+	semantic.SetMinSimilarity(cfg.MinSimilarity)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.embeddings = NewEmbeddingService(cfg.Embedder)
+	m.semantic = semantic
+	m.index = index
+	m.indexPath = cfg.IndexPath
+}
+
+// IndexStale 判断索引是否与存储不一致（缺少向量或索引项数不符）
+func (m *Manager) IndexStale() (bool, error) {
+	if m.index == nil {
+		return false, nil
+	}
+	counter, ok := m.store.(embeddingCounter)
+	if !ok {
+		return false, fmt.Errorf("store does not support embedding counts")
+	}
+	embedded, total, err := counter.CountEmbedded()
+	if err != nil {
+		return false, err
+	}
+	return embedded != total || int64(m.index.Len()) != embedded, nil
+}
+
+// indexChanges 记录重建索引期间保存和删除的记忆，替换索引前补到新索引上
+type indexChanges struct {
+	added   map[string][]float32
+	deleted map[string]bool
+}
+
+func (c *indexChanges) add(id string, vector []float32) {
+	if c == nil {
+		return
+	}
+	c.added[id] = vector
+	delete(c.deleted, id)
+}
+
+func (c *indexChanges) remove(id string) {
+	if c == nil {
+		return
+	}
+	c.deleted[id] = true
+	delete(c.added, id)
+}
+
+// Reindex 为缺少向量的记忆生成向量，并重建索引；返回索引的记忆数。
+// 重建期间保存或删除的记忆会补到新索引上，不会丢失
+func (m *Manager) Reindex() (int, error) {
+	if m.index == nil {
+		return 0, fmt.Errorf("semantic retrieval is not enabled")
+	}
+	extendedStore, ok := m.store.(ExtendedStore)
+	if !ok {
+		return 0, fmt.Errorf("store does not support listing")
+	}
+
+	m.mu.Lock()
+	if m.reindexing != nil {
+		m.mu.Unlock()
+		return 0, fmt.Errorf("reindex already running")
+	}
+	changes := &indexChanges{added: make(map[string][]float32), deleted: make(map[string]bool)}
+	m.reindexing = changes
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.reindexing = nil
+		m.mu.Unlock()
+	}()
+
+	index := NewHNSWIndex(m.index.cfg)
+	for offset := 0; ; offset += reindexBatchSize {
+		items, err := extendedStore.List(reindexBatchSize, offset)
+		if err != nil {
+			return 0, err
+		}
+		if err := m.embedMissing(items); err != nil {
+			return 0, err
+		}
+		for _, item := range items {
+			if len(item.Embedding) == 0 {
+				continue
+			}
+			if err := index.Add(item.ID, item.Embedding); err != nil {
+				return 0, fmt.Errorf("index %s: %w", item.ID, err)
+			}
+		}
+		if len(items) < reindexBatchSize {
+			break
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id := range changes.deleted {
+		index.Remove(id)
+	}
+	for id, vector := range changes.added {
+		if err := index.Add(id, vector); err != nil {
+			return 0, fmt.Errorf("index %s: %w", id, err)
+		}
+	}
+	m.index.Replace(index)
+	return index.Len(), nil
+}
+
+// embedMissing 批量为缺少向量的记忆生成向量并保存
+func (m *Manager) embedMissing(items []*MemoryItem) error {
+	var missing []*MemoryItem
+	var texts []string
+	for _, item := range items {
+		if len(item.Embedding) == 0 {
+			missing = append(missing, item)
+			texts = append(texts, item.Content)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	vectors, err := m.embeddings.embedder.EmbedBatch(texts)
+	if err != nil {
+		return fmt.Errorf("generate embeddings: %w", err)
+	}
+	// 持有锁保存，避免写回重建期间已删除的记忆
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, item := range missing {
+		item.Embedding = embeddingToFloat32(vectors[i])
+		if m.reindexing != nil && m.reindexing.deleted[item.ID] {
+			item.Embedding = nil
+			continue
+		}
+		if err := m.store.Save(item); err != nil {
+			return fmt.Errorf("save embedding: %w", err)
+		}
+	}
+	return nil
+}
+
+// embedItem 为记忆生成向量（如缺失）；失败时记忆仍会保存，由 Reindex 补齐
+func (m *Manager) embedItem(item *MemoryItem) {
+	if m.embeddings == nil || len(item.Embedding) > 0 {
+		return
+	}
+	_ = m.embeddings.GenerateEmbedding(item)
+}
@@ -204,8 +204,18 @@ func (s *SQLiteStore) Query(q Query) ([]*MemoryItem, error) {
 
 	// 关键词过滤（简单实现：在 content 中搜索）
 	if len(q.Keywords) > 0 {
-		for _, kw := range q.Keywords {
-			builder.add("content LIKE ?", "%"+kw+"%")
+		if q.AnyKeyword {
+			conds := make([]string, len(q.Keywords))
+			args := make([]any, len(q.Keywords))
+			for i, kw := range q.Keywords {
+				conds[i] = "content LIKE ?"
+				args[i] = "%" + kw + "%"
+			}
+			builder.add("("+strings.Join(conds, " OR ")+")", args...)
+		} else {
+			for _, kw := range q.Keywords {
+				builder.add("content LIKE ?", "%"+kw+"%")
+			}
 		}
 	}
 
@@ -304,6 +314,12 @@ func (s *SQLiteStore) Count() (int64, error) {
 	return count, err
 }
 
+// CountEmbedded 统计带向量的记忆项数和总数
+func (s *SQLiteStore) CountEmbedded() (embedded, total int64, err error) {
+	err = s.db.QueryRow("SELECT COUNT(embedding), COUNT(*) FROM memories").Scan(&embedded, &total)
+	return embedded, total, err
+}
+
 // CountByType 按类型统计记忆数量
 func (s *SQLiteStore) CountByType() (map[MemoryType]int64, error) {
 	rows, err := s.db.Query("SELECT type, COUNT(*) FROM memories GROUP BY type")
@@ -98,6 +98,16 @@ func (item *MemoryItem) AddTag(tag string) {
 	item.Tags = append(item.Tags, tag)
 }
 
+// HasTag reports whether the memory item has a tag.
+func (item *MemoryItem) HasTag(tag string) bool {
+	for _, t := range item.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
 // RemoveTag removes a tag from the memory item.
 func (item *MemoryItem) RemoveTag(tag string) {
 	for i, t := range item.Tags {
@@ -136,6 +146,7 @@ func (item *MemoryItem) RecordAccess() {
 type Query struct {
 	Types      []MemoryType
 	Keywords   []string
+	AnyKeyword bool // 任一关键词匹配即可（默认需全部匹配）
 	Tags       []string
 	Metadata   map[string]any
 	TimeRange  *TimeRange
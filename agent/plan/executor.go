@@ -3,8 +3,11 @@ package plan
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 )
 
 // PlanExecutor 计划执行器
@@ -13,6 +16,12 @@ type PlanExecutor struct {
 	callback     ModeCallback
 	permission   PermissionService
 	config       ExecutionConfig
+	store        *Store
+	onStoreError func(*Plan, error)
+
+	// mu guards plan and step state while steps run concurrently; it is
+	// not held while a step's tool executes.
+	mu sync.Mutex
 }
 
 // ToolRegistry 工具注册表接口
@@ -35,16 +44,24 @@ type PermissionService interface {
 // ExecutionConfig 执行配置
 type ExecutionConfig struct {
 	ContinueOnError bool
-	MaxRetries      int
-	TimeoutPerStep  int // seconds
+	// PauseOnError pauses the plan instead of failing it when a step fails
+	// or execution is cancelled, so it can be fixed up and resumed.
+	PauseOnError   bool
+	MaxRetries     int
+	TimeoutPerStep int // seconds
+	// MaxParallel bounds how many independent steps run at once.
+	// Values below 2 run steps one at a time.
+	MaxParallel int
 }
 
 // DefaultExecutionConfig 返回默认执行配置
 func DefaultExecutionConfig() ExecutionConfig {
 	return ExecutionConfig{
 		ContinueOnError: false,
+		PauseOnError:    true,
 		MaxRetries:      1,
 		TimeoutPerStep:  60,
+		MaxParallel:     4,
 	}
 }
 
@@ -74,52 +91,120 @@ func (e *PlanExecutor) SetPermissionService(ps PermissionService) {
 	e.permission = ps
 }
 
-// Execute 执行计划
+// SetStore persists the plan after every status transition so it can be
+// resumed after a restart. Save failures do not stop execution; they are
+// passed to onError when it is set.
+func (e *PlanExecutor) SetStore(store *Store, onError func(*Plan, error)) {
+	e.store = store
+	e.onStoreError = onError
+}
+
+// persist 保存计划当前状态
+func (e *PlanExecutor) persist(plan *Plan) {
+	if e.store == nil {
+		return
+	}
+	if err := e.store.Save(plan); err != nil && e.onStoreError != nil {
+		e.onStoreError(plan, err)
+	}
+}
+
+// Execute 执行计划。步骤按依赖关系组成 DAG 执行：依赖都已满足的步骤
+// 并行运行（最多 MaxParallel 个），失败步骤的下游步骤被标记为跳过，
+// 与之无关的分支继续执行。
 func (e *PlanExecutor) Execute(ctx context.Context, plan *Plan) error {
 	if !plan.IsExecutable() {
 		return fmt.Errorf("plan is not executable: %s", plan.Status)
 	}
+	if _, err := plan.TopologicalOrder(); err != nil {
+		return fmt.Errorf("plan is not executable: %w", err)
+	}
 
+	e.mu.Lock()
 	if plan.Status == PlanStatusApproved {
 		plan.Start()
 	}
+	e.persist(plan)
+	e.mu.Unlock()
 
 	// 通知开始
 	if err := e.callback.OnPlanApproved(plan); err != nil {
 		return fmt.Errorf("plan approval callback: %w", err)
 	}
 
-	// 执行每个步骤
-	for _, step := range plan.Steps {
-		// 检查上下文取消
-		if err := ctx.Err(); err != nil {
-			return fmt.Errorf("execution cancelled: %w", err)
-		}
+	limit := e.config.MaxParallel
+	if limit < 1 {
+		limit = 1
+	}
 
-		// 跳过已完成的步骤
-		if step.IsCompleted() {
-			continue
+	type stepDone struct {
+		step *PlanStep
+		err  error
+	}
+	done := make(chan stepDone)
+	attempted := make(map[string]bool, len(plan.Steps))
+	running := 0
+	var failedStep *PlanStep
+	var failedErr error
+
+	for {
+		e.mu.Lock()
+		if ctx.Err() == nil {
+			e.skipBrokenDependents(plan, attempted)
+			for _, step := range plan.Steps {
+				if running >= limit {
+					break
+				}
+				if attempted[step.ID] || !isRunnable(step) || !plan.CanExecuteStep(step) {
+					continue
+				}
+				attempted[step.ID] = true
+				running++
+				go func(step *PlanStep) {
+					done <- stepDone{step: step, err: e.executeStep(ctx, plan, step)}
+				}(step)
+			}
 		}
+		e.mu.Unlock()
 
-		// 跳过已跳过的步骤
-		if step.Status == StepStatusSkipped {
-			continue
+		if running == 0 {
+			break
+		}
+		d := <-done
+		running--
+		if d.err != nil && failedStep == nil {
+			failedStep, failedErr = d.step, d.err
 		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
-		// 检查依赖
-		if !plan.CanExecuteStep(step) {
-			step.Status = StepStatusBlocked
-			continue
+	// 检查上下文取消
+	if err := ctx.Err(); err != nil {
+		if e.config.PauseOnError {
+			plan.Pause()
+		} else {
+			plan.Cancel()
 		}
+		e.persist(plan)
+		return fmt.Errorf("execution cancelled: %w", err)
+	}
 
-		// 执行步骤
-		if err := e.executeStep(ctx, plan, step); err != nil {
-			step.Fail(err.Error())
-			if !e.config.ContinueOnError {
-				plan.Fail()
-				return fmt.Errorf("step %d failed: %w", step.Index, err)
-			}
+	if failedStep != nil {
+		switch {
+		case e.config.ContinueOnError:
+			plan.Fail()
+		case e.config.PauseOnError:
+			plan.Pause()
+		default:
+			plan.Fail()
+		}
+		e.persist(plan)
+		if e.config.ContinueOnError {
+			return nil
 		}
+		return fmt.Errorf("step %d failed: %w", failedStep.Index+1, failedErr)
 	}
 
 	// 检查是否全部完成
@@ -134,36 +219,85 @@ func (e *PlanExecutor) Execute(ctx context.Context, plan *Plan) error {
 	if allCompleted {
 		plan.Complete()
 	}
+	e.persist(plan)
 
 	return nil
 }
 
+// isRunnable 步骤尚未完成、未被跳过且未在执行中
+func isRunnable(step *PlanStep) bool {
+	switch step.Status {
+	case StepStatusPending, StepStatusFailed, StepStatusBlocked:
+		return true
+	default:
+		return false
+	}
+}
+
+// skipBrokenDependents 将依赖失败步骤的下游步骤标记为跳过，直到不再变化。
+// 失败步骤只有在本次执行中尝试过才算失败，否则它会被重新执行。
+// 调用方需持有 e.mu。
+func (e *PlanExecutor) skipBrokenDependents(plan *Plan, attempted map[string]bool) {
+	for changed := true; changed; {
+		changed = false
+		for _, step := range plan.Steps {
+			if attempted[step.ID] || !isRunnable(step) {
+				continue
+			}
+			for _, depID := range step.DependsOn {
+				dep := plan.GetStep(depID)
+				if dep == nil || !dependencyBroken(dep) {
+					continue
+				}
+				if dep.Status == StepStatusFailed && !attempted[dep.ID] {
+					continue
+				}
+				step.SkipDueTo(fmt.Sprintf("dependency step %d did not complete", dep.Index+1))
+				e.persist(plan)
+				changed = true
+				break
+			}
+		}
+	}
+}
+
 // executeStep 执行单个步骤
 func (e *PlanExecutor) executeStep(ctx context.Context, plan *Plan, step *PlanStep) error {
+	e.mu.Lock()
 	// 通知步骤开始
 	if err := e.callback.OnStepStarted(step, step.Index); err != nil {
+		step.Fail(err.Error())
+		e.persist(plan)
+		e.mu.Unlock()
 		return err
 	}
-
 	step.Start()
+	e.persist(plan)
+	tool, params, description := step.Tool, step.ToolParams, step.Description
+	e.mu.Unlock()
 
 	var result string
 	var err error
 
 	// 如果有指定工具，使用工具执行
-	if step.Tool != "" {
-		result, err = e.executeTool(ctx, step.Tool, step.ToolParams)
+	if tool != "" {
+		result, err = e.executeTool(ctx, tool, params)
 	} else {
 		// 没有指定工具，返回描述作为结果
-		result = step.Description
+		result = description
 	}
 
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	if err != nil {
 		step.Fail(err.Error())
+		e.persist(plan)
 		return err
 	}
 
 	step.Complete(result)
+	e.persist(plan)
 
 	// 通知步骤完成
 	if cbErr := e.callback.OnStepCompleted(step, step.Index, result); cbErr != nil {
@@ -218,8 +352,11 @@ func (e *PlanExecutor) SkipStep(plan *Plan, stepIndex int) error {
 		return fmt.Errorf("step index out of range: %d", stepIndex)
 	}
 
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	step := plan.Steps[stepIndex]
 	step.Skip()
+	e.persist(plan)
 	return nil
 }
 
@@ -229,6 +366,14 @@ func (e *PlanExecutor) Resume(ctx context.Context, plan *Plan) error {
 		return fmt.Errorf("plan is not paused: %s", plan.Status)
 	}
 
+	// 因依赖失败而跳过的步骤在恢复后重新等待依赖
+	for _, step := range plan.Steps {
+		if step.Status == StepStatusSkipped && step.SkipReason != "" {
+			step.Status = StepStatusPending
+			step.SkipReason = ""
+			step.CompletedAt = nil
+		}
+	}
 	plan.Resume()
 	return e.Execute(ctx, plan)
 }
@@ -298,25 +443,89 @@ type StepExecutionResult struct {
 	Duration int64 // milliseconds
 }
 
+// stepResultJSON is the serialized form of StepExecutionResult. The step is
+// referenced by index so a stored report can be relinked to its plan.
+type stepResultJSON struct {
+	StepIndex int    `json:"step_index"`
+	Success   bool   `json:"success"`
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Duration  int64  `json:"duration_ms"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r StepExecutionResult) MarshalJSON() ([]byte, error) {
+	out := stepResultJSON{
+		StepIndex: -1,
+		Success:   r.Success,
+		Result:    r.Result,
+		Duration:  r.Duration,
+	}
+	if r.Step != nil {
+		out.StepIndex = r.Step.Index
+	}
+	if r.Error != nil {
+		out.Error = r.Error.Error()
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Step is left nil until the
+// report is relinked to its plan.
+func (r *StepExecutionResult) UnmarshalJSON(data []byte) error {
+	var in stepResultJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*r = StepExecutionResult{
+		Step:     &PlanStep{Index: in.StepIndex},
+		Success:  in.Success,
+		Result:   in.Result,
+		Duration: in.Duration,
+	}
+	if in.Error != "" {
+		r.Error = errors.New(in.Error)
+	}
+	return nil
+}
+
 // ExecutionReport 执行报告
 type ExecutionReport struct {
-	Plan         *Plan
-	Results      []StepExecutionResult
-	StartTime    int64
-	EndTime      int64
-	TotalSteps   int
-	SuccessSteps int
-	FailedSteps  int
-	SkippedSteps int
+	Plan         *Plan                 `json:"-"`
+	PlanID       string                `json:"plan_id"`
+	Results      []StepExecutionResult `json:"results"`
+	StartTime    int64                 `json:"start_time,omitempty"` // unix milliseconds
+	EndTime      int64                 `json:"end_time,omitempty"`   // unix milliseconds
+	TotalSteps   int                   `json:"total_steps"`
+	SuccessSteps int                   `json:"success_steps"`
+	FailedSteps  int                   `json:"failed_steps"`
+	SkippedSteps int                   `json:"skipped_steps"`
+	CriticalPath []int                 `json:"critical_path,omitempty"` // step indexes
 }
 
 // GenerateReport 生成执行报告
 func (e *PlanExecutor) GenerateReport(plan *Plan) *ExecutionReport {
+	return NewExecutionReport(plan)
+}
+
+// NewExecutionReport 根据计划当前状态生成执行报告
+func NewExecutionReport(plan *Plan) *ExecutionReport {
 	report := &ExecutionReport{
 		Plan:       plan,
+		PlanID:     plan.ID,
 		Results:    make([]StepExecutionResult, 0, len(plan.Steps)),
 		TotalSteps: len(plan.Steps),
 	}
+	if plan.StartedAt != nil {
+		report.StartTime = plan.StartedAt.UnixMilli()
+	}
+	if plan.CompletedAt != nil {
+		report.EndTime = plan.CompletedAt.UnixMilli()
+	}
+
+	for _, step := range plan.CriticalPath() {
+		report.CriticalPath = append(report.CriticalPath, step.Index)
+	}
 
 	for _, step := range plan.Steps {
 		result := StepExecutionResult{
@@ -328,6 +537,7 @@ func (e *PlanExecutor) GenerateReport(plan *Plan) *ExecutionReport {
 		if step.Error != "" {
 			result.Error = fmt.Errorf("%s", step.Error)
 		}
+		result.Duration = step.Duration().Milliseconds()
 
 		report.Results = append(report.Results, result)
 
@@ -344,6 +554,20 @@ func (e *PlanExecutor) GenerateReport(plan *Plan) *ExecutionReport {
 	return report
 }
 
+// link 将反序列化的报告重新关联到计划及其步骤
+func (r *ExecutionReport) link(plan *Plan) {
+	r.Plan = plan
+	for i := range r.Results {
+		index := -1
+		if r.Results[i].Step != nil {
+			index = r.Results[i].Step.Index
+		}
+		if step := plan.GetStepByIndex(index); step != nil {
+			r.Results[i].Step = step
+		}
+	}
+}
+
 // ReportToJSON 将报告转换为 JSON
 func (r *ExecutionReport) ToJSON() (string, error) {
 	data, err := json.MarshalIndent(r, "", "  ")
@@ -388,6 +612,29 @@ func (r *ExecutionReport) ToMarkdown() string {
 			fmt.Fprintf(&sb, "   - Error: %s\n", result.Error.Error())
 		}
 
+		if result.Step.SkipReason != "" {
+			fmt.Fprintf(&sb, "   - Skipped: %s\n", result.Step.SkipReason)
+		}
+
+		fmt.Fprintf(&sb, "\n")
+	}
+
+	if len(r.CriticalPath) > 1 {
+		var total time.Duration
+		names := make([]string, 0, len(r.CriticalPath))
+		for _, index := range r.CriticalPath {
+			names = append(names, fmt.Sprintf("Step %d", index+1))
+			if r.Plan != nil {
+				if step := r.Plan.GetStepByIndex(index); step != nil {
+					total += step.Duration()
+				}
+			}
+		}
+		fmt.Fprintf(&sb, "## Critical Path\n\n")
+		fmt.Fprintf(&sb, "%s", strings.Join(names, " → "))
+		if total > 0 {
+			fmt.Fprintf(&sb, " (%s)", total.Round(time.Millisecond))
+		}
 		fmt.Fprintf(&sb, "\n")
 	}
 
@@ -0,0 +1,118 @@
+package plan
+
+import (
+	"fmt"
+	"time"
+)
+
+// TopologicalOrder 按依赖关系排序步骤，依赖在前。
+// 同一层级内保持原有顺序；依赖缺失或存在环时返回错误。
+func (p *Plan) TopologicalOrder() ([]*PlanStep, error) {
+	indegree := make(map[string]int, len(p.Steps))
+	dependents := make(map[string][]*PlanStep, len(p.Steps))
+	for _, step := range p.Steps {
+		indegree[step.ID] += 0
+		for _, depID := range step.DependsOn {
+			if p.GetStep(depID) == nil {
+				return nil, fmt.Errorf("step %s depends on non-existent step %s", step.ID, depID)
+			}
+			indegree[step.ID]++
+			dependents[depID] = append(dependents[depID], step)
+		}
+	}
+
+	order := make([]*PlanStep, 0, len(p.Steps))
+	queue := make([]*PlanStep, 0, len(p.Steps))
+	for _, step := range p.Steps {
+		if indegree[step.ID] == 0 {
+			queue = append(queue, step)
+		}
+	}
+	for len(queue) > 0 {
+		step := queue[0]
+		queue = queue[1:]
+		order = append(order, step)
+		for _, next := range dependents[step.ID] {
+			indegree[next.ID]--
+			if indegree[next.ID] == 0 {
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	if len(order) != len(p.Steps) {
+		return nil, fmt.Errorf("plan has a dependency cycle")
+	}
+	return order, nil
+}
+
+// CriticalPath 返回耗时最长的依赖链。
+// 已执行步骤按实际耗时计算；耗时相同时取步骤最多的链。
+func (p *Plan) CriticalPath() []*PlanStep {
+	order, err := p.TopologicalOrder()
+	if err != nil || len(order) == 0 {
+		return nil
+	}
+
+	type node struct {
+		total time.Duration
+		steps int
+		prev  *PlanStep
+	}
+	longest := make(map[string]node, len(order))
+
+	var end *PlanStep
+	for _, step := range order {
+		best := node{}
+		for _, depID := range step.DependsOn {
+			dep := longest[depID]
+			if dep.total > best.total || (dep.total == best.total && dep.steps > best.steps) {
+				best = node{total: dep.total, steps: dep.steps, prev: p.GetStep(depID)}
+			}
+		}
+		best.total += step.Duration()
+		best.steps++
+		longest[step.ID] = best
+
+		if end == nil {
+			end = step
+			continue
+		}
+		cur := longest[end.ID]
+		if best.total > cur.total || (best.total == cur.total && best.steps > cur.steps) {
+			end = step
+		}
+	}
+
+	path := make([]*PlanStep, longest[end.ID].steps)
+	for i, step := len(path)-1, end; step != nil; i, step = i-1, longest[step.ID].prev {
+		path[i] = step
+	}
+	return path
+}
+
+// Duration 返回步骤的执行耗时，未执行完时为 0
+func (s *PlanStep) Duration() time.Duration {
+	if s.StartedAt == nil || s.CompletedAt == nil {
+		return 0
+	}
+	return s.CompletedAt.Sub(*s.StartedAt)
+}
+
+// dependencySatisfied 依赖步骤已完成，或被用户手动跳过
+func dependencySatisfied(dep *PlanStep) bool {
+	switch dep.Status {
+	case StepStatusCompleted:
+		return true
+	case StepStatusSkipped:
+		return dep.SkipReason == ""
+	default:
+		return false
+	}
+}
+
+// dependencyBroken 依赖步骤失败，或因上游失败被跳过
+func dependencyBroken(dep *PlanStep) bool {
+	return dep.Status == StepStatusFailed ||
+		(dep.Status == StepStatusSkipped && dep.SkipReason != "")
+}
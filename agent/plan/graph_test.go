@@ -0,0 +1,159 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vigo999/ms-cli/test/mocks"
+)
+
+func TestPlannerParsesDependencies(t *testing.T) {
+	provider := mocks.NewMockProvider()
+	provider.AddResponse(`[
+		{"id": "fetch", "description": "Fetch data"},
+		{"description": "Read config"},
+		{"description": "Build", "depends_on": ["fetch", 2]},
+		{"description": "Test", "depends_on": ["Step 3", 4, 99]}
+	]`)
+
+	p, err := NewPlanner(provider, DefaultPlannerConfig()).GeneratePlan(context.Background(), "ship it", nil)
+	if err != nil {
+		t.Fatalf("GeneratePlan failed: %v", err)
+	}
+
+	if got := p.Steps[2].DependsOn; len(got) != 2 || got[0] != p.Steps[0].ID || got[1] != p.Steps[1].ID {
+		t.Errorf("step 3 depends on %v, want steps 1 and 2", got)
+	}
+	// Self and unknown references are dropped.
+	if got := p.Steps[3].DependsOn; len(got) != 1 || got[0] != p.Steps[2].ID {
+		t.Errorf("step 4 depends on %v, want step 3", got)
+	}
+}
+
+func TestPlanValidateRejectsCycles(t *testing.T) {
+	plan := NewPlan("Test")
+	a := plan.AddStep("A")
+	b := plan.AddStep("B")
+	a.DependsOn = []string{b.ID}
+	b.DependsOn = []string{a.ID}
+
+	if err := plan.Validate(); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+}
+
+func TestPlanExecutorRunsIndependentStepsInParallel(t *testing.T) {
+	// Both branches must be running at the same time to pass the barrier.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	barrier := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(barrier)
+	}()
+
+	registry := NewSimpleToolRegistry()
+	registry.Register(NewSimpleTool("branch", func(ctx context.Context, params map[string]any) (string, error) {
+		wg.Done()
+		select {
+		case <-barrier:
+			return "ok", nil
+		case <-time.After(2 * time.Second):
+			return "", errors.New("branches did not run in parallel")
+		}
+	}))
+	registry.Register(NewSimpleTool("join", func(ctx context.Context, params map[string]any) (string, error) {
+		return "joined", nil
+	}))
+
+	plan := NewPlan("Test")
+	left := plan.AddStepWithTool("Left", "branch", nil)
+	right := plan.AddStepWithTool("Right", "branch", nil)
+	join := plan.AddStepWithTool("Join", "join", nil)
+	join.DependsOn = []string{left.ID, right.ID}
+	plan.Approve()
+
+	executor := NewPlanExecutor(registry, &DefaultModeCallback{}, DefaultExecutionConfig())
+	if err := executor.Execute(context.Background(), plan); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if plan.Status != PlanStatusCompleted || join.Result != "joined" {
+		t.Fatalf("expected join to run after both branches, got %s / %q", plan.Status, join.Result)
+	}
+}
+
+func TestPlanExecutorSkipsDependentsOfFailedStep(t *testing.T) {
+	registry := NewSimpleToolRegistry()
+	registry.Register(NewSimpleTool("ok", func(ctx context.Context, params map[string]any) (string, error) {
+		return "done", nil
+	}))
+	registry.Register(NewSimpleTool("broken", func(ctx context.Context, params map[string]any) (string, error) {
+		return "", errors.New("boom")
+	}))
+
+	plan := NewPlan("Test")
+	build := plan.AddStepWithTool("Build", "broken", nil)
+	test := plan.AddStepWithTool("Test", "ok", nil)
+	deploy := plan.AddStepWithTool("Deploy", "ok", nil)
+	docs := plan.AddStepWithTool("Docs", "ok", nil)
+	test.DependsOn = []string{build.ID}
+	deploy.DependsOn = []string{test.ID}
+	plan.Approve()
+
+	executor := NewPlanExecutor(registry, &DefaultModeCallback{}, DefaultExecutionConfig())
+	if err := executor.Execute(context.Background(), plan); err == nil {
+		t.Fatal("expected failing step to be reported")
+	}
+
+	if plan.Status != PlanStatusPaused {
+		t.Errorf("Expected status Paused, got %s", plan.Status)
+	}
+	if docs.Status != StepStatusCompleted {
+		t.Errorf("independent step should still run, got %s", docs.Status)
+	}
+	for _, step := range []*PlanStep{test, deploy} {
+		if step.Status != StepStatusSkipped || step.SkipReason == "" {
+			t.Errorf("%s: expected skip due to failed dependency, got %s %q", step.Description, step.Status, step.SkipReason)
+		}
+	}
+
+	// Skipping the failed step by hand lets its dependents run on resume.
+	if err := executor.SkipStep(plan, build.Index); err != nil {
+		t.Fatalf("SkipStep failed: %v", err)
+	}
+	if err := executor.Resume(context.Background(), plan); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if plan.Status != PlanStatusCompleted || deploy.Status != StepStatusCompleted {
+		t.Errorf("expected dependents to run after resume, got %s / %s", plan.Status, deploy.Status)
+	}
+}
+
+func TestExecutionReportShowsCriticalPath(t *testing.T) {
+	plan := NewPlan("Test")
+	a := plan.AddStep("A")
+	b := plan.AddStep("B")
+	c := plan.AddStep("C")
+	c.DependsOn = []string{a.ID, b.ID}
+
+	start := time.Now()
+	for step, d := range map[*PlanStep]time.Duration{a: time.Second, b: 3 * time.Second, c: time.Second} {
+		begin, end := start, start.Add(d)
+		step.Status = StepStatusCompleted
+		step.StartedAt, step.CompletedAt = &begin, &end
+	}
+
+	path := plan.CriticalPath()
+	if len(path) != 2 || path[0] != b || path[1] != c {
+		t.Fatalf("expected critical path B → C, got %v", path)
+	}
+
+	md := NewExecutionReport(plan).ToMarkdown()
+	if !strings.Contains(md, "## Critical Path") || !strings.Contains(md, "Step 2 → Step 3 (4s)") {
+		t.Errorf("report missing critical path:\n%s", md)
+	}
+}
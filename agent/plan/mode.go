@@ -1,6 +1,7 @@
 package plan
 
 import (
+	"context"
 	"strings"
 )
 
@@ -63,6 +64,7 @@ func DefaultModeConfig() ModeConfig {
 // PlanModeConfig 计划模式配置
 type PlanModeConfig struct {
 	RequireApproval bool // 是否需要用户批准计划
+	MaxRevisions    int  // 拒绝后最多修订次数
 	MaxSteps        int  // 最大计划步骤数
 	AllowEdit       bool // 允许用户编辑计划
 	AutoExecute     bool // 批准后自动执行
@@ -73,6 +75,7 @@ type PlanModeConfig struct {
 func DefaultPlanModeConfig() PlanModeConfig {
 	return PlanModeConfig{
 		RequireApproval: true,
+		MaxRevisions:    5,
 		MaxSteps:        10,
 		AllowEdit:       true,
 		AutoExecute:     true,
@@ -132,6 +135,21 @@ type ModeCallback interface {
 	OnStepNeedsConfirmation(step *PlanStep, index int) (bool, error)
 }
 
+// ApprovalDecision 用户对计划的审批结果
+type ApprovalDecision struct {
+	Approved bool
+	// Feedback explains a rejection. A rejection with feedback asks for a
+	// revised plan; one without cancels the task.
+	Feedback string
+}
+
+// PlanApprover 阻塞等待用户审批计划
+type PlanApprover interface {
+	// AwaitApproval blocks until the user approves or rejects the plan, or
+	// ctx is done.
+	AwaitApproval(ctx context.Context, plan *Plan) (ApprovalDecision, error)
+}
+
 // DefaultModeCallback 默认模式回调
 type DefaultModeCallback struct{}
 
@@ -16,6 +16,8 @@ const (
 	PlanStatusPendingApproval PlanStatus = "pending_approval"
 	// PlanStatusApproved 已批准
 	PlanStatusApproved PlanStatus = "approved"
+	// PlanStatusRejected 已拒绝
+	PlanStatusRejected PlanStatus = "rejected"
 	// PlanStatusRunning 执行中
 	PlanStatusRunning PlanStatus = "running"
 	// PlanStatusPaused 暂停
@@ -48,31 +50,32 @@ const (
 
 // Plan 执行计划
 type Plan struct {
-	ID          string
-	Goal        string
-	Description string
-	Steps       []*PlanStep
-	Status      PlanStatus
-	CreatedAt   time.Time
-	StartedAt   *time.Time
-	CompletedAt *time.Time
-	Metadata    map[string]any
+	ID          string         `json:"id"`
+	Goal        string         `json:"goal"`
+	Description string         `json:"description,omitempty"`
+	Steps       []*PlanStep    `json:"steps"`
+	Status      PlanStatus     `json:"status"`
+	CreatedAt   time.Time      `json:"created_at"`
+	StartedAt   *time.Time     `json:"started_at,omitempty"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+	Metadata    map[string]any `json:"metadata,omitempty"`
 }
 
 // PlanStep 计划步骤
 type PlanStep struct {
-	ID          string
-	Index       int
-	Description string
-	Tool        string         // 可选：指定工具
-	ToolParams  map[string]any // 工具参数
-	DependsOn   []string       // 依赖的其他步骤 ID
-	Status      StepStatus
-	Result      string
-	Error       string
-	StartedAt   *time.Time
-	CompletedAt *time.Time
-	Metadata    map[string]any
+	ID          string         `json:"id"`
+	Index       int            `json:"index"`
+	Description string         `json:"description"`
+	Tool        string         `json:"tool,omitempty"`        // 可选：指定工具
+	ToolParams  map[string]any `json:"tool_params,omitempty"` // 工具参数
+	DependsOn   []string       `json:"depends_on,omitempty"`  // 依赖的其他步骤 ID
+	Status      StepStatus     `json:"status"`
+	Result      string         `json:"result,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	SkipReason  string         `json:"skip_reason,omitempty"` // 非空表示因依赖失败而跳过
+	StartedAt   *time.Time     `json:"started_at,omitempty"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+	Metadata    map[string]any `json:"metadata,omitempty"`
 }
 
 // NewPlan 创建新计划
@@ -171,6 +174,29 @@ func (p *Plan) Approve() {
 	p.Status = PlanStatusApproved
 }
 
+// Reject 拒绝计划
+func (p *Plan) Reject() {
+	p.Status = PlanStatusRejected
+}
+
+// Retry 重新批准已失败或已取消的计划：失败、中断和因依赖跳过的步骤
+// 恢复为等待执行，已完成和被用户跳过的步骤保持不变
+func (p *Plan) Retry() {
+	for _, step := range p.Steps {
+		switch {
+		case step.Status == StepStatusFailed, step.Status == StepStatusRunning, step.Status == StepStatusBlocked,
+			step.Status == StepStatusSkipped && step.SkipReason != "":
+			step.Status = StepStatusPending
+			step.Error = ""
+			step.SkipReason = ""
+			step.StartedAt = nil
+			step.CompletedAt = nil
+		}
+	}
+	p.CompletedAt = nil
+	p.Approve()
+}
+
 // IsExecutable 检查计划是否可执行
 func (p *Plan) IsExecutable() bool {
 	return p.Status == PlanStatusApproved || p.Status == PlanStatusRunning
@@ -206,15 +232,11 @@ func (p *Plan) GetProgress() float64 {
 	return float64(completed) / float64(len(p.Steps)) * 100
 }
 
-// CanExecuteStep 检查步骤是否可以执行
+// CanExecuteStep 检查步骤的依赖是否都已满足
 func (p *Plan) CanExecuteStep(step *PlanStep) bool {
-	// 检查依赖
 	for _, depID := range step.DependsOn {
 		dep := p.GetStep(depID)
-		if dep == nil {
-			return false
-		}
-		if dep.Status != StepStatusCompleted {
+		if dep == nil || !dependencySatisfied(dep) {
 			return false
 		}
 	}
@@ -230,13 +252,9 @@ func (p *Plan) Validate() error {
 		return fmt.Errorf("plan must have at least one step")
 	}
 
-	// 检查步骤依赖是否有效
-	for _, step := range p.Steps {
-		for _, depID := range step.DependsOn {
-			if p.GetStep(depID) == nil {
-				return fmt.Errorf("step %s depends on non-existent step %s", step.ID, depID)
-			}
-		}
+	// 检查步骤依赖是否有效且无环
+	if _, err := p.TopologicalOrder(); err != nil {
+		return err
 	}
 
 	return nil
@@ -280,6 +298,15 @@ func (p *Plan) ToMarkdown() string {
 		if step.Tool != "" {
 			fmt.Fprintf(&sb, "   - Tool: `%s`\n", step.Tool)
 		}
+		if len(step.DependsOn) > 0 {
+			deps := make([]string, 0, len(step.DependsOn))
+			for _, depID := range step.DependsOn {
+				if dep := p.GetStep(depID); dep != nil {
+					deps = append(deps, fmt.Sprintf("%d", dep.Index+1))
+				}
+			}
+			fmt.Fprintf(&sb, "   - Depends on: %s\n", strings.Join(deps, ", "))
+		}
 		if step.Result != "" {
 			fmt.Fprintf(&sb, "   - Result: %s\n", step.Result)
 		}
@@ -294,6 +321,8 @@ func (p *Plan) ToMarkdown() string {
 // Start 开始执行步骤
 func (s *PlanStep) Start() {
 	s.Status = StepStatusRunning
+	s.Error = ""
+	s.SkipReason = ""
 	now := time.Now()
 	s.StartedAt = &now
 }
@@ -317,10 +346,17 @@ func (s *PlanStep) Fail(err string) {
 // Skip 跳过步骤
 func (s *PlanStep) Skip() {
 	s.Status = StepStatusSkipped
+	s.SkipReason = ""
 	now := time.Now()
 	s.CompletedAt = &now
 }
 
+// SkipDueTo 因依赖失败跳过步骤；依赖它的步骤也不会执行
+func (s *PlanStep) SkipDueTo(reason string) {
+	s.Skip()
+	s.SkipReason = reason
+}
+
 // IsPending 检查步骤是否等待执行
 func (s *PlanStep) IsPending() bool {
 	return s.Status == StepStatusPending
@@ -352,11 +388,14 @@ Create a plan with the following format:
 2. Specify which tool to use for each step (if applicable)
 3. Steps should be in logical order
 4. Keep the plan concise (3-10 steps)
+5. List in "depends_on" the numbers of the steps that must finish first;
+   steps without dependencies on each other may run in parallel
 
 Format your response as a JSON array of steps:
 [
   {"description": "Step 1 description", "tool": "tool_name"},
-  {"description": "Step 2 description", "tool": "tool_name"}
+  {"description": "Step 2 description", "tool": "tool_name"},
+  {"description": "Step 3 description", "tool": "tool_name", "depends_on": [1, 2]}
 ]`, goal, toolList)
 }
 
@@ -82,9 +82,11 @@ func (p *Planner) parsePlan(goal, content string) (*Plan, error) {
 
 	// 解析 JSON
 	var steps []struct {
-		Description string         `json:"description"`
-		Tool        string         `json:"tool"`
-		Params      map[string]any `json:"params,omitempty"`
+		ID          any               `json:"id,omitempty"`
+		Description string            `json:"description"`
+		Tool        string            `json:"tool"`
+		Params      map[string]any    `json:"params,omitempty"`
+		DependsOn   []json.RawMessage `json:"depends_on,omitempty"`
 	}
 
 	if err := json.Unmarshal([]byte(jsonStr), &steps); err != nil {
@@ -97,8 +99,9 @@ func (p *Planner) parsePlan(goal, content string) (*Plan, error) {
 		steps = steps[:p.config.MaxSteps]
 	}
 
-	// 创建步骤
-	for _, s := range steps {
+	// 创建步骤；步骤可以用序号（从 1 开始）或自定义 id 引用
+	refs := make(map[string]*PlanStep, len(steps)*2)
+	for i, s := range steps {
 		step := plan.AddStep(s.Description)
 		if s.Tool != "" {
 			step.Tool = s.Tool
@@ -106,11 +109,50 @@ func (p *Planner) parsePlan(goal, content string) (*Plan, error) {
 		if s.Params != nil {
 			step.ToolParams = s.Params
 		}
+		refs[fmt.Sprintf("%d", i+1)] = step
+		if s.ID != nil {
+			refs[strings.ToLower(strings.TrimSpace(fmt.Sprint(s.ID)))] = step
+		}
+	}
+
+	// 解析依赖；无法识别的引用（如被截断的步骤）会被忽略
+	for i, s := range steps {
+		step := plan.Steps[i]
+		for _, raw := range s.DependsOn {
+			dep := refs[stepRef(raw)]
+			if dep == nil || dep == step || containsString(step.DependsOn, dep.ID) {
+				continue
+			}
+			step.DependsOn = append(step.DependsOn, dep.ID)
+		}
 	}
 
 	return plan, nil
 }
 
+// stepRef 将 depends_on 中的数字或字符串规范化为引用键
+func stepRef(raw json.RawMessage) string {
+	var n float64
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return fmt.Sprintf("%d", int(n))
+	}
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		str = strings.ToLower(strings.TrimSpace(str))
+		return strings.TrimSpace(strings.TrimPrefix(str, "step "))
+	}
+	return ""
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 // parsePlanFromLines 从文本行解析计划
 func (p *Planner) parsePlanFromLines(goal, content string) (*Plan, error) {
 	plan := NewPlan(goal)
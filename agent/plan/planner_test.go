@@ -2,6 +2,7 @@ package plan
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 
@@ -376,6 +377,93 @@ func TestPlanExecutor(t *testing.T) {
 	}
 }
 
+func TestPlanExecutorPausesAndResumesAfterSkip(t *testing.T) {
+	registry := NewSimpleToolRegistry()
+	registry.Register(NewSimpleTool("ok", func(ctx context.Context, params map[string]any) (string, error) {
+		return "done", nil
+	}))
+	registry.Register(NewSimpleTool("broken", func(ctx context.Context, params map[string]any) (string, error) {
+		return "", errors.New("boom")
+	}))
+
+	executor := NewPlanExecutor(registry, &DefaultModeCallback{}, DefaultExecutionConfig())
+
+	plan := NewPlan("Test")
+	plan.AddStepWithTool("Step 1", "ok", nil)
+	plan.AddStepWithTool("Step 2", "broken", nil)
+	plan.AddStepWithTool("Step 3", "ok", nil)
+	plan.Approve()
+
+	if err := executor.Execute(context.Background(), plan); err == nil {
+		t.Fatal("expected failing step to stop execution")
+	}
+	if plan.Status != PlanStatusPaused {
+		t.Fatalf("Expected status Paused, got %s", plan.Status)
+	}
+
+	if err := executor.SkipStep(plan, 1); err != nil {
+		t.Fatalf("SkipStep failed: %v", err)
+	}
+	if err := executor.Resume(context.Background(), plan); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if plan.Status != PlanStatusCompleted {
+		t.Errorf("Expected status Completed, got %s", plan.Status)
+	}
+	if plan.Steps[1].Status != StepStatusSkipped || plan.Steps[2].Status != StepStatusCompleted {
+		t.Errorf("unexpected step statuses: %s, %s", plan.Steps[1].Status, plan.Steps[2].Status)
+	}
+}
+
+func TestPlanRetryRerunsUnfinishedSteps(t *testing.T) {
+	fail := true
+	registry := NewSimpleToolRegistry()
+	registry.Register(NewSimpleTool("ok", func(ctx context.Context, params map[string]any) (string, error) {
+		return "done", nil
+	}))
+	registry.Register(NewSimpleTool("flaky", func(ctx context.Context, params map[string]any) (string, error) {
+		if fail {
+			return "", errors.New("boom")
+		}
+		return "done", nil
+	}))
+
+	config := DefaultExecutionConfig()
+	config.PauseOnError = false
+	executor := NewPlanExecutor(registry, &DefaultModeCallback{}, config)
+
+	plan := NewPlan("Test")
+	first := plan.AddStepWithTool("Step 1", "ok", nil)
+	flaky := plan.AddStepWithTool("Step 2", "flaky", nil)
+	last := plan.AddStepWithTool("Step 3", "ok", nil)
+	last.DependsOn = []string{flaky.ID}
+	skipped := plan.AddStepWithTool("Step 4", "ok", nil)
+	skipped.Skip()
+	plan.Approve()
+
+	if err := executor.Execute(context.Background(), plan); err == nil {
+		t.Fatal("expected the flaky step to fail")
+	}
+	if plan.Status != PlanStatusFailed || last.Status != StepStatusSkipped {
+		t.Fatalf("unexpected state after failure: plan %s, step 3 %s", plan.Status, last.Status)
+	}
+
+	fail = false
+	plan.Retry()
+	if plan.Status != PlanStatusApproved || flaky.Status != StepStatusPending || last.Status != StepStatusPending {
+		t.Fatalf("Retry should reset unfinished steps: plan %s, step 2 %s, step 3 %s", plan.Status, flaky.Status, last.Status)
+	}
+	if first.Status != StepStatusCompleted || skipped.Status != StepStatusSkipped {
+		t.Errorf("Retry should keep finished steps: step 1 %s, step 4 %s", first.Status, skipped.Status)
+	}
+	if err := executor.Execute(context.Background(), plan); err != nil {
+		t.Fatalf("Execute after Retry failed: %v", err)
+	}
+	if plan.Status != PlanStatusCompleted || last.Status != StepStatusCompleted {
+		t.Errorf("expected the retried plan to complete, got plan %s, step 3 %s", plan.Status, last.Status)
+	}
+}
+
 func TestModeConfig(t *testing.T) {
 	cfg := DefaultModeConfig()
 
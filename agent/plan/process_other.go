@@ -0,0 +1,11 @@
+//go:build !unix
+
+package plan
+
+import "os"
+
+// processAlive 判断 pid 对应的进程是否存在；无法判断时视为存在
+func processAlive(pid int) bool {
+	_, err := os.FindProcess(pid)
+	return err == nil
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package plan
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive 判断 pid 对应的进程是否存在
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
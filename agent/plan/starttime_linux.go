@@ -0,0 +1,33 @@
+package plan
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processStartTime 返回进程的启动时间（自系统启动以来的时钟滴答数），
+// 用于识别被复用的 PID；无法读取时返回 0
+func processStartTime(pid int) uint64 {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0
+	}
+	// 进程名可能包含空格和括号，从最后一个 ')' 之后开始数字段：
+	// 第一个是 state（第 3 个字段），starttime 是第 22 个字段
+	stat := string(data)
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0
+	}
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 20 {
+		return 0
+	}
+	start, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return start
+}
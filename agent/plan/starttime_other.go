@@ -0,0 +1,8 @@
+//go:build !linux
+
+package plan
+
+// processStartTime 无法读取进程启动时间时返回 0，只按 PID 判断
+func processStartTime(pid int) uint64 {
+	return 0
+}
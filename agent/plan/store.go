@@ -0,0 +1,226 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record 持久化的计划记录
+type Record struct {
+	Plan      *Plan            `json:"plan"`
+	Report    *ExecutionReport `json:"report"`
+	Owner     *Owner           `json:"owner,omitempty"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// Owner 最后保存计划的进程
+type Owner struct {
+	PID     int    `json:"pid"`
+	Host    string `json:"host"`
+	Started uint64 `json:"started,omitempty"` // 进程启动时间，见 processStartTime
+}
+
+// alive 判断所有者进程是否仍在运行。其他主机上的进程无法检查，视为仍在运行；
+// 没有记录所有者的旧计划视为已退出。记录了启动时间时，PID 相同但启动时间
+// 不同的进程是复用了该 PID 的新进程（容器中常见的 PID 1 也是如此）
+func (o *Owner) alive() bool {
+	if o == nil || o.PID <= 0 {
+		return false
+	}
+	if host, _ := os.Hostname(); o.Host != host {
+		return true
+	}
+	if o.PID != os.Getpid() && !processAlive(o.PID) {
+		return false
+	}
+	if o.Started != 0 {
+		if started := processStartTime(o.PID); started != 0 && started != o.Started {
+			return false
+		}
+	}
+	return true
+}
+
+// draftTTL 是由 /plan 生成但从未执行的草稿的保留时间
+const draftTTL = 7 * 24 * time.Hour
+
+// Store 将计划保存为 <dir>/<id>.json，使计划在进程重启后可以恢复
+type Store struct {
+	dir   string
+	owner Owner // 写入每条记录，用于区分其他进程仍在执行的计划
+	mu    sync.Mutex
+}
+
+// NewStore 创建计划存储
+func NewStore(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("plan store directory is required")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create plan store directory: %w", err)
+	}
+	host, _ := os.Hostname()
+	owner := Owner{PID: os.Getpid(), Host: host, Started: processStartTime(os.Getpid())}
+	return &Store{dir: dir, owner: owner}, nil
+}
+
+// Dir 返回存储目录
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save 保存计划及其当前执行报告
+func (s *Store) Save(p *Plan) error {
+	if p == nil || p.ID == "" {
+		return fmt.Errorf("plan id is required")
+	}
+
+	owner := s.owner
+	data, err := json.MarshalIndent(Record{
+		Plan:      p,
+		Report:    NewExecutionReport(p),
+		Owner:     &owner,
+		UpdatedAt: time.Now(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal plan: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 先写临时文件再重命名，避免崩溃时留下半个文件
+	path := s.path(p.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write plan: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write plan: %w", err)
+	}
+	return nil
+}
+
+// Load 加载计划记录
+func (s *Store) Load(id string) (*Record, error) {
+	s.mu.Lock()
+	data, err := os.ReadFile(s.path(id))
+	s.mu.Unlock()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("plan not found: %s", id)
+		}
+		return nil, fmt.Errorf("read plan: %w", err)
+	}
+
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("parse plan %s: %w", id, err)
+	}
+	if rec.Plan == nil {
+		return nil, fmt.Errorf("parse plan %s: missing plan", id)
+	}
+	if rec.Plan.Metadata == nil {
+		rec.Plan.Metadata = make(map[string]any)
+	}
+	for _, step := range rec.Plan.Steps {
+		if step.Metadata == nil {
+			step.Metadata = make(map[string]any)
+		}
+	}
+	if rec.Report == nil {
+		rec.Report = NewExecutionReport(rec.Plan)
+	}
+	rec.Report.link(rec.Plan)
+	return &rec, nil
+}
+
+// List 返回所有计划记录，最近更新的在前。无法解析的文件会被跳过。
+func (s *Store) List() ([]*Record, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read plan store: %w", err)
+	}
+
+	records := make([]*Record, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		rec, err := s.Load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].UpdatedAt.After(records[j].UpdatedAt)
+	})
+	return records, nil
+}
+
+// Unfinished 返回可恢复的计划，最近更新的在前。
+// 状态仍为 running 且所有者进程已退出的计划说明进程在执行中途退出：
+// 它们会被标记为 paused，执行到一半的步骤重置为 pending，然后写回存储。
+// 其他进程仍在执行的计划不会返回。超过 draftTTL 未执行的草稿会被删除。
+func (s *Store) Unfinished() ([]*Plan, error) {
+	records, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []*Plan
+	for _, rec := range records {
+		p := rec.Plan
+		switch p.Status {
+		case PlanStatusRunning:
+			if rec.Owner.alive() {
+				continue
+			}
+			for _, step := range p.Steps {
+				if step.Status == StepStatusRunning {
+					step.Status = StepStatusPending
+					step.StartedAt = nil
+				}
+			}
+			p.Pause()
+			if err := s.Save(p); err != nil {
+				return nil, err
+			}
+		case PlanStatusPaused, PlanStatusApproved:
+		case PlanStatusDraft:
+			if time.Since(rec.UpdatedAt) > draftTTL {
+				if err := s.Delete(p.ID); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		default:
+			continue
+		}
+		plans = append(plans, p)
+	}
+	return plans, nil
+}
+
+// Delete 删除计划
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete plan: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+".json")
+}
@@ -0,0 +1,252 @@
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStorePersistsPlanAfterEachStep(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), ".mscli", "plans"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	registry := NewSimpleToolRegistry()
+	registry.Register(NewSimpleTool("ok", func(ctx context.Context, params map[string]any) (string, error) {
+		return "done", nil
+	}))
+	registry.Register(NewSimpleTool("broken", func(ctx context.Context, params map[string]any) (string, error) {
+		return "", errors.New("boom")
+	}))
+
+	executor := NewPlanExecutor(registry, &DefaultModeCallback{}, DefaultExecutionConfig())
+	executor.SetStore(store, func(p *Plan, err error) {
+		t.Errorf("persist %s: %v", p.ID, err)
+	})
+
+	plan := NewPlan("Test")
+	plan.AddStepWithTool("Step 1", "ok", nil)
+	plan.AddStepWithTool("Step 2", "broken", nil)
+	plan.Approve()
+
+	if err := executor.Execute(context.Background(), plan); err == nil {
+		t.Fatal("expected failing step to stop execution")
+	}
+	if _, err := os.Stat(filepath.Join(store.Dir(), plan.ID+".json")); err != nil {
+		t.Fatalf("plan file not written: %v", err)
+	}
+
+	rec, err := store.Load(plan.ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if rec.Plan.Status != PlanStatusPaused {
+		t.Errorf("Expected status Paused, got %s", rec.Plan.Status)
+	}
+	if got := rec.Plan.Steps[0]; got.Status != StepStatusCompleted || got.Result != "done" {
+		t.Errorf("step 1 not recorded: %s %q", got.Status, got.Result)
+	}
+	if got := rec.Plan.Steps[1]; got.Status != StepStatusFailed || got.Error != "boom" {
+		t.Errorf("step 2 not recorded: %s %q", got.Status, got.Error)
+	}
+
+	report := rec.Report
+	if report.PlanID != plan.ID || report.SuccessSteps != 1 || report.FailedSteps != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if report.Plan != rec.Plan || report.Results[1].Step != rec.Plan.Steps[1] {
+		t.Error("report should be linked to the loaded plan")
+	}
+	if report.Results[1].Error == nil || report.Results[1].Error.Error() != "boom" {
+		t.Errorf("step error not restored: %v", report.Results[1].Error)
+	}
+}
+
+func TestStoreUnfinishedRecoversInterruptedPlans(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	// A plan saved mid-step by a process that has since crashed.
+	interrupted := NewPlan("Interrupted")
+	interrupted.AddStep("Step 1").Complete("done")
+	interrupted.AddStep("Step 2").Start()
+	interrupted.Approve()
+	interrupted.Start()
+	crashed := &Store{dir: store.dir, owner: Owner{PID: exitedPID(t), Host: store.owner.Host}}
+	if err := crashed.Save(interrupted); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	finished := NewPlan("Finished")
+	finished.AddStep("Step 1").Complete("done")
+	finished.Complete()
+	if err := store.Save(finished); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	plans, err := store.Unfinished()
+	if err != nil {
+		t.Fatalf("Unfinished failed: %v", err)
+	}
+	if len(plans) != 1 || plans[0].ID != interrupted.ID {
+		t.Fatalf("expected only the interrupted plan, got %d", len(plans))
+	}
+	p := plans[0]
+	if p.Status != PlanStatusPaused || p.Steps[1].Status != StepStatusPending {
+		t.Fatalf("expected paused plan with step 2 pending, got %s/%s", p.Status, p.Steps[1].Status)
+	}
+
+	executor := NewPlanExecutor(NewSimpleToolRegistry(), &DefaultModeCallback{}, DefaultExecutionConfig())
+	executor.SetStore(store, nil)
+	if err := executor.Resume(context.Background(), p); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	rec, err := store.Load(p.ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if rec.Plan.Status != PlanStatusCompleted || rec.Report.SuccessSteps != 2 {
+		t.Errorf("resumed plan not saved as completed: %s, %d steps", rec.Plan.Status, rec.Report.SuccessSteps)
+	}
+}
+
+func TestStoreUnfinishedSkipsPlansOfLiveProcesses(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	// Running in this process.
+	running := NewPlan("Running here")
+	running.AddStep("Step 1").Start()
+	running.Approve()
+	running.Start()
+	if err := store.Save(running); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Running in a process on another host, which cannot be checked.
+	remote := NewPlan("Running elsewhere")
+	remote.AddStep("Step 1").Start()
+	remote.Approve()
+	remote.Start()
+	other := &Store{dir: store.dir, owner: Owner{PID: exitedPID(t), Host: store.owner.Host + "-other"}}
+	if err := other.Save(remote); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	plans, err := store.Unfinished()
+	if err != nil {
+		t.Fatalf("Unfinished failed: %v", err)
+	}
+	if len(plans) != 0 {
+		t.Fatalf("expected no resumable plans, got %d", len(plans))
+	}
+	for _, p := range []*Plan{running, remote} {
+		rec, err := store.Load(p.ID)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if rec.Plan.Status != PlanStatusRunning || rec.Plan.Steps[0].Status != StepStatusRunning {
+			t.Errorf("plan %q of a live process was changed to %s", p.Goal, rec.Plan.Status)
+		}
+	}
+}
+
+func TestStoreUnfinishedDetectsReusedPID(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if store.owner.Started == 0 {
+		t.Skip("process start times are not available on this platform")
+	}
+
+	// Saved by an earlier process that had this process's PID, as happens
+	// with PID 1 in a restarted container.
+	p := NewPlan("Crashed with a reused PID")
+	p.AddStep("Step 1").Start()
+	p.Approve()
+	p.Start()
+	earlier := &Store{dir: store.dir, owner: store.owner}
+	earlier.owner.Started--
+	if err := earlier.Save(p); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	plans, err := store.Unfinished()
+	if err != nil {
+		t.Fatalf("Unfinished failed: %v", err)
+	}
+	if len(plans) != 1 || plans[0].Status != PlanStatusPaused {
+		t.Fatalf("expected the plan of the earlier process to be paused for resume, got %d", len(plans))
+	}
+}
+
+func TestStoreUnfinishedExpiresDrafts(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	fresh, stale := NewPlan("Fresh draft"), NewPlan("Stale draft")
+	for _, p := range []*Plan{fresh, stale} {
+		if err := store.Save(p); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	old := time.Now().Add(-draftTTL - time.Hour)
+	rewriteUpdatedAt(t, store, stale.ID, old)
+
+	plans, err := store.Unfinished()
+	if err != nil {
+		t.Fatalf("Unfinished failed: %v", err)
+	}
+	if len(plans) != 0 {
+		t.Errorf("drafts are not resumable, got %d", len(plans))
+	}
+	if _, err := store.Load(stale.ID); err == nil {
+		t.Error("the stale draft should be deleted")
+	}
+	if _, err := store.Load(fresh.ID); err != nil {
+		t.Errorf("the fresh draft should be kept: %v", err)
+	}
+}
+
+// rewriteUpdatedAt changes the saved time of a plan record.
+func rewriteUpdatedAt(t *testing.T, store *Store, id string, at time.Time) {
+	t.Helper()
+	data, err := os.ReadFile(store.path(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec map[string]any
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatal(err)
+	}
+	rec["updated_at"] = at
+	if data, err = json.Marshal(rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(store.path(id), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// exitedPID returns the PID of a process that has exited.
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("run test binary: %v", err)
+	}
+	return cmd.Process.Pid
+}
@@ -152,6 +152,13 @@ func (m *Manager) SetCurrent(id ID) error {
 	return nil
 }
 
+// UnsetCurrent 取消当前会话（会话本身仍保留在存储中）
+func (m *Manager) UnsetCurrent() {
+	m.mu.Lock()
+	m.current = nil
+	m.mu.Unlock()
+}
+
 // CreateAndSetCurrent 创建并设置为当前会话
 func (m *Manager) CreateAndSetCurrent(name, workDir string) (*Session, error) {
 	session, err := m.Create(name, workDir)
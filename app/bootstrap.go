@@ -3,19 +3,29 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/vigo999/ms-cli/agent/context"
+	"github.com/vigo999/ms-cli/agent/context/bpe"
+	"github.com/vigo999/ms-cli/agent/cost"
 	"github.com/vigo999/ms-cli/agent/loop"
+	"github.com/vigo999/ms-cli/agent/memory"
+	"github.com/vigo999/ms-cli/agent/plan"
+	"github.com/vigo999/ms-cli/agent/session"
 	"github.com/vigo999/ms-cli/configs"
 	"github.com/vigo999/ms-cli/executor"
 	"github.com/vigo999/ms-cli/integrations/llm"
+	"github.com/vigo999/ms-cli/integrations/llm/anthropic"
+	"github.com/vigo999/ms-cli/integrations/llm/local"
 	openai "github.com/vigo999/ms-cli/integrations/llm/openai"
 	"github.com/vigo999/ms-cli/permission"
 	"github.com/vigo999/ms-cli/tools"
+	"github.com/vigo999/ms-cli/tools/artifact"
 	"github.com/vigo999/ms-cli/tools/fs"
+	memorytools "github.com/vigo999/ms-cli/tools/memory"
 	"github.com/vigo999/ms-cli/tools/shell"
 	"github.com/vigo999/ms-cli/trace"
 	"github.com/vigo999/ms-cli/ui/model"
@@ -28,6 +38,8 @@ type BootstrapConfig struct {
 	URL        string // Override API URL from config
 	Model      string // Override model from config
 	Key        string // Override API key from config
+	Resume     bool   // Resume a saved session
+	ResumeID   string // Session to resume; empty means the most recent one
 }
 
 // Bootstrap wires top-level dependencies.
@@ -87,14 +99,73 @@ func Bootstrap(cfg BootstrapConfig) (*Application, error) {
 		}, nil
 	}
 
-	// Initialize LLM provider
-	provider, err := initProvider(config.Model)
+	// Initialize per-session trajectory writer.
+	traceWriter, err := trace.NewTimestampWriter(filepath.Join(workDir, ".cache"))
+	if err != nil {
+		return nil, fmt.Errorf("init trace writer: %w", err)
+	}
+
+	// Initialize LLM provider with its retry policy and fallbacks
+	provider, err := initProviderChain(config.Model, traceWriter)
 	if err != nil {
 		return nil, fmt.Errorf("init provider: %w", err)
 	}
 
+	// Facts learned in earlier sessions are recalled for related tasks.
+	var memManager *memory.Manager
+	if config.Memory.Enabled {
+		memManager, err = initMemory(config.Memory, workDir)
+		if err != nil {
+			// Memory is optional; run without it.
+			fmt.Fprintf(os.Stderr, "Warning: failed to open memory: %v\n", err)
+		} else if config.Memory.Embedding.Enabled {
+			if err := initSemanticMemory(memManager, config, workDir, traceWriter); err != nil {
+				// Recall falls back to keyword search.
+				fmt.Fprintf(os.Stderr, "Warning: semantic memory disabled: %v\n", err)
+			}
+		}
+	}
+
+	// Oversized tool outputs are saved per session and previewed in the context.
+	var artifacts *artifact.Store
+	if config.Execution.SpillBytes > 0 {
+		artifacts = artifact.NewStore(artifact.Config{
+			Dir:       filepath.Join(workDir, ".cache", "artifacts"),
+			Threshold: config.Execution.SpillBytes,
+		})
+	}
+
+	// Shell commands run on the host, in a sandbox or in a container
+	shellBackend, err := initShellBackend(config.Execution, workDir)
+	if err != nil {
+		return nil, fmt.Errorf("init %s execution: %w", config.Execution.Mode, err)
+	}
+	if docker, ok := shellBackend.(*shell.DockerBackend); ok {
+		// Earlier runs that were killed leave their containers behind.
+		go func() {
+			n, err := docker.RemoveStale()
+			if err != nil {
+				_ = traceWriter.Write("container_error", map[string]any{"stage": "remove_stale", "error": err.Error()})
+			}
+			if n > 0 {
+				_ = traceWriter.Write("container_cleanup", map[string]any{"removed": n})
+			}
+		}()
+	}
+
+	shellRunner := shell.NewRunner(shell.Config{
+		WorkDir:        workDir,
+		Timeout:        time.Duration(config.Execution.TimeoutSec) * time.Second,
+		AllowedCmds:    config.Permissions.AllowedTools,
+		BlockedCmds:    config.Permissions.BlockedTools,
+		RequireConfirm: []string{"rm", "mv", "cp"},
+		MaxOutputBytes: shellOutputBytes(artifacts),
+		Backend:        shellBackend,
+		Persistent:     config.Execution.ShellSession,
+	})
+
 	// Initialize tool registry
-	toolRegistry := initTools(config, workDir)
+	toolRegistry := initTools(config, workDir, memManager, artifacts, shellRunner)
 
 	// Initialize context manager
 	ctxManager := context.NewManager(context.ManagerConfig{
@@ -102,13 +173,17 @@ func Bootstrap(cfg BootstrapConfig) (*Application, error) {
 		ReserveTokens:       config.Context.ReserveTokens,
 		CompactionThreshold: config.Context.CompactionThreshold,
 		MaxHistoryRounds:    config.Context.MaxHistoryRounds,
+		EnableSmartCompact:  true,
+		CompactStrategy:     context.ParseCompactStrategy(config.Context.CompactStrategy),
 	})
-
-	// Initialize per-session trajectory writer.
-	traceWriter, err := trace.NewTimestampWriter(filepath.Join(workDir, ".cache"))
-	if err != nil {
-		return nil, fmt.Errorf("init trace writer: %w", err)
+	// Without a vocabulary, token counts fall back to the calibrated
+	// estimate; the TUI repeats the warning once it starts.
+	counter, tokenizerErr := tokenCounter(config.Context, config.Model.Model, workDir)
+	if tokenizerErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: tokenizer unavailable: %v\n", tokenizerErr)
+		_ = traceWriter.Write("tokenizer_fallback", map[string]any{"error": tokenizerErr.Error()})
 	}
+	ctxManager.SetTokenCounter(counter)
 
 	// Initialize engine
 	// MaxIterations = 0 means no limit (user can interrupt with Ctrl+C)
@@ -117,62 +192,382 @@ func Bootstrap(cfg BootstrapConfig) (*Application, error) {
 		MaxTokens:      config.Budget.MaxTokens,
 		Temperature:    float32(config.Model.Temperature),
 		TimeoutPerTurn: time.Duration(config.Model.TimeoutSec) * time.Second,
+		Stream:         config.Model.Stream,
+		MaxConcurrency: config.Execution.MaxConcurrency,
+		MemoryTopK:     config.Memory.TopK,
+		MemoryBudget:   config.Memory.BudgetTokens,
+		MemoryExtract:  config.Memory.Extract,
 	}
 	engine := loop.NewEngine(engineCfg, provider, toolRegistry)
 	engine.SetContextManager(ctxManager)
 	engine.SetTraceWriter(traceWriter)
+	if memManager != nil {
+		engine.SetMemory(memManager)
+	}
+	if artifacts != nil {
+		engine.SetArtifactStore(artifacts)
+	}
+
+	// Initialize cost accounting; daily spend is shared by sessions in this project.
+	costTracker, err := initCostTracker(config, workDir)
+	if err != nil {
+		return nil, fmt.Errorf("init cost tracker: %w", err)
+	}
+	engine.SetCostTracker(costTracker)
+
+	// Plans are saved after every step so they survive a restart.
+	planStore, err := plan.NewStore(filepath.Join(workDir, ".mscli", "plans"))
+	if err != nil {
+		return nil, fmt.Errorf("init plan store: %w", err)
+	}
+	engine.SetPlanStore(planStore)
 
-	// Initialize permission service (default allow for now)
+	// Tool calls that need approval are asked about in the TUI. Remembered
+	// decisions come from the user-level and project-level files, the
+	// project winning; "always allow" answers go to the project file.
 	permService := permission.NewDefaultPermissionService(config.Permissions)
+	permStore, err := permission.NewLayeredPermissionStore(
+		filepath.Join(workDir, ".mscli", "permissions.json"), userPermissionsPath())
+	if err != nil {
+		return nil, fmt.Errorf("init permission store: %w", err)
+	}
+	permService.SetStore(permStore)
+	permRules, err := permission.LoadRules(filepath.Join(workDir, ".mscli", "permissions.yaml"), workDir)
+	if err != nil {
+		return nil, fmt.Errorf("load permission rules: %w", err)
+	}
+	permService.SetRules(permRules)
 	engine.SetPermissionService(permService)
 
-	return &Application{
+	app := &Application{
 		Engine:       engine,
 		EventCh:      make(chan model.Event, 64),
 		Demo:         false,
 		WorkDir:      workDir,
 		RepoURL:      "github.com/vigo999/ms-cli",
 		Config:       config,
+		provider:     provider,
 		toolRegistry: toolRegistry,
 		ctxManager:   ctxManager,
 		permService:  permService,
+		permStore:    permStore,
 		stateManager: stateManager,
 		traceWriter:  traceWriter,
-	}, nil
+		costTracker:  costTracker,
+		planStore:    planStore,
+		memory:       memManager,
+		artifacts:    artifacts,
+		shellBackend: shellBackend,
+		shellRunner:  shellRunner,
+		tokenizerErr: tokenizerErr,
+	}
+	engine.SetEventSink(app.forwardEvent)
+
+	// Every exchange is saved to a session so it can be resumed later.
+	sessionCfg := session.DefaultConfig()
+	sessionCfg.StorePath = filepath.Join(workDir, ".mscli", "sessions")
+	sessionStore, err := session.NewFileStore(sessionCfg.StorePath)
+	if err != nil {
+		return nil, fmt.Errorf("init session store: %w", err)
+	}
+	app.sessionStore = sessionStore
+	app.sessions = session.NewManager(sessionStore, sessionCfg)
+	engine.SetMessageSink(app.recordMessage)
+	if artifacts != nil {
+		artifacts.SetSessionFunc(app.currentSessionID)
+	}
+	if docker, ok := shellBackend.(*shell.DockerBackend); ok {
+		// Each session runs its commands in its own container.
+		docker.SetSessionFunc(app.currentSessionID)
+	}
+	// Each session gets its own shell, too.
+	shellRunner.SetSessionFunc(app.currentSessionID)
+	if cfg.Resume {
+		if _, err := app.resumeSession(cfg.ResumeID); err != nil {
+			return nil, fmt.Errorf("resume session: %w", err)
+		}
+	}
+
+	permService.SetUI(newPermissionPrompt(app.EventCh))
+
+	// Plans are shown in the chat and wait for the user's approval.
+	app.planApproval = newPlanApproval(app.EventCh)
+	engine.SetModeCallback(app.planApproval)
+	engine.SetPlanApprover(app.planApproval)
+
+	return app, nil
 }
 
-// initProvider initializes the LLM provider.
-func initProvider(cfg configs.ModelConfig) (llm.Provider, error) {
-	key := strings.TrimSpace(cfg.Key)
-	if key == "" {
-		key = strings.TrimSpace(os.Getenv("MSCLI_API_KEY"))
+// userPermissionsPath returns the user-level permission file next to the
+// user config, or "" when there is no home directory.
+func userPermissionsPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
 	}
-	if key == "" {
-		key = strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
+	return filepath.Join(home, ".config", "mscli", "permissions.json")
+}
+
+// defaultOpenAIURL is the OpenAI endpoint used when no URL is configured.
+const defaultOpenAIURL = "https://api.openai.com/v1"
+
+// initCostTracker creates the tracker that prices LLM usage and enforces
+// the budget limits.
+func initCostTracker(config *configs.Config, workDir string) (*cost.Tracker, error) {
+	pricing := make(map[string]cost.Pricing, len(config.Budget.Pricing))
+	for name, p := range config.Budget.Pricing {
+		pricing[strings.ToLower(name)] = cost.Pricing{
+			InputPerMTok:  p.InputPerMTok,
+			OutputPerMTok: p.OutputPerMTok,
+		}
 	}
-	if key == "" {
-		return nil, fmt.Errorf("API key not found (set MSCLI_API_KEY/OPENAI_API_KEY or key in config)")
+	return cost.NewTracker(cost.Config{
+		MaxCostUSD:    config.Budget.MaxCostUSD,
+		DailyLimitUSD: float64(config.Budget.DailyLimit),
+		Pricing:       pricing,
+		Model:         config.Model.Model,
+		StorePath:     filepath.Join(workDir, ".mscli", "spend.yaml"),
+	})
+}
+
+// initMemory opens the project's memory store, .mscli/memory.db unless
+// another path is configured.
+func initMemory(cfg configs.MemoryConfig, workDir string) (*memory.Manager, error) {
+	path := memoryStorePath(cfg, workDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	memCfg := memory.DefaultConfig()
+	memCfg.StorePath = path
+	memCfg.MaxItems = cfg.MaxItems
+	memCfg.MaxBytes = cfg.MaxBytes
+	memCfg.DefaultTTL = time.Duration(cfg.TTLHours) * time.Hour
+	store, err := memory.NewSQLiteStore(path, memCfg)
+	if err != nil {
+		return nil, err
 	}
+	return memory.NewManager(store, memCfg), nil
+}
+
+// memoryStorePath returns the memory database path.
+func memoryStorePath(cfg configs.MemoryConfig, workDir string) string {
+	if cfg.StorePath != "" {
+		return cfg.StorePath
+	}
+	return filepath.Join(workDir, ".mscli", "memory.db")
+}
+
+// tokenCounter returns the BPE encoding the context manager counts tokens
+// with. It returns nil for the heuristic estimate, and for auto when no
+// vocabulary is available for the model; that case also returns an error
+// so the fallback is not silent.
+func tokenCounter(cfg configs.ContextConfig, modelName, workDir string) (context.TokenCounter, error) {
+	dir := cfg.TokenizerDir
+	if dir == "" {
+		dir = filepath.Join(workDir, ".mscli", "tokenizers")
+	}
+
+	switch name := strings.ToLower(strings.TrimSpace(cfg.Tokenizer)); {
+	case name == "heuristic":
+		return nil, nil
+	case autoTokenizer(name):
+		enc, err := bpe.ForModel(modelName, dir)
+		if err != nil {
+			return nil, fmt.Errorf("%w; counting tokens with the calibrated estimate (put %s.tiktoken in %s, see agent/context/bpe/vocab/README.md)",
+				err, bpe.EncodingForModel(modelName), dir)
+		}
+		return enc, nil
+	default:
+		enc, err := bpe.Load(name, dir)
+		if err != nil {
+			return nil, err
+		}
+		return enc, nil
+	}
+}
+
+// autoTokenizer reports whether name selects the encoding by model.
+func autoTokenizer(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "auto":
+		return true
+	}
+	return false
+}
+
+// initSemanticMemory enables embedding-based recall. The vector index is
+// kept next to the memory database and rebuilt in the background when it
+// is missing or out of date.
+func initSemanticMemory(mem *memory.Manager, config *configs.Config, workDir string, w trace.Writer) error {
+	cfg := config.Memory.Embedding
+	provider := config.Model.ProviderName()
+	openAICompatible := provider == configs.ProviderOpenAI || provider == configs.ProviderLocal
 
 	url := strings.TrimSpace(cfg.URL)
+	if url == "" && openAICompatible {
+		url = strings.TrimSpace(config.Model.URL)
+	}
 	if url == "" {
-		url = "https://api.openai.com/v1"
+		url = defaultOpenAIURL
+	}
+
+	key := strings.TrimSpace(cfg.Key)
+	if key == "" {
+		key = strings.TrimSpace(os.Getenv("MSCLI_EMBEDDING_KEY"))
+	}
+	if key == "" && openAICompatible {
+		key = strings.TrimSpace(config.Model.Key)
+		if key == "" {
+			key = strings.TrimSpace(os.Getenv("MSCLI_API_KEY"))
+		}
+	}
+	if key == "" {
+		key = strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
 	}
 
-	client, err := openai.NewClient(openai.Config{
-		Key:     key,
-		URL:     url,
-		Model:   cfg.Model,
-		Timeout: time.Duration(cfg.TimeoutSec) * time.Second,
+	embedder, err := memory.NewOpenAIEmbedder(memory.OpenAIEmbedderConfig{
+		URL:        url,
+		Key:        key,
+		Model:      cfg.Model,
+		Dimensions: cfg.Dimensions,
+		BatchSize:  cfg.BatchSize,
+		CacheSize:  1000,
+		Timeout:    time.Duration(config.Model.TimeoutSec) * time.Second,
 	})
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	dbPath := memoryStorePath(config.Memory, workDir)
+	indexPath := strings.TrimSuffix(dbPath, filepath.Ext(dbPath)) + ".hnsw"
+	index, err := memory.LoadHNSWIndex(indexPath)
+	if err != nil {
+		index = nil // missing or unreadable; rebuilt below
+	}
+	mem.SetSemantic(memory.SemanticConfig{
+		Embedder:      embedder,
+		Index:         index,
+		IndexPath:     indexPath,
+		MinSimilarity: cfg.MinSimilarity,
+	})
+
+	if stale, err := mem.IndexStale(); err != nil || stale {
+		go func() {
+			if _, err := mem.Reindex(); err != nil {
+				_ = w.Write("memory_error", map[string]any{"stage": "reindex", "error": err.Error()})
+			}
+		}()
 	}
-	return client, nil
+	return nil
 }
 
-// initTools initializes the tool registry.
-func initTools(cfg *configs.Config, workDir string) *tools.Registry {
+// initProviderChain initializes the configured provider followed by its
+// fallbacks, wrapped so transient failures are retried and then fail over.
+// Entries may share a provider type, e.g. two OpenAI models.
+func initProviderChain(cfg configs.ModelConfig, w trace.Writer) (llm.Provider, error) {
+	chain := append([]configs.ModelConfig{cfg}, cfg.Fallbacks...)
+	providers := make([]llm.Provider, 0, len(chain))
+	for i, mc := range chain {
+		if i > 0 && mc.TimeoutSec == 0 {
+			mc.TimeoutSec = cfg.TimeoutSec
+		}
+		p, err := initProvider(mc, i == 0)
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("fallback %d: %w", i, err)
+		}
+		providers = append(providers, p)
+	}
+
+	policy := llm.RetryPolicy{
+		MaxRetries: cfg.Retry.MaxRetries,
+		BaseDelay:  time.Duration(cfg.Retry.BackoffMs) * time.Millisecond,
+		MaxDelay:   time.Duration(cfg.Retry.MaxBackoffSec) * time.Second,
+	}
+	return llm.NewFallbackProvider(providers, policy, w)
+}
+
+// providerKey returns the API key for cfg and the provider's key variable.
+// MSCLI_API_KEY applies only to the primary provider, so a fallback of
+// another type is not sent its key.
+func providerKey(cfg configs.ModelConfig, primary bool) (key, keyEnv string) {
+	keyEnv = "OPENAI_API_KEY"
+	if cfg.ProviderName() == configs.ProviderAnthropic {
+		keyEnv = "ANTHROPIC_API_KEY"
+	}
+	if key = strings.TrimSpace(cfg.Key); key != "" {
+		return key, keyEnv
+	}
+	if primary {
+		if key = strings.TrimSpace(os.Getenv("MSCLI_API_KEY")); key != "" {
+			return key, keyEnv
+		}
+	}
+	return strings.TrimSpace(os.Getenv(keyEnv)), keyEnv
+}
+
+// initProvider initializes the LLM provider selected by cfg.Provider.
+// primary is false for fallbacks.
+func initProvider(cfg configs.ModelConfig, primary bool) (llm.Provider, error) {
+	providerName := cfg.ProviderName()
+
+	key, keyEnv := providerKey(cfg, primary)
+	// Local servers do not authenticate, so only hosted providers need a key.
+	if key == "" && providerName != configs.ProviderLocal {
+		if !primary {
+			return nil, fmt.Errorf("API key not found (set %s or key in config)", keyEnv)
+		}
+		return nil, fmt.Errorf("API key not found (set MSCLI_API_KEY/%s or key in config)", keyEnv)
+	}
+
+	url := strings.TrimSpace(cfg.URL)
+	timeout := time.Duration(cfg.TimeoutSec) * time.Second
+
+	switch providerName {
+	case configs.ProviderAnthropic:
+		// The built-in default URL points at OpenAI; let the client use its own.
+		if url == defaultOpenAIURL {
+			url = ""
+		}
+		return anthropic.NewClient(anthropic.Config{
+			Key:            key,
+			URL:            url,
+			Model:          cfg.Model,
+			Timeout:        timeout,
+			ThinkingBudget: cfg.ThinkingBudget,
+		})
+	case configs.ProviderLocal:
+		if url == defaultOpenAIURL {
+			url = ""
+		}
+		return local.NewClient(local.Config{
+			Key:      key,
+			URL:      url,
+			Model:    cfg.Model,
+			Timeout:  timeout,
+			ToolMode: cfg.ToolMode,
+		})
+	case configs.ProviderOpenAI:
+		if url == "" {
+			url = defaultOpenAIURL
+		}
+		return openai.NewClient(openai.Config{
+			Key:     key,
+			URL:     url,
+			Model:   cfg.Model,
+			Timeout: timeout,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", providerName)
+	}
+}
+
+// initTools initializes the tool registry. The memory tools are registered
+// only when memory is available.
+func initTools(cfg *configs.Config, workDir string, mem *memory.Manager, artifacts *artifact.Store, shellRunner *shell.Runner) *tools.Registry {
 	registry := tools.NewRegistry()
 
 	// Register file tools
@@ -183,14 +578,64 @@ func initTools(cfg *configs.Config, workDir string) *tools.Registry {
 	registry.MustRegister(fs.NewGlobTool(workDir))
 
 	// Register shell tool
-	shellRunner := shell.NewRunner(shell.Config{
-		WorkDir:        workDir,
-		Timeout:        time.Duration(cfg.Execution.TimeoutSec) * time.Second,
-		AllowedCmds:    cfg.Permissions.AllowedTools,
-		BlockedCmds:    cfg.Permissions.BlockedTools,
-		RequireConfirm: []string{"rm", "mv", "cp"},
-	})
 	registry.MustRegister(shell.NewShellTool(shellRunner))
 
+	// Register the tool that pages through spilled outputs
+	if artifacts != nil {
+		registry.MustRegister(artifact.NewReadTool(artifacts))
+	}
+
+	// Register memory tools
+	if cfg.Memory.Enabled && mem != nil {
+		registry.MustRegister(memorytools.NewRememberTool(mem))
+		registry.MustRegister(memorytools.NewRecallTool(mem))
+	}
+
 	return registry
 }
+
+// spilledShellOutputBytes caps command output when it is saved as an
+// artifact rather than sent to the model in full.
+const spilledShellOutputBytes = 4 * 1024 * 1024
+
+// initShellBackend returns the backend for execution.mode.
+func initShellBackend(cfg configs.ExecutionConfig, workDir string) (shell.Backend, error) {
+	switch cfg.Mode {
+	case "", configs.ExecutionLocal:
+		return shell.LocalBackend{}, nil
+	case configs.ExecutionSandbox:
+		return shell.NewSandboxBackend(shell.SandboxConfig{
+			Isolation: cfg.Sandbox.Isolation,
+			Network:   cfg.Sandbox.Network,
+			CPU:       cfg.Sandbox.CPU,
+			Memory:    cfg.Sandbox.Memory,
+		})
+	case configs.ExecutionDocker:
+		runtime := cfg.Docker.Runtime
+		if runtime == "" {
+			runtime = "docker"
+		}
+		if _, err := exec.LookPath(runtime); err != nil {
+			return nil, fmt.Errorf("container runtime not found: %w", err)
+		}
+		return shell.NewDockerBackend(shell.DockerConfig{
+			Runtime: shell.CLIRuntime{Binary: runtime},
+			Image:   cfg.Docker.Image,
+			WorkDir: workDir,
+			Env:     cfg.Docker.Env,
+			CPU:     cfg.Docker.CPU,
+			Memory:  cfg.Docker.Memory,
+			Network: cfg.Docker.Network,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported execution mode %q", cfg.Mode)
+	}
+}
+
+// shellOutputBytes returns the shell output cap; zero keeps the runner's default.
+func shellOutputBytes(artifacts *artifact.Store) int {
+	if artifacts == nil {
+		return 0
+	}
+	return spilledShellOutputBytes
+}
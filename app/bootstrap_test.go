@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vigo999/ms-cli/configs"
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+func TestInitProviderChainSameProviderFallback(t *testing.T) {
+	cfg := configs.ModelConfig{
+		Provider: configs.ProviderOpenAI,
+		Model:    "gpt-4o",
+		Key:      "primary-key",
+		Fallbacks: []configs.ModelConfig{
+			{Provider: configs.ProviderOpenAI, Model: "gpt-4o-mini", Key: "fallback-key"},
+			{Provider: configs.ProviderOpenAI, Model: "gpt-4.1-mini", Key: "fallback-key"},
+		},
+	}
+	p, err := initProviderChain(cfg, nil)
+	if err != nil {
+		t.Fatalf("initProviderChain: %v", err)
+	}
+	fallback, ok := p.(*llm.FallbackProvider)
+	if !ok {
+		t.Fatalf("provider = %T, want *llm.FallbackProvider", p)
+	}
+	if got := len(fallback.Providers()); got != 3 {
+		t.Errorf("providers = %d, want 3", got)
+	}
+}
+
+func TestProviderKeyFallbackUsesProviderVariable(t *testing.T) {
+	t.Setenv("MSCLI_API_KEY", "mscli-key")
+	t.Setenv("OPENAI_API_KEY", "openai-key")
+	t.Setenv("ANTHROPIC_API_KEY", "anthropic-key")
+
+	tests := []struct {
+		name    string
+		cfg     configs.ModelConfig
+		primary bool
+		want    string
+	}{
+		{"primary", configs.ModelConfig{Provider: configs.ProviderOpenAI}, true, "mscli-key"},
+		{"anthropic fallback", configs.ModelConfig{Provider: configs.ProviderAnthropic}, false, "anthropic-key"},
+		{"openai fallback", configs.ModelConfig{Provider: configs.ProviderOpenAI}, false, "openai-key"},
+		{"config key", configs.ModelConfig{Provider: configs.ProviderAnthropic, Key: "own-key"}, false, "own-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := providerKey(tt.cfg, tt.primary); got != tt.want {
+				t.Errorf("providerKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenCounterAutoReportsFallback(t *testing.T) {
+	dir := t.TempDir()
+	counter, err := tokenCounter(configs.ContextConfig{Tokenizer: "auto", TokenizerDir: dir}, "gpt-4o", dir)
+	if counter == nil && err == nil {
+		t.Fatal("auto fell back to the estimate without an error")
+	}
+	if counter != nil && err != nil {
+		t.Errorf("got a counter and error %v", err)
+	}
+
+	if counter, err := tokenCounter(configs.ContextConfig{Tokenizer: "heuristic"}, "gpt-4o", dir); counter != nil || err != nil {
+		t.Errorf("heuristic = %v, %v; want no counter and no error", counter, err)
+	}
+}
+
+func TestSetProviderFailureKeepsConfig(t *testing.T) {
+	t.Setenv("MSCLI_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("ANTHROPIC_BASE_URL", "https://anthropic.example")
+	t.Setenv("MSCLI_PROVIDER", "local")
+
+	cfg := configs.DefaultConfig()
+	cfg.Model = configs.ModelConfig{
+		Provider: configs.ProviderOpenAI,
+		URL:      "https://openai.example/v1",
+		Model:    "gpt-4o",
+		Key:      "openai-key",
+	}
+	cfg.Budget.MaxTokens = 1234
+	want := cfg.Model
+	app := &Application{Config: cfg}
+
+	if err := app.SetProvider("anthropic", "", ""); err == nil {
+		t.Fatal("expected an error without an Anthropic key")
+	}
+	if app.Config.Model.Provider != want.Provider || app.Config.Model.URL != want.URL ||
+		app.Config.Model.Model != want.Model || app.Config.Model.Key != want.Key {
+		t.Errorf("model config changed after a failed switch: %+v", app.Config.Model)
+	}
+	if app.Config.Budget.MaxTokens != 1234 || app.Engine != nil {
+		t.Errorf("a failed switch touched other settings")
+	}
+}
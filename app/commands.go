@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/vigo999/ms-cli/agent/plan"
+	"github.com/vigo999/ms-cli/configs"
 	"github.com/vigo999/ms-cli/internal/project"
 	"github.com/vigo999/ms-cli/permission"
 	"github.com/vigo999/ms-cli/ui/model"
@@ -40,6 +44,14 @@ func (a *Application) handleCommand(input string) {
 		a.cmdYolo()
 	case "/mouse":
 		a.cmdMouse(parts[1:])
+	case "/mode":
+		a.cmdMode(parts[1:])
+	case "/plan":
+		a.cmdPlan(parts[1:])
+	case "/session":
+		a.cmdSession(parts[1:])
+	case "/memory":
+		a.cmdMemory(parts[1:])
 	case "/help":
 		a.cmdHelp()
 	default:
@@ -50,6 +62,228 @@ func (a *Application) handleCommand(input string) {
 	}
 }
 
+// cmdMode handles "/mode [standard|plan|review]".
+func (a *Application) cmdMode(args []string) {
+	if len(args) == 0 {
+		a.EventCh <- model.Event{
+			Type:    model.AgentReply,
+			Message: fmt.Sprintf("Current mode: %s\n\nUsage: /mode standard|plan|review", a.Engine.RunMode()),
+		}
+		return
+	}
+
+	var mode plan.RunMode
+	switch strings.ToLower(args[0]) {
+	case "standard":
+		mode = plan.ModeStandard
+	case "plan":
+		mode = plan.ModePlan
+	case "review":
+		mode = plan.ModeReview
+	default:
+		a.EventCh <- model.Event{
+			Type:    model.AgentReply,
+			Message: fmt.Sprintf("Unknown mode: %s. Usage: /mode standard|plan|review", args[0]),
+		}
+		return
+	}
+
+	a.Engine.SetRunMode(mode)
+	descriptions := map[plan.RunMode]string{
+		plan.ModeStandard: "tasks run directly",
+		plan.ModePlan:     "tasks start with a plan that needs your approval",
+		plan.ModeReview:   "each step asks for confirmation",
+	}
+	a.EventCh <- model.Event{
+		Type:    model.AgentReply,
+		Message: fmt.Sprintf("Mode set to %s: %s.", mode, descriptions[mode]),
+	}
+}
+
+// cmdPlan handles "/plan <goal>", "/plan run", "/plan resume" and
+// "/plan skip <n>".
+func (a *Application) cmdPlan(args []string) {
+	if len(args) == 0 {
+		if p := a.planApproval.lastPlan(); p != nil {
+			a.EventCh <- model.Event{Type: model.AgentReply, Message: p.ToMarkdown()}
+			return
+		}
+		a.EventCh <- model.Event{
+			Type:    model.AgentReply,
+			Message: "Usage: /plan <goal> | /plan run | /plan resume [id] | /plan skip <n>",
+		}
+		return
+	}
+
+	if len(args) == 1 {
+		switch args[0] {
+		case "run":
+			a.cmdPlanRun()
+			return
+		case "resume":
+			a.cmdPlanResume("")
+			return
+		}
+	}
+	if args[0] == "resume" && len(args) == 2 {
+		a.cmdPlanResume(args[1])
+		return
+	}
+	if args[0] == "skip" && len(args) == 2 {
+		a.cmdPlanSkip(args[1])
+		return
+	}
+
+	goal := strings.Join(args, " ")
+	go func() {
+		a.EventCh <- model.Event{Type: model.AgentThinking}
+		p, err := a.Engine.GeneratePlan(context.Background(), goal)
+		if err != nil {
+			a.EventCh <- model.Event{
+				Type:     model.ToolError,
+				ToolName: "plan",
+				Message:  fmt.Sprintf("Failed to generate plan: %v", err),
+			}
+			return
+		}
+		// A draft that was never run is replaced by the new plan.
+		if prev := a.planApproval.lastPlan(); prev != nil && prev.Status == plan.PlanStatusDraft && a.planStore != nil {
+			_ = a.planStore.Delete(prev.ID)
+		}
+		a.planApproval.OnPlanCreated(p)
+		a.EventCh <- model.Event{
+			Type:    model.AgentReply,
+			Message: "Use /plan run to execute it, or /plan skip <n> to drop a step first.",
+		}
+	}()
+}
+
+// cmdPlanRun executes the last plan.
+func (a *Application) cmdPlanRun() {
+	p := a.planApproval.lastPlan()
+	switch {
+	case p == nil:
+		a.planError("No plan yet. Create one with /plan <goal>.")
+		return
+	case p.Status == plan.PlanStatusPaused:
+		a.planError("The plan is paused. Use /plan resume to continue it.")
+		return
+	case p.Status == plan.PlanStatusRunning:
+		a.planError("The plan is already running.")
+		return
+	case p.Status == plan.PlanStatusCompleted:
+		a.planError("The plan has already completed. Create a new one with /plan <goal>.")
+		return
+	case p.Status == plan.PlanStatusFailed || p.Status == plan.PlanStatusCancelled:
+		// Run again from the steps that did not finish.
+		p.Retry()
+	default:
+		p.Approve()
+	}
+
+	go a.executePlan(p, a.Engine.ExecutePlan)
+}
+
+// cmdPlanResume continues the last plan, or the saved plan with the given
+// id, after it was paused.
+func (a *Application) cmdPlanResume(id string) {
+	p := a.planApproval.lastPlan()
+	if id != "" {
+		if a.planStore == nil {
+			a.planError("Plan storage is not available.")
+			return
+		}
+		rec, err := a.planStore.Load(id)
+		if err != nil {
+			a.planError(fmt.Sprintf("Cannot load plan: %v", err))
+			return
+		}
+		p = rec.Plan
+		a.planApproval.setLast(p)
+	}
+	if p != nil && p.Status == plan.PlanStatusApproved {
+		go a.executePlan(p, a.Engine.ExecutePlan)
+		return
+	}
+	if p == nil || p.Status != plan.PlanStatusPaused {
+		a.planError("No paused plan to resume.")
+		return
+	}
+	go a.executePlan(p, a.Engine.ResumePlan)
+}
+
+// cmdPlanSkip marks step n (1-based) of the last plan as skipped.
+func (a *Application) cmdPlanSkip(arg string) {
+	p := a.planApproval.lastPlan()
+	if p == nil {
+		a.planError("No plan yet. Create one with /plan <goal>.")
+		return
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		a.planError(fmt.Sprintf("Invalid step number: %s", arg))
+		return
+	}
+	if err := a.Engine.SkipPlanStep(p, n-1); err != nil {
+		a.planError(fmt.Sprintf("Cannot skip step %d: %v", n, err))
+		return
+	}
+	a.EventCh <- model.Event{
+		Type:    model.AgentReply,
+		Message: fmt.Sprintf("Skipped step %d.\n\n%s", n, p.ToMarkdown()),
+	}
+}
+
+// executePlan runs or resumes a plan and reports the outcome.
+func (a *Application) executePlan(p *plan.Plan, run func(context.Context, *plan.Plan) error) {
+	a.EventCh <- model.Event{Type: model.AgentThinking}
+	err := run(context.Background(), p)
+	if err != nil {
+		msg := fmt.Sprintf("Plan execution stopped: %v", err)
+		if p.Status == plan.PlanStatusPaused {
+			msg += "\n\nThe plan is paused. Use /plan skip <n> to drop a step, then /plan resume."
+		}
+		a.planError(msg)
+	}
+	a.EventCh <- model.Event{
+		Type:    model.AgentReply,
+		Message: a.Engine.PlanReport(p).ToMarkdown(),
+	}
+}
+
+// restorePlans picks up plans left unfinished by a previous run, so the
+// most recent one can be continued with /plan resume.
+func (a *Application) restorePlans() {
+	if a.planStore == nil {
+		return
+	}
+	plans, err := a.planStore.Unfinished()
+	if err != nil {
+		a.planError(fmt.Sprintf("Failed to load saved plans: %v", err))
+		return
+	}
+	if len(plans) == 0 {
+		return
+	}
+
+	a.planApproval.setLast(plans[0])
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Found %d unfinished plan(s) from a previous run:\n", len(plans))
+	for _, p := range plans {
+		fmt.Fprintf(&sb, "- %s: %s (%d/%d steps done)\n", p.ID, p.Goal, p.GetCompletedSteps(), len(p.Steps))
+	}
+	sb.WriteString("\nUse /plan resume to continue the latest one, or /plan resume <id> for another.")
+	a.EventCh <- model.Event{Type: model.AgentReply, Message: sb.String()}
+}
+
+func (a *Application) planError(msg string) {
+	a.EventCh <- model.Event{
+		Type:     model.ToolError,
+		ToolName: "plan",
+		Message:  msg,
+	}
+}
+
 // cmdRoadmap handles "/roadmap status [path]".
 func (a *Application) cmdRoadmap(args []string) {
 	if len(args) == 0 || args[0] != "status" {
@@ -136,56 +370,76 @@ func (a *Application) cmdModel(args []string) {
 		return
 	}
 
-	// Accept "openai:model" for backward compatibility.
+	// Accept "provider:model" to switch provider and model together. Other
+	// colons belong to the model name, as in Ollama tags like "qwen2.5:7b".
 	modelArg := args[0]
-	if strings.Contains(modelArg, ":") {
-		parts := strings.SplitN(modelArg, ":", 2)
-		providerName := strings.TrimSpace(parts[0])
-		modelName := parts[1]
-		if providerName != "" && providerName != "openai" {
-			a.EventCh <- model.Event{
-				Type:    model.AgentReply,
-				Message: fmt.Sprintf("Unsupported provider prefix: %s (only openai-compatible is supported)", providerName),
-			}
+	if prefix, modelName, ok := strings.Cut(modelArg, ":"); ok {
+		switch providerName := strings.ToLower(strings.TrimSpace(prefix)); providerName {
+		case configs.ProviderOpenAI, configs.ProviderAnthropic, configs.ProviderLocal:
+			a.switchModel(providerName, modelName)
 			return
 		}
-		a.switchModel(modelName)
-		return
 	}
 
 	// Just switch model.
-	a.switchModel(modelArg)
+	a.switchModel("", modelArg)
 }
 
-// showCurrentModel displays current URL/model/key status.
+// showCurrentModel displays current provider/URL/model/key status.
 func (a *Application) showCurrentModel() {
+	providerName := a.Config.Model.ProviderName()
 	modelName := a.Config.Model.Model
 	url := a.Config.Model.URL
 	if url == "" {
-		url = "https://api.openai.com/v1"
+		url = "(provider default)"
 	}
 
+	keyEnv := "OPENAI_API_KEY"
+	if providerName == configs.ProviderAnthropic {
+		keyEnv = "ANTHROPIC_API_KEY"
+	}
 	apiKeyStatus := "not set"
 	if a.Config.Model.Key != "" ||
 		getEnv("MSCLI_API_KEY") != "" ||
-		getEnv("OPENAI_API_KEY") != "" {
+		getEnv(keyEnv) != "" {
 		apiKeyStatus = "set"
+	} else if providerName == configs.ProviderLocal {
+		apiKeyStatus = "not required"
 	}
 
-	msg := fmt.Sprintf(`Current Model Configuration:
+	// Local servers report which models are installed.
+	installed := ""
+	if providerName == configs.ProviderLocal && a.provider != nil {
+		var ids []string
+		for _, m := range a.provider.AvailableModels() {
+			if m.Provider == configs.ProviderLocal {
+				ids = append(ids, m.ID)
+			}
+		}
+		if len(ids) == 0 {
+			installed = "\n  Installed: (server unreachable or no models pulled)\n"
+		} else {
+			installed = "\n  Installed: " + strings.Join(ids, ", ") + "\n"
+		}
+	}
 
-  URL:   %s
-  Model: %s
-  Key:   %s
+	msg := fmt.Sprintf(`Current Model Configuration:
 
+  Provider: %s
+  URL:      %s
+  Model:    %s
+  Key:      %s
+%s
 To switch model:
   /model <model-name>
-  /model openai:<model>         (backward-compatible prefix)
+  /model <provider>:<model>     (provider: openai, anthropic, local)
 
 Examples:
   /model gpt-4o
-  /model openai:gpt-4o-mini`,
-		url, modelName, apiKeyStatus)
+  /model openai:gpt-4o-mini
+  /model anthropic:claude-sonnet-4-20250514
+  /model local:qwen2.5-coder:7b`,
+		providerName, url, modelName, apiKeyStatus, installed)
 
 	a.EventCh <- model.Event{
 		Type:    model.AgentReply,
@@ -193,11 +447,11 @@ Examples:
 	}
 }
 
-// switchModel switches to a new model.
-func (a *Application) switchModel(modelName string) {
+// switchModel switches to a new model, and provider when one is given.
+func (a *Application) switchModel(providerName, modelName string) {
 	a.EventCh <- model.Event{Type: model.AgentThinking}
 
-	err := a.SetProvider("", modelName, "")
+	err := a.SetProvider(providerName, modelName, "")
 	if err != nil {
 		a.EventCh <- model.Event{
 			Type:     model.ToolError,
@@ -212,6 +466,7 @@ func (a *Application) switchModel(modelName string) {
 		Type:    model.ModelUpdate,
 		Message: a.Config.Model.Model,
 	}
+	a.warnTokenizer()
 
 	// Save state to disk
 	if err := a.SaveState(); err != nil {
@@ -250,14 +505,25 @@ func (a *Application) cmdExit() {
 func (a *Application) cmdCompact() {
 	a.EventCh <- model.Event{Type: model.AgentThinking}
 
-	// Trigger context compaction through the engine
-	if a.Engine != nil {
-		// In a real implementation, this would compact the conversation context
-		// For now, just show a message
-		a.EventCh <- model.Event{
-			Type:    model.AgentReply,
-			Message: "Context compacted. Conversation summary has been created to save tokens.",
-		}
+	// Summarizing may call the model, so compact off the input loop.
+	if a.ctxManager != nil {
+		go func() {
+			before := a.ctxManager.TokenUsage().Current
+			if err := a.ctxManager.Compact(); err != nil {
+				a.EventCh <- model.Event{
+					Type:     model.ToolError,
+					ToolName: "compact",
+					Message:  fmt.Sprintf("Failed to compact context: %v", err),
+				}
+				return
+			}
+			after := a.ctxManager.TokenUsage().Current
+			msg := "Nothing to compact yet; the conversation is still short."
+			if after < before {
+				msg = fmt.Sprintf("Context compacted from %d to %d tokens. Earlier messages were replaced by a summary.", before, after)
+			}
+			a.EventCh <- model.Event{Type: model.AgentReply, Message: msg}
+		}()
 	} else {
 		a.EventCh <- model.Event{
 			Type:    model.AgentReply,
@@ -329,6 +595,14 @@ func (a *Application) cmdPermission(args []string) {
 		return
 	}
 
+	if len(args) > 0 {
+		switch args[0] {
+		case "list", "revoke", "export", "import", "expire", "explain":
+			a.cmdPermissionDecisions(permSvc, args)
+			return
+		}
+	}
+
 	if len(args) == 0 {
 		// Show current permissions
 		policies := permSvc.GetPolicies()
@@ -343,6 +617,12 @@ func (a *Application) cmdPermission(args []string) {
 		}
 		msg += "\nUsage:\n"
 		msg += "  /permission <tool> <level>\n"
+		msg += "  /permission list                  - Saved decisions (project and user)\n"
+		msg += "  /permission revoke <tool|command|path>\n"
+		msg += "  /permission export <path>\n"
+		msg += "  /permission import <path> [--user]\n"
+		msg += "  /permission expire [age]          - Drop decisions older than age (default 7d)\n"
+		msg += "  /permission explain <tool> <args> - Show which rule decides a call\n"
 		msg += "\nLevels:\n"
 		msg += "  ask         - Ask each time (default)\n"
 		msg += "  allow_once  - Allow once\n"
@@ -463,7 +743,33 @@ func (a *Application) cmdHelp() {
 Model Commands:
   /model                  Show current configuration
   /model gpt-4o           Switch to gpt-4o
-  /model openai:gpt-4o    Backward-compatible format
+  /model openai:gpt-4o    Switch provider and model
+  /model anthropic:claude-sonnet-4-20250514
+  /model local:qwen2.5-coder:7b
+
+Mode & Plan Commands:
+  /mode                   Show the current run mode
+  /mode plan              Plan first, run after approval (also: standard, review)
+  /plan <goal>            Generate and show a plan without running it
+  /plan run               Execute the last plan
+  /plan resume [id]       Continue a paused or saved plan
+  /plan skip <n>          Skip step n of the last plan
+
+Session Commands:
+  /session                Show the current session
+  /session list           List saved sessions
+  /session new [name]     Start a new session
+  /session load <id>      Continue a saved session
+  /session rename <name>  Rename the current session
+  /session archive [id]   Archive a session (default: current)
+  /session export <path> [id]  Export a session to a JSON file
+  /session import <path>  Import a session from a JSON file
+
+Memory Commands:
+  /memory list            List saved memories (* marks pinned)
+  /memory search <query>  Search memories
+  /memory forget <id>     Delete a memory
+  /memory pin <id>        Keep a memory and add it to every task
 
 Permission Commands:
   /permission             Show current permission settings
@@ -487,12 +793,17 @@ Keybindings:
   ctrl+c     Cancel/Quit (press twice to exit)
 
 Environment Variables:
-  MSCLI_BASE_URL          OpenAI-compatible base URL
+  MSCLI_PROVIDER          Provider (openai, anthropic, local)
+  MSCLI_BASE_URL          Provider base URL
   MSCLI_MODEL             Default model
   MSCLI_API_KEY           API key
-  OPENAI_BASE_URL         Base URL (fallback)
-  OPENAI_MODEL            Model (fallback)
-  OPENAI_API_KEY          API key (fallback)`
+  OPENAI_BASE_URL         Base URL (fallback, openai)
+  OPENAI_MODEL            Model (fallback, openai)
+  OPENAI_API_KEY          API key (fallback, openai)
+  ANTHROPIC_BASE_URL      Base URL (fallback, anthropic)
+  ANTHROPIC_MODEL         Model (fallback, anthropic)
+  ANTHROPIC_API_KEY       API key (fallback, anthropic)
+  OLLAMA_HOST             Server address (fallback, local)`
 
 	a.EventCh <- model.Event{
 		Type:    model.AgentReply,
@@ -8,28 +8,84 @@ import (
 )
 
 func main() {
+	cfg, err := parseFlags(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	app, err := Bootstrap(cfg)
+	if err != nil {
+		log.Fatalf("Failed to bootstrap: %v", err)
+	}
+
+	if err := app.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseFlags parses the command line into a bootstrap config.
+func parseFlags(fs *flag.FlagSet, args []string) (BootstrapConfig, error) {
 	var (
-		demo       = flag.Bool("demo", false, "Run in demo mode")
-		configPath = flag.String("config", "", "Path to config file")
-		url        = flag.String("url", "", "OpenAI-compatible base URL")
-		model      = flag.String("model", "", "Model name")
-		apiKey     = flag.String("api-key", "", "API key")
+		demo       = fs.Bool("demo", false, "Run in demo mode")
+		configPath = fs.String("config", "", "Path to config file")
+		url        = fs.String("url", "", "OpenAI-compatible base URL")
+		model      = fs.String("model", "", "Model name")
+		apiKey     = fs.String("api-key", "", "API key")
+		sessionID  = fs.String("session", "", "Resume the session with the given id")
+		resume     optionalString
 	)
-	flag.Parse()
+	fs.Var(&resume, "resume", "Resume the most recent session, or the session with the given id (--resume=<id> or --resume <id>)")
+	if err := fs.Parse(args); err != nil {
+		return BootstrapConfig{}, err
+	}
 
-	app, err := Bootstrap(BootstrapConfig{
+	// A value-less flag stops at the next argument, so `--resume <id>` leaves
+	// the id, and any flags after it, unparsed.
+	if resume.set && resume.value == "" && fs.NArg() > 0 {
+		resume.value = fs.Arg(0)
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return BootstrapConfig{}, err
+		}
+	}
+	if fs.NArg() > 0 {
+		return BootstrapConfig{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if *sessionID != "" {
+		if resume.value != "" && resume.value != *sessionID {
+			return BootstrapConfig{}, fmt.Errorf("--resume %s and --session %s name different sessions", resume.value, *sessionID)
+		}
+		resume.set, resume.value = true, *sessionID
+	}
+
+	return BootstrapConfig{
 		Demo:       *demo,
 		ConfigPath: *configPath,
 		URL:        *url,
 		Model:      *model,
 		Key:        *apiKey,
-	})
-	if err != nil {
-		log.Fatalf("Failed to bootstrap: %v", err)
-	}
+		Resume:     resume.set,
+		ResumeID:   resume.value,
+	}, nil
+}
 
-	if err := app.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+// optionalString is a flag that may be given with or without a value.
+type optionalString struct {
+	set   bool
+	value string
+}
+
+func (o *optionalString) String() string { return o.value }
+
+func (o *optionalString) Set(v string) error {
+	o.set = true
+	if v != "true" {
+		o.value = v
 	}
+	return nil
 }
+
+// IsBoolFlag lets the flag appear without a value.
+func (o *optionalString) IsBoolFlag() bool { return true }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestParseFlagsResume(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		resume  bool
+		id      string
+		model   string
+		wantErr bool
+	}{
+		{name: "no resume", args: []string{"--model", "m"}, model: "m"},
+		{name: "latest", args: []string{"--resume"}, resume: true},
+		{name: "latest before flag", args: []string{"--resume", "--model", "m"}, resume: true, model: "m"},
+		{name: "id with equals", args: []string{"--resume=s1", "--model", "m"}, resume: true, id: "s1", model: "m"},
+		{name: "id with space", args: []string{"--resume", "s1"}, resume: true, id: "s1"},
+		{name: "id with space before flag", args: []string{"--resume", "s1", "--model", "m"}, resume: true, id: "s1", model: "m"},
+		{name: "session", args: []string{"--session", "s1", "--model", "m"}, resume: true, id: "s1", model: "m"},
+		{name: "session and same resume", args: []string{"--resume=s1", "--session", "s1"}, resume: true, id: "s1"},
+		{name: "session and other resume", args: []string{"--resume=s1", "--session", "s2"}, wantErr: true},
+		{name: "stray argument", args: []string{"s1"}, wantErr: true},
+		{name: "two ids", args: []string{"--resume", "s1", "s2"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("ms-cli", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			cfg, err := parseFlags(fs, tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseFlags(%q) succeeded, want an error", tt.args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFlags(%q): %v", tt.args, err)
+			}
+			if cfg.Resume != tt.resume || cfg.ResumeID != tt.id || cfg.Model != tt.model {
+				t.Errorf("got resume %v id %q model %q, want %v %q %q", cfg.Resume, cfg.ResumeID, cfg.Model, tt.resume, tt.id, tt.model)
+			}
+		})
+	}
+}
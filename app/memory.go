@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vigo999/ms-cli/agent/memory"
+	"github.com/vigo999/ms-cli/ui/model"
+)
+
+// memoryListLimit bounds how many memories /memory list and search show.
+const memoryListLimit = 20
+
+// cmdMemory handles "/memory list|search|forget|pin".
+func (a *Application) cmdMemory(args []string) {
+	if a.memory == nil {
+		a.memoryError("Memory is not available. Enable it with memory.enabled in the config.")
+		return
+	}
+	if len(args) == 0 {
+		a.EventCh <- model.Event{
+			Type:    model.AgentReply,
+			Message: "Usage: /memory list | search <query> | forget <id> | pin <id>",
+		}
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		items, err := a.memory.List(memoryListLimit)
+		if err != nil {
+			a.memoryError(fmt.Sprintf("Failed to list memories: %v", err))
+			return
+		}
+		a.showMemories("Memories (most recent first):", items)
+	case "search":
+		if len(args) < 2 {
+			a.memoryError("Usage: /memory search <query>")
+			return
+		}
+		query := strings.Join(args[1:], " ")
+		items, err := a.memory.Search(query, memoryListLimit)
+		if err != nil {
+			a.memoryError(fmt.Sprintf("Failed to search memories: %v", err))
+			return
+		}
+		a.showMemories(fmt.Sprintf("Memories matching %q:", query), items)
+	case "forget":
+		if len(args) != 2 {
+			a.memoryError("Usage: /memory forget <id>")
+			return
+		}
+		item, err := a.memory.Get(args[1])
+		if err == nil && item == nil {
+			err = fmt.Errorf("memory not found: %s", args[1])
+		}
+		if err == nil {
+			err = a.memory.Delete(args[1])
+		}
+		if err != nil {
+			a.memoryError(fmt.Sprintf("Failed to forget memory: %v", err))
+			return
+		}
+		a.EventCh <- model.Event{Type: model.AgentReply, Message: fmt.Sprintf("Forgot memory %s.", args[1])}
+	case "pin":
+		if len(args) != 2 {
+			a.memoryError("Usage: /memory pin <id>")
+			return
+		}
+		if err := a.memory.Pin(args[1]); err != nil {
+			a.memoryError(fmt.Sprintf("Failed to pin memory: %v", err))
+			return
+		}
+		a.EventCh <- model.Event{
+			Type:    model.AgentReply,
+			Message: fmt.Sprintf("Pinned memory %s; it is kept and added to every task.", args[1]),
+		}
+	default:
+		a.memoryError(fmt.Sprintf("Unknown subcommand: %s. Usage: /memory list|search|forget|pin", args[0]))
+	}
+}
+
+func (a *Application) showMemories(title string, items []*memory.MemoryItem) {
+	if len(items) == 0 {
+		a.EventCh <- model.Event{Type: model.AgentReply, Message: "No memories found."}
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(title + "\n\n")
+	for _, item := range items {
+		marker := "  "
+		if item.HasTag(memory.PinnedTag) {
+			marker = "* "
+		}
+		fmt.Fprintf(&sb, "%s%s  [%s] %s  (%s)\n",
+			marker, item.ID, item.Type, oneLine(item.Content, 100), item.CreatedAt.Format(time.DateTime))
+	}
+	sb.WriteString("\n* pinned. Use /memory forget <id> or /memory pin <id>.")
+	a.EventCh <- model.Event{Type: model.AgentReply, Message: sb.String()}
+}
+
+// oneLine collapses whitespace and shortens s to max runes.
+func oneLine(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > max {
+		s = string(r[:max]) + "..."
+	}
+	return s
+}
+
+func (a *Application) memoryError(msg string) {
+	a.EventCh <- model.Event{
+		Type:     model.ToolError,
+		ToolName: "memory",
+		Message:  msg,
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vigo999/ms-cli/permission"
+	"github.com/vigo999/ms-cli/ui/model"
+)
+
+// permissionCancelTimeout bounds how long a cancelled request waits to
+// dismiss its prompt.
+var permissionCancelTimeout = time.Second
+
+// permissionPrompt implements permission.PermissionUI by showing a modal in
+// the TUI. The calling tool goroutine blocks until the user answers.
+type permissionPrompt struct {
+	eventCh chan<- model.Event
+
+	// mu keeps one prompt on screen at a time when tool calls run in
+	// parallel.
+	mu sync.Mutex
+}
+
+func newPermissionPrompt(eventCh chan<- model.Event) *permissionPrompt {
+	return &permissionPrompt{eventCh: eventCh}
+}
+
+// RequestPermission shows the prompt and waits for the user's choice.
+func (pp *permissionPrompt) RequestPermission(ctx context.Context, req permission.PermissionRequest) (permission.PermissionLevel, error) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	reply := make(chan model.PermissionChoice, 1)
+	prompt := &model.PermissionPrompt{
+		Tool:   req.Tool,
+		Target: req.Action,
+		Scope:  req.Scope(),
+		Reply:  reply,
+	}
+	if req.Path != "" {
+		prompt.Target = req.Path
+	}
+	if req.Danger != nil {
+		prompt.Category = req.Danger.Category
+		prompt.Description = req.Danger.Description
+		if seg := req.Danger.Segment; seg != "" && seg != strings.TrimSpace(req.Action) {
+			prompt.Segment = seg
+			prompt.Target = req.Danger.Highlight(req.Action)
+		}
+	}
+
+	select {
+	case pp.eventCh <- model.Event{Type: model.PermissionRequest, Permission: prompt}:
+	case <-ctx.Done():
+		return permission.PermissionDeny, ctx.Err()
+	}
+
+	select {
+	case choice := <-reply:
+		switch choice {
+		case model.PermissionAllowOnce:
+			return permission.PermissionAllowOnce, nil
+		case model.PermissionAllowSession:
+			return permission.PermissionAllowSession, nil
+		case model.PermissionAllowAlways:
+			return permission.PermissionAllowAlways, nil
+		default:
+			return permission.PermissionDeny, nil
+		}
+	case <-ctx.Done():
+		// Take the prompt off the screen; nobody is waiting for the answer.
+		// The UI may already have stopped reading, e.g. on quit.
+		select {
+		case pp.eventCh <- model.Event{Type: model.PermissionCancel, Permission: prompt}:
+		case <-time.After(permissionCancelTimeout):
+		}
+		return permission.PermissionDeny, ctx.Err()
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vigo999/ms-cli/permission"
+	"github.com/vigo999/ms-cli/ui/model"
+)
+
+func TestRequestPermissionCancelDismissesPrompt(t *testing.T) {
+	events := make(chan model.Event, 4)
+	pp := newPermissionPrompt(events)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		level, err := pp.RequestPermission(ctx, permission.PermissionRequest{Tool: "shell", Action: "rm -rf build"})
+		if level != permission.PermissionDeny {
+			err = errors.New("cancelled request was not denied")
+		}
+		done <- err
+	}()
+
+	req := <-events
+	if req.Type != model.PermissionRequest || req.Permission == nil {
+		t.Fatalf("first event = %+v, want a PermissionRequest", req)
+	}
+	cancel()
+
+	select {
+	case ev := <-events:
+		if ev.Type != model.PermissionCancel || ev.Permission != req.Permission {
+			t.Errorf("event = %+v, want PermissionCancel for the shown prompt", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no PermissionCancel after the request was cancelled")
+	}
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestRequestPermissionCancelWithoutReader(t *testing.T) {
+	permissionCancelTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { permissionCancelTimeout = time.Second })
+
+	events := make(chan model.Event)
+	pp := newPermissionPrompt(events)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := pp.RequestPermission(ctx, permission.PermissionRequest{Tool: "shell", Action: "make"})
+		done <- err
+	}()
+
+	<-events // the UI shows the prompt, then stops reading
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("RequestPermission blocked on a UI that stopped reading")
+	}
+}
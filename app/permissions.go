@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vigo999/ms-cli/permission"
+	"github.com/vigo999/ms-cli/ui/model"
+)
+
+const permissionDecisionsUsage = "Usage: /permission list|revoke <tool|command|path>|export <path>|import <path> [--user]|expire [age]|explain <tool> <args>"
+
+// cmdPermissionDecisions handles the /permission subcommands that manage
+// remembered decisions.
+func (a *Application) cmdPermissionDecisions(svc *permission.DefaultPermissionService, args []string) {
+	if args[0] == "explain" {
+		if len(args) < 2 {
+			a.permissionError("Usage: /permission explain <tool> <args>")
+			return
+		}
+		a.cmdPermissionExplain(svc, args[1], strings.Join(args[2:], " "))
+		return
+	}
+	if a.permStore == nil {
+		a.permissionError("Saved permission decisions are not available.")
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		a.cmdPermissionList()
+	case "revoke":
+		if len(args) != 2 {
+			a.permissionError("Usage: /permission revoke <tool|command|path>")
+			return
+		}
+		a.cmdPermissionRevoke(svc, args[1])
+	case "export":
+		if len(args) != 2 {
+			a.permissionError("Usage: /permission export <path>")
+			return
+		}
+		a.cmdPermissionExport(args[1])
+	case "import":
+		if len(args) < 2 || len(args) > 3 || (len(args) == 3 && args[2] != "--user") {
+			a.permissionError("Usage: /permission import <path> [--user]")
+			return
+		}
+		a.cmdPermissionImport(svc, args[1], len(args) == 3)
+	case "expire":
+		if len(args) > 2 {
+			a.permissionError("Usage: /permission expire [age]")
+			return
+		}
+		a.cmdPermissionExpire(svc, args[1:])
+	default:
+		a.permissionError(permissionDecisionsUsage)
+	}
+}
+
+func (a *Application) cmdPermissionList() {
+	entries := a.permStore.Entries()
+	if len(entries) == 0 {
+		a.EventCh <- model.Event{
+			Type:    model.AgentReply,
+			Message: "No saved permission decisions. Choose \"Always allow\" in a permission prompt to add one.",
+		}
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Saved permission decisions:\n")
+	layers := []struct {
+		label, source string
+		store         *permission.FilePermissionStore
+	}{
+		{"Project", permission.SourceProject, a.permStore.Project()},
+		{"User", permission.SourceUser, a.permStore.User()},
+	}
+	for _, layer := range layers {
+		store, source := layer.store, layer.source
+		if store == nil {
+			continue
+		}
+		stats := store.GetStats()
+		fmt.Fprintf(&sb, "\n%s (%s), %d:\n", layer.label, store.GetFilePath(), stats.TotalDecisions)
+		for _, e := range entries {
+			if e.Source != source {
+				continue
+			}
+			fmt.Fprintf(&sb, "  %-13s %s  (saved %s)", e.Level, decisionScope(e.PermissionDecision), e.Timestamp.Format(time.DateTime))
+			if e.Overridden {
+				sb.WriteString("  [overridden by project]")
+			}
+			sb.WriteString("\n")
+		}
+	}
+	sb.WriteString("\nUse /permission revoke <tool|command|path> to remove one.")
+	a.EventCh <- model.Event{Type: model.AgentReply, Message: sb.String()}
+}
+
+func (a *Application) cmdPermissionRevoke(svc *permission.DefaultPermissionService, target string) {
+	removed, err := a.permStore.Remove(func(d permission.PermissionDecision) bool {
+		return decisionMatches(d, target)
+	})
+	for _, d := range removed {
+		svc.ForgetDecision(d.PermissionDecision)
+	}
+	// Decisions left in the other file still apply.
+	svc.SetStore(a.permStore)
+	if err != nil {
+		a.permissionError(fmt.Sprintf("Failed to revoke permissions: %v", err))
+		return
+	}
+	if len(removed) == 0 {
+		a.permissionError(fmt.Sprintf("No saved decision matches %q. See /permission list.", target))
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Revoked %d decision(s):\n", len(removed))
+	for _, d := range removed {
+		fmt.Fprintf(&sb, "  %s %s (%s)\n", d.Level, decisionScope(d.PermissionDecision), d.Source)
+	}
+	a.EventCh <- model.Event{Type: model.AgentReply, Message: strings.TrimSuffix(sb.String(), "\n")}
+}
+
+func (a *Application) cmdPermissionExport(path string) {
+	n, err := a.permStore.ExportToFile(path)
+	if err != nil {
+		a.permissionError(fmt.Sprintf("Failed to export permissions: %v", err))
+		return
+	}
+	a.EventCh <- model.Event{
+		Type:    model.AgentReply,
+		Message: fmt.Sprintf("Exported %d permission decision(s) to %s.", n, path),
+	}
+}
+
+func (a *Application) cmdPermissionImport(svc *permission.DefaultPermissionService, path string, user bool) {
+	if err := a.permStore.ImportFromFile(path, user); err != nil {
+		a.permissionError(fmt.Sprintf("Failed to import permissions: %v", err))
+		return
+	}
+	svc.SetStore(a.permStore)
+
+	target := a.permStore.Project().GetFilePath()
+	if user {
+		target = a.permStore.User().GetFilePath()
+	}
+	a.EventCh <- model.Event{
+		Type:    model.AgentReply,
+		Message: fmt.Sprintf("Imported permission decisions from %s into %s.", path, target),
+	}
+}
+
+func (a *Application) cmdPermissionExpire(svc *permission.DefaultPermissionService, args []string) {
+	maxAge := permission.DefaultPermissionStoreConfig().MaxAge
+	if len(args) == 1 {
+		age, err := parseAge(args[0])
+		if err != nil {
+			a.permissionError(fmt.Sprintf("Invalid age %q: use e.g. 30d or 12h.", args[0]))
+			return
+		}
+		maxAge = age
+	}
+
+	removed, err := a.permStore.RemoveExpired(maxAge)
+	for _, d := range removed {
+		svc.ForgetDecision(d.PermissionDecision)
+	}
+	svc.SetStore(a.permStore)
+	if err != nil {
+		a.permissionError(fmt.Sprintf("Failed to expire permissions: %v", err))
+		return
+	}
+	a.EventCh <- model.Event{
+		Type:    model.AgentReply,
+		Message: fmt.Sprintf("Removed %d permission decision(s) older than %s.", len(removed), formatAge(maxAge)),
+	}
+}
+
+// cmdPermissionExplain shows which rule or policy decides a tool call.
+// args is the command for shell, and otherwise a path or the raw JSON
+// arguments.
+func (a *Application) cmdPermissionExplain(svc *permission.DefaultPermissionService, tool, args string) {
+	action, path := args, ""
+	if tool != "shell" {
+		action = ""
+		path = args
+		if strings.HasPrefix(strings.TrimSpace(args), "{") {
+			action, path = args, jsonPathArg(args)
+		}
+	}
+
+	d := svc.Explain(tool, action, path)
+	target := args
+	if target == "" {
+		target = "(no arguments)"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s → %s\n", tool, target, d.Level)
+	if d.Segment != "" && d.Segment != strings.TrimSpace(action) {
+		fmt.Fprintf(&sb, "Strictest segment: %s\n", d.Segment)
+	}
+	switch {
+	case d.Rule != nil:
+		file := svc.Rules().File()
+		if file == "" {
+			file = "the rules file"
+		}
+		fmt.Fprintf(&sb, "Decided by %s in %s.", d.Reason, file)
+	case svc.Rules() != nil:
+		fmt.Fprintf(&sb, "No rule in %s matched; decided by %s.", svc.Rules().File(), d.Reason)
+	default:
+		fmt.Fprintf(&sb, "No rules file (.mscli/permissions.yaml); decided by %s.", d.Reason)
+	}
+	a.EventCh <- model.Event{Type: model.AgentReply, Message: sb.String()}
+}
+
+// jsonPathArg returns the path or file_path field of raw tool arguments.
+func jsonPathArg(raw string) string {
+	var params struct {
+		Path     string `json:"path"`
+		FilePath string `json:"file_path"`
+	}
+	if err := json.Unmarshal([]byte(raw), &params); err != nil {
+		return ""
+	}
+	if params.Path != "" {
+		return params.Path
+	}
+	return params.FilePath
+}
+
+func (a *Application) permissionError(msg string) {
+	a.EventCh <- model.Event{
+		Type:     model.ToolError,
+		ToolName: "permission",
+		Message:  msg,
+	}
+}
+
+// decisionScope describes what a saved decision covers.
+func decisionScope(d permission.PermissionDecision) string {
+	if d.Exact {
+		return "`" + d.Action + "`"
+	}
+	return permission.PermissionRequest{Tool: d.Tool, Action: d.Action, Path: d.Path}.Scope()
+}
+
+// decisionMatches reports whether target names the decision's tool,
+// command or path.
+func decisionMatches(d permission.PermissionDecision, target string) bool {
+	switch {
+	case d.Tool == "shell" && d.Action != "":
+		fields := strings.Fields(d.Action)
+		return len(fields) > 0 && fields[0] == target
+	case d.Path != "":
+		return d.Path == target
+	default:
+		return d.Tool == target
+	}
+}
+
+// parseAge parses a duration, also accepting whole days such as "30d".
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid days %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// formatAge prints whole days as "7d".
+func formatAge(d time.Duration) string {
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
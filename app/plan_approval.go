@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/vigo999/ms-cli/agent/plan"
+	"github.com/vigo999/ms-cli/ui/model"
+)
+
+// planApproval bridges Plan and Review Mode to the TUI: plans and steps are
+// shown in the chat and, while a question is pending, the next user input
+// answers it. It also remembers the last plan for /plan.
+type planApproval struct {
+	eventCh chan<- model.Event
+
+	mu      sync.Mutex
+	pending chan string
+	last    *plan.Plan
+}
+
+func newPlanApproval(eventCh chan<- model.Event) *planApproval {
+	return &planApproval{eventCh: eventCh}
+}
+
+// lastPlan returns the most recently created plan, or nil.
+func (pa *planApproval) lastPlan() *plan.Plan {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	return pa.last
+}
+
+// setLast makes p the plan that /plan run, resume and skip act on.
+func (pa *planApproval) setLast(p *plan.Plan) {
+	pa.mu.Lock()
+	pa.last = p
+	pa.mu.Unlock()
+}
+
+// OnPlanCreated shows the plan in the chat and remembers it.
+func (pa *planApproval) OnPlanCreated(p *plan.Plan) error {
+	pa.setLast(p)
+
+	pa.eventCh <- model.Event{
+		Type:    model.AgentReply,
+		Message: p.ToMarkdown(),
+	}
+	return nil
+}
+
+// OnPlanApproved confirms the plan is about to run.
+func (pa *planApproval) OnPlanApproved(p *plan.Plan) error {
+	pa.eventCh <- model.Event{
+		Type:    model.AgentReply,
+		Message: fmt.Sprintf("Plan approved. Executing %d steps...", len(p.Steps)),
+	}
+	pa.eventCh <- model.Event{Type: model.AgentThinking}
+	return nil
+}
+
+// OnPlanRejected reports whether the plan is being revised or dropped.
+func (pa *planApproval) OnPlanRejected(p *plan.Plan, reason string) error {
+	msg := "Plan rejected. Revising with your feedback..."
+	if p.Status == plan.PlanStatusCancelled {
+		msg = "Plan rejected. Task cancelled."
+	}
+	pa.eventCh <- model.Event{Type: model.AgentReply, Message: msg}
+	return nil
+}
+
+// OnStepStarted implements plan.ModeCallback.
+func (pa *planApproval) OnStepStarted(step *plan.PlanStep, index int) error {
+	return nil
+}
+
+// OnStepCompleted reports step progress.
+func (pa *planApproval) OnStepCompleted(step *plan.PlanStep, index int, result string) error {
+	pa.eventCh <- model.Event{
+		Type:    model.AgentReply,
+		Message: fmt.Sprintf("Step %d done: %s", index+1, step.Description),
+	}
+	return nil
+}
+
+// OnStepNeedsConfirmation asks the user whether to run a step in Review Mode.
+func (pa *planApproval) OnStepNeedsConfirmation(step *plan.PlanStep, index int) (bool, error) {
+	answer, err := pa.ask(context.Background(),
+		fmt.Sprintf("Run step %d: %s? Reply \"yes\" to run it or \"no\" to skip.", index+1, step.Description))
+	if err != nil {
+		return false, err
+	}
+	return parseApproval(answer).Approved, nil
+}
+
+// AwaitApproval asks the user to approve the plan and blocks until they
+// answer.
+func (pa *planApproval) AwaitApproval(ctx context.Context, p *plan.Plan) (plan.ApprovalDecision, error) {
+	answer, err := pa.ask(ctx, "Approve this plan? Reply \"yes\" to run it, \"no\" to cancel, or describe what to change.")
+	if err != nil {
+		return plan.ApprovalDecision{}, err
+	}
+	return parseApproval(answer), nil
+}
+
+// ask shows a question and blocks until the user answers or ctx is done.
+func (pa *planApproval) ask(ctx context.Context, question string) (string, error) {
+	answerCh := make(chan string, 1)
+	pa.mu.Lock()
+	pa.pending = answerCh
+	pa.mu.Unlock()
+	defer func() {
+		pa.mu.Lock()
+		if pa.pending == answerCh {
+			pa.pending = nil
+		}
+		pa.mu.Unlock()
+	}()
+
+	pa.eventCh <- model.Event{Type: model.AgentReply, Message: question}
+
+	select {
+	case answer := <-answerCh:
+		return answer, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// answer delivers user input to a pending question. It reports false when
+// nothing is waiting, so the input is handled as usual.
+func (pa *planApproval) answer(input string) bool {
+	pa.mu.Lock()
+	defer pa.mu.Unlock()
+	if pa.pending == nil {
+		return false
+	}
+	pa.pending <- input
+	pa.pending = nil
+	return true
+}
+
+// parseApproval turns a free-form reply into a decision. Anything other than
+// a plain yes or no is feedback for a revised plan.
+func parseApproval(answer string) plan.ApprovalDecision {
+	switch strings.ToLower(strings.TrimSpace(strings.TrimRight(answer, ".!"))) {
+	case "y", "yes", "ok", "approve", "approved", "lgtm", "go", "run":
+		return plan.ApprovalDecision{Approved: true}
+	case "n", "no", "reject", "cancel", "stop":
+		return plan.ApprovalDecision{}
+	default:
+		return plan.ApprovalDecision{Feedback: strings.TrimSpace(answer)}
+	}
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -17,6 +20,18 @@ func (a *Application) Run() error {
 	if closer, ok := a.traceWriter.(interface{ Close() error }); ok {
 		defer closer.Close()
 	}
+	if a.memory != nil {
+		// Also saves the memory vector index.
+		defer a.memory.Close()
+	}
+	if closer, ok := a.shellBackend.(interface{ Close() error }); ok {
+		// Removes the session's container.
+		defer closer.Close()
+	}
+	if a.shellRunner != nil {
+		// Ends the session's shell before its container is removed.
+		defer a.shellRunner.Close()
+	}
 
 	if a.Demo {
 		return a.runDemo()
@@ -33,6 +48,22 @@ func (a *Application) runReal() error {
 	// Use /mouse off to disable if needed.
 	p := tea.NewProgram(tui, tea.WithAltScreen(), tea.WithMouseCellMotion())
 
+	// Bubble Tea quits on SIGTERM; quit on SIGHUP too, so that closing the
+	// terminal still removes the session's container.
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-hangup:
+			p.Quit()
+		case <-done:
+		}
+	}()
+
+	go a.showStartupState()
 	go a.inputLoop(userCh)
 
 	_, err := p.Run()
@@ -40,6 +71,32 @@ func (a *Application) runReal() error {
 	return err
 }
 
+// showStartupState replays a resumed session and lists unfinished plans. It
+// runs once the TUI is consuming events, as a replay can exceed the event
+// buffer.
+func (a *Application) showStartupState() {
+	a.warnTokenizer()
+	if a.sessions != nil {
+		if s := a.sessions.Current(); s != nil && len(s.Messages) > 0 {
+			a.replaySession(s)
+		}
+	}
+	a.restorePlans()
+}
+
+// warnTokenizer tells the user that token counts are estimated because no
+// BPE vocabulary is available for the model.
+func (a *Application) warnTokenizer() {
+	if a.tokenizerErr == nil {
+		return
+	}
+	a.EventCh <- model.Event{
+		Type:     model.ToolError,
+		ToolName: "tokenizer",
+		Message:  fmt.Sprintf("Token counts are estimated: %v", a.tokenizerErr),
+	}
+}
+
 // inputLoop reads user input submitted via the TUI and routes it to the
 // engine or slash-command handler.
 func (a *Application) inputLoop(userCh <-chan string) {
@@ -62,6 +119,11 @@ func (a *Application) processInput(input string) {
 		return
 	}
 
+	// A plan waiting for approval takes the input as its answer.
+	if a.planApproval != nil && a.planApproval.answer(trimmed) {
+		return
+	}
+
 	// Free-form: send to engine in a goroutine
 	go a.runTask(trimmed)
 }
@@ -76,28 +138,45 @@ func (a *Application) runTask(description string) {
 		Description: description,
 	}
 
+	// Events reach the UI live through forwardEvent; only errors the engine
+	// did not report are added here.
 	events, err := a.Engine.Run(task)
-	if err != nil {
-		// Provide user-friendly error message
-		errMsg := err.Error()
-		if strings.Contains(errMsg, "timeout") || strings.Contains(errMsg, "deadline") {
-			errMsg = fmt.Sprintf("%s\n\nTip: The request timed out. This can happen with long conversations. Try:\n  1. Run /compact to reduce context size\n  2. Start a new conversation with /clear\n  3. Increase timeout in config (model.timeout_sec)", errMsg)
-		}
+	a.reportTaskError(events, err)
+}
+
+// reportTaskError shows a task's error unless the engine already delivered a
+// TaskFailed event for it. Timeouts always get a hint on how to recover.
+func (a *Application) reportTaskError(events []loop.Event, err error) {
+	if err == nil {
+		return
+	}
+	errMsg := err.Error()
+	if strings.Contains(errMsg, "timeout") || strings.Contains(errMsg, "deadline") {
 		a.EventCh <- model.Event{
 			Type:     model.ToolError,
 			ToolName: "Engine",
-			Message:  errMsg,
+			Message:  fmt.Sprintf("%s\n\nTip: The request timed out. This can happen with long conversations. Try:\n  1. Run /compact to reduce context size\n  2. Start a new conversation with /clear\n  3. Increase timeout in config (model.timeout_sec)", errMsg),
 		}
 		return
 	}
-
-	// Convert loop events to UI events
 	for _, ev := range events {
-		uiEvent := a.convertEvent(ev)
-		if uiEvent != nil {
-			a.EventCh <- *uiEvent
+		if ev.Type == loop.EventTaskFailed {
+			return
 		}
 	}
+	a.EventCh <- model.Event{
+		Type:     model.ToolError,
+		ToolName: "Task",
+		Message:  errMsg,
+	}
+}
+
+// forwardEvent is the engine's event sink: it converts each loop event and
+// sends it to the UI as soon as it is emitted.
+func (a *Application) forwardEvent(ev loop.Event) {
+	if uiEvent := a.convertEvent(ev); uiEvent != nil {
+		a.EventCh <- *uiEvent
+	}
 }
 
 // convertEvent converts a loop event to a UI event.
@@ -120,6 +199,18 @@ func (a *Application) convertEvent(ev loop.Event) *model.Event {
 			TokensUsed: ev.TokensUsed,
 		}
 
+	case loop.EventReplyDelta:
+		return &model.Event{
+			Type:    model.AgentReplyDelta,
+			Message: ev.Message,
+		}
+
+	case loop.EventThinkingDelta:
+		return &model.Event{
+			Type:    model.AgentThinkingDelta,
+			Message: ev.Message,
+		}
+
 	case loop.EventToolRead:
 		return &model.Event{
 			Type:       model.ToolRead,
@@ -207,6 +298,7 @@ func (a *Application) convertEvent(ev loop.Event) *model.Event {
 			CtxUsed:    ev.CtxUsed,
 			CtxMax:     ev.CtxMax,
 			TokensUsed: ev.TokensUsed,
+			CostUSD:    ev.CostUSD,
 		}
 
 	case loop.EventTaskCompleted:
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vigo999/ms-cli/agent/loop"
+	"github.com/vigo999/ms-cli/ui/model"
+)
+
+func TestReportTaskError(t *testing.T) {
+	failed := []loop.Event{loop.NewEvent(loop.EventTaskStarted, ""), loop.NewEvent(loop.EventTaskFailed, "LLM error: boom")}
+	tests := []struct {
+		name   string
+		events []loop.Event
+		err    error
+		want   string
+	}{
+		{name: "no error", events: failed},
+		{name: "reported by the engine", events: failed, err: errors.New("LLM completion: boom")},
+		{name: "no events", err: errors.New("provider: unauthorized"), want: "provider: unauthorized"},
+		{name: "no TaskFailed", events: failed[:1], err: errors.New("plan: boom"), want: "plan: boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &Application{EventCh: make(chan model.Event, 1)}
+			app.reportTaskError(tt.events, tt.err)
+			select {
+			case ev := <-app.EventCh:
+				if tt.want == "" {
+					t.Fatalf("unexpected event %+v", ev)
+				}
+				if ev.Type != model.ToolError || ev.Message != tt.want {
+					t.Errorf("event = %+v, want a ToolError %q", ev, tt.want)
+				}
+			default:
+				if tt.want != "" {
+					t.Fatalf("error %q was not shown", tt.want)
+				}
+			}
+		})
+	}
+
+	app := &Application{EventCh: make(chan model.Event, 1)}
+	app.reportTaskError(failed, errors.New("LLM completion: context deadline exceeded"))
+	if ev := <-app.EventCh; ev.ToolName != "Engine" {
+		t.Errorf("timeouts should still get the hint, got %+v", ev)
+	}
+}
+
+func TestWarnTokenizer(t *testing.T) {
+	app := &Application{EventCh: make(chan model.Event, 1)}
+	app.warnTokenizer()
+	select {
+	case ev := <-app.EventCh:
+		t.Fatalf("unexpected warning %+v", ev)
+	default:
+	}
+
+	app.tokenizerErr = errors.New("vocabulary o200k_base.tiktoken not found")
+	app.warnTokenizer()
+	if ev := <-app.EventCh; ev.Type != model.ToolError || ev.ToolName != "tokenizer" {
+		t.Errorf("event = %+v, want a tokenizer warning", ev)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vigo999/ms-cli/agent/session"
+	"github.com/vigo999/ms-cli/integrations/llm"
+	"github.com/vigo999/ms-cli/ui/model"
+)
+
+// maxReplayMessages bounds how many messages of a loaded session are shown
+// in the chat; the full history is still restored into the context.
+const maxReplayMessages = 50
+
+// recordMessage is the engine's message sink: it saves every message to the
+// current session, starting one on the first message.
+func (a *Application) recordMessage(msg llm.Message) {
+	if a.sessions == nil {
+		return
+	}
+	if a.sessions.Current() == nil {
+		if _, err := a.sessions.CreateAndSetCurrent(sessionName(msg), a.WorkDir); err != nil {
+			a.writeSessionError(err)
+			return
+		}
+	}
+	if err := a.sessions.AddMessageToCurrent(msg); err != nil {
+		a.writeSessionError(err)
+	}
+}
+
+// currentSessionID names the directory tool output artifacts are saved in.
+func (a *Application) currentSessionID() string {
+	if s := a.sessions.Current(); s != nil {
+		return string(s.ID)
+	}
+	return ""
+}
+
+func (a *Application) writeSessionError(err error) {
+	if a.traceWriter != nil {
+		_ = a.traceWriter.Write("session_error", map[string]any{"error": err.Error()})
+	}
+}
+
+// sessionName names a new session after its first user message.
+func sessionName(msg llm.Message) string {
+	name := strings.Join(strings.Fields(msg.Content), " ")
+	if msg.Role != "user" || name == "" {
+		return ""
+	}
+	if r := []rune(name); len(r) > 40 {
+		name = string(r[:40]) + "..."
+	}
+	return name
+}
+
+// resumeSession loads a session, or the most recent active one when id is
+// empty, and rebuilds the context history from its messages.
+func (a *Application) resumeSession(id string) (*session.Session, error) {
+	if id == "" {
+		infos, err := a.sessions.ListActive()
+		if err != nil {
+			return nil, err
+		}
+		if len(infos) == 0 {
+			return nil, fmt.Errorf("no session to resume")
+		}
+		id = string(infos[0].ID)
+	}
+
+	s, err := a.sessions.Load(session.ID(id))
+	if err != nil {
+		return nil, err
+	}
+
+	// Replayed messages are compacted without calling the model.
+	if err := a.ctxManager.Restore(s.Messages); err != nil {
+		return nil, fmt.Errorf("restore session %s: %w", id, err)
+	}
+	return s, nil
+}
+
+// replaySession shows a loaded session's conversation in the chat.
+func (a *Application) replaySession(s *session.Session) {
+	a.EventCh <- model.Event{
+		Type:    model.ClearScreen,
+		Message: fmt.Sprintf("Resumed session %s (%s), %d messages.", s.Name, s.ID, len(s.Messages)),
+	}
+
+	msgs := s.Messages
+	if len(msgs) > maxReplayMessages {
+		msgs = msgs[len(msgs)-maxReplayMessages:]
+	}
+	for _, msg := range msgs {
+		switch {
+		case msg.Role == "user":
+			a.EventCh <- model.Event{Type: model.UserMessage, Message: msg.Content}
+		case msg.Role == "assistant" && strings.TrimSpace(msg.Content) != "":
+			a.EventCh <- model.Event{Type: model.AgentReply, Message: msg.Content}
+		}
+	}
+}
+
+// cmdSession handles "/session list|new|load|rename|archive|export|import".
+func (a *Application) cmdSession(args []string) {
+	if a.sessions == nil {
+		a.sessionError("Sessions are not available.")
+		return
+	}
+	if len(args) == 0 {
+		a.showCurrentSession()
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		a.cmdSessionList()
+	case "new":
+		a.cmdSessionNew(strings.Join(args[1:], " "))
+	case "load":
+		if len(args) != 2 {
+			a.sessionError("Usage: /session load <id>")
+			return
+		}
+		a.cmdSessionLoad(args[1])
+	case "rename":
+		if len(args) < 2 {
+			a.sessionError("Usage: /session rename <name>")
+			return
+		}
+		a.cmdSessionRename(strings.Join(args[1:], " "))
+	case "archive":
+		if len(args) > 2 {
+			a.sessionError("Usage: /session archive [id]")
+			return
+		}
+		a.cmdSessionArchive(args[1:])
+	case "export":
+		if len(args) < 2 || len(args) > 3 {
+			a.sessionError("Usage: /session export <path> [id]")
+			return
+		}
+		a.cmdSessionExport(args[1], args[2:])
+	case "import":
+		if len(args) != 2 {
+			a.sessionError("Usage: /session import <path>")
+			return
+		}
+		a.cmdSessionImport(args[1])
+	default:
+		a.sessionError(fmt.Sprintf("Unknown subcommand: %s. Usage: /session list|new|load|rename|archive|export|import", args[0]))
+	}
+}
+
+func (a *Application) showCurrentSession() {
+	msg := "No session yet; one starts with your first message."
+	if s := a.sessions.Current(); s != nil {
+		msg = fmt.Sprintf("Current session: %s (%s), %d messages, updated %s.",
+			s.Name, s.ID, len(s.Messages), s.UpdatedAt.Format(time.DateTime))
+	}
+	a.EventCh <- model.Event{
+		Type:    model.AgentReply,
+		Message: msg + "\n\nUsage: /session list|new [name]|load <id>|rename <name>|archive [id]|export <path> [id]|import <path>",
+	}
+}
+
+func (a *Application) cmdSessionList() {
+	infos, err := a.sessions.ListActive()
+	if err != nil {
+		a.sessionError(fmt.Sprintf("Failed to list sessions: %v", err))
+		return
+	}
+	if len(infos) == 0 {
+		a.EventCh <- model.Event{Type: model.AgentReply, Message: "No saved sessions."}
+		return
+	}
+
+	var current session.ID
+	if s := a.sessions.Current(); s != nil {
+		current = s.ID
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Sessions (most recent first):\n\n")
+	for _, info := range infos {
+		marker := "  "
+		if info.ID == current {
+			marker = "* "
+		}
+		fmt.Fprintf(&sb, "%s%s  %s  (%d messages, %s)\n",
+			marker, info.ID, info.Name, info.MessageCount, info.UpdatedAt.Format(time.DateTime))
+	}
+	sb.WriteString("\nUse /session load <id> to continue one.")
+	a.EventCh <- model.Event{Type: model.AgentReply, Message: sb.String()}
+}
+
+func (a *Application) cmdSessionNew(name string) {
+	a.ctxManager.Clear()
+	if name == "" {
+		// The session is created with the first message.
+		a.sessions.UnsetCurrent()
+		a.EventCh <- model.Event{Type: model.ClearScreen, Message: "Started a new session."}
+		return
+	}
+
+	s, err := a.sessions.CreateAndSetCurrent(name, a.WorkDir)
+	if err != nil {
+		a.sessionError(fmt.Sprintf("Failed to create session: %v", err))
+		return
+	}
+	a.EventCh <- model.Event{
+		Type:    model.ClearScreen,
+		Message: fmt.Sprintf("Started new session %s (%s).", s.Name, s.ID),
+	}
+}
+
+func (a *Application) cmdSessionLoad(id string) {
+	s, err := a.resumeSession(id)
+	if err != nil {
+		a.sessionError(fmt.Sprintf("Failed to load session: %v", err))
+		return
+	}
+	a.replaySession(s)
+}
+
+func (a *Application) cmdSessionRename(name string) {
+	s := a.sessions.Current()
+	if s == nil {
+		a.sessionError("No session yet; one starts with your first message.")
+		return
+	}
+	if err := a.sessions.Rename(s.ID, name); err != nil {
+		a.sessionError(fmt.Sprintf("Failed to rename session: %v", err))
+		return
+	}
+	a.EventCh <- model.Event{
+		Type:    model.AgentReply,
+		Message: fmt.Sprintf("Session %s renamed to %q.", s.ID, name),
+	}
+}
+
+func (a *Application) cmdSessionArchive(args []string) {
+	var id session.ID
+	current := a.sessions.Current()
+	switch {
+	case len(args) == 1:
+		id = session.ID(args[0])
+	case current != nil:
+		id = current.ID
+	default:
+		a.sessionError("No session to archive. Usage: /session archive [id]")
+		return
+	}
+
+	if err := a.sessions.Archive(id); err != nil {
+		a.sessionError(fmt.Sprintf("Failed to archive session: %v", err))
+		return
+	}
+
+	msg := fmt.Sprintf("Session %s archived.", id)
+	if current != nil && current.ID == id {
+		a.sessions.UnsetCurrent()
+		a.ctxManager.Clear()
+		msg += " A new session starts with your next message."
+	}
+	a.EventCh <- model.Event{Type: model.AgentReply, Message: msg}
+}
+
+func (a *Application) cmdSessionExport(path string, args []string) {
+	var id session.ID
+	if len(args) == 1 {
+		id = session.ID(args[0])
+	} else if s := a.sessions.Current(); s != nil {
+		if err := a.sessions.SaveCurrent(); err != nil {
+			a.sessionError(fmt.Sprintf("Failed to save session: %v", err))
+			return
+		}
+		id = s.ID
+	} else {
+		a.sessionError("No session to export. Usage: /session export <path> [id]")
+		return
+	}
+
+	if err := a.sessionStore.Export(id, path); err != nil {
+		a.sessionError(fmt.Sprintf("Failed to export session: %v", err))
+		return
+	}
+	a.EventCh <- model.Event{
+		Type:    model.AgentReply,
+		Message: fmt.Sprintf("Session %s exported to %s.", id, path),
+	}
+}
+
+func (a *Application) cmdSessionImport(path string) {
+	s, err := a.sessionStore.Import(path)
+	if err != nil {
+		a.sessionError(fmt.Sprintf("Failed to import session: %v", err))
+		return
+	}
+	a.EventCh <- model.Event{
+		Type:    model.AgentReply,
+		Message: fmt.Sprintf("Imported session %s as %s. Use /session load %s to continue it.", s.Name, s.ID, s.ID),
+	}
+}
+
+func (a *Application) sessionError(msg string) {
+	a.EventCh <- model.Event{
+		Type:     model.ToolError,
+		ToolName: "session",
+		Message:  msg,
+	}
+}
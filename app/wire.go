@@ -2,13 +2,21 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/vigo999/ms-cli/agent/context"
+	"github.com/vigo999/ms-cli/agent/cost"
 	"github.com/vigo999/ms-cli/agent/loop"
+	"github.com/vigo999/ms-cli/agent/memory"
+	"github.com/vigo999/ms-cli/agent/plan"
+	"github.com/vigo999/ms-cli/agent/session"
 	"github.com/vigo999/ms-cli/configs"
+	"github.com/vigo999/ms-cli/integrations/llm"
 	"github.com/vigo999/ms-cli/permission"
 	"github.com/vigo999/ms-cli/tools"
+	"github.com/vigo999/ms-cli/tools/artifact"
+	"github.com/vigo999/ms-cli/tools/shell"
 	"github.com/vigo999/ms-cli/trace"
 	"github.com/vigo999/ms-cli/ui/model"
 )
@@ -23,33 +31,60 @@ type Application struct {
 	WorkDir      string
 	RepoURL      string
 	Config       *configs.Config
+	provider     llm.Provider
 	toolRegistry *tools.Registry
 	ctxManager   *context.Manager
 	permService  permission.PermissionService
+	permStore    *permission.LayeredPermissionStore
 	stateManager *configs.StateManager
 	traceWriter  trace.Writer
+	costTracker  *cost.Tracker
+	planApproval *planApproval
+	planStore    *plan.Store
+	sessions     *session.Manager
+	artifacts    *artifact.Store
+	sessionStore *session.FileStore
+	memory       *memory.Manager
+	shellBackend shell.Backend
+	shellRunner  *shell.Runner
+	// tokenizerErr is why token counts are estimated rather than counted
+	// with a BPE vocabulary; nil when a vocabulary is in use.
+	tokenizerErr error
 }
 
-// SetProvider updates model/key and reinitializes the engine.
-// providerName is kept for command compatibility and only accepts "openai".
+// SetProvider updates provider/model/key and reinitializes the engine.
+// An empty providerName keeps the current provider.
 func (a *Application) SetProvider(providerName, modelName, apiKey string) error {
-	if providerName != "" && providerName != "openai" {
-		return fmt.Errorf("unsupported provider: %s (only openai-compatible is supported)", providerName)
+	providerName = strings.ToLower(strings.TrimSpace(providerName))
+	switch providerName {
+	case "", configs.ProviderOpenAI, configs.ProviderAnthropic, configs.ProviderLocal:
+	default:
+		return fmt.Errorf("unsupported provider: %s (supported: openai, anthropic, local)", providerName)
 	}
 
-	// Update config
+	// Endpoint and key belong to the previous provider; resolve them again
+	// from the new provider's variables. The config changes only once the
+	// new provider is up, so a failed switch keeps the current one.
+	modelCfg := a.Config.Model
+	if providerName != "" && providerName != modelCfg.ProviderName() {
+		modelCfg.Provider = providerName
+		modelCfg.URL = ""
+		modelCfg.Key = ""
+		configs.ApplyProviderEnv(&modelCfg)
+	}
 	if modelName != "" {
-		a.Config.Model.Model = modelName
+		modelCfg.Model = modelName
 	}
 	if apiKey != "" {
-		a.Config.Model.Key = apiKey
+		modelCfg.Key = apiKey
 	}
 
 	// Initialize new provider
-	provider, err := initProvider(a.Config.Model)
+	provider, err := initProviderChain(modelCfg, a.traceWriter)
 	if err != nil {
 		return fmt.Errorf("init provider: %w", err)
 	}
+	a.Config.Model = modelCfg
 
 	// Create new engine with the new provider but keep other settings
 	engineCfg := loop.EngineConfig{
@@ -57,14 +92,50 @@ func (a *Application) SetProvider(providerName, modelName, apiKey string) error
 		MaxTokens:      a.Config.Budget.MaxTokens,
 		Temperature:    float32(a.Config.Model.Temperature),
 		TimeoutPerTurn: time.Duration(a.Config.Model.TimeoutSec) * time.Second,
+		Stream:         a.Config.Model.Stream,
+		MaxConcurrency: a.Config.Execution.MaxConcurrency,
+		MemoryTopK:     a.Config.Memory.TopK,
+		MemoryBudget:   a.Config.Memory.BudgetTokens,
+		MemoryExtract:  a.Config.Memory.Extract,
 	}
+	// Count tokens with the new model's encoding, or the estimate when it is
+	// unavailable; calibration starts over.
+	counter, err := tokenCounter(a.Config.Context, a.Config.Model.Model, a.WorkDir)
+	a.ctxManager.SetTokenCounter(counter)
+	a.tokenizerErr = err
+
 	newEngine := loop.NewEngine(engineCfg, provider, a.toolRegistry)
 	newEngine.SetContextManager(a.ctxManager)
 	newEngine.SetPermissionService(a.permService)
 	newEngine.SetTraceWriter(a.traceWriter)
+	newEngine.SetCostTracker(a.costTracker)
+	if a.planStore != nil {
+		newEngine.SetPlanStore(a.planStore)
+	}
+	if a.memory != nil {
+		newEngine.SetMemory(a.memory)
+	}
+	if a.artifacts != nil {
+		newEngine.SetArtifactStore(a.artifacts)
+	}
+	if a.planApproval != nil {
+		newEngine.SetModeCallback(a.planApproval)
+		newEngine.SetPlanApprover(a.planApproval)
+	}
+	if a.costTracker != nil {
+		a.costTracker.SetModel(a.Config.Model.Model)
+	}
+	newEngine.SetEventSink(a.forwardEvent)
+	if a.sessions != nil {
+		newEngine.SetMessageSink(a.recordMessage)
+	}
+	if a.Engine != nil {
+		newEngine.SetRunMode(a.Engine.RunMode())
+	}
 
 	// Replace the engine
 	a.Engine = newEngine
+	a.provider = provider
 
 	// Save state to disk
 	if a.stateManager != nil {
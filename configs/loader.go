@@ -101,24 +101,20 @@ func FindConfigFile() string {
 }
 
 // ApplyEnvOverrides applies environment variable overrides to the config.
-// Precedence: MSCLI_* > OPENAI_* > YAML > defaults.
+// Precedence: MSCLI_* > OPENAI_*/ANTHROPIC_*/OLLAMA_HOST > YAML > defaults.
+// Provider specific variables only apply to the provider currently selected.
 func ApplyEnvOverrides(cfg *Config) {
 	// Model settings
-	if v := os.Getenv("OPENAI_MODEL"); v != "" {
-		cfg.Model.Model = v
+	if v := strings.TrimSpace(os.Getenv("MSCLI_PROVIDER")); v != "" {
+		cfg.Model.Provider = strings.ToLower(v)
 	}
+	ApplyProviderEnv(&cfg.Model)
 	if v := os.Getenv("MSCLI_MODEL"); v != "" {
 		cfg.Model.Model = v
 	}
-	if v := strings.TrimSpace(os.Getenv("OPENAI_API_KEY")); v != "" {
-		cfg.Model.Key = v
-	}
 	if v := strings.TrimSpace(os.Getenv("MSCLI_API_KEY")); v != "" {
 		cfg.Model.Key = v
 	}
-	if v := strings.TrimSpace(os.Getenv("OPENAI_BASE_URL")); v != "" {
-		cfg.Model.URL = v
-	}
 	if v := strings.TrimSpace(os.Getenv("MSCLI_BASE_URL")); v != "" {
 		cfg.Model.URL = v
 	}
@@ -149,6 +145,11 @@ func ApplyEnvOverrides(cfg *Config) {
 			cfg.Budget.MaxCostUSD = f
 		}
 	}
+	if v := os.Getenv("MSCLI_BUDGET_DAILY"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil {
+			cfg.Budget.DailyLimit = i
+		}
+	}
 
 	// UI settings
 	if v := os.Getenv("MSCLI_UI_ENABLED"); v != "" {
@@ -179,6 +180,11 @@ func ApplyEnvOverrides(cfg *Config) {
 		}
 	}
 
+	// Execution settings
+	if v := strings.TrimSpace(os.Getenv("MSCLI_EXECUTION_MODE")); v != "" {
+		cfg.Execution.Mode = strings.ToLower(v)
+	}
+
 	// Memory settings
 	if v := os.Getenv("MSCLI_MEMORY_ENABLED"); v != "" {
 		if b, err := strconv.ParseBool(v); err == nil {
@@ -188,6 +194,42 @@ func ApplyEnvOverrides(cfg *Config) {
 	if v := os.Getenv("MSCLI_MEMORY_PATH"); v != "" {
 		cfg.Memory.StorePath = v
 	}
+	if v := os.Getenv("MSCLI_EMBEDDING_ENABLED"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.Memory.Embedding.Enabled = b
+		}
+	}
+}
+
+// ApplyProviderEnv applies the model, key and URL variables of m's
+// provider, such as ANTHROPIC_API_KEY or OLLAMA_HOST.
+func ApplyProviderEnv(m *ModelConfig) {
+	switch m.ProviderName() {
+	case ProviderAnthropic:
+		if v := os.Getenv("ANTHROPIC_MODEL"); v != "" {
+			m.Model = v
+		}
+		if v := strings.TrimSpace(os.Getenv("ANTHROPIC_API_KEY")); v != "" {
+			m.Key = v
+		}
+		if v := strings.TrimSpace(os.Getenv("ANTHROPIC_BASE_URL")); v != "" {
+			m.URL = v
+		}
+	case ProviderLocal:
+		if v := strings.TrimSpace(os.Getenv("OLLAMA_HOST")); v != "" {
+			m.URL = ollamaURL(v)
+		}
+	default:
+		if v := os.Getenv("OPENAI_MODEL"); v != "" {
+			m.Model = v
+		}
+		if v := strings.TrimSpace(os.Getenv("OPENAI_API_KEY")); v != "" {
+			m.Key = v
+		}
+		if v := strings.TrimSpace(os.Getenv("OPENAI_BASE_URL")); v != "" {
+			m.URL = v
+		}
+	}
 }
 
 // SaveToFile saves the configuration to a YAML file.
@@ -234,3 +276,16 @@ func StringSliceEnv(key string) []string {
 	}
 	return parts
 }
+
+// ollamaURL turns an OLLAMA_HOST value such as "127.0.0.1:11434" into the
+// server's OpenAI-compatible endpoint.
+func ollamaURL(host string) string {
+	host = strings.TrimRight(host, "/")
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	if !strings.HasSuffix(host, "/v1") {
+		host += "/v1"
+	}
+	return host
+}
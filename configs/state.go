@@ -12,6 +12,7 @@ import (
 
 // State holds user preferences that persist across sessions.
 type State struct {
+	Provider     string `yaml:"provider,omitempty"`
 	Model        string `yaml:"model,omitempty"`
 	Key          string `yaml:"key,omitempty"`
 	LegacyAPIKey string `yaml:"api_key,omitempty"` // Backward compatibility.
@@ -105,6 +106,9 @@ func (m *StateManager) ApplyToConfig(cfg *Config) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	if m.state.Provider != "" {
+		cfg.Model.Provider = m.state.Provider
+	}
 	if m.state.Model != "" {
 		cfg.Model.Model = m.state.Model
 	}
@@ -120,6 +124,7 @@ func (m *StateManager) SaveFromConfig(cfg *Config) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.state.Provider = cfg.Model.Provider
 	m.state.Model = cfg.Model.Model
 	m.state.Key = cfg.Model.Key
 }
@@ -19,20 +19,64 @@ type Config struct {
 
 // ModelConfig holds the LLM model configuration.
 type ModelConfig struct {
+	Provider    string            `yaml:"provider,omitempty"`
 	URL         string            `yaml:"url,omitempty"`
 	Key         string            `yaml:"key,omitempty"`
 	Model       string            `yaml:"model"`
 	Temperature float64           `yaml:"temperature"`
 	MaxTokens   int               `yaml:"max_tokens"`
 	TimeoutSec  int               `yaml:"timeout_sec"`
+	Stream      bool              `yaml:"stream"`
 	Headers     map[string]string `yaml:"headers,omitempty"`
+
+	// ThinkingBudget enables extended thinking on providers that support it.
+	ThinkingBudget int `yaml:"thinking_budget,omitempty"`
+	// ToolMode selects how the local provider offers tools: auto, native or json.
+	ToolMode string `yaml:"tool_mode,omitempty"`
+
+	// Retry controls retries of rate limits, server errors and timeouts.
+	Retry RetryConfig `yaml:"retry"`
+	// Fallbacks are tried in order once this provider gives up.
+	Fallbacks []ModelConfig `yaml:"fallbacks,omitempty"`
+}
+
+// RetryConfig holds the retry policy for transient provider failures.
+type RetryConfig struct {
+	MaxRetries    int `yaml:"max_retries"`
+	BackoffMs     int `yaml:"backoff_ms"`
+	MaxBackoffSec int `yaml:"max_backoff_sec"`
+}
+
+// Supported model providers.
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderLocal     = "local"
+)
+
+// ProviderName returns the configured provider, defaulting to openai.
+func (m ModelConfig) ProviderName() string {
+	if m.Provider == "" {
+		return ProviderOpenAI
+	}
+	return m.Provider
 }
 
 // BudgetConfig holds the budget control configuration.
 type BudgetConfig struct {
 	MaxTokens  int     `yaml:"max_tokens"`
 	MaxCostUSD float64 `yaml:"max_cost_usd"`
-	DailyLimit int     `yaml:"daily_limit,omitempty"`
+	// DailyLimit caps the spend of one calendar day in USD; 0 disables it.
+	DailyLimit int `yaml:"daily_limit,omitempty"`
+	// Pricing adds or overrides per-model prices, keyed by model name or
+	// name prefix.
+	Pricing map[string]ModelPricing `yaml:"pricing,omitempty"`
+}
+
+// ModelPricing is the price of a model in USD per million tokens.
+type ModelPricing struct {
+	InputPerMTok  float64 `yaml:"input_per_mtok"`
+	OutputPerMTok float64 `yaml:"output_per_mtok"`
 }
 
 // UIConfig holds the UI configuration.
@@ -58,6 +102,16 @@ type ContextConfig struct {
 	ReserveTokens       int     `yaml:"reserve_tokens"`
 	CompactionThreshold float64 `yaml:"compaction_threshold"`
 	MaxHistoryRounds    int     `yaml:"max_history_rounds"`
+
+	// CompactStrategy is how old messages are compacted: summarize (the
+	// model writes a summary), hybrid, priority or simple.
+	CompactStrategy string `yaml:"compact_strategy"`
+
+	// Tokenizer counts tokens: auto picks the BPE encoding for the model,
+	// or cl100k_base, o200k_base or heuristic. Vocabularies not built into
+	// the binary are read from TokenizerDir (default .mscli/tokenizers).
+	Tokenizer    string `yaml:"tokenizer"`
+	TokenizerDir string `yaml:"tokenizer_dir,omitempty"`
 }
 
 // MemoryConfig holds the memory system configuration.
@@ -67,6 +121,30 @@ type MemoryConfig struct {
 	MaxItems  int    `yaml:"max_items"`
 	MaxBytes  int64  `yaml:"max_bytes"`
 	TTLHours  int    `yaml:"ttl_hours"`
+
+	// TopK is how many relevant memories are added to the prompt per task;
+	// BudgetTokens caps the tokens they may use.
+	TopK         int `yaml:"top_k"`
+	BudgetTokens int `yaml:"budget_tokens"`
+
+	// Extract asks the model, after each completed task, for facts and
+	// decisions to save. It is on by default and costs an extra model call
+	// per task.
+	Extract bool `yaml:"extract"`
+
+	Embedding EmbeddingConfig `yaml:"embedding"`
+}
+
+// EmbeddingConfig configures semantic memory recall through an
+// OpenAI-compatible /embeddings endpoint.
+type EmbeddingConfig struct {
+	Enabled       bool    `yaml:"enabled"`
+	URL           string  `yaml:"url,omitempty"` // default: model.url, or the OpenAI API
+	Key           string  `yaml:"key,omitempty"` // default: MSCLI_EMBEDDING_KEY, then the model key
+	Model         string  `yaml:"model"`
+	Dimensions    int     `yaml:"dimensions,omitempty"`
+	BatchSize     int     `yaml:"batch_size"`
+	MinSimilarity float64 `yaml:"min_similarity"`
 }
 
 // SkillsConfig holds the skills system configuration.
@@ -77,16 +155,34 @@ type SkillsConfig struct {
 	Workflows []string `yaml:"workflows"`
 }
 
+// Execution modes select where shell commands run.
+const (
+	ExecutionLocal   = "local"
+	ExecutionSandbox = "sandbox"
+	ExecutionDocker  = "docker"
+)
+
 // ExecutionConfig holds the execution configuration.
 type ExecutionConfig struct {
-	Mode           string       `yaml:"mode"`
-	TimeoutSec     int          `yaml:"timeout_sec"`
-	MaxConcurrency int          `yaml:"max_concurrency"`
-	Docker         DockerConfig `yaml:"docker,omitempty"`
+	Mode           string        `yaml:"mode"` // local, sandbox or docker
+	TimeoutSec     int           `yaml:"timeout_sec"`
+	MaxConcurrency int           `yaml:"max_concurrency"`
+	Docker         DockerConfig  `yaml:"docker,omitempty"`
+	Sandbox        SandboxConfig `yaml:"sandbox,omitempty"`
+
+	// SpillBytes is the size above which a tool output is saved under
+	// .cache/artifacts and only a preview enters the context; the agent
+	// reads the rest with artifact_read. 0 keeps every output in full.
+	SpillBytes int `yaml:"spill_bytes"`
+
+	// ShellSession runs the shell commands of a session in one long-lived
+	// shell, so cd and exported variables carry over between commands.
+	ShellSession bool `yaml:"shell_session"`
 }
 
 // DockerConfig holds the Docker execution configuration.
 type DockerConfig struct {
+	Runtime string            `yaml:"runtime,omitempty"` // docker (default) or podman
 	Image   string            `yaml:"image"`
 	CPU     string            `yaml:"cpu"`
 	Memory  string            `yaml:"memory"`
@@ -94,16 +190,32 @@ type DockerConfig struct {
 	Env     map[string]string `yaml:"env,omitempty"`
 }
 
+// SandboxConfig holds the Linux sandbox execution configuration. The work
+// dir is writable and the rest of the file system read-only.
+type SandboxConfig struct {
+	Isolation string `yaml:"isolation"` // auto, bwrap or namespaces
+	CPU       string `yaml:"cpu"`
+	Memory    string `yaml:"memory"`
+	Network   string `yaml:"network"` // none or host
+}
+
 // DefaultConfig returns a configuration with default values.
 func DefaultConfig() *Config {
 	return &Config{
 		Model: ModelConfig{
+			Provider:    ProviderOpenAI,
 			URL:         "https://api.openai.com/v1",
 			Model:       "gpt-4o-mini",
 			Temperature: 0.7,
 			MaxTokens:   4096,
 			TimeoutSec:  180, // 3 minutes for longer conversations
+			Stream:      true,
 			Headers:     make(map[string]string),
+			Retry: RetryConfig{
+				MaxRetries:    3,
+				BackoffMs:     1000,
+				MaxBackoffSec: 30,
+			},
 		},
 		Budget: BudgetConfig{
 			MaxTokens:  32768,
@@ -128,13 +240,23 @@ func DefaultConfig() *Config {
 			ReserveTokens:       4000,
 			CompactionThreshold: 0.85,
 			MaxHistoryRounds:    10,
+			CompactStrategy:     "summarize",
+			Tokenizer:           "auto",
 		},
 		Memory: MemoryConfig{
-			Enabled:   true,
-			StorePath: "",
-			MaxItems:  200,
-			MaxBytes:  2 * 1024 * 1024, // 2MB
-			TTLHours:  168,             // 7 days
+			Enabled:      true,
+			StorePath:    "",
+			MaxItems:     200,
+			MaxBytes:     2 * 1024 * 1024, // 2MB
+			TTLHours:     168,             // 7 days
+			TopK:         5,
+			BudgetTokens: 800,
+			Extract:      true,
+			Embedding: EmbeddingConfig{
+				Model:         "text-embedding-3-small",
+				BatchSize:     64,
+				MinSimilarity: 0.3,
+			},
 		},
 		Skills: SkillsConfig{
 			Repo:      "https://github.com/vigo/mindspore-skills.git",
@@ -146,6 +268,8 @@ func DefaultConfig() *Config {
 			Mode:           "local",
 			TimeoutSec:     1800,
 			MaxConcurrency: 2,
+			SpillBytes:     16 * 1024,
+			ShellSession:   true,
 			Docker: DockerConfig{
 				Image:   "ubuntu:22.04",
 				CPU:     "2",
@@ -153,16 +277,43 @@ func DefaultConfig() *Config {
 				Network: "none",
 				Env:     make(map[string]string),
 			},
+			Sandbox: SandboxConfig{
+				Isolation: "auto",
+				CPU:       "2",
+				Memory:    "4g",
+				Network:   "none",
+			},
 		},
 	}
 }
 
 // Validate validates the configuration.
 func (c *Config) Validate() error {
+	switch c.Model.ProviderName() {
+	case ProviderOpenAI, ProviderAnthropic, ProviderLocal:
+	default:
+		return fmt.Errorf("unsupported model provider %q", c.Model.Provider)
+	}
+
 	if c.Model.URL == "" {
 		return fmt.Errorf("model url is required")
 	}
 
+	for i, fb := range c.Model.Fallbacks {
+		switch fb.ProviderName() {
+		case ProviderOpenAI, ProviderAnthropic, ProviderLocal:
+		default:
+			return fmt.Errorf("fallback %d: unsupported model provider %q", i+1, fb.Provider)
+		}
+		if fb.Model == "" {
+			return fmt.Errorf("fallback %d: model name is required", i+1)
+		}
+	}
+
+	if c.Model.Retry.MaxRetries < 0 {
+		return fmt.Errorf("retry max_retries must be non-negative")
+	}
+
 	if c.Model.Model == "" {
 		return fmt.Errorf("model name is required")
 	}
@@ -175,6 +326,16 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("max_tokens must be non-negative")
 	}
 
+	if c.Budget.MaxCostUSD < 0 || c.Budget.DailyLimit < 0 {
+		return fmt.Errorf("budget limits must be non-negative")
+	}
+
+	switch c.Execution.Mode {
+	case "", ExecutionLocal, ExecutionSandbox, ExecutionDocker:
+	default:
+		return fmt.Errorf("unsupported execution mode %q", c.Execution.Mode)
+	}
+
 	if c.Context.MaxTokens < c.Context.ReserveTokens {
 		return fmt.Errorf("max_tokens must be greater than reserve_tokens")
 	}
@@ -184,6 +345,9 @@ func (c *Config) Validate() error {
 
 // Merge merges another config into this one (overwriting values).
 func (c *Config) Merge(other *Config) {
+	if other.Model.Provider != "" {
+		c.Model.Provider = other.Model.Provider
+	}
 	if other.Model.URL != "" {
 		c.Model.URL = other.Model.URL
 	}
@@ -205,6 +369,18 @@ func (c *Config) Merge(other *Config) {
 	if len(other.Model.Headers) > 0 {
 		c.Model.Headers = other.Model.Headers
 	}
+	if other.Model.ThinkingBudget != 0 {
+		c.Model.ThinkingBudget = other.Model.ThinkingBudget
+	}
+	if other.Model.ToolMode != "" {
+		c.Model.ToolMode = other.Model.ToolMode
+	}
+	if other.Model.Retry != (RetryConfig{}) {
+		c.Model.Retry = other.Model.Retry
+	}
+	if len(other.Model.Fallbacks) > 0 {
+		c.Model.Fallbacks = other.Model.Fallbacks
+	}
 
 	if other.Budget.MaxTokens != 0 {
 		c.Budget.MaxTokens = other.Budget.MaxTokens
@@ -212,6 +388,12 @@ func (c *Config) Merge(other *Config) {
 	if other.Budget.MaxCostUSD != 0 {
 		c.Budget.MaxCostUSD = other.Budget.MaxCostUSD
 	}
+	if other.Budget.DailyLimit != 0 {
+		c.Budget.DailyLimit = other.Budget.DailyLimit
+	}
+	if len(other.Budget.Pricing) > 0 {
+		c.Budget.Pricing = other.Budget.Pricing
+	}
 
 	if other.Context.MaxTokens != 0 {
 		c.Context.MaxTokens = other.Context.MaxTokens
@@ -225,4 +407,13 @@ func (c *Config) Merge(other *Config) {
 	if other.Context.MaxHistoryRounds != 0 {
 		c.Context.MaxHistoryRounds = other.Context.MaxHistoryRounds
 	}
+	if other.Context.CompactStrategy != "" {
+		c.Context.CompactStrategy = other.Context.CompactStrategy
+	}
+	if other.Context.Tokenizer != "" {
+		c.Context.Tokenizer = other.Context.Tokenizer
+	}
+	if other.Context.TokenizerDir != "" {
+		c.Context.TokenizerDir = other.Context.TokenizerDir
+	}
 }
@@ -0,0 +1,628 @@
+// Package anthropic provides an Anthropic Messages API provider implementation.
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+const (
+	defaultEndpoint  = "https://api.anthropic.com/v1"
+	defaultTimeout   = 180 * time.Second // 3 minutes for longer conversations
+	defaultMaxTokens = 4096
+	apiVersion       = "2023-06-01"
+)
+
+// Config holds the Anthropic client configuration.
+type Config struct {
+	Key     string
+	URL     string
+	Model   string
+	Timeout time.Duration
+
+	// ThinkingBudget enables extended thinking with the given token budget.
+	// Zero disables it.
+	ThinkingBudget int
+
+	HTTPClient *http.Client
+}
+
+// Client implements the llm.Provider interface for the Anthropic Messages API.
+type Client struct {
+	apiKey         string
+	endpoint       string
+	model          string
+	thinkingBudget int
+	httpClient     *http.Client
+}
+
+// NewClient creates a new Anthropic client.
+func NewClient(cfg Config) (*Client, error) {
+	apiKey := strings.TrimSpace(cfg.Key)
+	if apiKey == "" {
+		return nil, fmt.Errorf("key is required")
+	}
+
+	endpoint := cfg.URL
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: timeout}
+	}
+
+	return &Client{
+		apiKey:         apiKey,
+		endpoint:       strings.TrimRight(endpoint, "/"),
+		model:          cfg.Model,
+		thinkingBudget: cfg.ThinkingBudget,
+		httpClient:     httpClient,
+	}, nil
+}
+
+// Name returns the provider name.
+func (c *Client) Name() string {
+	return "anthropic"
+}
+
+// SupportsTools returns whether the provider supports tool calls.
+func (c *Client) SupportsTools() bool {
+	return true
+}
+
+// Complete performs a non-streaming completion request.
+func (c *Client) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	body, err := c.buildRequestBody(req, false)
+	if err != nil {
+		return nil, fmt.Errorf("build request body: %w", err)
+	}
+
+	resp, err := c.doRequest(ctx, body)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("request timeout: the operation took too long (>%v). Try reducing context size or increasing timeout", c.httpClient.Timeout)
+		}
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var result messageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("response timeout: server took too long to respond. Try with a shorter conversation or increase timeout")
+		}
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	return c.convertResponse(&result), nil
+}
+
+// CompleteStream performs a streaming completion request.
+func (c *Client) CompleteStream(ctx context.Context, req *llm.CompletionRequest) (llm.StreamIterator, error) {
+	body, err := c.buildRequestBody(req, true)
+	if err != nil {
+		return nil, fmt.Errorf("build request body: %w", err)
+	}
+
+	resp, err := c.doRequest(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, c.parseError(resp)
+	}
+
+	return &streamIterator{reader: bufio.NewReader(resp.Body), closer: resp.Body}, nil
+}
+
+// AvailableModels returns the list of available models.
+func (c *Client) AvailableModels() []llm.ModelInfo {
+	return []llm.ModelInfo{
+		{ID: "claude-opus-4-20250514", Provider: "anthropic", MaxTokens: 200000},
+		{ID: "claude-sonnet-4-20250514", Provider: "anthropic", MaxTokens: 200000},
+		{ID: "claude-3-7-sonnet-20250219", Provider: "anthropic", MaxTokens: 200000},
+		{ID: "claude-3-5-haiku-20241022", Provider: "anthropic", MaxTokens: 200000},
+	}
+}
+
+func (c *Client) buildRequestBody(req *llm.CompletionRequest, stream bool) ([]byte, error) {
+	model := req.Model
+	if model == "" {
+		model = c.model
+	}
+	if model == "" {
+		model = "claude-sonnet-4-20250514"
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+
+	system, messages := c.convertMessages(req.Messages)
+	body := map[string]any{
+		"model":      model,
+		"messages":   messages,
+		"max_tokens": maxTokens,
+		"stream":     stream,
+	}
+	if system != "" {
+		body["system"] = system
+	}
+
+	if c.thinkingBudget > 0 {
+		// max_tokens must leave room for the answer after thinking, and
+		// the API rejects custom sampling parameters with thinking on.
+		if maxTokens <= c.thinkingBudget {
+			body["max_tokens"] = c.thinkingBudget + defaultMaxTokens
+		}
+		body["thinking"] = map[string]any{
+			"type":          "enabled",
+			"budget_tokens": c.thinkingBudget,
+		}
+	} else {
+		body["temperature"] = req.Temperature
+		if req.TopP > 0 {
+			body["top_p"] = req.TopP
+		}
+	}
+
+	if len(req.Stop) > 0 {
+		body["stop_sequences"] = req.Stop
+	}
+	if len(req.Tools) > 0 {
+		body["tools"] = c.convertTools(req.Tools)
+	}
+
+	return json.Marshal(body)
+}
+
+func (c *Client) doRequest(ctx context.Context, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("anthropic-version", apiVersion)
+
+	return c.httpClient.Do(req)
+}
+
+func (c *Client) parseError(resp *http.Response) error {
+	// Read the full body with a limit to prevent memory issues
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024)) // 1MB limit
+	if err != nil {
+		return llm.NewAPIError(resp, "", "failed to read error body: "+err.Error())
+	}
+
+	bodyStr := string(body)
+	if bodyStr == "" {
+		return llm.NewAPIError(resp, "", "empty response")
+	}
+
+	var errResp errorResponse
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
+		return llm.NewAPIError(resp, errResp.Error.Type, errResp.Error.Message)
+	}
+
+	return llm.NewAPIError(resp, "", bodyStr)
+}
+
+// convertMessages splits out the system prompt and converts the rest into
+// Messages API turns. Tool results become user turns, and consecutive turns
+// of the same role are merged because the API requires alternation.
+func (c *Client) convertMessages(msgs []llm.Message) (string, []message) {
+	var system []string
+	result := make([]message, 0, len(msgs))
+
+	for _, m := range msgs {
+		var role string
+		var blocks []contentBlock
+
+		switch m.Role {
+		case "system":
+			if m.Content != "" {
+				system = append(system, m.Content)
+			}
+			continue
+		case "tool":
+			role = "user"
+			blocks = []contentBlock{{
+				Type:      "tool_result",
+				ToolUseID: m.ToolCallID,
+				Content:   m.Content,
+			}}
+		case "assistant":
+			role = "assistant"
+			blocks = append(blocks, thinkingBlocks(m)...)
+			if m.Content != "" {
+				blocks = append(blocks, contentBlock{Type: "text", Text: m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				input := tc.Function.Arguments
+				if len(bytes.TrimSpace(input)) == 0 {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, contentBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Function.Name,
+					Input: input,
+				})
+			}
+		default:
+			role = "user"
+			if m.Content != "" {
+				blocks = []contentBlock{{Type: "text", Text: m.Content}}
+			}
+		}
+
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(result); n > 0 && result[n-1].Role == role {
+			result[n-1].Content = append(result[n-1].Content, blocks...)
+			continue
+		}
+		result = append(result, message{Role: role, Content: blocks})
+	}
+
+	return strings.Join(system, "\n\n"), result
+}
+
+// thinkingBlocks returns an assistant message's thinking blocks as sent by
+// the API, each with its own signature. Unsigned reasoning, such as that of
+// other providers, cannot be verified and is left out.
+func thinkingBlocks(m llm.Message) []contentBlock {
+	if len(m.ThinkingBlocks) == 0 {
+		// Sessions saved before blocks were kept apart.
+		if m.Thinking != "" && m.ThinkingSignature != "" {
+			return []contentBlock{{Type: "thinking", Thinking: m.Thinking, Signature: m.ThinkingSignature}}
+		}
+		return nil
+	}
+	var blocks []contentBlock
+	for _, b := range m.ThinkingBlocks {
+		switch {
+		case b.Data != "":
+			blocks = append(blocks, contentBlock{Type: "redacted_thinking", Data: b.Data})
+		case b.Signature != "":
+			blocks = append(blocks, contentBlock{Type: "thinking", Thinking: b.Thinking, Signature: b.Signature})
+		}
+	}
+	return blocks
+}
+
+func (c *Client) convertTools(tools []llm.Tool) []tool {
+	result := make([]tool, len(tools))
+	for i, t := range tools {
+		result[i] = tool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: t.Function.Parameters,
+		}
+	}
+	return result
+}
+
+func (c *Client) convertResponse(resp *messageResponse) *llm.CompletionResponse {
+	result := &llm.CompletionResponse{
+		ID:           resp.ID,
+		Model:        resp.Model,
+		FinishReason: convertStopReason(resp.StopReason),
+		Usage: llm.Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+	}
+
+	var text []string
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			text = append(text, block.Text)
+		case "thinking":
+			result.Thinking += block.Thinking
+			result.ThinkingBlocks = append(result.ThinkingBlocks, llm.ThinkingBlock{Thinking: block.Thinking, Signature: block.Signature})
+		case "redacted_thinking":
+			result.ThinkingBlocks = append(result.ThinkingBlocks, llm.ThinkingBlock{Data: block.Data})
+		case "tool_use":
+			result.ToolCalls = append(result.ToolCalls, llm.ToolCall{
+				ID:   block.ID,
+				Type: "function",
+				Function: llm.ToolCallFunc{
+					Name:      block.Name,
+					Arguments: block.Input,
+				},
+			})
+		}
+	}
+	result.Content = strings.Join(text, "")
+	if len(result.ToolCalls) > 0 {
+		result.FinishReason = llm.FinishToolCalls
+	}
+
+	return result
+}
+
+func convertStopReason(reason string) llm.FinishReason {
+	switch reason {
+	case "max_tokens":
+		return llm.FinishLength
+	case "tool_use":
+		return llm.FinishToolCalls
+	case "refusal":
+		return llm.FinishContentFilter
+	default:
+		return llm.FinishStop
+	}
+}
+
+// Request/Response types for the Anthropic Messages API.
+
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+type contentBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// thinking
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+
+	// redacted_thinking
+	Data string `json:"data,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema llm.ToolSchema `json:"input_schema"`
+}
+
+type messageResponse struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	Role       string         `json:"role"`
+	Model      string         `json:"model"`
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      usage          `json:"usage"`
+}
+
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type errorResponse struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Streaming types.
+
+type streamEvent struct {
+	Type         string          `json:"type"`
+	Index        int             `json:"index"`
+	Message      messageResponse `json:"message"`
+	ContentBlock contentBlock    `json:"content_block"`
+	Delta        streamDelta     `json:"delta"`
+	Usage        *usage          `json:"usage,omitempty"`
+	Error        struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type streamDelta struct {
+	Type        string `json:"type"`
+	Text        string `json:"text,omitempty"`
+	Thinking    string `json:"thinking,omitempty"`
+	Signature   string `json:"signature,omitempty"`
+	PartialJSON string `json:"partial_json,omitempty"`
+	StopReason  string `json:"stop_reason,omitempty"`
+}
+
+// streamIterator implements llm.StreamIterator over Messages API SSE events.
+type streamIterator struct {
+	reader *bufio.Reader
+	closer io.Closer
+	done   bool
+
+	usage      llm.Usage
+	toolCalls  []llm.ToolCall
+	toolIndex  map[int]int    // content block index -> toolCalls index
+	signatures map[int]string // content block index -> thinking signature
+}
+
+func (it *streamIterator) Next() (*llm.StreamChunk, error) {
+	if it.done {
+		return nil, io.EOF
+	}
+
+	for {
+		line, err := it.reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				it.done = true
+			}
+			return nil, err
+		}
+
+		// Only data lines matter; the event name is repeated in the payload.
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var ev streamEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			continue
+		}
+
+		switch ev.Type {
+		case "message_start":
+			it.usage.PromptTokens = ev.Message.Usage.InputTokens
+			it.usage.CompletionTokens = ev.Message.Usage.OutputTokens
+
+		case "content_block_start":
+			switch ev.ContentBlock.Type {
+			case "tool_use":
+				if it.toolIndex == nil {
+					it.toolIndex = make(map[int]int)
+				}
+				it.toolIndex[ev.Index] = len(it.toolCalls)
+				it.toolCalls = append(it.toolCalls, llm.ToolCall{
+					ID:   ev.ContentBlock.ID,
+					Type: "function",
+					Function: llm.ToolCallFunc{
+						Name: ev.ContentBlock.Name,
+					},
+				})
+				return &llm.StreamChunk{ToolCalls: it.snapshotToolCalls()}, nil
+			case "text":
+				if ev.ContentBlock.Text != "" {
+					return &llm.StreamChunk{Content: ev.ContentBlock.Text}, nil
+				}
+			case "redacted_thinking":
+				return &llm.StreamChunk{RedactedThinking: ev.ContentBlock.Data}, nil
+			}
+
+		case "content_block_delta":
+			switch ev.Delta.Type {
+			case "text_delta":
+				return &llm.StreamChunk{Content: ev.Delta.Text}, nil
+			case "thinking_delta":
+				return &llm.StreamChunk{Thinking: ev.Delta.Thinking}, nil
+			case "signature_delta":
+				// Sent once the block is complete; passed on when it stops.
+				if it.signatures == nil {
+					it.signatures = make(map[int]string)
+				}
+				it.signatures[ev.Index] += ev.Delta.Signature
+			case "input_json_delta":
+				idx, ok := it.toolIndex[ev.Index]
+				if !ok {
+					continue
+				}
+				args := string(it.toolCalls[idx].Function.Arguments) + ev.Delta.PartialJSON
+				it.toolCalls[idx].Function.Arguments = json.RawMessage(args)
+				return &llm.StreamChunk{ToolCalls: it.snapshotToolCalls()}, nil
+			}
+
+		case "content_block_stop":
+			if sig, ok := it.signatures[ev.Index]; ok {
+				delete(it.signatures, ev.Index)
+				return &llm.StreamChunk{ThinkingSignature: sig}, nil
+			}
+			// A tool call without input streams no JSON deltas.
+			if idx, ok := it.toolIndex[ev.Index]; ok && len(it.toolCalls[idx].Function.Arguments) == 0 {
+				it.toolCalls[idx].Function.Arguments = json.RawMessage("{}")
+				return &llm.StreamChunk{ToolCalls: it.snapshotToolCalls()}, nil
+			}
+
+		case "message_delta":
+			if ev.Usage != nil {
+				it.usage.CompletionTokens = ev.Usage.OutputTokens
+			}
+			it.usage.TotalTokens = it.usage.PromptTokens + it.usage.CompletionTokens
+			usage := it.usage
+			return &llm.StreamChunk{
+				FinishReason: convertStopReason(ev.Delta.StopReason),
+				Usage:        &usage,
+			}, nil
+
+		case "message_stop":
+			it.done = true
+			return nil, io.EOF
+
+		case "error":
+			it.done = true
+			return nil, &llm.APIError{
+				StatusCode: streamErrorStatus(ev.Error.Type),
+				Type:       ev.Error.Type,
+				Message:    ev.Error.Message,
+			}
+		}
+	}
+}
+
+// streamErrorStatus maps the type of an error sent mid-stream to the HTTP
+// status the API uses for it, so it is classified like a failed request.
+func streamErrorStatus(errType string) int {
+	switch errType {
+	case "invalid_request_error":
+		return http.StatusBadRequest
+	case "authentication_error":
+		return http.StatusUnauthorized
+	case "permission_error":
+		return http.StatusForbidden
+	case "not_found_error":
+		return http.StatusNotFound
+	case "request_too_large":
+		return http.StatusRequestEntityTooLarge
+	case "rate_limit_error":
+		return http.StatusTooManyRequests
+	case "overloaded_error":
+		return 529
+	default: // api_error and unknown types
+		return http.StatusInternalServerError
+	}
+}
+
+func (it *streamIterator) Close() error {
+	if it.closer != nil {
+		return it.closer.Close()
+	}
+	return nil
+}
+
+func (it *streamIterator) snapshotToolCalls() []llm.ToolCall {
+	calls := make([]llm.ToolCall, len(it.toolCalls))
+	copy(calls, it.toolCalls)
+	return calls
+}
@@ -0,0 +1,382 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc, thinkingBudget int) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(Config{
+		Key:            "test-key",
+		URL:            server.URL + "/v1",
+		Model:          "claude-test",
+		ThinkingBudget: thinkingBudget,
+	})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	return client
+}
+
+func TestCompleteConvertsMessagesAndToolUse(t *testing.T) {
+	var got map[string]any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" {
+			t.Errorf("missing api key header")
+		}
+		if r.Header.Get("anthropic-version") == "" {
+			t.Errorf("missing anthropic-version header")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+
+		fmt.Fprint(w, `{
+			"id": "msg_1",
+			"model": "claude-test",
+			"content": [
+				{"type": "thinking", "thinking": "need the file", "signature": "sig"},
+				{"type": "text", "text": "Reading it."},
+				{"type": "tool_use", "id": "toolu_1", "name": "read", "input": {"path": "a.go"}}
+			],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 20, "output_tokens": 5}
+		}`)
+	}, 0)
+
+	resp, err := client.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []llm.Message{
+			llm.NewSystemMessage("be brief"),
+			llm.NewUserMessage("open a.go"),
+			{
+				Role:      "assistant",
+				ToolCalls: []llm.ToolCall{{ID: "toolu_0", Type: "function", Function: llm.ToolCallFunc{Name: "glob", Arguments: json.RawMessage(`{"pattern":"*.go"}`)}}},
+			},
+			llm.NewToolMessage("toolu_0", "a.go"),
+			llm.NewUserMessage("go on"),
+		},
+		Tools: []llm.Tool{{
+			Type: "function",
+			Function: llm.ToolFunction{
+				Name:        "read",
+				Description: "Read a file",
+				Parameters:  llm.ToolSchema{Type: "object", Required: []string{"path"}},
+			},
+		}},
+		Temperature: 0.2,
+	})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	if got["system"] != "be brief" {
+		t.Fatalf("expected system prompt to be sent separately, got %v", got["system"])
+	}
+	messages := got["messages"].([]any)
+	if len(messages) != 3 {
+		t.Fatalf("expected user/assistant/user turns, got %d: %v", len(messages), messages)
+	}
+	last := messages[2].(map[string]any)["content"].([]any)
+	if len(last) != 2 || last[0].(map[string]any)["type"] != "tool_result" {
+		t.Fatalf("expected tool result merged with following user text, got %v", last)
+	}
+	tools := got["tools"].([]any)
+	if tools[0].(map[string]any)["input_schema"] == nil {
+		t.Fatalf("expected tools to carry input_schema, got %v", tools[0])
+	}
+
+	if resp.Content != "Reading it." {
+		t.Fatalf("unexpected content %q", resp.Content)
+	}
+	if resp.Thinking != "need the file" || len(resp.ThinkingBlocks) != 1 || resp.ThinkingBlocks[0].Signature != "sig" {
+		t.Fatalf("unexpected thinking %q / %+v", resp.Thinking, resp.ThinkingBlocks)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Function.Name != "read" {
+		t.Fatalf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+	if string(resp.ToolCalls[0].Function.Arguments) != `{"path": "a.go"}` {
+		t.Fatalf("unexpected tool arguments %s", resp.ToolCalls[0].Function.Arguments)
+	}
+	if resp.FinishReason != llm.FinishToolCalls {
+		t.Fatalf("unexpected finish reason %q", resp.FinishReason)
+	}
+	if resp.Usage.TotalTokens != 25 {
+		t.Fatalf("unexpected usage %+v", resp.Usage)
+	}
+}
+
+func TestThinkingBudgetOmitsTemperature(t *testing.T) {
+	var got map[string]any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, `{"content": [{"type": "text", "text": "ok"}], "stop_reason": "end_turn"}`)
+	}, 2048)
+
+	_, err := client.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []llm.Message{
+			llm.NewUserMessage("hi"),
+			{Role: "assistant", Content: "hello", Thinking: "greet", ThinkingSignature: "sig"},
+			llm.NewUserMessage("again"),
+		},
+		Temperature: 0.7,
+		MaxTokens:   1024,
+	})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	if _, ok := got["temperature"]; ok {
+		t.Fatalf("temperature must not be sent with thinking enabled")
+	}
+	thinking, ok := got["thinking"].(map[string]any)
+	if !ok || thinking["budget_tokens"].(float64) != 2048 {
+		t.Fatalf("expected thinking config, got %v", got["thinking"])
+	}
+	if got["max_tokens"].(float64) <= 2048 {
+		t.Fatalf("expected max_tokens above thinking budget, got %v", got["max_tokens"])
+	}
+	assistant := got["messages"].([]any)[1].(map[string]any)["content"].([]any)
+	if assistant[0].(map[string]any)["type"] != "thinking" {
+		t.Fatalf("expected thinking block to be replayed first, got %v", assistant)
+	}
+}
+
+func TestCompleteStreamParsesSSE(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":12,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Hi"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":" there"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"grep","input":{}}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"pattern\":"}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"\"TODO\"}"}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}`,
+		`{"type":"message_stop"}`,
+	}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] != true {
+			t.Errorf("expected stream=true")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range events {
+			var typ struct {
+				Type string `json:"type"`
+			}
+			_ = json.Unmarshal([]byte(ev), &typ)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typ.Type, ev)
+		}
+	}, 0)
+
+	it, err := client.CompleteStream(context.Background(), &llm.CompletionRequest{
+		Messages: []llm.Message{llm.NewUserMessage("find todos")},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream failed: %v", err)
+	}
+	defer it.Close()
+
+	var content, thinking, signature strings.Builder
+	var calls []llm.ToolCall
+	var finish llm.FinishReason
+	var usage *llm.Usage
+	for {
+		chunk, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		content.WriteString(chunk.Content)
+		thinking.WriteString(chunk.Thinking)
+		signature.WriteString(chunk.ThinkingSignature)
+		if len(chunk.ToolCalls) > 0 {
+			calls = chunk.ToolCalls
+		}
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+
+	if content.String() != "Hi there" {
+		t.Fatalf("unexpected content %q", content.String())
+	}
+	if thinking.String() != "hmm" || signature.String() != "sig" {
+		t.Fatalf("unexpected thinking %q / %q", thinking.String(), signature.String())
+	}
+	if len(calls) != 1 || calls[0].ID != "toolu_1" || string(calls[0].Function.Arguments) != `{"pattern":"TODO"}` {
+		t.Fatalf("unexpected tool calls %+v", calls)
+	}
+	if finish != llm.FinishToolCalls {
+		t.Fatalf("unexpected finish reason %q", finish)
+	}
+	if usage == nil || usage.PromptTokens != 12 || usage.CompletionTokens != 9 || usage.TotalTokens != 21 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)
+	}, 0)
+
+	_, err := client.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []llm.Message{llm.NewUserMessage("hi")},
+	})
+	if err == nil || !strings.Contains(err.Error(), "rate_limit_error") || !strings.Contains(err.Error(), "slow down") {
+		t.Fatalf("expected parsed API error, got %v", err)
+	}
+}
+
+func TestThinkingBlocksKeepTheirSignatures(t *testing.T) {
+	var got map[string]any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, `{
+			"content": [
+				{"type": "thinking", "thinking": "first", "signature": "sig1"},
+				{"type": "redacted_thinking", "data": "opaque"},
+				{"type": "thinking", "thinking": "second", "signature": "sig2"},
+				{"type": "tool_use", "id": "toolu_1", "name": "read", "input": {"path": "a.go"}}
+			],
+			"stop_reason": "tool_use"
+		}`)
+	}, 2048)
+
+	req := &llm.CompletionRequest{Messages: []llm.Message{llm.NewUserMessage("open a.go")}}
+	resp, err := client.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	want := []llm.ThinkingBlock{
+		{Thinking: "first", Signature: "sig1"},
+		{Data: "opaque"},
+		{Thinking: "second", Signature: "sig2"},
+	}
+	if fmt.Sprint(resp.ThinkingBlocks) != fmt.Sprint(want) {
+		t.Fatalf("thinking blocks = %+v, want %+v", resp.ThinkingBlocks, want)
+	}
+
+	// The next turn sends every block back unchanged.
+	req.Messages = append(req.Messages,
+		llm.Message{Role: "assistant", ToolCalls: resp.ToolCalls, Thinking: resp.Thinking, ThinkingBlocks: resp.ThinkingBlocks},
+		llm.NewToolMessage("toolu_1", "package main"),
+	)
+	if _, err := client.Complete(context.Background(), req); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	assistant := got["messages"].([]any)[1].(map[string]any)["content"].([]any)
+	if len(assistant) != 4 {
+		t.Fatalf("expected three thinking blocks and the tool use, got %v", assistant)
+	}
+	for i, w := range []map[string]any{
+		{"type": "thinking", "thinking": "first", "signature": "sig1"},
+		{"type": "redacted_thinking", "data": "opaque"},
+		{"type": "thinking", "thinking": "second", "signature": "sig2"},
+	} {
+		block := assistant[i].(map[string]any)
+		for k, v := range w {
+			if block[k] != v {
+				t.Errorf("block %d: %s = %v, want %v", i, k, block[k], v)
+			}
+		}
+	}
+}
+
+func TestCompleteStreamKeepsThinkingBlocksApart(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"usage":{"input_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"first"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig1"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"redacted_thinking","data":"opaque"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"thinking_delta","thinking":"second"}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"signature_delta","signature":"sig2"}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_stop"}`,
+	}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		for _, ev := range events {
+			fmt.Fprintf(w, "data: %s\n\n", ev)
+		}
+	}, 2048)
+
+	it, err := client.CompleteStream(context.Background(), &llm.CompletionRequest{
+		Messages: []llm.Message{llm.NewUserMessage("think")},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream failed: %v", err)
+	}
+	defer it.Close()
+
+	var got []string
+	for {
+		chunk, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		got = append(got, chunk.Thinking+"|"+chunk.ThinkingSignature+"|"+chunk.RedactedThinking)
+	}
+	want := []string{"first||", "|sig1|", "||opaque", "second||", "|sig2|"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("chunks = %v, want %v", got, want)
+	}
+}
+
+func TestStreamErrorIsAPIError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"type\":\"message_start\",\"message\":{}}\n\n")
+		fmt.Fprint(w, "data: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+	}, 0)
+
+	it, err := client.CompleteStream(context.Background(), &llm.CompletionRequest{
+		Messages: []llm.Message{llm.NewUserMessage("hi")},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream failed: %v", err)
+	}
+	defer it.Close()
+
+	_, err = it.Next()
+	var apiErr *llm.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 529 || apiErr.Type != "overloaded_error" {
+		t.Fatalf("expected an overloaded APIError, got %v", err)
+	}
+	if !llm.IsTransient(err) {
+		t.Error("an overloaded stream should be retried")
+	}
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError is a non-2xx response from a provider API.
+type APIError struct {
+	StatusCode int
+	Type       string
+	Message    string
+	// RetryAfter is the delay the server asked for, zero if none.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("API error (status %d, %s): %s", e.StatusCode, e.Type, e.Message)
+	}
+	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the request may succeed when retried:
+// rate limits, request timeouts and server errors.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode >= 500
+}
+
+// NewAPIError builds an APIError from an error response, reading the
+// Retry-After header.
+func NewAPIError(resp *http.Response, errType, message string) *APIError {
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Type:       errType,
+		Message:    message,
+		RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// ParseRetryAfter parses a Retry-After header given either as seconds or as
+// an HTTP date. Missing or invalid values yield zero.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,282 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/vigo999/ms-cli/trace"
+)
+
+// RetryPolicy controls how FallbackProvider retries transient failures.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries per provider after the first attempt.
+	MaxRetries int
+	// BaseDelay is the first backoff delay; it doubles on every retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay. A Retry-After longer than this makes
+	// the provider fail over instead of waiting.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  time.Second,
+		MaxDelay:   30 * time.Second,
+	}
+}
+
+// backoff returns the delay before the given retry (1-based).
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < retry && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// FallbackProvider wraps an ordered list of providers. Transient failures
+// (rate limits, server errors, timeouts) are retried with exponential
+// backoff; once a provider gives up, the next one in the list is tried.
+//
+// Streams are retried while opening them and until their first chunk, so an
+// overloaded error sent before any content still fails over. An error in the
+// middle of a stream is returned to the caller.
+type FallbackProvider struct {
+	providers []Provider
+	policy    RetryPolicy
+	trace     trace.Writer
+
+	// sleep waits between attempts; tests replace it.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewFallbackProvider creates a provider that tries providers in order.
+func NewFallbackProvider(providers []Provider, policy RetryPolicy, w trace.Writer) (*FallbackProvider, error) {
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("fallback provider needs at least one provider")
+	}
+	return &FallbackProvider{
+		providers: providers,
+		policy:    policy,
+		trace:     w,
+		sleep:     sleepContext,
+	}, nil
+}
+
+// Fallback builds a FallbackProvider from registered providers, in the
+// given order.
+func (r *Registry) Fallback(names []string, policy RetryPolicy, w trace.Writer) (*FallbackProvider, error) {
+	providers := make([]Provider, 0, len(names))
+	for _, name := range names {
+		p, err := r.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	return NewFallbackProvider(providers, policy, w)
+}
+
+// Name returns the name of the primary provider.
+func (f *FallbackProvider) Name() string {
+	return f.providers[0].Name()
+}
+
+// Providers returns the wrapped providers in fallback order.
+func (f *FallbackProvider) Providers() []Provider {
+	return f.providers
+}
+
+// SupportsTools reports whether the primary provider supports tools.
+func (f *FallbackProvider) SupportsTools() bool {
+	return f.providers[0].SupportsTools()
+}
+
+// AvailableModels returns the models of all wrapped providers.
+func (f *FallbackProvider) AvailableModels() []ModelInfo {
+	var models []ModelInfo
+	for _, p := range f.providers {
+		models = append(models, p.AvailableModels()...)
+	}
+	return models
+}
+
+// Complete performs a completion, retrying and failing over as needed.
+func (f *FallbackProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	var resp *CompletionResponse
+	err := f.do(ctx, "complete", func(p Provider) error {
+		var err error
+		resp, err = p.Complete(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// CompleteStream opens a stream, retrying and failing over as needed until
+// the first chunk has arrived.
+func (f *FallbackProvider) CompleteStream(ctx context.Context, req *CompletionRequest) (StreamIterator, error) {
+	var it StreamIterator
+	err := f.do(ctx, "stream", func(p Provider) error {
+		opened, err := p.CompleteStream(ctx, req)
+		if err != nil || opened == nil {
+			it = opened
+			return err
+		}
+		chunk, err := opened.Next()
+		if err != nil && err != io.EOF {
+			opened.Close()
+			return err
+		}
+		it = &peekedStream{StreamIterator: opened, chunk: chunk, err: err}
+		return nil
+	})
+	return it, err
+}
+
+// peekedStream returns an already read first chunk before the rest of the
+// stream.
+type peekedStream struct {
+	StreamIterator
+	chunk  *StreamChunk
+	err    error
+	peeked bool
+}
+
+func (s *peekedStream) Next() (*StreamChunk, error) {
+	if !s.peeked {
+		s.peeked = true
+		return s.chunk, s.err
+	}
+	return s.StreamIterator.Next()
+}
+
+// do runs call against each provider in turn until one succeeds.
+func (f *FallbackProvider) do(ctx context.Context, op string, call func(Provider) error) error {
+	var failures []error
+	for _, p := range f.providers {
+		err := f.tryProvider(ctx, op, p, call)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		failures = append(failures, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+
+	if len(failures) == 1 {
+		return errors.Unwrap(failures[0])
+	}
+	msgs := make([]string, len(failures))
+	for i, err := range failures {
+		msgs[i] = err.Error()
+	}
+	return &FallbackError{Errors: failures, msg: "all providers failed: " + strings.Join(msgs, "; ")}
+}
+
+// tryProvider calls one provider, retrying transient failures.
+func (f *FallbackProvider) tryProvider(ctx context.Context, op string, p Provider, call func(Provider) error) error {
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := call(p)
+		record := map[string]any{
+			"provider":    p.Name(),
+			"op":          op,
+			"attempt":     attempt,
+			"duration_ms": time.Since(start).Milliseconds(),
+		}
+		if err == nil {
+			record["outcome"] = "ok"
+			f.writeTrace(record)
+			return nil
+		}
+		record["error"] = err.Error()
+
+		if ctx.Err() != nil || !IsTransient(err) {
+			record["outcome"] = "failed"
+			f.writeTrace(record)
+			return err
+		}
+		if attempt > f.policy.MaxRetries {
+			record["outcome"] = "retries_exhausted"
+			f.writeTrace(record)
+			return err
+		}
+
+		delay := f.policy.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			if f.policy.MaxDelay > 0 && apiErr.RetryAfter > f.policy.MaxDelay {
+				record["outcome"] = "retry_after_too_long"
+				record["retry_after_ms"] = apiErr.RetryAfter.Milliseconds()
+				f.writeTrace(record)
+				return err
+			}
+			delay = apiErr.RetryAfter
+		}
+		record["outcome"] = "retry"
+		record["delay_ms"] = delay.Milliseconds()
+		f.writeTrace(record)
+
+		if err := f.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *FallbackProvider) writeTrace(record map[string]any) {
+	if f.trace == nil {
+		return
+	}
+	_ = f.trace.Write("llm_attempt", record)
+}
+
+// FallbackError reports that every provider in a fallback chain failed.
+type FallbackError struct {
+	Errors []error
+	msg    string
+}
+
+func (e *FallbackError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the per-provider errors.
+func (e *FallbackError) Unwrap() []error {
+	return e.Errors
+}
+
+// IsTransient reports whether err is worth retrying: a retryable API status
+// or a network timeout.
+func IsTransient(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,270 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedProvider returns the queued errors in order, then succeeds.
+type scriptedProvider struct {
+	name  string
+	errs  []error
+	calls int
+}
+
+func (p *scriptedProvider) Name() string                 { return p.name }
+func (p *scriptedProvider) SupportsTools() bool          { return true }
+func (p *scriptedProvider) AvailableModels() []ModelInfo { return nil }
+
+func (p *scriptedProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return nil, err
+	}
+	return &CompletionResponse{Content: "from " + p.name}, nil
+}
+
+func (p *scriptedProvider) CompleteStream(ctx context.Context, req *CompletionRequest) (StreamIterator, error) {
+	_, err := p.Complete(ctx, req)
+	return nil, err
+}
+
+type recordingTrace struct {
+	mu      sync.Mutex
+	records []map[string]any
+}
+
+func (w *recordingTrace) Write(eventType string, payload any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if eventType == "llm_attempt" {
+		w.records = append(w.records, payload.(map[string]any))
+	}
+	return nil
+}
+
+func newTestFallback(t *testing.T, policy RetryPolicy, providers ...Provider) (*FallbackProvider, *recordingTrace, *[]time.Duration) {
+	t.Helper()
+	tw := &recordingTrace{}
+	f, err := NewFallbackProvider(providers, policy, tw)
+	if err != nil {
+		t.Fatalf("NewFallbackProvider failed: %v", err)
+	}
+	var delays []time.Duration
+	f.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	return f, tw, &delays
+}
+
+func TestFallbackRetriesTransientErrors(t *testing.T) {
+	primary := &scriptedProvider{name: "openai", errs: []error{
+		&APIError{StatusCode: http.StatusTooManyRequests, Message: "slow down"},
+		&APIError{StatusCode: http.StatusBadGateway, Message: "bad gateway"},
+	}}
+	f, tw, delays := newTestFallback(t, RetryPolicy{MaxRetries: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}, primary)
+
+	resp, err := f.Complete(context.Background(), &CompletionRequest{})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if resp.Content != "from openai" || primary.calls != 3 {
+		t.Fatalf("unexpected result %q after %d calls", resp.Content, primary.calls)
+	}
+	if want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}; !equalDurations(*delays, want) {
+		t.Fatalf("delays = %v, want %v", *delays, want)
+	}
+
+	outcomes := make([]string, len(tw.records))
+	for i, r := range tw.records {
+		outcomes[i] = r["outcome"].(string)
+	}
+	if strings.Join(outcomes, ",") != "retry,retry,ok" {
+		t.Fatalf("unexpected trace outcomes %v", outcomes)
+	}
+}
+
+func TestFallbackHonoursRetryAfter(t *testing.T) {
+	primary := &scriptedProvider{name: "openai", errs: []error{
+		&APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 3 * time.Second},
+	}}
+	f, _, delays := newTestFallback(t, RetryPolicy{MaxRetries: 1, BaseDelay: 100 * time.Millisecond, MaxDelay: 10 * time.Second}, primary)
+
+	if _, err := f.Complete(context.Background(), &CompletionRequest{}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if want := []time.Duration{3 * time.Second}; !equalDurations(*delays, want) {
+		t.Fatalf("delays = %v, want %v", *delays, want)
+	}
+}
+
+func TestFallbackFailsOverToNextProvider(t *testing.T) {
+	rateLimited := &APIError{StatusCode: http.StatusTooManyRequests, Message: "slow down"}
+	primary := &scriptedProvider{name: "openai", errs: []error{rateLimited, rateLimited, rateLimited}}
+	secondary := &scriptedProvider{name: "anthropic"}
+	f, tw, _ := newTestFallback(t, RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}, primary, secondary)
+
+	it, err := f.CompleteStream(context.Background(), &CompletionRequest{})
+	if err != nil || it != nil {
+		t.Fatalf("CompleteStream = %v, %v", it, err)
+	}
+	if primary.calls != 3 || secondary.calls != 1 {
+		t.Fatalf("calls = %d/%d, want 3/1", primary.calls, secondary.calls)
+	}
+	last := tw.records[len(tw.records)-1]
+	if last["provider"] != "anthropic" || last["outcome"] != "ok" || last["op"] != "stream" {
+		t.Fatalf("unexpected last trace record %v", last)
+	}
+	if tw.records[2]["outcome"] != "retries_exhausted" {
+		t.Fatalf("expected exhausted retries on primary, got %v", tw.records[2])
+	}
+}
+
+func TestFallbackSkipsRetryForPermanentErrors(t *testing.T) {
+	primary := &scriptedProvider{name: "openai", errs: []error{&APIError{StatusCode: http.StatusUnauthorized, Message: "bad key"}}}
+	secondary := &scriptedProvider{name: "local", errs: []error{errors.New("connection refused")}}
+	f, _, delays := newTestFallback(t, DefaultRetryPolicy(), primary, secondary)
+
+	_, err := f.Complete(context.Background(), &CompletionRequest{})
+	if err == nil {
+		t.Fatal("expected error when every provider fails")
+	}
+	if len(*delays) != 0 || primary.calls != 1 || secondary.calls != 1 {
+		t.Fatalf("permanent errors must not be retried: delays=%v calls=%d/%d", *delays, primary.calls, secondary.calls)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected wrapped API error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "openai: API error (status 401)") || !strings.Contains(err.Error(), "local: connection refused") {
+		t.Fatalf("unexpected error message %q", err.Error())
+	}
+}
+
+func TestFallbackStopsWhenContextCancelled(t *testing.T) {
+	primary := &scriptedProvider{name: "openai", errs: []error{&APIError{StatusCode: http.StatusServiceUnavailable}}}
+	secondary := &scriptedProvider{name: "anthropic"}
+	f, _, _ := newTestFallback(t, DefaultRetryPolicy(), primary, secondary)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.Complete(ctx, &CompletionRequest{}); err == nil {
+		t.Fatal("expected error for cancelled request")
+	}
+	if primary.calls != 1 || secondary.calls != 0 {
+		t.Fatalf("cancelled request must not retry or fail over: calls=%d/%d", primary.calls, secondary.calls)
+	}
+}
+
+func TestRegistryFallbackKeepsOrder(t *testing.T) {
+	r := NewRegistry()
+	for _, name := range []string{"openai", "anthropic", "local"} {
+		if err := r.Register(&scriptedProvider{name: name}); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+	}
+
+	f, err := r.Fallback([]string{"local", "openai"}, DefaultRetryPolicy(), nil)
+	if err != nil {
+		t.Fatalf("Fallback failed: %v", err)
+	}
+	if f.Name() != "local" || len(f.Providers()) != 2 || f.Providers()[1].Name() != "openai" {
+		t.Fatalf("unexpected chain %v", f.Providers())
+	}
+	if _, err := r.Fallback([]string{"missing"}, DefaultRetryPolicy(), nil); err == nil {
+		t.Fatal("expected error for unregistered provider")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"2":                             2 * time.Second,
+		"0.5":                           500 * time.Millisecond,
+		"-1":                            0,
+		"soon":                          0,
+		"Fri, 02 Jan 2026 03:04:15 GMT": 10 * time.Second,
+	}
+	for value, want := range tests {
+		if got := ParseRetryAfter(value, now); got != want {
+			t.Errorf("ParseRetryAfter(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func equalDurations(a, b []time.Duration) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// failingStream fails on its first chunk.
+type failingStream struct{ err error }
+
+func (s *failingStream) Next() (*StreamChunk, error) { return nil, s.err }
+func (s *failingStream) Close() error                { return nil }
+
+// oneChunkStream yields a single chunk.
+type oneChunkStream struct{ sent bool }
+
+func (s *oneChunkStream) Next() (*StreamChunk, error) {
+	if s.sent {
+		return nil, io.EOF
+	}
+	s.sent = true
+	return &StreamChunk{Content: "hello"}, nil
+}
+func (s *oneChunkStream) Close() error { return nil }
+
+// streamProvider opens the given streams in order.
+type streamProvider struct {
+	scriptedProvider
+	streams []StreamIterator
+}
+
+func (p *streamProvider) CompleteStream(ctx context.Context, req *CompletionRequest) (StreamIterator, error) {
+	p.calls++
+	it := p.streams[0]
+	p.streams = p.streams[1:]
+	return it, nil
+}
+
+func TestFallbackFailsOverOnStreamErrorBeforeFirstChunk(t *testing.T) {
+	overloaded := &APIError{StatusCode: 529, Type: "overloaded_error", Message: "Overloaded"}
+	primary := &streamProvider{scriptedProvider: scriptedProvider{name: "anthropic"}, streams: []StreamIterator{
+		&failingStream{err: overloaded}, &failingStream{err: overloaded},
+	}}
+	secondary := &streamProvider{scriptedProvider: scriptedProvider{name: "openai"}, streams: []StreamIterator{&oneChunkStream{}}}
+	f, _, _ := newTestFallback(t, RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond}, primary, secondary)
+
+	it, err := f.CompleteStream(context.Background(), &CompletionRequest{})
+	if err != nil {
+		t.Fatalf("CompleteStream failed: %v", err)
+	}
+	if primary.calls != 2 || secondary.calls != 1 {
+		t.Fatalf("calls = %d/%d, want 2/1", primary.calls, secondary.calls)
+	}
+	chunk, err := it.Next()
+	if err != nil || chunk.Content != "hello" {
+		t.Fatalf("first chunk = %+v, %v", chunk, err)
+	}
+	if _, err := it.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF after the only chunk, got %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+// Package local provides a provider for local Ollama and llama.cpp servers.
+//
+// Both servers expose an OpenAI-compatible chat endpoint, so requests are
+// sent through the openai client. Models without native tool calling are
+// driven through a JSON-in-text protocol instead.
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+	openai "github.com/vigo999/ms-cli/integrations/llm/openai"
+)
+
+const (
+	defaultEndpoint  = "http://localhost:11434/v1"
+	defaultTimeout   = 300 * time.Second // local models can be slow on CPU
+	discoveryTimeout = 5 * time.Second
+	placeholderKey   = "local" // local servers ignore the key
+)
+
+// Tool calling modes.
+const (
+	ToolModeAuto   = "auto"   // ask the server whether the model supports tools
+	ToolModeNative = "native" // always send tools natively
+	ToolModeJSON   = "json"   // always use the JSON-in-text protocol
+)
+
+// Config holds the local client configuration.
+type Config struct {
+	URL      string
+	Model    string
+	Key      string
+	Timeout  time.Duration
+	ToolMode string
+
+	HTTPClient *http.Client
+}
+
+// Client implements the llm.Provider interface for local model servers.
+type Client struct {
+	endpoint   string
+	model      string
+	toolMode   string
+	chat       *openai.Client
+	httpClient *http.Client
+
+	mu         sync.Mutex
+	toolsCache map[string]bool
+}
+
+// NewClient creates a new local client.
+func NewClient(cfg Config) (*Client, error) {
+	endpoint := cfg.URL
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	endpoint = strings.TrimRight(endpoint, "/")
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	key := strings.TrimSpace(cfg.Key)
+	if key == "" {
+		key = placeholderKey
+	}
+
+	toolMode := strings.ToLower(strings.TrimSpace(cfg.ToolMode))
+	switch toolMode {
+	case "":
+		toolMode = ToolModeAuto
+	case ToolModeAuto, ToolModeNative, ToolModeJSON:
+	default:
+		return nil, fmt.Errorf("unsupported tool mode %q (use auto, native or json)", cfg.ToolMode)
+	}
+
+	chat, err := openai.NewClient(openai.Config{
+		Key:        key,
+		URL:        endpoint,
+		Model:      cfg.Model,
+		Timeout:    timeout,
+		HTTPClient: cfg.HTTPClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: discoveryTimeout}
+	}
+
+	return &Client{
+		endpoint:   endpoint,
+		model:      cfg.Model,
+		toolMode:   toolMode,
+		chat:       chat,
+		httpClient: httpClient,
+		toolsCache: make(map[string]bool),
+	}, nil
+}
+
+// Name returns the provider name.
+func (c *Client) Name() string {
+	return "local"
+}
+
+// SupportsTools reports whether the configured model accepts native tool
+// calls. When it returns false, tools are offered through the JSON-in-text
+// protocol instead.
+func (c *Client) SupportsTools() bool {
+	return c.supportsTools(c.model)
+}
+
+// Complete performs a non-streaming completion request.
+func (c *Client) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	if len(req.Tools) == 0 || c.supportsTools(c.modelFor(req)) {
+		return c.chat.Complete(ctx, req)
+	}
+
+	resp, err := c.chat.Complete(ctx, toJSONToolRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return parseJSONToolResponse(resp), nil
+}
+
+// CompleteStream performs a streaming completion request. In JSON tool mode
+// the reply has to be parsed as a whole, so it is delivered as one chunk.
+func (c *Client) CompleteStream(ctx context.Context, req *llm.CompletionRequest) (llm.StreamIterator, error) {
+	if len(req.Tools) == 0 || c.supportsTools(c.modelFor(req)) {
+		return c.chat.CompleteStream(ctx, req)
+	}
+
+	resp, err := c.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	usage := resp.Usage
+	return &singleChunkIterator{chunk: &llm.StreamChunk{
+		Content:      resp.Content,
+		ToolCalls:    resp.ToolCalls,
+		FinishReason: resp.FinishReason,
+		Usage:        &usage,
+	}}, nil
+}
+
+// AvailableModels queries the server for installed models. It tries the
+// OpenAI-compatible /models endpoint first and falls back to Ollama's
+// /api/tags. An unreachable server yields no models.
+func (c *Client) AvailableModels() []llm.ModelInfo {
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := c.getJSON(ctx, c.endpoint+"/models", &list); err == nil && len(list.Data) > 0 {
+		models := make([]llm.ModelInfo, 0, len(list.Data))
+		for _, m := range list.Data {
+			models = append(models, llm.ModelInfo{ID: m.ID, Provider: "local"})
+		}
+		return models
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := c.getJSON(ctx, c.serverRoot()+"/api/tags", &tags); err == nil {
+		models := make([]llm.ModelInfo, 0, len(tags.Models))
+		for _, m := range tags.Models {
+			models = append(models, llm.ModelInfo{ID: m.Name, Provider: "local"})
+		}
+		return models
+	}
+
+	return nil
+}
+
+func (c *Client) modelFor(req *llm.CompletionRequest) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return c.model
+}
+
+// supportsTools resolves the tool mode for a model, caching the answer the
+// server gives in auto mode.
+func (c *Client) supportsTools(model string) bool {
+	switch c.toolMode {
+	case ToolModeNative:
+		return true
+	case ToolModeJSON:
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if supported, ok := c.toolsCache[model]; ok {
+		return supported
+	}
+	supported := c.queryToolSupport(model)
+	c.toolsCache[model] = supported
+	return supported
+}
+
+// queryToolSupport asks Ollama's /api/show for the model's capabilities.
+// Servers without that endpoint, such as llama.cpp, get the JSON protocol,
+// which works with any model.
+func (c *Client) queryToolSupport(model string) bool {
+	if model == "" {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+
+	body, _ := json.Marshal(map[string]string{"model": model})
+	req, err := http.NewRequestWithContext(ctx, "POST", c.serverRoot()+"/api/show", bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	var show struct {
+		Capabilities []string `json:"capabilities"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&show); err != nil {
+		return false
+	}
+	for _, capability := range show.Capabilities {
+		if capability == "tools" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// serverRoot strips the OpenAI-compatible /v1 suffix to reach native APIs.
+func (c *Client) serverRoot() string {
+	return strings.TrimSuffix(c.endpoint, "/v1")
+}
+
+// singleChunkIterator yields one chunk and then io.EOF.
+type singleChunkIterator struct {
+	chunk *llm.StreamChunk
+}
+
+func (it *singleChunkIterator) Next() (*llm.StreamChunk, error) {
+	if it.chunk == nil {
+		return nil, io.EOF
+	}
+	chunk := it.chunk
+	it.chunk = nil
+	return chunk, nil
+}
+
+func (it *singleChunkIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,248 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+// fakeOllama is a stand-in for an Ollama server.
+type fakeOllama struct {
+	models       []string
+	toolModels   map[string]bool
+	openAIModels bool
+	reply        string
+	lastChat     map[string]any
+}
+
+func (f *fakeOllama) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/models":
+		if !f.openAIModels {
+			http.NotFound(w, r)
+			return
+		}
+		data := make([]map[string]string, len(f.models))
+		for i, m := range f.models {
+			data[i] = map[string]string{"id": m}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	case "/api/tags":
+		models := make([]map[string]string, len(f.models))
+		for i, m := range f.models {
+			models[i] = map[string]string{"name": m}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"models": models})
+	case "/api/show":
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		caps := []string{"completion"}
+		if f.toolModels[req.Model] {
+			caps = append(caps, "tools")
+		}
+		json.NewEncoder(w).Encode(map[string]any{"capabilities": caps})
+	case "/v1/chat/completions":
+		json.NewDecoder(r.Body).Decode(&f.lastChat)
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":    "chat_1",
+			"model": f.lastChat["model"],
+			"choices": []map[string]any{{
+				"message":       map[string]any{"role": "assistant", "content": f.reply},
+				"finish_reason": "stop",
+			}},
+			"usage": map[string]int{"prompt_tokens": 5, "completion_tokens": 7, "total_tokens": 12},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestClient(t *testing.T, server *fakeOllama, model, toolMode string) *Client {
+	t.Helper()
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	client, err := NewClient(Config{URL: ts.URL + "/v1", Model: model, ToolMode: toolMode})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	return client
+}
+
+func readTool() llm.Tool {
+	return llm.Tool{
+		Type: "function",
+		Function: llm.ToolFunction{
+			Name:        "read",
+			Description: "Read a file",
+			Parameters:  llm.ToolSchema{Type: "object", Required: []string{"path"}},
+		},
+	}
+}
+
+func TestAvailableModelsQueriesServer(t *testing.T) {
+	server := &fakeOllama{models: []string{"qwen2.5:7b", "llama3.1:8b"}, openAIModels: true}
+	client := newTestClient(t, server, "", "")
+
+	models := client.AvailableModels()
+	if len(models) != 2 || models[0].ID != "qwen2.5:7b" || models[1].Provider != "local" {
+		t.Fatalf("unexpected models %+v", models)
+	}
+
+	// Servers without /v1/models fall back to Ollama's /api/tags.
+	server.openAIModels = false
+	models = client.AvailableModels()
+	if len(models) != 2 || models[1].ID != "llama3.1:8b" {
+		t.Fatalf("unexpected models from /api/tags %+v", models)
+	}
+}
+
+func TestAvailableModelsUnreachableServer(t *testing.T) {
+	client, err := NewClient(Config{URL: "http://127.0.0.1:1/v1"})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if models := client.AvailableModels(); len(models) != 0 {
+		t.Fatalf("expected no models from unreachable server, got %+v", models)
+	}
+}
+
+func TestSupportsToolsAutoDetect(t *testing.T) {
+	server := &fakeOllama{toolModels: map[string]bool{"qwen2.5:7b": true}}
+
+	if !newTestClient(t, server, "qwen2.5:7b", "").SupportsTools() {
+		t.Fatal("expected tool-capable model to report native tools")
+	}
+	if newTestClient(t, server, "gemma:2b", "").SupportsTools() {
+		t.Fatal("expected model without tools capability to use JSON protocol")
+	}
+	if !newTestClient(t, server, "gemma:2b", ToolModeNative).SupportsTools() {
+		t.Fatal("expected native tool mode to override detection")
+	}
+}
+
+func TestCompleteFallsBackToJSONTools(t *testing.T) {
+	server := &fakeOllama{
+		reply: "I'll read it.\n```json\n{\"tool_calls\": [{\"name\": \"read\", \"arguments\": {\"path\": \"main.go\"}}]}\n```",
+	}
+	client := newTestClient(t, server, "gemma:2b", ToolModeJSON)
+
+	resp, err := client.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []llm.Message{
+			llm.NewSystemMessage("be helpful"),
+			llm.NewUserMessage("what is in main.go?"),
+			{
+				Role:      "assistant",
+				ToolCalls: []llm.ToolCall{{ID: "call_0", Type: "function", Function: llm.ToolCallFunc{Name: "glob", Arguments: json.RawMessage(`{"pattern":"*.go"}`)}}},
+			},
+			llm.NewToolMessage("call_0", "main.go"),
+		},
+		Tools: []llm.Tool{readTool()},
+	})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	if _, ok := server.lastChat["tools"]; ok {
+		t.Fatal("tools must not be sent natively in JSON mode")
+	}
+	messages := server.lastChat["messages"].([]any)
+	system := messages[0].(map[string]any)["content"].(string)
+	if !strings.HasPrefix(system, "be helpful") || !strings.Contains(system, "- read: Read a file") {
+		t.Fatalf("expected tool instructions in system prompt, got %q", system)
+	}
+	for _, m := range messages {
+		if role := m.(map[string]any)["role"]; role == "tool" {
+			t.Fatal("tool results should be replayed as user messages")
+		}
+	}
+	replayed := messages[3].(map[string]any)["content"].(string)
+	if !strings.HasPrefix(replayed, "Tool result (glob)") {
+		t.Fatalf("unexpected replayed tool result %q", replayed)
+	}
+
+	if resp.Content != "I'll read it." {
+		t.Fatalf("unexpected content %q", resp.Content)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Function.Name != "read" {
+		t.Fatalf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+	if string(resp.ToolCalls[0].Function.Arguments) != `{"path": "main.go"}` {
+		t.Fatalf("unexpected arguments %s", resp.ToolCalls[0].Function.Arguments)
+	}
+	if resp.FinishReason != llm.FinishToolCalls {
+		t.Fatalf("unexpected finish reason %q", resp.FinishReason)
+	}
+}
+
+func TestCompleteStreamJSONModeYieldsOneChunk(t *testing.T) {
+	server := &fakeOllama{reply: `{"name": "read", "arguments": "{\"path\": \"a.go\"}"}`}
+	client := newTestClient(t, server, "gemma:2b", ToolModeJSON)
+
+	it, err := client.CompleteStream(context.Background(), &llm.CompletionRequest{
+		Messages: []llm.Message{llm.NewUserMessage("read a.go")},
+		Tools:    []llm.Tool{readTool()},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream failed: %v", err)
+	}
+	defer it.Close()
+
+	chunk, err := it.Next()
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if len(chunk.ToolCalls) != 1 || string(chunk.ToolCalls[0].Function.Arguments) != `{"path": "a.go"}` {
+		t.Fatalf("unexpected tool calls %+v", chunk.ToolCalls)
+	}
+	if chunk.Usage == nil || chunk.Usage.TotalTokens != 12 {
+		t.Fatalf("expected usage on the chunk, got %+v", chunk.Usage)
+	}
+	if _, err := it.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF after the single chunk, got %v", err)
+	}
+}
+
+func TestPlainReplyIsNotToolCall(t *testing.T) {
+	resp := parseJSONToolResponse(&llm.CompletionResponse{Content: `Use {"a": 1} as config.`})
+	if len(resp.ToolCalls) != 0 {
+		t.Fatalf("expected no tool calls, got %+v", resp.ToolCalls)
+	}
+	if resp.Content != `Use {"a": 1} as config.` {
+		t.Fatalf("content should be unchanged, got %q", resp.Content)
+	}
+}
+
+func TestJSONToolCallIDsAreUniqueAcrossTurns(t *testing.T) {
+	server := &fakeOllama{reply: `{"tool_calls": [{"name": "read", "arguments": {"path": "a.go"}}, {"name": "read", "arguments": {"path": "b.go"}}]}`}
+	client := newTestClient(t, server, "gemma:2b", ToolModeJSON)
+	req := &llm.CompletionRequest{
+		Messages: []llm.Message{llm.NewUserMessage("read a.go and b.go")},
+		Tools:    []llm.Tool{readTool()},
+	}
+
+	seen := make(map[string]bool)
+	for turn := 1; turn <= 2; turn++ {
+		resp, err := client.Complete(context.Background(), req)
+		if err != nil {
+			t.Fatalf("turn %d: Complete failed: %v", turn, err)
+		}
+		if len(resp.ToolCalls) != 2 {
+			t.Fatalf("turn %d: unexpected tool calls %+v", turn, resp.ToolCalls)
+		}
+		for _, tc := range resp.ToolCalls {
+			if seen[tc.ID] {
+				t.Fatalf("turn %d: tool call ID %q was already used", turn, tc.ID)
+			}
+			seen[tc.ID] = true
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package local
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+)
+
+// jsonToolInstructions describes the JSON-in-text tool protocol to models
+// without native tool calling.
+const jsonToolInstructions = `You can call tools. To call one or more tools, reply with ONLY a JSON object of this form and nothing else:
+{"tool_calls": [{"name": "<tool name>", "arguments": {<arguments>}}]}
+
+Tool results are sent back in a user message starting with "Tool result". When you have the final answer, reply in plain text without any tool_calls JSON.
+
+Available tools:`
+
+// jsonToolCall is one call in the JSON-in-text protocol.
+type jsonToolCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// toJSONToolRequest rewrites a request for a model without native tools:
+// tool definitions move into the system prompt, and earlier tool calls and
+// results are replayed as plain text.
+func toJSONToolRequest(req *llm.CompletionRequest) *llm.CompletionRequest {
+	var sb strings.Builder
+	sb.WriteString(jsonToolInstructions)
+	for _, t := range req.Tools {
+		schema, _ := json.Marshal(t.Function.Parameters)
+		fmt.Fprintf(&sb, "\n- %s: %s\n  parameters: %s", t.Function.Name, t.Function.Description, schema)
+	}
+	instructions := sb.String()
+
+	names := make(map[string]string)
+	messages := make([]llm.Message, 0, len(req.Messages)+1)
+	hasSystem := false
+	for _, m := range req.Messages {
+		switch {
+		case m.Role == "system" && !hasSystem:
+			hasSystem = true
+			messages = append(messages, llm.NewSystemMessage(m.Content+"\n\n"+instructions))
+		case m.Role == "assistant" && len(m.ToolCalls) > 0:
+			calls := make([]jsonToolCall, len(m.ToolCalls))
+			for i, tc := range m.ToolCalls {
+				names[tc.ID] = tc.Function.Name
+				calls[i] = jsonToolCall{Name: tc.Function.Name, Arguments: tc.Function.Arguments}
+			}
+			data, _ := json.Marshal(map[string]any{"tool_calls": calls})
+			content := strings.TrimSpace(m.Content + "\n" + string(data))
+			messages = append(messages, llm.NewAssistantMessage(content))
+		case m.Role == "tool":
+			label := m.ToolCallID
+			if name := names[m.ToolCallID]; name != "" {
+				label = name
+			}
+			messages = append(messages, llm.NewUserMessage(fmt.Sprintf("Tool result (%s):\n%s", label, m.Content)))
+		default:
+			messages = append(messages, m)
+		}
+	}
+	if !hasSystem {
+		messages = append([]llm.Message{llm.NewSystemMessage(instructions)}, messages...)
+	}
+
+	rewritten := *req
+	rewritten.Messages = messages
+	rewritten.Tools = nil
+	return &rewritten
+}
+
+// parseJSONToolResponse extracts tool calls written as JSON in the reply
+// text. Text outside the JSON object is kept as the reply content.
+func parseJSONToolResponse(resp *llm.CompletionResponse) *llm.CompletionResponse {
+	start, end, calls := findToolCallJSON(resp.Content)
+	if len(calls) == 0 {
+		return resp
+	}
+
+	parsed := *resp
+	parsed.Content = strings.TrimSpace(trimFence(resp.Content[:start]) + " " + trimFence(resp.Content[end:]))
+	parsed.ToolCalls = make([]llm.ToolCall, len(calls))
+	for i, c := range calls {
+		args := c.Arguments
+		// Some models quote the arguments object as a string.
+		var quoted string
+		if json.Unmarshal(args, &quoted) == nil && json.Valid([]byte(quoted)) {
+			args = json.RawMessage(quoted)
+		}
+		if len(args) == 0 || string(args) == "null" {
+			args = json.RawMessage("{}")
+		}
+		parsed.ToolCalls[i] = llm.ToolCall{
+			ID:   newToolCallID(),
+			Type: "function",
+			Function: llm.ToolCallFunc{
+				Name:      c.Name,
+				Arguments: args,
+			},
+		}
+	}
+	parsed.FinishReason = llm.FinishToolCalls
+	return &parsed
+}
+
+// newToolCallID returns a random tool call ID. IDs have to be unique across
+// the whole history, including sessions resumed by another client, as tool
+// results are paired with their calls by ID.
+func newToolCallID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("call_%d", time.Now().UnixNano())
+	}
+	return "call_" + hex.EncodeToString(b)
+}
+
+// findToolCallJSON returns the byte range and calls of the first JSON object
+// in text that carries tool calls, either as {"tool_calls": [...]} or as a
+// single {"name": ..., "arguments": ...} object.
+func findToolCallJSON(text string) (int, int, []jsonToolCall) {
+	for i := 0; i < len(text); i++ {
+		if text[i] != '{' {
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(text[i:]))
+		var obj struct {
+			ToolCalls []jsonToolCall `json:"tool_calls"`
+			jsonToolCall
+		}
+		if err := dec.Decode(&obj); err != nil {
+			continue
+		}
+		end := i + int(dec.InputOffset())
+		if len(obj.ToolCalls) > 0 {
+			return i, end, obj.ToolCalls
+		}
+		if obj.Name != "" && obj.Arguments != nil {
+			return i, end, []jsonToolCall{obj.jsonToolCall}
+		}
+	}
+	return 0, 0, nil
+}
+
+// trimFence removes whitespace and a markdown code fence left around the
+// extracted JSON.
+func trimFence(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(s, "```json")
+	s = strings.TrimSuffix(s, "```")
+	s = strings.TrimPrefix(s, "```")
+	return strings.TrimSpace(s)
+}
@@ -154,6 +154,9 @@ func (c *Client) buildRequestBody(req *llm.CompletionRequest, stream bool) ([]by
 		"temperature": req.Temperature,
 		"stream":      stream,
 	}
+	if stream {
+		body["stream_options"] = map[string]any{"include_usage": true}
+	}
 
 	if req.MaxTokens > 0 {
 		body["max_tokens"] = req.MaxTokens
@@ -187,12 +190,12 @@ func (c *Client) parseError(resp *http.Response) error {
 	// Read the full body with a limit to prevent memory issues
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024)) // 1MB limit
 	if err != nil {
-		return fmt.Errorf("API error (status %d): failed to read error body: %w", resp.StatusCode, err)
+		return llm.NewAPIError(resp, "", "failed to read error body: "+err.Error())
 	}
 
 	bodyStr := string(body)
 	if bodyStr == "" {
-		return fmt.Errorf("API error (status %d): empty response", resp.StatusCode)
+		return llm.NewAPIError(resp, "", "empty response")
 	}
 
 	// Try to parse standard error format
@@ -204,10 +207,10 @@ func (c *Client) parseError(resp *http.Response) error {
 		} `json:"error"`
 	}
 	if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
-		return fmt.Errorf("API error (status %d, %s): %s", resp.StatusCode, errResp.Error.Type, errResp.Error.Message)
+		return llm.NewAPIError(resp, errResp.Error.Type, errResp.Error.Message)
 	}
 
-	return fmt.Errorf("API error (status %d): %s", resp.StatusCode, bodyStr)
+	return llm.NewAPIError(resp, "", bodyStr)
 }
 
 func (c *Client) convertMessages(msgs []llm.Message) []message {
@@ -377,9 +380,10 @@ type streamChoice struct {
 }
 
 type delta struct {
-	Role      string           `json:"role,omitempty"`
-	Content   string           `json:"content,omitempty"`
-	ToolCalls []streamToolCall `json:"tool_calls,omitempty"`
+	Role             string           `json:"role,omitempty"`
+	Content          string           `json:"content,omitempty"`
+	ReasoningContent string           `json:"reasoning_content,omitempty"`
+	ToolCalls        []streamToolCall `json:"tool_calls,omitempty"`
 }
 
 type streamToolCall struct {
@@ -414,30 +418,18 @@ func (it *streamIterator) Next() (*llm.StreamChunk, error) {
 		if err != nil {
 			if err == io.EOF {
 				it.done = true
-				if it.accumulated.Content != "" || len(it.accumulated.ToolCalls) > 0 {
-					return &llm.StreamChunk{
-						Content:   it.accumulated.Content,
-						ToolCalls: it.accumulated.ToolCalls,
-					}, io.EOF
-				}
 			}
 			return nil, err
 		}
 
 		line = strings.TrimSpace(line)
-		if line == "" || line == "data: [DONE]" {
-			if line == "data: [DONE]" {
-				it.done = true
-				if it.accumulated.Content != "" || len(it.accumulated.ToolCalls) > 0 {
-					return &llm.StreamChunk{
-						Content:   it.accumulated.Content,
-						ToolCalls: it.accumulated.ToolCalls,
-					}, nil
-				}
-				return nil, io.EOF
-			}
+		if line == "" {
 			continue
 		}
+		if line == "data: [DONE]" {
+			it.done = true
+			return nil, io.EOF
+		}
 
 		if !strings.HasPrefix(line, "data: ") {
 			continue
@@ -449,16 +441,28 @@ func (it *streamIterator) Next() (*llm.StreamChunk, error) {
 			continue
 		}
 
+		chunk := &llm.StreamChunk{}
+		// With include_usage the final chunk carries usage and no choices.
+		if resp.Usage != nil {
+			chunk.Usage = &llm.Usage{
+				PromptTokens:     resp.Usage.PromptTokens,
+				CompletionTokens: resp.Usage.CompletionTokens,
+				TotalTokens:      resp.Usage.TotalTokens,
+			}
+		}
+
 		if len(resp.Choices) == 0 {
+			if chunk.Usage != nil {
+				return chunk, nil
+			}
 			continue
 		}
 
 		choice := resp.Choices[0]
 		delta := choice.Delta
 
-		chunk := &llm.StreamChunk{
-			Content: delta.Content,
-		}
+		chunk.Content = delta.Content
+		chunk.Thinking = delta.ReasoningContent
 		if delta.Content != "" {
 			it.accumulated.Content += delta.Content
 		}
@@ -470,18 +474,10 @@ func (it *streamIterator) Next() (*llm.StreamChunk, error) {
 			copy(chunk.ToolCalls, it.accumulated.ToolCalls)
 		}
 
-		// Check finish reason
+		// Keep reading after finish_reason so the trailing usage chunk is
+		// not lost; the stream ends at [DONE] or EOF.
 		if choice.FinishReason != nil {
 			chunk.FinishReason = llm.FinishReason(*choice.FinishReason)
-			it.done = true
-		}
-
-		if resp.Usage != nil {
-			chunk.Usage = &llm.Usage{
-				PromptTokens:     resp.Usage.PromptTokens,
-				CompletionTokens: resp.Usage.CompletionTokens,
-				TotalTokens:      resp.Usage.TotalTokens,
-			}
 		}
 
 		return chunk, nil
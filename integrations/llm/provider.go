@@ -43,6 +43,20 @@ type CompletionResponse struct {
 	ToolCalls    []ToolCall
 	FinishReason FinishReason
 	Usage        Usage
+
+	// Thinking holds the extended-thinking text for display. ThinkingBlocks
+	// holds the blocks themselves, which must be sent back unchanged on
+	// later turns for providers that verify them.
+	Thinking       string
+	ThinkingBlocks []ThinkingBlock
+}
+
+// ThinkingBlock is one extended-thinking block with its own signature. A
+// redacted block carries only its encrypted Data.
+type ThinkingBlock struct {
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
 }
 
 // FinishReason represents why the completion finished.
@@ -61,6 +75,13 @@ type Message struct {
 	Content    string     `json:"content"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
+
+	// Thinking and ThinkingBlocks carry an assistant's extended thinking so
+	// it can be replayed to providers that require it. ThinkingSignature is
+	// the signature of a single block, as saved by earlier sessions.
+	Thinking          string          `json:"thinking,omitempty"`
+	ThinkingSignature string          `json:"thinking_signature,omitempty"`
+	ThinkingBlocks    []ThinkingBlock `json:"thinking_blocks,omitempty"`
 }
 
 // Tool represents a tool definition.
@@ -85,9 +106,10 @@ type ToolSchema struct {
 
 // Property represents a property in the tool schema.
 type Property struct {
-	Type        string   `json:"type"`
-	Description string   `json:"description"`
-	Enum        []string `json:"enum,omitempty"`
+	Type        string    `json:"type"`
+	Description string    `json:"description"`
+	Enum        []string  `json:"enum,omitempty"`
+	Items       *Property `json:"items,omitempty"` // element schema for arrays
 }
 
 // ToolCall represents a tool call request from the model.
@@ -133,11 +155,18 @@ type StreamIterator interface {
 }
 
 // StreamChunk represents a chunk in a streaming response.
+// Content and Thinking carry only the text added by this chunk, while
+// ToolCalls holds every tool call assembled so far in the stream.
+// ThinkingSignature ends the thinking block streamed so far, and
+// RedactedThinking is the data of a whole redacted block.
 type StreamChunk struct {
-	Content      string
-	ToolCalls    []ToolCall
-	FinishReason FinishReason
-	Usage        *Usage
+	Content           string
+	Thinking          string
+	ThinkingSignature string
+	RedactedThinking  string
+	ToolCalls         []ToolCall
+	FinishReason      FinishReason
+	Usage             *Usage
 }
 
 // NewUserMessage creates a new user message.
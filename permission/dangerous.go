@@ -1,16 +1,29 @@
 package permission
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 )
 
 // DangerousCommand 定义危险命令
 type DangerousCommand struct {
-	Pattern     string          // 正则表达式模式
+	Pattern     string          // 正则表达式模式，从简单命令的开头匹配
 	Level       PermissionLevel // 默认权限级别
 	Category    string          // 类别
 	Description string          // 描述
+
+	// 检查结果中触发判定的片段及其在原命令中的位置
+	Segment    string
+	Start, End int
+}
+
+// Highlight 用 »« 标出命令中触发判定的片段
+func (d *DangerousCommand) Highlight(command string) string {
+	if d == nil || d.Start < 0 || d.End > len(command) || d.Start >= d.End {
+		return command
+	}
+	return command[:d.Start] + "»" + command[d.Start:d.End] + "«" + command[d.End:]
 }
 
 // 预定义的危险命令列表
@@ -166,16 +179,40 @@ var defaultDangerousCommands = []DangerousCommand{
 	},
 }
 
-// DangerousCommandChecker 危险命令检查器
+// DangerousCommandChecker 危险命令检查器。命令先由 shell 解析器拆分，
+// 管道、列表、子 shell、命令替换以及 sh -c 和 eval 中的每个简单命令都会被检查。
 type DangerousCommandChecker struct {
 	commands []compiledCommand
 }
 
 type compiledCommand struct {
-	cmd DangerousCommand
-	re  *regexp.Regexp
+	cmd      DangerousCommand
+	re       *regexp.Regexp // 匹配整条命令，用于无法解析的命令
+	anchored *regexp.Regexp // 从简单命令的开头匹配
 }
 
+// 管道和函数定义的结构性检查
+var (
+	pipeToShell = DangerousCommand{
+		Level:       PermissionAsk,
+		Category:    "exec",
+		Description: "Pipe into shell",
+	}
+	forkBomb = DangerousCommand{
+		Level:       PermissionDeny,
+		Category:    "system",
+		Description: "Fork bomb",
+	}
+	unparsedCommand = DangerousCommand{
+		Level:       PermissionAsk,
+		Category:    "syntax",
+		Description: "Command could not be parsed",
+	}
+)
+
+// downloaders 通过管道传给 shell 时视为网络风险的命令
+var downloaders = map[string]bool{"curl": true, "wget": true}
+
 // NewDangerousCommandChecker 创建危险命令检查器
 func NewDangerousCommandChecker() *DangerousCommandChecker {
 	c := &DangerousCommandChecker{
@@ -193,11 +230,83 @@ func (c *DangerousCommandChecker) AddCommand(cmd DangerousCommand) {
 	if err != nil {
 		return
 	}
-	c.commands = append(c.commands, compiledCommand{cmd: cmd, re: re})
+	anchored, err := regexp.Compile(`^(?:` + cmd.Pattern + `)`)
+	if err != nil {
+		return
+	}
+	c.commands = append(c.commands, compiledCommand{cmd: cmd, re: re, anchored: anchored})
 }
 
-// Check 检查命令是否危险
+// Check 检查命令是否危险，返回最严格的判定及触发它的片段。
+// 无法解析的命令按原始字符串匹配，没有匹配时也需要询问。
 func (c *DangerousCommandChecker) Check(command string) *DangerousCommand {
+	if strings.TrimSpace(command) == "" {
+		return nil
+	}
+	parsed, err := parseShell(command)
+	if err != nil {
+		whole := CommandSegment{Text: command, Start: 0, End: len(command)}
+		if found := c.checkRaw(command); found != nil {
+			return verdict(*found, whole)
+		}
+		return verdict(unparsedCommand, whole)
+	}
+
+	var strictest *DangerousCommand
+	consider := func(found *DangerousCommand) {
+		if found != nil && (strictest == nil || found.Level < strictest.Level) {
+			strictest = found
+		}
+	}
+
+	for _, seg := range parsed.segments {
+		consider(c.checkSegment(seg))
+	}
+	for _, pipe := range parsed.pipes {
+		if !readsScriptFromStdin(pipe.to.Args) {
+			continue
+		}
+		found := pipeToShell
+		for _, arg := range pipe.from.Args {
+			if name := commandBase(arg); downloaders[name] {
+				found.Category = "network"
+				found.Description = fmt.Sprintf("Pipe %s to shell", name)
+				break
+			}
+		}
+		seg := CommandSegment{Start: pipe.start, End: pipe.end}
+		seg.Text = command[pipe.start:pipe.end]
+		consider(verdict(found, seg))
+	}
+	for _, seg := range parsed.forkBombs {
+		consider(verdict(forkBomb, seg))
+	}
+	return strictest
+}
+
+// checkSegment 检查一个简单命令及其去掉包装命令后的形式
+func (c *DangerousCommandChecker) checkSegment(seg CommandSegment) *DangerousCommand {
+	// 每次去掉一层包装，使 sudo 本身和被包装的命令都被检查
+	var candidates []string
+	for args, ok := seg.Args, len(seg.Args) > 0; ok && len(args) > 0; args, ok = peelWrapper(args) {
+		normalized := append([]string{commandBase(args[0])}, args[1:]...)
+		candidates = append(candidates, strings.Join(normalized, " "))
+	}
+	candidates = append(candidates, seg.Redirects...)
+
+	var strictest *DangerousCommand
+	for _, candidate := range candidates {
+		for _, cmd := range c.commands {
+			if cmd.anchored.MatchString(candidate) && (strictest == nil || cmd.cmd.Level < strictest.Level) {
+				strictest = verdict(cmd.cmd, seg)
+			}
+		}
+	}
+	return strictest
+}
+
+// checkRaw 按原始字符串匹配
+func (c *DangerousCommandChecker) checkRaw(command string) *DangerousCommand {
 	for _, cmd := range c.commands {
 		if cmd.re.MatchString(command) {
 			found := cmd.cmd
@@ -207,6 +316,13 @@ func (c *DangerousCommandChecker) Check(command string) *DangerousCommand {
 	return nil
 }
 
+// verdict 返回带片段信息的检查结果
+func verdict(cmd DangerousCommand, seg CommandSegment) *DangerousCommand {
+	cmd.Segment = seg.Text
+	cmd.Start, cmd.End = seg.Start, seg.End
+	return &cmd
+}
+
 // IsDangerous 检查命令是否危险
 func (c *DangerousCommandChecker) IsDangerous(command string) bool {
 	return c.Check(command) != nil
@@ -307,10 +423,15 @@ func NewCommandWhitelist(commands []string) *CommandWhitelist {
 	return w
 }
 
-// IsAllowed 检查命令是否在白名单中
+// IsAllowed 检查命令中的每个命令是否都在白名单中
 func (w *CommandWhitelist) IsAllowed(command string) bool {
-	cmd := extractCommandName(command)
-	return w.commands[cmd]
+	names := commandNames(command)
+	for _, name := range names {
+		if !w.commands[name] {
+			return false
+		}
+	}
+	return len(names) > 0
 }
 
 // Add 添加命令到白名单
@@ -339,10 +460,14 @@ func NewCommandBlacklist(commands []string) *CommandBlacklist {
 	return b
 }
 
-// IsBlocked 检查命令是否在黑名单中
+// IsBlocked 检查命令中是否有命令在黑名单中
 func (b *CommandBlacklist) IsBlocked(command string) bool {
-	cmd := extractCommandName(command)
-	return b.commands[cmd]
+	for _, name := range commandNames(command) {
+		if b.commands[name] {
+			return true
+		}
+	}
+	return false
 }
 
 // Add 添加命令到黑名单
@@ -355,7 +480,7 @@ func (b *CommandBlacklist) Remove(command string) {
 	delete(b.commands, command)
 }
 
-// IsAllowedCommand 检查命令是否是常见的安全命令
+// IsAllowedCommand 检查命令中的每个命令是否都是常见的安全命令
 func IsAllowedCommand(command string) bool {
 	allowedCommands := []string{
 		"ls", "ll", "cat", "pwd", "echo", "cd", "pwd",
@@ -364,11 +489,18 @@ func IsAllowedCommand(command string) bool {
 		"date", "cal", "clear", "history", "exit",
 	}
 
-	cmd := extractCommandName(command)
-	for _, allowed := range allowedCommands {
-		if cmd == allowed {
-			return true
+	names := commandNames(command)
+	for _, name := range names {
+		allowed := false
+		for _, a := range allowedCommands {
+			if name == a {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
 		}
 	}
-	return false
+	return len(names) > 0
 }
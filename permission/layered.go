@@ -0,0 +1,188 @@
+package permission
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// 决策来源
+const (
+	SourceProject = "project"
+	SourceUser    = "user"
+)
+
+// SourcedDecision 带来源的权限决策
+type SourcedDecision struct {
+	PermissionDecision
+	Source     string
+	Overridden bool // 用户级决策被项目级同一决策覆盖
+}
+
+// LayeredPermissionStore 合并项目级与用户级权限存储，项目级优先。
+// 新决策写入项目级存储。
+type LayeredPermissionStore struct {
+	project *FilePermissionStore
+	user    *FilePermissionStore
+}
+
+// NewLayeredPermissionStore 创建分层权限存储；userPath 为空时只使用项目级存储
+func NewLayeredPermissionStore(projectPath, userPath string) (*LayeredPermissionStore, error) {
+	project, err := NewFilePermissionStore(projectPath)
+	if err != nil {
+		return nil, fmt.Errorf("project permissions: %w", err)
+	}
+	s := &LayeredPermissionStore{project: project}
+	if userPath != "" && userPath != projectPath {
+		user, err := NewFilePermissionStore(userPath)
+		if err != nil {
+			return nil, fmt.Errorf("user permissions: %w", err)
+		}
+		s.user = user
+	}
+	return s, nil
+}
+
+// Project 返回项目级存储
+func (s *LayeredPermissionStore) Project() *FilePermissionStore {
+	return s.project
+}
+
+// User 返回用户级存储，未配置时为 nil
+func (s *LayeredPermissionStore) User() *FilePermissionStore {
+	return s.user
+}
+
+// SaveDecision 保存决策到项目级存储
+func (s *LayeredPermissionStore) SaveDecision(decision PermissionDecision) error {
+	return s.project.SaveDecision(decision)
+}
+
+// LoadDecisions 加载生效的决策：先用户级，再项目级，后者覆盖前者
+func (s *LayeredPermissionStore) LoadDecisions() ([]PermissionDecision, error) {
+	var result []PermissionDecision
+	for _, e := range s.layers() {
+		if !e.Overridden {
+			result = append(result, e.PermissionDecision)
+		}
+	}
+	return result, nil
+}
+
+// ClearDecisions 清除两级存储中的所有决策
+func (s *LayeredPermissionStore) ClearDecisions() error {
+	for _, store := range s.stores() {
+		if err := store.ClearDecisions(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Entries 返回所有决策及其来源，项目级在前
+func (s *LayeredPermissionStore) Entries() []SourcedDecision {
+	entries := s.layers()
+	result := make([]SourcedDecision, 0, len(entries))
+	for _, e := range entries {
+		if e.Source == SourceProject {
+			result = append(result, e)
+		}
+	}
+	for _, e := range entries {
+		if e.Source == SourceUser {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Remove 从两级存储中移除满足条件的决策
+func (s *LayeredPermissionStore) Remove(match func(PermissionDecision) bool) ([]SourcedDecision, error) {
+	var removed []SourcedDecision
+	for i, store := range s.stores() {
+		source := SourceProject
+		if i > 0 {
+			source = SourceUser
+		}
+		ds, err := store.RemoveDecisions(match)
+		for _, d := range ds {
+			removed = append(removed, SourcedDecision{PermissionDecision: d, Source: source})
+		}
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// RemoveExpired 移除早于 maxAge 的决策
+func (s *LayeredPermissionStore) RemoveExpired(maxAge time.Duration) ([]SourcedDecision, error) {
+	cutoff := time.Now().Add(-maxAge)
+	return s.Remove(func(d PermissionDecision) bool {
+		return !d.Timestamp.After(cutoff)
+	})
+}
+
+// ExportToFile 导出生效的决策
+func (s *LayeredPermissionStore) ExportToFile(exportPath string) (int, error) {
+	decisions, _ := s.LoadDecisions()
+	if decisions == nil {
+		decisions = []PermissionDecision{}
+	}
+	data, err := json.MarshalIndent(decisions, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	return len(decisions), os.WriteFile(exportPath, data, 0644)
+}
+
+// ImportFromFile 导入决策到项目级存储，user 为 true 时导入用户级存储
+func (s *LayeredPermissionStore) ImportFromFile(importPath string, user bool) error {
+	if !user {
+		return s.project.ImportFromFile(importPath)
+	}
+	if s.user == nil {
+		return fmt.Errorf("no user-level permission file")
+	}
+	return s.user.ImportFromFile(importPath)
+}
+
+// layers 返回用户级决策（标记被覆盖者）及项目级决策
+func (s *LayeredPermissionStore) layers() []SourcedDecision {
+	projectDecisions, _ := s.project.LoadDecisions()
+	overrides := make(map[string]bool, len(projectDecisions))
+	for _, d := range projectDecisions {
+		overrides[decisionKey(d)] = true
+	}
+
+	var result []SourcedDecision
+	if s.user != nil {
+		userDecisions, _ := s.user.LoadDecisions()
+		for _, d := range userDecisions {
+			result = append(result, SourcedDecision{
+				PermissionDecision: d,
+				Source:             SourceUser,
+				Overridden:         overrides[decisionKey(d)],
+			})
+		}
+	}
+	for _, d := range projectDecisions {
+		result = append(result, SourcedDecision{PermissionDecision: d, Source: SourceProject})
+	}
+	return result
+}
+
+// stores 返回项目级和用户级存储，项目级在前
+func (s *LayeredPermissionStore) stores() []*FilePermissionStore {
+	if s.user == nil {
+		return []*FilePermissionStore{s.project}
+	}
+	return []*FilePermissionStore{s.project, s.user}
+}
+
+// decisionKey 决策的唯一键
+func decisionKey(d PermissionDecision) string {
+	return d.Tool + "\x00" + d.Action + "\x00" + d.Path + "\x00" + strconv.FormatBool(d.Exact)
+}
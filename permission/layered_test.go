@@ -0,0 +1,73 @@
+package permission
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vigo999/ms-cli/configs"
+)
+
+func newTestLayeredStore(t *testing.T) *LayeredPermissionStore {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := NewLayeredPermissionStore(filepath.Join(dir, "project.json"), filepath.Join(dir, "user.json"))
+	if err != nil {
+		t.Fatalf("NewLayeredPermissionStore failed: %v", err)
+	}
+	return store
+}
+
+func TestLayeredStoreProjectWins(t *testing.T) {
+	store := newTestLayeredStore(t)
+	now := time.Now()
+	_ = store.User().SaveDecision(PermissionDecision{Tool: "shell", Action: "git", Level: PermissionAllowAlways, Timestamp: now})
+	_ = store.User().SaveDecision(PermissionDecision{Tool: "write", Level: PermissionAllowAlways, Timestamp: now})
+	_ = store.SaveDecision(PermissionDecision{Tool: "shell", Action: "git", Level: PermissionDeny, Timestamp: now})
+
+	svc := NewDefaultPermissionService(configs.DefaultConfig().Permissions)
+	svc.SetStore(store)
+	if level := svc.GetCommandPolicies()["git"]; level != PermissionDeny {
+		t.Errorf("expected the project decision for git, got %s", level)
+	}
+	if level := svc.Check("write", ""); level != PermissionAllowAlways {
+		t.Errorf("expected the user decision for write, got %s", level)
+	}
+
+	var overridden int
+	for _, e := range store.Entries() {
+		if e.Overridden {
+			overridden++
+		}
+	}
+	if overridden != 1 {
+		t.Errorf("expected one overridden user decision, got %d", overridden)
+	}
+}
+
+func TestLayeredStoreRemoveAndExpire(t *testing.T) {
+	store := newTestLayeredStore(t)
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	_ = store.SaveDecision(PermissionDecision{Tool: "shell", Action: "make", Level: PermissionAllowAlways, Timestamp: time.Now()})
+	_ = store.User().SaveDecision(PermissionDecision{Tool: "shell", Action: "make", Level: PermissionAllowAlways, Timestamp: old})
+	_ = store.User().SaveDecision(PermissionDecision{Tool: "edit", Level: PermissionAllowAlways, Timestamp: old})
+
+	expired, err := store.RemoveExpired(7 * 24 * time.Hour)
+	if err != nil || len(expired) != 2 {
+		t.Fatalf("expected two expired user decisions, got %d (%v)", len(expired), err)
+	}
+
+	svc := NewDefaultPermissionService(configs.DefaultConfig().Permissions)
+	svc.SetStore(store)
+	removed, err := store.Remove(func(d PermissionDecision) bool { return d.Action == "make" })
+	if err != nil || len(removed) != 1 || removed[0].Source != SourceProject {
+		t.Fatalf("unexpected removal: %+v (%v)", removed, err)
+	}
+	svc.ForgetDecision(removed[0].PermissionDecision)
+	if _, ok := svc.GetCommandPolicies()["make"]; ok {
+		t.Error("revoked command should no longer be granted")
+	}
+	if decisions, _ := store.LoadDecisions(); len(decisions) != 0 {
+		t.Errorf("expected no decisions left, got %+v", decisions)
+	}
+}
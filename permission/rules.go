@@ -0,0 +1,319 @@
+package permission
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 访问类型
+const (
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+// readTools 只读取数据的工具；其余工具（包括 shell）视为写
+var readTools = map[string]bool{
+	"read":          true,
+	"grep":          true,
+	"glob":          true,
+	"artifact_read": true,
+	"recall":        true,
+}
+
+// ToolAccess 返回工具的访问类型
+func ToolAccess(tool string) string {
+	if readTools[tool] {
+		return AccessRead
+	}
+	return AccessWrite
+}
+
+// Rule 权限规则。所有设置的条件都满足时规则匹配。
+type Rule struct {
+	Name    string `yaml:"name,omitempty"`
+	Tool    string `yaml:"tool,omitempty"`    // 工具名通配，空表示任意工具
+	Access  string `yaml:"access,omitempty"`  // read 或 write，空表示任意
+	Path    string `yaml:"path,omitempty"`    // 路径通配，** 跨目录匹配
+	Command Argv   `yaml:"command,omitempty"` // shell 命令参数匹配
+	Level   string `yaml:"level"`
+
+	level PermissionLevel
+}
+
+// Argv 按参数匹配的命令模式：* 匹配一个参数，** 匹配其余所有参数。
+// YAML 中可写作字符串 "git push **" 或列表。
+type Argv []string
+
+// UnmarshalYAML 支持字符串和列表两种写法
+func (a *Argv) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*a = SplitArgv(node.Value)
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// String 返回命令模式的文本形式
+func (a Argv) String() string {
+	return strings.Join(a, " ")
+}
+
+// RuleSet 有序的权限规则，第一条匹配的规则生效
+type RuleSet struct {
+	Rules []Rule `yaml:"rules"`
+
+	file string // 规则文件路径
+	root string // 相对路径规则的根目录
+}
+
+// LoadRules 加载规则文件；文件不存在时返回 nil。相对路径按 root 解析。
+func LoadRules(file, root string) (*RuleSet, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read permission rules: %w", err)
+	}
+	rs, err := ParseRules(data, root)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	rs.file = file
+	return rs, nil
+}
+
+// ParseRules 解析并校验规则
+func ParseRules(data []byte, root string) (*RuleSet, error) {
+	var rs RuleSet
+	if err := yaml.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("parse permission rules: %w", err)
+	}
+	for i := range rs.Rules {
+		if err := rs.Rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	rs.root = root
+	return &rs, nil
+}
+
+// File 返回规则文件路径
+func (rs *RuleSet) File() string {
+	if rs == nil {
+		return ""
+	}
+	return rs.file
+}
+
+// Match 返回第一条匹配请求的规则及其序号（从 1 开始）
+func (rs *RuleSet) Match(req PermissionRequest) (*Rule, int) {
+	if rs == nil {
+		return nil, 0
+	}
+	for i := range rs.Rules {
+		if rs.Rules[i].matches(req, rs.root) {
+			return &rs.Rules[i], i + 1
+		}
+	}
+	return nil, 0
+}
+
+func (r *Rule) validate() error {
+	switch strings.ToLower(strings.TrimSpace(r.Level)) {
+	case "deny":
+		r.level = PermissionDeny
+	case "ask":
+		r.level = PermissionAsk
+	case "allow_session":
+		r.level = PermissionAllowSession
+	case "allow", "allow_always":
+		r.level = PermissionAllowAlways
+	case "":
+		return fmt.Errorf("level is required")
+	default:
+		return fmt.Errorf("invalid level %q (use deny, ask, allow_session or allow)", r.Level)
+	}
+
+	switch r.Access {
+	case "", AccessRead, AccessWrite:
+	default:
+		return fmt.Errorf("invalid access %q (use read or write)", r.Access)
+	}
+	if r.Tool != "" {
+		if _, err := path.Match(r.Tool, ""); err != nil {
+			return fmt.Errorf("invalid tool pattern %q: %w", r.Tool, err)
+		}
+	}
+	if r.Path != "" {
+		if _, err := path.Match(strings.ReplaceAll(r.Path, "**", "*"), ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %w", r.Path, err)
+		}
+	}
+	for _, arg := range r.Command {
+		if _, err := path.Match(arg, ""); err != nil {
+			return fmt.Errorf("invalid command pattern %q: %w", r.Command, err)
+		}
+	}
+	return nil
+}
+
+// LevelValue 返回规则的权限级别
+func (r *Rule) LevelValue() PermissionLevel {
+	return r.level
+}
+
+// Describe 返回规则的简短描述
+func (r *Rule) Describe() string {
+	var parts []string
+	if r.Name != "" {
+		parts = append(parts, fmt.Sprintf("%q", r.Name))
+	}
+	if r.Tool != "" {
+		parts = append(parts, "tool="+r.Tool)
+	}
+	if r.Access != "" {
+		parts = append(parts, "access="+r.Access)
+	}
+	if r.Path != "" {
+		parts = append(parts, "path="+r.Path)
+	}
+	if len(r.Command) > 0 {
+		parts = append(parts, fmt.Sprintf("command=%q", r.Command.String()))
+	}
+	parts = append(parts, "level="+r.level.String())
+	return strings.Join(parts, " ")
+}
+
+func (r *Rule) matches(req PermissionRequest, root string) bool {
+	if r.Tool != "" {
+		if ok, _ := path.Match(r.Tool, req.Tool); !ok {
+			return false
+		}
+	}
+	if r.Access != "" && ToolAccess(req.Tool) != r.Access {
+		return false
+	}
+	if r.Path != "" {
+		if req.Path == "" || !matchPath(r.Path, req.Path, root) {
+			return false
+		}
+	}
+	if len(r.Command) > 0 {
+		if req.Tool != "shell" || !matchArgv(r.Command, SplitArgv(normalizeCommandInput(req.Action))) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchArgv 按参数匹配命令
+func matchArgv(pattern, argv []string) bool {
+	for i, p := range pattern {
+		if p == "**" {
+			return true
+		}
+		if i >= len(argv) {
+			return false
+		}
+		if ok, _ := path.Match(p, argv[i]); !ok {
+			return false
+		}
+	}
+	return len(argv) == len(pattern)
+}
+
+// matchPath 匹配路径。不含 / 的模式匹配文件名；其余模式按 root 的相对路径匹配，
+// ** 匹配任意层目录。
+func matchPath(pattern, p, root string) bool {
+	p = filepath.ToSlash(filepath.Clean(p))
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(p))
+		return ok
+	}
+
+	if path.IsAbs(p) && !path.IsAbs(pattern) && root != "" {
+		rel, err := filepath.Rel(root, filepath.FromSlash(p))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return false
+		}
+		p = filepath.ToSlash(rel)
+	}
+	pattern = strings.TrimPrefix(pattern, "./")
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(p, "/"))
+}
+
+func matchSegments(pattern, segs []string) bool {
+	if len(pattern) == 0 {
+		return len(segs) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchSegments(pattern[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segs[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segs[1:])
+}
+
+// SplitArgv 按空白拆分命令参数，支持单双引号和反斜杠转义
+func SplitArgv(command string) []string {
+	var (
+		args    []string
+		cur     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range command {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
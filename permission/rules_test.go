@@ -0,0 +1,109 @@
+package permission
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vigo999/ms-cli/configs"
+)
+
+const testRules = `
+rules:
+  - name: no secrets
+    path: "**/.env"
+    level: deny
+  - name: confirm pushes
+    tool: shell
+    command: git push **
+    level: ask
+  - tool: shell
+    command: [git, "*"]
+    level: allow
+  - access: read
+    level: allow
+  - tool: write
+    path: src/**/*.go
+    level: allow_session
+`
+
+func newRulesService(t *testing.T) *DefaultPermissionService {
+	t.Helper()
+	rules, err := ParseRules([]byte(testRules), "/work")
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+	svc := NewDefaultPermissionService(configs.DefaultConfig().Permissions)
+	svc.SetRules(rules)
+	return svc
+}
+
+func TestRulesFirstMatchWins(t *testing.T) {
+	svc := newRulesService(t)
+
+	cases := []struct {
+		tool, action, path string
+		level              PermissionLevel
+		rule               int
+	}{
+		{"read", "", "config/.env", PermissionDeny, 1},
+		{"shell", "git push origin main", "", PermissionAsk, 2},
+		{"shell", "git status", "", PermissionAllowAlways, 3},
+		{"shell", "git log --oneline", "", PermissionAsk, 0}, // two args: no rule
+		{"grep", "", "src/main.go", PermissionAllowAlways, 4},
+		{"write", "", "/work/src/app/main.go", PermissionAllowSession, 5},
+		{"write", "", "docs/readme.md", PermissionAsk, 0},
+	}
+	for _, c := range cases {
+		d := svc.Explain(c.tool, c.action, c.path)
+		if d.Level != c.level || d.RuleIndex != c.rule {
+			t.Errorf("%s %q %q: got %s by rule %d (%s), want %s by rule %d",
+				c.tool, c.action, c.path, d.Level, d.RuleIndex, d.Reason, c.level, c.rule)
+		}
+	}
+}
+
+func TestAskRuleKeepsRememberedGrants(t *testing.T) {
+	svc := newRulesService(t)
+	svc.GrantCommand("git", PermissionAllowSession)
+
+	d := svc.Explain("shell", "git push origin main", "")
+	if d.Level != PermissionAllowSession || d.RuleIndex != 2 {
+		t.Fatalf("expected the session grant under rule 2, got %s (%s)", d.Level, d.Reason)
+	}
+	if !strings.Contains(d.Reason, "`git` commands") {
+		t.Errorf("reason should name the grant: %q", d.Reason)
+	}
+
+	// A deny rule is final.
+	svc.GrantPath("config/.env", PermissionAllowAlways)
+	if granted, _ := svc.Request(context.Background(), "read", "", "config/.env"); granted {
+		t.Error("deny rule should not be overridden")
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "permissions.yaml")
+
+	if rules, err := LoadRules(file, dir); err != nil || rules != nil {
+		t.Fatalf("missing file should load no rules, got %v %v", rules, err)
+	}
+
+	if err := os.WriteFile(file, []byte("rules:\n  - tool: shell\n    level: maybe\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRules(file, dir); err == nil || !strings.Contains(err.Error(), "rule 1") {
+		t.Errorf("expected an invalid level error, got %v", err)
+	}
+}
+
+func TestSplitArgv(t *testing.T) {
+	got := SplitArgv(`git commit -m "fix the bug" --author='A B' a\ b`)
+	want := []string{"git", "commit", "-m", "fix the bug", "--author=A B", "a b"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("SplitArgv = %q, want %q", got, want)
+	}
+}
@@ -51,11 +51,14 @@ type DefaultPermissionService struct {
 	mu              sync.RWMutex
 	policies        map[string]PermissionLevel
 	commandPolicies map[string]PermissionLevel
+	exactCommands   map[string]PermissionLevel // grants for one exact dangerous command
 	pathPatterns    []PathPermission
 	default_        PermissionLevel
 	skipAsk         bool
 	ui              PermissionUI
 	store           PermissionStore
+	configured      map[string]PermissionLevel // tool policies from the config
+	rules           *RuleSet
 }
 
 // PathPermission 路径权限
@@ -66,9 +69,33 @@ type PathPermission struct {
 
 // PermissionUI is the interface for permission UI interaction.
 type PermissionUI interface {
-	// RequestPermission asks the user for permission.
-	// Returns (granted, remember, error).
-	RequestPermission(tool, action, path string) (bool, bool, error)
+	// RequestPermission asks the user about a tool call and blocks until
+	// they answer or ctx is done. It returns PermissionAllowOnce,
+	// PermissionAllowSession, PermissionAllowAlways or PermissionDeny.
+	RequestPermission(ctx context.Context, req PermissionRequest) (PermissionLevel, error)
+}
+
+// PermissionRequest describes a tool call waiting for the user's decision.
+type PermissionRequest struct {
+	Tool   string
+	Action string // shell command, or the raw tool arguments
+	Path   string
+	Danger *DangerousCommand // set when a shell command matches a dangerous pattern
+}
+
+// Scope describes what a session or permanent grant covers. A dangerous
+// shell command is only ever granted as its exact text.
+func (r PermissionRequest) Scope() string {
+	switch names := commandNames(r.Action); {
+	case r.Tool == "shell" && r.Danger != nil:
+		return "this exact command"
+	case r.Tool == "shell" && len(names) > 0:
+		return "`" + strings.Join(names, "`, `") + "` commands"
+	case r.Path != "":
+		return r.Path
+	default:
+		return fmt.Sprintf("the %s tool", r.Tool)
+	}
 }
 
 // NewDefaultPermissionService creates a new permission service.
@@ -76,6 +103,7 @@ func NewDefaultPermissionService(cfg configs.PermissionsConfig) *DefaultPermissi
 	svc := &DefaultPermissionService{
 		policies:        make(map[string]PermissionLevel),
 		commandPolicies: make(map[string]PermissionLevel),
+		exactCommands:   make(map[string]PermissionLevel),
 		pathPatterns:    make([]PathPermission, 0),
 		default_:        ParsePermissionLevel(cfg.DefaultLevel),
 		skipAsk:         cfg.SkipRequests,
@@ -96,6 +124,11 @@ func NewDefaultPermissionService(cfg configs.PermissionsConfig) *DefaultPermissi
 		svc.policies[tool] = PermissionDeny
 	}
 
+	svc.configured = make(map[string]PermissionLevel, len(svc.policies))
+	for tool, level := range svc.policies {
+		svc.configured[tool] = level
+	}
+
 	return svc
 }
 
@@ -104,7 +137,22 @@ func (s *DefaultPermissionService) SetUI(ui PermissionUI) {
 	s.ui = ui
 }
 
-// SetStore sets the permission store.
+// SetRules sets the ordered rules checked before any other policy.
+func (s *DefaultPermissionService) SetRules(rules *RuleSet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = rules
+}
+
+// Rules returns the rules in use, or nil.
+func (s *DefaultPermissionService) Rules() *RuleSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rules
+}
+
+// SetStore sets the permission store and applies its saved decisions.
+// Calling it again re-applies them, e.g. after an import.
 func (s *DefaultPermissionService) SetStore(store PermissionStore) {
 	s.store = store
 	if s.store == nil {
@@ -115,44 +163,61 @@ func (s *DefaultPermissionService) SetStore(store PermissionStore) {
 		return
 	}
 	for _, d := range decisions {
-		if d.Action != "" && d.Tool == "shell" {
-			s.GrantCommand(d.Action, d.Level)
-			continue
-		}
-		if d.Path != "" {
-			s.GrantPath(d.Path, d.Level)
-			continue
-		}
-		s.Grant(d.Tool, d.Level)
+		s.ApplyDecision(d)
 	}
 }
 
-// Request requests permission.
-func (s *DefaultPermissionService) Request(ctx context.Context, tool, action, path string) (bool, error) {
-	// 1. 检查工具级别权限
-	toolLevel := s.Check(tool, action)
-	level := toolLevel
-	cmdLevel := PermissionAllowAlways
-	pathLevel := PermissionAllowAlways
-
-	// 2. 检查命令级别权限（如果是 shell 工具）
-	if tool == "shell" && action != "" {
-		cmdLevel = s.CheckCommand(action)
-		if cmdLevel < level {
-			level = cmdLevel
-		}
+// ApplyDecision grants a saved decision on its scope.
+func (s *DefaultPermissionService) ApplyDecision(d PermissionDecision) {
+	switch {
+	case d.Exact && d.Tool == "shell":
+		s.GrantExactCommand(d.Action, d.Level)
+	case d.Action != "" && d.Tool == "shell":
+		s.GrantCommand(d.Action, d.Level)
+	case d.Path != "":
+		s.GrantPath(d.Path, d.Level)
+	default:
+		s.Grant(d.Tool, d.Level)
 	}
+}
 
-	// 3. 检查路径级别权限
-	if path != "" {
-		pathLevel = s.CheckPath(path)
-		if pathLevel < level {
-			level = pathLevel
+// ForgetDecision undoes ApplyDecision. A tool falls back to its configured
+// policy, if any.
+func (s *DefaultPermissionService) ForgetDecision(d PermissionDecision) {
+	switch {
+	case d.Exact && d.Tool == "shell":
+		s.RevokeExactCommand(d.Action)
+	case d.Action != "" && d.Tool == "shell":
+		s.RevokeCommand(d.Action)
+	case d.Path != "":
+		s.RevokePath(d.Path)
+	default:
+		if level, ok := s.configured[d.Tool]; ok {
+			s.Grant(d.Tool, level)
+			return
 		}
+		s.Revoke(d.Tool)
 	}
+}
+
+// Request requests permission.
+//
+// The first matching rule decides; an "ask" rule still lets remembered
+// grants apply. Without a matching rule, an explicit deny on the tool,
+// command or path wins, and otherwise the most specific explicit policy
+// decides: a command policy for shell, then a matching path pattern, then
+// the tool level. A dangerous shell command still needs asking under a
+// command policy unless it was granted by its exact text or a rule allows it.
+func (s *DefaultPermissionService) Request(ctx context.Context, tool, action, path string) (bool, error) {
+	req := PermissionRequest{Tool: tool, Action: action, Path: path}
+	d := s.decide(req)
+	level, scope := d.Level, d.scope
 
 	switch level {
 	case PermissionDeny:
+		if d.Rule != nil {
+			return false, nil
+		}
 		return false, fmt.Errorf("tool %q is blocked", tool)
 
 	case PermissionAllowAlways, PermissionAllowSession:
@@ -160,16 +225,7 @@ func (s *DefaultPermissionService) Request(ctx context.Context, tool, action, pa
 
 	case PermissionAllowOnce:
 		// Consume "allow once" on the scope that granted it.
-		switch {
-		case tool == "shell" && action != "" && cmdLevel == PermissionAllowOnce:
-			s.GrantCommand(action, PermissionAsk)
-		case path != "" && pathLevel == PermissionAllowOnce:
-			s.GrantPath(path, PermissionAsk)
-		case toolLevel == PermissionAllowOnce:
-			s.Grant(tool, PermissionAsk)
-		default:
-			s.Grant(tool, PermissionAsk)
-		}
+		s.grantScope(req, scope, PermissionAsk)
 		return true, nil
 
 	case PermissionAsk:
@@ -179,33 +235,35 @@ func (s *DefaultPermissionService) Request(ctx context.Context, tool, action, pa
 
 		// Interactive permission request
 		if s.ui != nil {
-			granted, remember, err := s.ui.RequestPermission(tool, action, path)
+			if tool == "shell" {
+				req.Danger = GetDangerousCommandInfo(normalizeCommandInput(action))
+			}
+			choice, err := s.ui.RequestPermission(ctx, req)
 			if err != nil {
 				return false, err
 			}
 
-			if granted && remember {
-				switch {
-				case tool == "shell" && action != "":
-					s.GrantCommand(action, PermissionAllowSession)
-				case path != "":
-					s.GrantPath(path, PermissionAllowSession)
-				default:
-					s.Grant(tool, PermissionAllowSession)
-				}
+			switch choice {
+			case PermissionAllowOnce:
+				return true, nil
+			case PermissionAllowSession:
+				s.grantScope(req, s.requestScope(req), PermissionAllowSession)
+				return true, nil
+			case PermissionAllowAlways:
+				scope := s.requestScope(req)
+				s.grantScope(req, scope, PermissionAllowAlways)
 				// 持久化决策
 				if s.store != nil {
-					_ = s.store.SaveDecision(PermissionDecision{
-						Tool:      tool,
-						Action:    action,
-						Path:      path,
-						Level:     PermissionAllowSession,
-						Timestamp: time.Now(),
-					})
+					for _, d := range scopeDecisions(req, scope, PermissionAllowAlways) {
+						if err := s.store.SaveDecision(d); err != nil {
+							return true, fmt.Errorf("save permission decision: %w", err)
+						}
+					}
 				}
+				return true, nil
+			default:
+				return false, nil
 			}
-
-			return granted, nil
 		}
 
 		// No UI, default to allow
@@ -215,6 +273,311 @@ func (s *DefaultPermissionService) Request(ctx context.Context, tool, action, pa
 	return false, fmt.Errorf("unknown permission level")
 }
 
+// permissionScope 权限生效的范围
+type permissionScope int
+
+const (
+	scopeTool permissionScope = iota
+	scopeCommand
+	scopeExactCommand
+	scopePath
+	scopeRule
+)
+
+// Decision explains how the permission level for a call was reached.
+type Decision struct {
+	Level     PermissionLevel
+	Rule      *Rule // the rule that decided, if any
+	RuleIndex int   // 1-based position of Rule in the rules file
+	Reason    string
+	Segment   string // the part of a compound shell command that decided
+
+	scope permissionScope
+}
+
+// Explain returns the level a tool call would get and what decided it,
+// without asking or consuming any grant.
+func (s *DefaultPermissionService) Explain(tool, action, path string) Decision {
+	return s.decide(PermissionRequest{Tool: tool, Action: action, Path: path})
+}
+
+// decide 决定请求的权限级别。由多个简单命令组成的 shell 命令逐段决定，
+// 取最严格的结果。
+func (s *DefaultPermissionService) decide(req PermissionRequest) Decision {
+	if req.Tool != "shell" {
+		return s.decideOne(req)
+	}
+	// 精确授权覆盖整条命令，不再逐段决定
+	if _, ok := s.exactPolicy(req.Action); ok {
+		return s.decideOne(req)
+	}
+	segments, err := SplitCommand(normalizeCommandInput(req.Action))
+	if err != nil || len(segments) < 2 {
+		return s.decideOne(req)
+	}
+
+	var strictest Decision
+	for i, seg := range segments {
+		sub := req
+		sub.Action = seg.Text
+		d := s.decideOne(sub)
+		if i == 0 || d.Level < strictest.Level {
+			strictest = d
+			strictest.Segment = seg.Text
+		}
+	}
+
+	// 管道传给 shell 等跨片段的风险，在没有规则时即使每个命令名都已授权也需要询问
+	if strictest.Rule == nil {
+		strictest = s.checkDanger(req, strictest)
+	}
+	return strictest
+}
+
+// checkDanger 危险命令的命令名或工具授权不足以放行，至少需要询问
+func (s *DefaultPermissionService) checkDanger(req PermissionRequest, d Decision) Decision {
+	if req.Tool != "shell" || d.scope == scopeExactCommand {
+		return d
+	}
+	info := GetDangerousCommandInfo(normalizeCommandInput(req.Action))
+	if info == nil {
+		return d
+	}
+	level := minPermission(d.Level, minPermission(s.default_, PermissionAsk))
+	if level >= d.Level {
+		return d
+	}
+	return Decision{
+		Level:   level,
+		Reason:  fmt.Sprintf("dangerous command (%s: %s)", info.Category, info.Description),
+		Segment: info.Segment,
+		scope:   d.scope,
+	}
+}
+
+// decideOne 先按规则决定，再回退到工具、命令和路径策略
+func (s *DefaultPermissionService) decideOne(req PermissionRequest) Decision {
+	rule, idx := s.Rules().Match(req)
+	if rule == nil {
+		return s.resolve(req)
+	}
+
+	d := Decision{Level: rule.LevelValue(), Rule: rule, RuleIndex: idx, scope: scopeRule}
+	d.Reason = fmt.Sprintf("rule %d (%s)", idx, rule.Describe())
+	if d.Level != PermissionAsk {
+		return d
+	}
+
+	// ask 规则下，提示框或已保存的授权仍然生效
+	if remembered, ok := s.remembered(req); ok && remembered.Level != PermissionAsk {
+		remembered.Rule, remembered.RuleIndex = rule, idx
+		remembered.Reason = fmt.Sprintf("%s under %s", remembered.Reason, d.Reason)
+		return remembered
+	}
+	return d
+}
+
+// remembered 返回命令、路径或工具上的显式授权
+func (s *DefaultPermissionService) remembered(req PermissionRequest) (Decision, bool) {
+	if req.Tool == "shell" && req.Action != "" {
+		if level, ok := s.exactPolicy(req.Action); ok {
+			return Decision{Level: level, scope: scopeExactCommand, Reason: "policy for this exact command"}, true
+		}
+		if level, ok := s.commandPolicy(req.Action); ok {
+			d := Decision{Level: level, scope: scopeCommand, Reason: "policy for " + req.Scope()}
+			return s.checkDanger(req, d), true
+		}
+	}
+	if req.Path != "" {
+		if level, ok := s.pathPolicy(req.Path); ok {
+			return Decision{Level: level, scope: scopePath, Reason: "policy for path " + req.Path}, true
+		}
+	}
+	s.mu.RLock()
+	level, ok := s.policies[req.Tool]
+	s.mu.RUnlock()
+	if ok {
+		return Decision{Level: level, scope: scopeTool, Reason: "policy for tool " + req.Tool}, true
+	}
+	return Decision{}, false
+}
+
+// resolve 计算请求的有效权限级别及其来源范围
+func (s *DefaultPermissionService) resolve(req PermissionRequest) Decision {
+	toolLevel := s.Check(req.Tool, req.Action)
+	toolReason := "default for tool " + req.Tool
+	s.mu.RLock()
+	if _, ok := s.policies[req.Tool]; ok {
+		toolReason = "policy for tool " + req.Tool
+	}
+	s.mu.RUnlock()
+	if toolLevel == PermissionDeny {
+		return Decision{Level: PermissionDeny, scope: scopeTool, Reason: toolReason}
+	}
+
+	var (
+		cmdLevel, pathLevel PermissionLevel
+		hasCmd, hasPath     bool
+	)
+	if req.Tool == "shell" && req.Action != "" {
+		if level, ok := s.exactPolicy(req.Action); ok {
+			return Decision{Level: level, scope: scopeExactCommand, Reason: "policy for this exact command"}
+		}
+		cmdLevel, hasCmd = s.commandPolicy(req.Action)
+	}
+	if req.Path != "" {
+		pathLevel, hasPath = s.pathPolicy(req.Path)
+	}
+
+	cmdReason := "policy for " + req.Scope()
+	pathReason := "policy for path " + req.Path
+	switch {
+	case hasCmd && cmdLevel == PermissionDeny:
+		return Decision{Level: PermissionDeny, scope: scopeCommand, Reason: cmdReason}
+	case hasPath && pathLevel == PermissionDeny:
+		return Decision{Level: PermissionDeny, scope: scopePath, Reason: pathReason}
+	case hasCmd:
+		return s.checkDanger(req, Decision{Level: cmdLevel, scope: scopeCommand, Reason: cmdReason})
+	case hasPath:
+		return Decision{Level: pathLevel, scope: scopePath, Reason: pathReason}
+	}
+
+	// 没有显式命令策略时，危险命令至少需要询问
+	if req.Tool == "shell" && req.Action != "" {
+		level := minPermission(toolLevel, s.CheckCommand(req.Action))
+		if info := GetDangerousCommandInfo(normalizeCommandInput(req.Action)); info != nil && level < toolLevel {
+			toolReason = fmt.Sprintf("dangerous command (%s: %s)", info.Category, info.Description)
+			return Decision{Level: level, scope: scopeTool, Reason: toolReason, Segment: info.Segment}
+		}
+		return Decision{Level: level, scope: scopeTool, Reason: toolReason}
+	}
+	return Decision{Level: toolLevel, scope: scopeTool, Reason: toolReason}
+}
+
+// requestScope 用户授权时所覆盖的范围；危险命令只授权其精确文本
+func (s *DefaultPermissionService) requestScope(req PermissionRequest) permissionScope {
+	switch {
+	case req.Tool == "shell" && req.Danger != nil:
+		return scopeExactCommand
+	case req.Tool == "shell" && len(commandNames(req.Action)) > 0:
+		return scopeCommand
+	case req.Path != "":
+		return scopePath
+	default:
+		return scopeTool
+	}
+}
+
+// grantScope 在指定范围内授权
+func (s *DefaultPermissionService) grantScope(req PermissionRequest, scope permissionScope, level PermissionLevel) {
+	switch scope {
+	case scopeCommand:
+		for _, name := range commandNames(req.Action) {
+			s.GrantCommand(name, level)
+		}
+	case scopeExactCommand:
+		s.GrantExactCommand(req.Action, level)
+	case scopePath:
+		s.GrantPath(req.Path, level)
+	default:
+		s.Grant(req.Tool, level)
+	}
+}
+
+// scopeDecisions 构造按范围持久化的决策；只记录命令名或路径，不保存工具参数。
+// 危险命令是例外，只保存其精确文本。
+func scopeDecisions(req PermissionRequest, scope permissionScope, level PermissionLevel) []PermissionDecision {
+	d := PermissionDecision{Tool: req.Tool, Level: level, Timestamp: time.Now()}
+	switch scope {
+	case scopeCommand:
+		var decisions []PermissionDecision
+		for _, name := range commandNames(req.Action) {
+			d.Action = name
+			decisions = append(decisions, d)
+		}
+		return decisions
+	case scopeExactCommand:
+		d.Action, d.Exact = normalizeCommandInput(req.Action), true
+	case scopePath:
+		d.Path = req.Path
+	}
+	return []PermissionDecision{d}
+}
+
+// commandPolicy 返回命令的显式策略。命令中的每个命令名（包括 sudo 等包装
+// 命令）都有策略时才生效，取其中最严格的；任何一个被拒绝则拒绝。
+func (s *DefaultPermissionService) commandPolicy(command string) (PermissionLevel, bool) {
+	names := commandNames(command)
+	if len(names) == 0 {
+		return 0, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	level := PermissionAllowAlways
+	complete := true
+	for _, name := range names {
+		l, ok := s.commandPolicies[name]
+		switch {
+		case ok && l == PermissionDeny:
+			return PermissionDeny, true
+		case ok:
+			level = minPermission(level, l)
+		default:
+			complete = false
+		}
+	}
+	return level, complete
+}
+
+// exactPolicy 返回整条命令的精确授权
+func (s *DefaultPermissionService) exactPolicy(command string) (PermissionLevel, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	level, ok := s.exactCommands[normalizeCommandInput(command)]
+	return level, ok
+}
+
+// commandNames 返回 shell 命令中执行的所有命令名，包括管道、列表、命令替换
+// 中的命令和 sudo 等包装命令。无法解析时退回到第一个词。
+func commandNames(command string) []string {
+	command = normalizeCommandInput(command)
+	segments, err := SplitCommand(command)
+	if err != nil {
+		if name := extractCommandName(command); name != "" {
+			return []string{name}
+		}
+		return nil
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, seg := range segments {
+		for _, name := range seg.Names() {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// pathPolicy 返回第一个匹配路径的显式策略
+func (s *DefaultPermissionService) pathPolicy(path string) (PermissionLevel, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, pp := range s.pathPatterns {
+		if matched, _ := filepath.Match(pp.Pattern, path); matched {
+			return pp.Level, true
+		}
+	}
+	return 0, false
+}
+
 // Check checks the permission level.
 func (s *DefaultPermissionService) Check(tool, action string) PermissionLevel {
 	s.mu.RLock()
@@ -244,16 +607,10 @@ func (s *DefaultPermissionService) Check(tool, action string) PermissionLevel {
 
 // CheckCommand checks permission for a specific command.
 func (s *DefaultPermissionService) CheckCommand(command string) PermissionLevel {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
 	command = normalizeCommandInput(command)
 
-	// 解析命令名
-	cmd := extractCommandName(command)
-
 	// 检查是否有该命令的特定策略
-	if level, ok := s.commandPolicies[cmd]; ok {
+	if level, ok := s.commandPolicy(command); ok {
 		return level
 	}
 
@@ -297,6 +654,14 @@ func (s *DefaultPermissionService) GrantCommand(command string, level Permission
 	s.commandPolicies[cmd] = level
 }
 
+// GrantExactCommand grants permission for one exact command text only.
+func (s *DefaultPermissionService) GrantExactCommand(command string, level PermissionLevel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.exactCommands[normalizeCommandInput(command)] = level
+}
+
 // GrantPath grants permission for a specific path pattern.
 func (s *DefaultPermissionService) GrantPath(pattern string, level PermissionLevel) {
 	s.mu.Lock()
@@ -334,6 +699,14 @@ func (s *DefaultPermissionService) RevokeCommand(command string) {
 	delete(s.commandPolicies, cmd)
 }
 
+// RevokeExactCommand revokes a grant made with GrantExactCommand.
+func (s *DefaultPermissionService) RevokeExactCommand(command string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.exactCommands, normalizeCommandInput(command))
+}
+
 // RevokePath revokes permission for a specific path pattern.
 func (s *DefaultPermissionService) RevokePath(pattern string) {
 	s.mu.Lock()
@@ -0,0 +1,381 @@
+package permission
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// CommandSegment 命令中的一个简单命令
+type CommandSegment struct {
+	Text      string   // 简单命令的源文本，可单独作为命令检查
+	Args      []string // 参数；非字面量的部分保留源文本
+	Redirects []string // 重定向，如 "> /dev/sda"
+	// Start 和 End 是该片段在原命令中的位置。来自 sh -c 或 eval 的片段
+	// 指向外层命令。
+	Start, End int
+}
+
+// Names 返回片段执行的命令名，包括 sudo、env 等包装命令
+func (s CommandSegment) Names() []string {
+	names, _ := unwrapCommand(s.Args)
+	return names
+}
+
+// shellPipe 管道中接收数据的一端
+type shellPipe struct {
+	from, to   CommandSegment
+	start, end int
+}
+
+// parsedCommand 解析后的 shell 命令
+type parsedCommand struct {
+	segments  []CommandSegment
+	pipes     []shellPipe
+	forkBombs []CommandSegment
+}
+
+// maxShellDepth 限制 sh -c、eval 的嵌套解析层数
+const maxShellDepth = 4
+
+// shellInterpreters 从标准输入或 -c 参数执行脚本的解释器
+var shellInterpreters = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "ash": true, "mksh": true,
+}
+
+// wrapperCommand 执行其参数中的命令的包装命令
+type wrapperCommand struct {
+	argOpts     map[string]bool // 带参数值的选项
+	positionals int             // 命令前的位置参数个数
+	assigns     bool            // 跳过 NAME=VALUE
+}
+
+var wrapperCommands = map[string]wrapperCommand{
+	"sudo":    {argOpts: optSet("-u", "-g", "-h", "-p", "-C", "-D", "-r", "-t", "-U", "-R", "--user", "--group", "--host", "--prompt", "--chdir")},
+	"doas":    {argOpts: optSet("-u", "-C")},
+	"env":     {argOpts: optSet("-u", "-C", "-S", "--unset", "--chdir", "--split-string"), assigns: true},
+	"nice":    {argOpts: optSet("-n", "--adjustment")},
+	"nohup":   {},
+	"time":    {argOpts: optSet("-f", "-o", "--format", "--output")},
+	"command": {},
+	"exec":    {argOpts: optSet("-a")},
+	"builtin": {},
+	"stdbuf":  {argOpts: optSet("-i", "-o", "-e")},
+	"timeout": {argOpts: optSet("-s", "-k", "--signal", "--kill-after"), positionals: 1},
+	"xargs":   {argOpts: optSet("-I", "-n", "-P", "-L", "-d", "-s", "-E", "-a", "--max-args", "--max-procs", "--delimiter", "--arg-file")},
+	"chroot":  {argOpts: optSet("--userspec", "--groups"), positionals: 1},
+	"ionice":  {argOpts: optSet("-c", "-n", "--class", "--classdata")},
+	"busybox": {},
+}
+
+func optSet(opts ...string) map[string]bool {
+	m := make(map[string]bool, len(opts))
+	for _, o := range opts {
+		m[o] = true
+	}
+	return m
+}
+
+// SplitCommand 用 shell 解析器拆分命令，返回管道、列表、子 shell、命令替换
+// 以及 sh -c 和 eval 中的每个简单命令
+func SplitCommand(command string) ([]CommandSegment, error) {
+	parsed, err := parseShell(command)
+	if err != nil {
+		return nil, err
+	}
+	return parsed.segments, nil
+}
+
+func parseShell(command string) (*parsedCommand, error) {
+	parsed := &parsedCommand{}
+	if err := parsed.parse(command, nil, 0); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// parse 解析 src；outer 不为空时 src 来自该片段的 sh -c 或 eval 参数
+func (pc *parsedCommand) parse(src string, outer *CommandSegment, depth int) error {
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(src), "")
+	if err != nil {
+		return fmt.Errorf("parse shell command: %w", err)
+	}
+
+	var walkErr error
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.Stmt:
+			seg, ok := newSegment(src, n, outer)
+			if !ok {
+				return true
+			}
+			pc.segments = append(pc.segments, seg)
+			if script, ok := nestedScript(seg.Args); ok && depth < maxShellDepth {
+				if err := pc.parse(script, &seg, depth+1); err != nil && walkErr == nil {
+					walkErr = err
+				}
+			}
+		case *syntax.BinaryCmd:
+			if n.Op == syntax.Pipe || n.Op == syntax.PipeAll {
+				pc.addPipe(src, n, outer)
+			}
+		case *syntax.FuncDecl:
+			if callsItselfConcurrently(n) {
+				pc.forkBombs = append(pc.forkBombs, spanSegment(src, n.Pos(), n.End(), outer))
+			}
+		}
+		return true
+	})
+	return walkErr
+}
+
+// newSegment 为简单命令或声明语句创建片段
+func newSegment(src string, stmt *syntax.Stmt, outer *CommandSegment) (CommandSegment, bool) {
+	var args []string
+	switch cmd := stmt.Cmd.(type) {
+	case *syntax.CallExpr:
+		for _, w := range cmd.Args {
+			args = append(args, wordString(src, w))
+		}
+	case *syntax.DeclClause:
+		args = append(args, cmd.Variant.Value)
+		for _, a := range cmd.Args {
+			args = append(args, assignString(src, a))
+		}
+	case nil:
+		if len(stmt.Redirs) == 0 {
+			return CommandSegment{}, false
+		}
+	default:
+		return CommandSegment{}, false
+	}
+
+	seg := spanSegment(src, stmt.Pos(), stmt.End(), outer)
+	seg.Args = args
+	for _, r := range stmt.Redirs {
+		if r.Word != nil {
+			seg.Redirects = append(seg.Redirects, r.Op.String()+" "+wordString(src, r.Word))
+		}
+	}
+	return seg, true
+}
+
+// spanSegment 返回 src 中 [start, end) 的片段
+func spanSegment(src string, start, end syntax.Pos, outer *CommandSegment) CommandSegment {
+	text := strings.TrimRight(src[start.Offset():end.Offset()], " \t\n;&|")
+	seg := CommandSegment{Text: text, Start: int(start.Offset()), End: int(start.Offset()) + len(text)}
+	if outer != nil {
+		seg.Start, seg.End = outer.Start, outer.End
+	}
+	return seg
+}
+
+// addPipe 记录管道中每一段到下一段的连接
+func (pc *parsedCommand) addPipe(src string, n *syntax.BinaryCmd, outer *CommandSegment) {
+	to, ok := newSegment(src, n.Y, outer)
+	if !ok {
+		return
+	}
+	from := spanSegment(src, n.X.Pos(), n.X.End(), outer)
+	syntax.Walk(n.X, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok {
+			for _, w := range call.Args {
+				from.Args = append(from.Args, wordString(src, w))
+			}
+		}
+		return true
+	})
+	span := spanSegment(src, n.Pos(), n.End(), outer)
+	pc.pipes = append(pc.pipes, shellPipe{from: from, to: to, start: span.Start, end: span.End})
+}
+
+// callsItselfConcurrently 函数体在管道或后台中调用自身，如 :(){ :|:& };:
+func callsItselfConcurrently(fn *syntax.FuncDecl) bool {
+	name := fn.Name.Value
+	found := false
+	syntax.Walk(fn.Body, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.BinaryCmd:
+			if (n.Op == syntax.Pipe || n.Op == syntax.PipeAll) && (callsName(n.X, name) || callsName(n.Y, name)) {
+				found = true
+			}
+		case *syntax.Stmt:
+			if n.Background && callsName(n, name) {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+func callsName(stmt *syntax.Stmt, name string) bool {
+	call, ok := stmt.Cmd.(*syntax.CallExpr)
+	return ok && len(call.Args) > 0 && call.Args[0].Lit() == name
+}
+
+// nestedScript 返回 sh -c、su -c 或 eval 要执行的脚本
+func nestedScript(args []string) (string, bool) {
+	_, rest := unwrapCommand(args)
+	if len(rest) == 0 {
+		return "", false
+	}
+	name := commandBase(rest[0])
+	if name == "eval" {
+		return strings.Join(rest[1:], " "), len(rest) > 1
+	}
+	if !shellInterpreters[name] && name != "su" {
+		return "", false
+	}
+	for i := 1; i < len(rest)-1; i++ {
+		arg := rest[i]
+		if arg == "-c" || (strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "c")) {
+			return rest[i+1], true
+		}
+	}
+	return "", false
+}
+
+// readsScriptFromStdin 解释器没有 -c 或脚本参数时从标准输入读取脚本
+func readsScriptFromStdin(args []string) bool {
+	_, rest := unwrapCommand(args)
+	if len(rest) == 0 || !shellInterpreters[commandBase(rest[0])] {
+		return false
+	}
+	for _, arg := range rest[1:] {
+		if arg == "-s" {
+			return true
+		}
+		if !strings.HasPrefix(arg, "-") || strings.Contains(strings.TrimLeft(arg, "-"), "c") {
+			return false
+		}
+	}
+	return true
+}
+
+// unwrapCommand 去掉 sudo、env 等包装命令，返回依次执行的命令名和最终命令
+func unwrapCommand(args []string) ([]string, []string) {
+	var names []string
+	for len(args) > 0 {
+		names = append(names, commandBase(args[0]))
+		inner, ok := peelWrapper(args)
+		if !ok {
+			break
+		}
+		args = inner
+	}
+	return names, args
+}
+
+// peelWrapper 去掉一层包装命令及其选项；args 不是包装命令时返回 false
+func peelWrapper(args []string) ([]string, bool) {
+	if len(args) == 0 {
+		return nil, false
+	}
+	w, ok := wrapperCommands[commandBase(args[0])]
+	if !ok {
+		return nil, false
+	}
+
+	args = args[1:]
+	positionals := w.positionals
+	for len(args) > 0 {
+		arg := args[0]
+		switch {
+		case arg == "--":
+			return args[1:], true
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			args = args[1:]
+			if w.argOpts[arg] && len(args) > 0 {
+				args = args[1:]
+			}
+		case w.assigns && isAssignment(arg):
+			args = args[1:]
+		case positionals > 0:
+			positionals--
+			args = args[1:]
+		default:
+			return args, true
+		}
+	}
+	return args, true
+}
+
+// commandBase 返回去掉路径和转义的命令名
+func commandBase(arg string) string {
+	return filepath.Base(strings.TrimPrefix(arg, `\`))
+}
+
+func isAssignment(arg string) bool {
+	i := strings.IndexByte(arg, '=')
+	if i <= 0 {
+		return false
+	}
+	for _, r := range arg[:i] {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// wordString 返回单词的值；引号内的字面量去掉引号，其余部分保留源文本
+func wordString(src string, w *syntax.Word) string {
+	var sb strings.Builder
+	for _, part := range w.Parts {
+		writeWordPart(&sb, src, part)
+	}
+	return sb.String()
+}
+
+func writeWordPart(sb *strings.Builder, src string, part syntax.WordPart) {
+	switch p := part.(type) {
+	case *syntax.Lit:
+		sb.WriteString(unescapeLit(p.Value))
+	case *syntax.SglQuoted:
+		sb.WriteString(p.Value)
+	case *syntax.DblQuoted:
+		for _, inner := range p.Parts {
+			writeWordPart(sb, src, inner)
+		}
+	default:
+		sb.WriteString(src[part.Pos().Offset():part.End().Offset()])
+	}
+}
+
+// unescapeLit 去掉未加引号字面量中的反斜杠转义
+func unescapeLit(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	escaped := false
+	for _, r := range s {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func assignString(src string, a *syntax.Assign) string {
+	switch {
+	case a.Name == nil && a.Value != nil:
+		return wordString(src, a.Value)
+	case a.Name == nil:
+		return ""
+	case a.Naked:
+		return a.Name.Value
+	case a.Value != nil:
+		return a.Name.Value + "=" + wordString(src, a.Value)
+	case a.Array != nil:
+		return a.Name.Value + "=" + src[a.Array.Pos().Offset():a.Array.End().Offset()]
+	default:
+		return a.Name.Value + "="
+	}
+}
@@ -126,6 +126,28 @@ func (s *FilePermissionStore) RemoveExpiredDecisions(maxAge time.Duration) error
 	return s.save()
 }
 
+// RemoveDecisions 移除满足条件的决策，返回被移除的决策
+func (s *FilePermissionStore) RemoveDecisions(match func(PermissionDecision) bool) ([]PermissionDecision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed []PermissionDecision
+	kept := make([]PermissionDecision, 0, len(s.decisions))
+	for _, d := range s.decisions {
+		if match(d) {
+			removed = append(removed, d)
+			continue
+		}
+		kept = append(kept, d)
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+
+	s.decisions = kept
+	return removed, s.save()
+}
+
 // save 保存到文件（必须持有锁）
 func (s *FilePermissionStore) save() error {
 	data, err := json.MarshalIndent(s.decisions, "", "  ")
@@ -64,4 +64,5 @@ type PermissionDecision struct {
 	Path      string
 	Level     PermissionLevel
 	Timestamp time.Time
+	Exact     bool `json:",omitempty"` // Action is a whole command, not a command name
 }
@@ -3,7 +3,7 @@ package mocks
 
 import (
 	"context"
-	"fmt"
+	"io"
 
 	"github.com/vigo999/ms-cli/integrations/llm"
 )
@@ -89,7 +89,7 @@ type mockStreamIterator struct {
 // Next returns the next chunk.
 func (m *mockStreamIterator) Next() (*llm.StreamChunk, error) {
 	if m.index >= len(m.chunks) {
-		return nil, fmt.Errorf("EOF")
+		return nil, io.EOF
 	}
 
 	chunk := m.chunks[m.index]
@@ -0,0 +1,122 @@
+package artifact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/vigo999/ms-cli/integrations/llm"
+	"github.com/vigo999/ms-cli/tools"
+)
+
+// ReadToolName is the name of the tool that pages through artifacts. Its
+// own output is never spilled.
+const ReadToolName = "artifact_read"
+
+const (
+	defaultReadLimit = 200
+	maxReadLimit     = 1000
+	// maxPageBytes keeps a single page from flooding the context again.
+	maxPageBytes = 12 * 1024
+)
+
+// ReadTool pages through a saved artifact.
+type ReadTool struct {
+	store *Store
+}
+
+// NewReadTool creates a new artifact_read tool.
+func NewReadTool(store *Store) *ReadTool {
+	return &ReadTool{store: store}
+}
+
+// Name returns the tool name.
+func (t *ReadTool) Name() string {
+	return ReadToolName
+}
+
+// Description returns the tool description.
+func (t *ReadTool) Description() string {
+	return "Read part of a large tool output that was saved as an artifact. Outputs that are too large for the conversation are replaced by a preview naming the artifact ID; use this tool to page through the full output by line."
+}
+
+// Schema returns the tool parameter schema.
+func (t *ReadTool) Schema() llm.ToolSchema {
+	return llm.ToolSchema{
+		Type: "object",
+		Properties: map[string]llm.Property{
+			"id": {
+				Type:        "string",
+				Description: "The artifact ID from the preview (e.g., 'art_1700000000000000000_1')",
+			},
+			"offset": {
+				Type:        "integer",
+				Description: "Line number to start reading from (1-based, default: 1)",
+			},
+			"limit": {
+				Type:        "integer",
+				Description: fmt.Sprintf("Maximum number of lines to read (default: %d, max: %d)", defaultReadLimit, maxReadLimit),
+			},
+		},
+		Required: []string{"id"},
+	}
+}
+
+type readParams struct {
+	ID     string `json:"id"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+}
+
+// Execute executes the artifact_read tool.
+func (t *ReadTool) Execute(ctx context.Context, params json.RawMessage) (*tools.Result, error) {
+	var p readParams
+	if err := tools.ParseParams(params, &p); err != nil {
+		return tools.ErrorResult(err), nil
+	}
+
+	id := strings.TrimSpace(p.ID)
+	if id == "" {
+		return tools.ErrorResultf("id is required"), nil
+	}
+	content, err := t.store.Load(id)
+	if err != nil {
+		return tools.ErrorResult(err), nil
+	}
+
+	lines := splitLines(content)
+	offset := p.Offset
+	if offset < 1 {
+		offset = 1
+	}
+	if offset > len(lines) {
+		return tools.ErrorResultf("offset %d is past the end of artifact %s (%d lines)", offset, id, len(lines)), nil
+	}
+	limit := p.Limit
+	if limit <= 0 {
+		limit = defaultReadLimit
+	}
+	if limit > maxReadLimit {
+		limit = maxReadLimit
+	}
+
+	var sb strings.Builder
+	end := offset - 1
+	for end < len(lines) && end-offset+1 < limit {
+		if end >= offset && sb.Len()+len(lines[end])+1 > maxPageBytes {
+			break
+		}
+		sb.WriteString(lines[end])
+		sb.WriteByte('\n')
+		end++
+	}
+
+	header := fmt.Sprintf("[artifact %s: lines %d-%d of %d]\n", id, offset, end, len(lines))
+	footer := ""
+	if end < len(lines) {
+		footer = fmt.Sprintf("[%d more lines; continue with offset %d]", len(lines)-end, end+1)
+	}
+	summary := fmt.Sprintf("lines %d-%d of %d", offset, end, len(lines))
+	return tools.StringResultWithSummary(header+sb.String()+footer, summary), nil
+}
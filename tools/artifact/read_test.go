@@ -0,0 +1,78 @@
+package artifact
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vigo999/ms-cli/tools"
+)
+
+func readArtifact(t *testing.T, tool *ReadTool, params map[string]any) *tools.Result {
+	t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := tool.Execute(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	return res
+}
+
+func TestReadToolPaging(t *testing.T) {
+	store := NewStore(Config{Dir: t.TempDir()})
+	a, err := store.Save("shell", numberedLines(10))
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	tool := NewReadTool(store)
+
+	res := readArtifact(t, tool, map[string]any{"id": a.ID, "offset": 3, "limit": 4})
+	want := "[artifact " + a.ID + ": lines 3-6 of 10]\nline 3\nline 4\nline 5\nline 6\n[4 more lines; continue with offset 7]"
+	if res.Error != nil || res.Content != want {
+		t.Errorf("page = %q (%v), want %q", res.Content, res.Error, want)
+	}
+	if res.Summary != "lines 3-6 of 10" {
+		t.Errorf("summary = %q", res.Summary)
+	}
+
+	// The last page runs to the end without a continuation hint.
+	res = readArtifact(t, tool, map[string]any{"id": a.ID, "offset": 7, "limit": 100})
+	if res.Error != nil || !strings.HasSuffix(res.Content, "line 10\n") || strings.Contains(res.Content, "more lines") {
+		t.Errorf("last page = %q (%v)", res.Content, res.Error)
+	}
+
+	// Without an offset, reading starts at line 1.
+	res = readArtifact(t, tool, map[string]any{"id": a.ID})
+	if res.Error != nil || !strings.HasPrefix(res.Content, "[artifact "+a.ID+": lines 1-10 of 10]\nline 1\n") {
+		t.Errorf("default page = %q (%v)", res.Content, res.Error)
+	}
+}
+
+func TestReadToolErrors(t *testing.T) {
+	store := NewStore(Config{Dir: t.TempDir()})
+	a, err := store.Save("shell", numberedLines(10))
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	tool := NewReadTool(store)
+
+	cases := []struct {
+		params map[string]any
+		want   string
+	}{
+		{map[string]any{"id": a.ID, "offset": 11}, "offset 11 is past the end"},
+		{map[string]any{"id": "art_0_0"}, "not found"},
+		{map[string]any{"id": "../" + a.ID}, "invalid artifact id"},
+		{map[string]any{"id": " "}, "id is required"},
+	}
+	for _, c := range cases {
+		res := readArtifact(t, tool, c.params)
+		if res.Error == nil || !strings.Contains(res.Error.Error(), c.want) {
+			t.Errorf("%v: error = %v, want %q", c.params, res.Error, c.want)
+		}
+	}
+}
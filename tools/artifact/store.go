@@ -0,0 +1,219 @@
+// Package artifact keeps oversized tool outputs on disk so only a preview
+// enters the conversation context.
+package artifact
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultThreshold  = 16 * 1024
+	defaultHeadBytes  = 2 * 1024
+	defaultTailBytes  = 1024
+	defaultHeadLines  = 40
+	defaultTailLines  = 20
+	defaultSessionDir = "default"
+
+	// maxLineBytes wraps longer lines so every line can be previewed and
+	// paged through, even in minified or binary-like output.
+	maxLineBytes = 1024
+)
+
+// validID matches artifact IDs and session directory names.
+var validID = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Config holds the artifact store configuration.
+type Config struct {
+	Dir       string // Root directory; each session gets a subdirectory
+	Threshold int    // Outputs larger than this many bytes are spilled
+	HeadBytes int    // Preview size taken from the start of the output
+	TailBytes int    // Preview size taken from the end of the output
+}
+
+// Store saves tool outputs as artifacts, one directory per session.
+type Store struct {
+	config  Config
+	seq     atomic.Int64
+	mu      sync.RWMutex
+	session func() string
+}
+
+// Artifact describes a saved output.
+type Artifact struct {
+	ID    string
+	Tool  string
+	Bytes int
+	Lines int
+}
+
+// NewStore creates an artifact store.
+func NewStore(cfg Config) *Store {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaultThreshold
+	}
+	if cfg.HeadBytes <= 0 {
+		cfg.HeadBytes = defaultHeadBytes
+	}
+	if cfg.TailBytes <= 0 {
+		cfg.TailBytes = defaultTailBytes
+	}
+	return &Store{config: cfg}
+}
+
+// SetSessionFunc sets how the current session is found; artifacts are saved
+// under its ID. Without one, or before a session starts, they go to "default".
+func (s *Store) SetSessionFunc(fn func() string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.session = fn
+}
+
+// Threshold returns the size above which outputs are spilled.
+func (s *Store) Threshold() int {
+	return s.config.Threshold
+}
+
+// Spill saves content that is larger than the threshold and returns the
+// preview that replaces it in the context. Smaller content is returned
+// unchanged with a nil artifact.
+func (s *Store) Spill(tool, content string) (string, *Artifact, error) {
+	if len(content) <= s.config.Threshold {
+		return content, nil, nil
+	}
+	a, err := s.Save(tool, content)
+	if err != nil {
+		return content, nil, err
+	}
+	return s.preview(a, content), a, nil
+}
+
+// Save writes content as a new artifact.
+func (s *Store) Save(tool, content string) (*Artifact, error) {
+	dir := s.sessionDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create artifact dir: %w", err)
+	}
+
+	a := &Artifact{
+		ID:    fmt.Sprintf("art_%d_%d", time.Now().UnixNano(), s.seq.Add(1)),
+		Tool:  tool,
+		Bytes: len(content),
+		Lines: len(splitLines(content)),
+	}
+	path := filepath.Join(dir, a.ID+".txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return nil, fmt.Errorf("write artifact: %w", err)
+	}
+	return a, nil
+}
+
+// Load returns the full content of an artifact. The current session is
+// searched first, then those of earlier sessions.
+func (s *Store) Load(id string) (string, error) {
+	if !validID.MatchString(id) {
+		return "", fmt.Errorf("invalid artifact id %q", id)
+	}
+
+	candidates := []string{filepath.Join(s.sessionDir(), id+".txt")}
+	if matches, err := filepath.Glob(filepath.Join(s.config.Dir, "*", id+".txt")); err == nil {
+		candidates = append(candidates, matches...)
+	}
+	for _, path := range candidates {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("read artifact: %w", err)
+		}
+	}
+	return "", fmt.Errorf("artifact %s not found", id)
+}
+
+func (s *Store) sessionDir() string {
+	s.mu.RLock()
+	fn := s.session
+	s.mu.RUnlock()
+
+	name := ""
+	if fn != nil {
+		name = fn()
+	}
+	if name == "" || !validID.MatchString(name) {
+		name = defaultSessionDir
+	}
+	return filepath.Join(s.config.Dir, name)
+}
+
+// preview keeps the first and last lines of content around a pointer to
+// the artifact.
+func (s *Store) preview(a *Artifact, content string) string {
+	lines := splitLines(content)
+	head := takeLines(lines, s.config.HeadBytes, defaultHeadLines, false)
+	tail := takeLines(lines[len(head):], s.config.TailBytes, defaultTailLines, true)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s output is %d bytes (%d lines); saved as artifact %s]\n", a.Tool, a.Bytes, a.Lines, a.ID)
+	sb.WriteString(strings.Join(head, "\n"))
+	omitted := len(lines) - len(head) - len(tail)
+	fmt.Fprintf(&sb, "\n... [%d lines omitted; call %s with id %q and offset %d to read them] ...\n",
+		omitted, ReadToolName, a.ID, len(head)+1)
+	sb.WriteString(strings.Join(tail, "\n"))
+	return sb.String()
+}
+
+// takeLines takes lines from the start, or the end when fromEnd is set,
+// within the byte and line limits. At least one line is taken.
+func takeLines(lines []string, maxBytes, maxLines int, fromEnd bool) []string {
+	var taken []string
+	size := 0
+	for i := 0; i < len(lines) && len(taken) < maxLines; i++ {
+		line := lines[i]
+		if fromEnd {
+			line = lines[len(lines)-1-i]
+		}
+		if len(taken) > 0 && size+len(line)+1 > maxBytes {
+			break
+		}
+		taken = append(taken, line)
+		size += len(line) + 1
+	}
+	if fromEnd {
+		for i, j := 0, len(taken)-1; i < j; i, j = i+1, j-1 {
+			taken[i], taken[j] = taken[j], taken[i]
+		}
+	}
+	return taken
+}
+
+// splitLines splits content into lines, wrapping lines longer than
+// maxLineBytes without splitting a UTF-8 sequence.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+		for len(line) > maxLineBytes {
+			n := maxLineBytes
+			for n > 0 && !utf8.RuneStart(line[n]) {
+				n--
+			}
+			if n == 0 {
+				n = maxLineBytes
+			}
+			lines = append(lines, line[:n])
+			line = line[n:]
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,90 @@
+package artifact
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns n lines "line 1" to "line n".
+func numberedLines(n int) string {
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	return sb.String()
+}
+
+func TestSpillThreshold(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(Config{Dir: dir, Threshold: 100, HeadBytes: 30, TailBytes: 20})
+	store.SetSessionFunc(func() string { return "s1" })
+
+	atLimit := strings.Repeat("x", 100)
+	got, a, err := store.Spill("shell", atLimit)
+	if err != nil || a != nil || got != atLimit {
+		t.Fatalf("output at the threshold should pass through, got %v %v %q", a, err, got)
+	}
+
+	content := numberedLines(30) // 231 bytes
+	preview, a, err := store.Spill("shell", content)
+	if err != nil || a == nil {
+		t.Fatalf("Spill failed: %v", err)
+	}
+	if a.Tool != "shell" || a.Bytes != len(content) || a.Lines != 30 {
+		t.Errorf("unexpected artifact %+v", a)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "s1", a.ID+".txt")); err != nil {
+		t.Errorf("artifact not saved in the session dir: %v", err)
+	}
+
+	if !strings.HasPrefix(preview, fmt.Sprintf("[shell output is %d bytes (30 lines); saved as artifact %s]\nline 1\n", len(content), a.ID)) {
+		t.Errorf("unexpected preview head:\n%s", preview)
+	}
+	if !strings.HasSuffix(preview, "] ...\nline 29\nline 30") {
+		t.Errorf("unexpected preview tail:\n%s", preview)
+	}
+	// 30 head bytes hold "line 1" to "line 4"; 20 tail bytes hold two lines.
+	if !strings.Contains(preview, fmt.Sprintf("[24 lines omitted; call %s with id %q and offset 5 to read them]", ReadToolName, a.ID)) {
+		t.Errorf("preview should point at the omitted lines:\n%s", preview)
+	}
+
+	full, err := store.Load(a.ID)
+	if err != nil || full != content {
+		t.Errorf("Load = %q, %v; want the full output", full, err)
+	}
+}
+
+func TestLoadSearchesEarlierSessions(t *testing.T) {
+	session := "s1"
+	store := NewStore(Config{Dir: t.TempDir()})
+	store.SetSessionFunc(func() string { return session })
+
+	a, err := store.Save("grep", "old output")
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	session = "s2"
+	if got, err := store.Load(a.ID); err != nil || got != "old output" {
+		t.Errorf("Load = %q, %v; want the earlier session's artifact", got, err)
+	}
+}
+
+func TestLoadRejectsBadIDs(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(Config{Dir: filepath.Join(dir, "artifacts")})
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"../secret", "../../secret", "default/../../secret", "/etc/passwd", "*", "a b", ""} {
+		if _, err := store.Load(id); err == nil || !strings.Contains(err.Error(), "invalid artifact id") {
+			t.Errorf("Load(%q) error = %v, want an invalid id error", id, err)
+		}
+	}
+	if _, err := store.Load("art_1_1"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Load of an unknown id = %v, want not found", err)
+	}
+}
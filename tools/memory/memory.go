@@ -0,0 +1,49 @@
+// Package memory provides tools that let the agent save and look up
+// long-term memories.
+package memory
+
+import (
+	"fmt"
+	"strings"
+
+	agentmemory "github.com/vigo999/ms-cli/agent/memory"
+)
+
+// rememberTypes are the memory types the model may save.
+var rememberTypes = []string{
+	string(agentmemory.MemoryTypeFact),
+	string(agentmemory.MemoryTypePreference),
+	string(agentmemory.MemoryTypeDecision),
+	string(agentmemory.MemoryTypeCode),
+}
+
+// recallTypes are the memory types the model may filter by.
+var recallTypes = append(append([]string(nil), rememberTypes...), string(agentmemory.MemoryTypeTask))
+
+// defaultImportance is used when remember is called without an importance.
+var defaultImportance = map[agentmemory.MemoryType]int{
+	agentmemory.MemoryTypeFact:       7,
+	agentmemory.MemoryTypePreference: 8,
+	agentmemory.MemoryTypeDecision:   7,
+	agentmemory.MemoryTypeCode:       6,
+}
+
+// parseType validates a memory type name against the allowed ones.
+func parseType(name string, allowed []string) (agentmemory.MemoryType, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, t := range allowed {
+		if name == t {
+			return agentmemory.MemoryType(name), nil
+		}
+	}
+	return "", fmt.Errorf("invalid type %q (expected one of: %s)", name, strings.Join(allowed, ", "))
+}
+
+// formatItem renders a memory for the model.
+func formatItem(item *agentmemory.MemoryItem) string {
+	line := fmt.Sprintf("[%s] %s (id: %s, importance: %d", item.Type, item.Content, item.ID, item.Importance)
+	if len(item.Tags) > 0 {
+		line += ", tags: " + strings.Join(item.Tags, ", ")
+	}
+	return line + ")"
+}
@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	agentmemory "github.com/vigo999/ms-cli/agent/memory"
+	"github.com/vigo999/ms-cli/tools"
+)
+
+func newTestManager(t *testing.T) *agentmemory.Manager {
+	t.Helper()
+	store, err := agentmemory.NewSQLiteStore(filepath.Join(t.TempDir(), "memory.db"), agentmemory.DefaultConfig())
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return agentmemory.NewManager(store, agentmemory.DefaultConfig())
+}
+
+// execute runs tool with params marshalled to JSON.
+func execute(t *testing.T, tool tools.Tool, params map[string]any) *tools.Result {
+	t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := tool.Execute(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	return res
+}
+
+func TestRememberSavesMemory(t *testing.T) {
+	mgr := newTestManager(t)
+	remember := NewRememberTool(mgr)
+
+	res := execute(t, remember, map[string]any{
+		"content": "Use tabs in Makefiles",
+		"type":    "Preference",
+		"tags":    []string{"make", " ", "style"},
+	})
+	if res.Error != nil {
+		t.Fatalf("remember failed: %v", res.Error)
+	}
+	if res.Summary != "saved preference" || !strings.HasPrefix(res.Content, "Remembered preference ") {
+		t.Errorf("result = %q (%q)", res.Content, res.Summary)
+	}
+
+	items, err := mgr.RetrieveByType(agentmemory.MemoryTypePreference, 10)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("expected one preference, got %d (%v)", len(items), err)
+	}
+	item := items[0]
+	if item.Content != "Use tabs in Makefiles" || item.Importance != 8 {
+		t.Errorf("saved %q with importance %d", item.Content, item.Importance)
+	}
+	if strings.Join(item.Tags, ",") != "make,style" {
+		t.Errorf("tags = %v, want [make style]", item.Tags)
+	}
+}
+
+func TestRememberSkipsDuplicate(t *testing.T) {
+	mgr := newTestManager(t)
+	remember := NewRememberTool(mgr)
+
+	first := execute(t, remember, map[string]any{"content": "Tests run with go test ./..."})
+	if first.Error != nil {
+		t.Fatalf("remember failed: %v", first.Error)
+	}
+	second := execute(t, remember, map[string]any{"content": "tests run with go test ./..."})
+	if second.Error != nil || second.Summary != "already known" {
+		t.Errorf("duplicate result = %q (%q, %v)", second.Content, second.Summary, second.Error)
+	}
+	if items, _ := mgr.List(10); len(items) != 1 {
+		t.Errorf("expected one memory, got %d", len(items))
+	}
+}
+
+func TestRememberValidatesParams(t *testing.T) {
+	remember := NewRememberTool(newTestManager(t))
+
+	tests := []struct {
+		name   string
+		params map[string]any
+		want   string
+	}{
+		{"empty content", map[string]any{"content": "  "}, "content is required"},
+		{"task type", map[string]any{"content": "x", "type": "task"}, "invalid type"},
+		{"importance too high", map[string]any{"content": "x", "importance": 11}, "between 1 and 10"},
+		{"wrong param type", map[string]any{"content": 42}, "parse params"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := execute(t, remember, tt.params)
+			if res.Error == nil || !strings.Contains(res.Error.Error(), tt.want) {
+				t.Errorf("error = %v, want one containing %q", res.Error, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecallFindsMemories(t *testing.T) {
+	mgr := newTestManager(t)
+	if err := mgr.SaveFact("Releases are built with make release", []string{"build"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.SavePreference("editor", "The user prefers short commit messages"); err != nil {
+		t.Fatal(err)
+	}
+	recall := NewRecallTool(mgr)
+
+	res := execute(t, recall, map[string]any{"query": "release"})
+	if res.Error != nil {
+		t.Fatalf("recall failed: %v", res.Error)
+	}
+	if res.Summary != "1 memories" || !strings.Contains(res.Content, "[fact] Releases are built with make release") ||
+		!strings.Contains(res.Content, "tags: build") {
+		t.Errorf("result = %q (%q)", res.Content, res.Summary)
+	}
+
+	// An empty query returns the most important memories of the type.
+	res = execute(t, recall, map[string]any{"type": "preference"})
+	if res.Error != nil || !strings.Contains(res.Content, "short commit messages") || strings.Contains(res.Content, "[fact]") {
+		t.Errorf("result = %q (%v)", res.Content, res.Error)
+	}
+
+	res = execute(t, recall, map[string]any{"query": "kubernetes"})
+	if res.Error != nil || res.Content != "No matching memories." {
+		t.Errorf("result = %q (%v)", res.Content, res.Error)
+	}
+}
+
+func TestRecallLimit(t *testing.T) {
+	mgr := newTestManager(t)
+	for _, fact := range []string{"Deploy step one", "Deploy step two", "Deploy step three"} {
+		if err := mgr.SaveFact(fact, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recall := NewRecallTool(mgr)
+
+	res := execute(t, recall, map[string]any{"query": "deploy", "limit": 2})
+	if res.Error != nil || res.Summary != "2 memories" {
+		t.Errorf("result = %q (%q, %v)", res.Content, res.Summary, res.Error)
+	}
+}
+
+func TestRecallRejectsInvalidType(t *testing.T) {
+	res := execute(t, NewRecallTool(newTestManager(t)), map[string]any{"type": "session"})
+	if res.Error == nil || !strings.Contains(res.Error.Error(), "invalid type") {
+		t.Errorf("error = %v, want invalid type", res.Error)
+	}
+}
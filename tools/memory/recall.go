@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	agentmemory "github.com/vigo999/ms-cli/agent/memory"
+	"github.com/vigo999/ms-cli/integrations/llm"
+	"github.com/vigo999/ms-cli/tools"
+)
+
+const (
+	defaultRecallLimit = 5
+	maxRecallLimit     = 20
+)
+
+// RecallTool looks up memories saved in this or earlier sessions.
+type RecallTool struct {
+	manager *agentmemory.Manager
+}
+
+// NewRecallTool creates a new recall tool.
+func NewRecallTool(manager *agentmemory.Manager) *RecallTool {
+	return &RecallTool{manager: manager}
+}
+
+// Name returns the tool name.
+func (t *RecallTool) Name() string {
+	return "recall"
+}
+
+// Description returns the tool description.
+func (t *RecallTool) Description() string {
+	return "Look up memories saved in earlier sessions of this project. Returns the most relevant memories for the query, optionally filtered by type. With an empty query, returns the most important memories."
+}
+
+// Schema returns the tool parameter schema.
+func (t *RecallTool) Schema() llm.ToolSchema {
+	return llm.ToolSchema{
+		Type: "object",
+		Properties: map[string]llm.Property{
+			"query": {
+				Type:        "string",
+				Description: "Words describing what to look up (e.g., 'release build command')",
+			},
+			"type": {
+				Type:        "string",
+				Description: "Only return memories of this kind",
+				Enum:        recallTypes,
+			},
+			"limit": {
+				Type:        "integer",
+				Description: fmt.Sprintf("Maximum number of memories to return (default: %d, max: %d)", defaultRecallLimit, maxRecallLimit),
+			},
+		},
+	}
+}
+
+type recallParams struct {
+	Query string `json:"query"`
+	Type  string `json:"type"`
+	Limit int    `json:"limit"`
+}
+
+// Execute executes the recall tool.
+func (t *RecallTool) Execute(ctx context.Context, params json.RawMessage) (*tools.Result, error) {
+	var p recallParams
+	if err := tools.ParseParams(params, &p); err != nil {
+		return tools.ErrorResult(err), nil
+	}
+
+	var types []agentmemory.MemoryType
+	if p.Type != "" {
+		memType, err := parseType(p.Type, recallTypes)
+		if err != nil {
+			return tools.ErrorResult(err), nil
+		}
+		types = []agentmemory.MemoryType{memType}
+	}
+	if p.Limit <= 0 {
+		p.Limit = defaultRecallLimit
+	}
+	if p.Limit > maxRecallLimit {
+		p.Limit = maxRecallLimit
+	}
+
+	items, err := t.manager.Recall(p.Query, types, p.Limit)
+	if err != nil {
+		return tools.ErrorResultf("recall memories: %w", err), nil
+	}
+	if len(items) == 0 {
+		return tools.StringResultWithSummary("No matching memories.", "0 memories"), nil
+	}
+
+	lines := make([]string, len(items))
+	for i, item := range items {
+		lines[i] = formatItem(item)
+	}
+	return tools.StringResultWithSummary(strings.Join(lines, "\n"), fmt.Sprintf("%d memories", len(items))), nil
+}
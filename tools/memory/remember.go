@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	agentmemory "github.com/vigo999/ms-cli/agent/memory"
+	"github.com/vigo999/ms-cli/integrations/llm"
+	"github.com/vigo999/ms-cli/tools"
+)
+
+// RememberTool saves a memory that later sessions can recall.
+type RememberTool struct {
+	manager *agentmemory.Manager
+}
+
+// NewRememberTool creates a new remember tool.
+func NewRememberTool(manager *agentmemory.Manager) *RememberTool {
+	return &RememberTool{manager: manager}
+}
+
+// Name returns the tool name.
+func (t *RememberTool) Name() string {
+	return "remember"
+}
+
+// Description returns the tool description.
+func (t *RememberTool) Description() string {
+	return "Save something worth knowing in future sessions of this project, such as a fact about the codebase, a user preference, a decision and its reason, or a useful code snippet. Keep each memory to one self-contained statement."
+}
+
+// Schema returns the tool parameter schema.
+func (t *RememberTool) Schema() llm.ToolSchema {
+	return llm.ToolSchema{
+		Type: "object",
+		Properties: map[string]llm.Property{
+			"content": {
+				Type:        "string",
+				Description: "What to remember, as a self-contained statement",
+			},
+			"type": {
+				Type:        "string",
+				Description: "Kind of memory (default: fact)",
+				Enum:        rememberTypes,
+			},
+			"tags": {
+				Type:        "array",
+				Description: "Short tags to find the memory by (e.g., 'build', 'testing')",
+				Items:       &llm.Property{Type: "string"},
+			},
+			"importance": {
+				Type:        "integer",
+				Description: "How important the memory is, from 1 to 10 (default depends on type)",
+			},
+		},
+		Required: []string{"content"},
+	}
+}
+
+type rememberParams struct {
+	Content    string   `json:"content"`
+	Type       string   `json:"type"`
+	Tags       []string `json:"tags"`
+	Importance int      `json:"importance"`
+}
+
+// Execute executes the remember tool.
+func (t *RememberTool) Execute(ctx context.Context, params json.RawMessage) (*tools.Result, error) {
+	var p rememberParams
+	if err := tools.ParseParams(params, &p); err != nil {
+		return tools.ErrorResult(err), nil
+	}
+
+	content := strings.TrimSpace(p.Content)
+	if content == "" {
+		return tools.ErrorResultf("content is required"), nil
+	}
+	if p.Type == "" {
+		p.Type = string(agentmemory.MemoryTypeFact)
+	}
+	memType, err := parseType(p.Type, rememberTypes)
+	if err != nil {
+		return tools.ErrorResult(err), nil
+	}
+	if p.Importance == 0 {
+		p.Importance = defaultImportance[memType]
+	}
+	if p.Importance < 1 || p.Importance > 10 {
+		return tools.ErrorResultf("importance must be between 1 and 10, got %d", p.Importance), nil
+	}
+
+	item := agentmemory.NewMemoryItem(memType, content)
+	item.Importance = p.Importance
+	for _, tag := range p.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			item.AddTag(tag)
+		}
+	}
+	existing, err := t.manager.SaveIfNew(item)
+	if err != nil {
+		return tools.ErrorResultf("save memory: %w", err), nil
+	}
+	if existing != nil {
+		return tools.StringResultWithSummary(
+			fmt.Sprintf("Already remembered as %s.", existing.ID), "already known"), nil
+	}
+
+	return tools.StringResultWithSummary(
+		fmt.Sprintf("Remembered %s %s.", memType, item.ID), fmt.Sprintf("saved %s", memType)), nil
+}
@@ -0,0 +1,38 @@
+package shell
+
+import (
+	"context"
+	"os"
+	"os/exec"
+)
+
+// Backend starts the processes that run shell commands. The Runner owns
+// output capture, timeouts and the allow/block lists; a backend only decides
+// where the command runs: on the host, in a sandbox or in a container.
+type Backend interface {
+	// Name identifies the backend in messages, e.g. "local" or "sandbox".
+	Name() string
+
+	// Command prepares command to run in dir. env holds the runner's extra
+	// KEY=VALUE variables; the backend adds them to its own base environment.
+	// The returned cleanup, if not nil, is called after the command has
+	// exited.
+	Command(ctx context.Context, command, dir string, env []string) (*exec.Cmd, func(), error)
+}
+
+// LocalBackend runs commands with sh -c directly on the host, in the
+// environment of this process.
+type LocalBackend struct{}
+
+// Name returns the backend name.
+func (LocalBackend) Name() string {
+	return "local"
+}
+
+// Command returns sh -c command.
+func (LocalBackend) Command(ctx context.Context, command, dir string, env []string) (*exec.Cmd, func(), error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	return cmd, nil, nil
+}
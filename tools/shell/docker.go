@@ -0,0 +1,419 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ContainerSpec describes the long-lived container a DockerBackend runs
+// commands in.
+type ContainerSpec struct {
+	Name    string
+	Image   string
+	WorkDir string // bind-mounted read-write at the same path
+	User    string // uid:gid, so files in the work dir keep their owner
+	Env     map[string]string
+	CPU     string // --cpus
+	Memory  string // --memory
+	Network string // --network; empty for the runtime's default
+	Labels  map[string]string
+}
+
+// Container is a container found by ContainerRuntime.List.
+type Container struct {
+	ID     string
+	Labels map[string]string
+}
+
+// ContainerRuntime is the container engine a DockerBackend drives.
+// CLIRuntime uses the docker or podman command line.
+type ContainerRuntime interface {
+	// Run starts a detached container that stays up until removed and
+	// returns its ID.
+	Run(ctx context.Context, spec ContainerSpec) (string, error)
+
+	// Exec prepares argv to run in the container in dir, with the extra
+	// KEY=VALUE variables env. With interactive set, the process reads the
+	// command's stdin.
+	Exec(ctx context.Context, id string, argv []string, dir string, env []string, interactive bool) *exec.Cmd
+
+	// Remove stops and deletes the container.
+	Remove(ctx context.Context, id string) error
+
+	// List returns the containers, running or stopped, that have all the
+	// labels.
+	List(ctx context.Context, labels map[string]string) ([]Container, error)
+}
+
+// DockerConfig configures the container backend.
+type DockerConfig struct {
+	Runtime ContainerRuntime // default: CLIRuntime{Binary: "docker"}
+	Image   string
+	WorkDir string
+	Env     map[string]string
+	CPU     string
+	Memory  string
+	Network string
+}
+
+// containerRemoveTimeout bounds removing a container on exit or when the
+// session changes.
+const containerRemoveTimeout = 30 * time.Second
+
+// DockerBackend runs each command with docker exec in a container that is
+// started on first use and kept for the session. A new session gets a new
+// container; Close removes the current one.
+type DockerBackend struct {
+	cfg       DockerConfig
+	runtime   ContainerRuntime
+	sessionFn func() string
+	seq       atomic.Int64
+
+	mu        sync.Mutex
+	container string // ID of the running container, "" before the first command
+	session   string // session the container belongs to
+}
+
+// NewDockerBackend creates a container backend. No container is started
+// until the first command.
+func NewDockerBackend(cfg DockerConfig) (*DockerBackend, error) {
+	if strings.TrimSpace(cfg.Image) == "" {
+		return nil, errors.New("docker image is required")
+	}
+	dir, err := filepath.Abs(cfg.WorkDir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve work dir: %w", err)
+	}
+	cfg.WorkDir = dir
+	if cfg.Runtime == nil {
+		cfg.Runtime = CLIRuntime{Binary: "docker"}
+	}
+	return &DockerBackend{cfg: cfg, runtime: cfg.Runtime}, nil
+}
+
+// SetSessionFunc sets the function that returns the current session ID.
+// When it changes, the next command starts a new container.
+func (b *DockerBackend) SetSessionFunc(fn func() string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sessionFn = fn
+}
+
+// Name returns the backend name.
+func (b *DockerBackend) Name() string {
+	return "docker"
+}
+
+// Command returns docker exec for command, starting the session's
+// container first if needed.
+func (b *DockerBackend) Command(ctx context.Context, command, dir string, env []string) (*exec.Cmd, func(), error) {
+	return b.command(ctx, command, dir, env, false)
+}
+
+// SessionCommand is Command with stdin attached, for the persistent shell
+// that reads its commands from stdin.
+func (b *DockerBackend) SessionCommand(ctx context.Context, command, dir string, env []string) (*exec.Cmd, func(), error) {
+	return b.command(ctx, command, dir, env, true)
+}
+
+func (b *DockerBackend) command(ctx context.Context, command, dir string, env []string, interactive bool) (*exec.Cmd, func(), error) {
+	id, err := b.ensureContainer(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dir == "" {
+		dir = b.cfg.WorkDir
+	}
+
+	// docker exec has no way to stop the process when the client is
+	// killed, so the command records its PID and Cancel kills its process
+	// tree inside the container. The files are removed with the container.
+	pidFile := fmt.Sprintf("/tmp/.mscli-exec-%d.pid", b.seq.Add(1))
+	argv := []string{"sh", "-c", `echo $$ > "$0"; exec sh -c "$1"`, pidFile, command}
+	cmd := b.runtime.Exec(ctx, id, argv, dir, env, interactive)
+	cmd.Cancel = func() error {
+		kill := b.runtime.Exec(context.Background(), id, []string{"sh", "-c", killTreeScript, pidFile}, "/", nil, false)
+		_ = kill.Run()
+		return cmd.Process.Kill()
+	}
+	return cmd, nil, nil
+}
+
+// killTreeScript kills the process whose PID is in the file $0 and all its
+// descendants, found by their parent PID in /proc. Each one is stopped
+// first so it cannot start new children.
+const killTreeScript = `kill_tree() {
+	kill -STOP "$1" 2>/dev/null || return
+	for stat in /proc/[0-9]*/stat; do
+		line=$(cat "$stat" 2>/dev/null) || continue
+		ppid=${line##*) }; ppid=${ppid#* }; ppid=${ppid%% *}
+		if [ "$ppid" = "$1" ]; then
+			pid=${stat#/proc/}
+			kill_tree "${pid%/stat}"
+		fi
+	done
+	kill -KILL "$1" 2>/dev/null
+}
+kill_tree "$(cat "$0")"`
+
+// ensureContainer returns the container of the current session, replacing
+// the container of a previous session.
+func (b *DockerBackend) ensureContainer(ctx context.Context) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	session := ""
+	if b.sessionFn != nil {
+		session = b.sessionFn()
+	}
+	if b.container != "" {
+		// Before the first message there is no session yet; keep the
+		// container when it gets one.
+		if session == b.session || b.session == "" {
+			b.session = session
+			return b.container, nil
+		}
+		b.removeLocked()
+	}
+
+	spec := b.containerSpec(session)
+	id, err := b.runtime.Run(ctx, spec)
+	if err != nil {
+		// A cancelled client may leave the container behind.
+		removeCtx, cancel := context.WithTimeout(context.Background(), containerRemoveTimeout)
+		_ = b.runtime.Remove(removeCtx, spec.Name)
+		cancel()
+		return "", fmt.Errorf("start container from %s: %w", b.cfg.Image, err)
+	}
+	b.container, b.session = id, session
+	return id, nil
+}
+
+func (b *DockerBackend) containerSpec(session string) ContainerSpec {
+	name := fmt.Sprintf("mscli-%d", os.Getpid())
+	if session != "" {
+		name = fmt.Sprintf("mscli-%s-%d", containerNamePart(session), os.Getpid())
+	}
+	spec := ContainerSpec{
+		Name:    name,
+		Image:   b.cfg.Image,
+		WorkDir: b.cfg.WorkDir,
+		Env:     b.cfg.Env,
+		CPU:     b.cfg.CPU,
+		Memory:  b.cfg.Memory,
+		Network: b.cfg.Network,
+		Labels: map[string]string{
+			"mscli.session": session,
+			"mscli.workdir": b.cfg.WorkDir,
+			"mscli.host":    hostname(),
+			"mscli.pid":     strconv.Itoa(os.Getpid()),
+		},
+	}
+	if uid, gid := os.Getuid(), os.Getgid(); uid >= 0 {
+		spec.User = fmt.Sprintf("%d:%d", uid, gid)
+	}
+	return spec
+}
+
+// Close removes the container, if one was started.
+func (b *DockerBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.removeLocked()
+}
+
+// RemoveStale removes the containers that ms-cli processes on this host
+// left behind for the work dir when they were killed before they could
+// clean up, and returns how many it removed. Containers of running
+// processes are kept.
+func (b *DockerBackend) RemoveStale() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), containerRemoveTimeout)
+	defer cancel()
+	containers, err := b.runtime.List(ctx, map[string]string{"mscli.workdir": b.cfg.WorkDir})
+	if err != nil {
+		return 0, fmt.Errorf("list containers: %w", err)
+	}
+	host := hostname()
+	removed := 0
+	var errs []error
+	for _, c := range containers {
+		pid, err := strconv.Atoi(c.Labels["mscli.pid"])
+		if err != nil || c.Labels["mscli.host"] != host || pid == os.Getpid() || processAlive(pid) {
+			continue
+		}
+		if err := b.runtime.Remove(ctx, c.ID); err != nil {
+			errs = append(errs, fmt.Errorf("remove container %s: %w", c.ID, err))
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
+
+func (b *DockerBackend) removeLocked() error {
+	if b.container == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), containerRemoveTimeout)
+	defer cancel()
+	err := b.runtime.Remove(ctx, b.container)
+	b.container, b.session = "", ""
+	return err
+}
+
+// hostname identifies this host in container labels, as one daemon can
+// serve several hosts.
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
+
+// containerNamePart keeps the characters Docker allows in container names.
+func containerNamePart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+// CLIRuntime runs containers with a Docker-compatible command line, such
+// as docker or podman.
+type CLIRuntime struct {
+	Binary string // default: docker
+}
+
+func (r CLIRuntime) binary() string {
+	if r.Binary == "" {
+		return "docker"
+	}
+	return r.Binary
+}
+
+// Run starts the container with docker run -d.
+func (r CLIRuntime) Run(ctx context.Context, spec ContainerSpec) (string, error) {
+	out, err := exec.CommandContext(ctx, r.binary(), runArgs(spec)...).Output()
+	if err != nil {
+		return "", cliError(err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Exec returns docker exec for argv, with -i when interactive.
+func (r CLIRuntime) Exec(ctx context.Context, id string, argv []string, dir string, env []string, interactive bool) *exec.Cmd {
+	args := []string{"exec", "-w", dir}
+	if interactive {
+		args = append(args, "-i")
+	}
+	for _, kv := range env {
+		args = append(args, "-e", kv)
+	}
+	args = append(args, id)
+	return exec.CommandContext(ctx, r.binary(), append(args, argv...)...)
+}
+
+// Remove deletes the container with docker rm -f.
+func (r CLIRuntime) Remove(ctx context.Context, id string) error {
+	out, err := exec.CommandContext(ctx, r.binary(), "rm", "-f", id).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// List finds the containers with docker ps and reads their labels with
+// docker inspect, whose output is the same for docker and podman.
+func (r CLIRuntime) List(ctx context.Context, labels map[string]string) ([]Container, error) {
+	args := []string{"ps", "-a", "-q", "--no-trunc"}
+	for _, k := range sortedKeys(labels) {
+		args = append(args, "--filter", "label="+k+"="+labels[k])
+	}
+	out, err := exec.CommandContext(ctx, r.binary(), args...).Output()
+	if err != nil {
+		return nil, cliError(err)
+	}
+	ids := strings.Fields(string(out))
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args = append([]string{"inspect", "--format", "{{json .Config.Labels}}"}, ids...)
+	out, err = exec.CommandContext(ctx, r.binary(), args...).Output()
+	if err != nil {
+		return nil, cliError(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != len(ids) {
+		return nil, fmt.Errorf("inspect returned %d results for %d containers", len(lines), len(ids))
+	}
+	containers := make([]Container, len(ids))
+	for i, id := range ids {
+		containers[i].ID = id
+		if err := json.Unmarshal([]byte(lines[i]), &containers[i].Labels); err != nil {
+			return nil, fmt.Errorf("parse labels of %s: %w", id, err)
+		}
+	}
+	return containers, nil
+}
+
+// runArgs returns the docker run arguments for spec. The container runs
+// tail -f /dev/null under --init so it stays up and reaps orphans.
+func runArgs(spec ContainerSpec) []string {
+	args := []string{"run", "-d", "--init", "--name", spec.Name}
+	for _, k := range sortedKeys(spec.Labels) {
+		args = append(args, "--label", k+"="+spec.Labels[k])
+	}
+	if spec.User != "" {
+		args = append(args, "--user", spec.User)
+	}
+	args = append(args, "-v", spec.WorkDir+":"+spec.WorkDir, "-w", spec.WorkDir)
+	if spec.CPU != "" {
+		args = append(args, "--cpus", spec.CPU)
+	}
+	if spec.Memory != "" {
+		args = append(args, "--memory", spec.Memory)
+	}
+	if spec.Network != "" {
+		args = append(args, "--network", spec.Network)
+	}
+	for _, k := range sortedKeys(spec.Env) {
+		args = append(args, "-e", k+"="+spec.Env[k])
+	}
+	return append(args, "--entrypoint", "tail", spec.Image, "-f", "/dev/null")
+}
+
+// cliError adds the command's stderr to err.
+func cliError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if msg := strings.TrimSpace(string(exitErr.Stderr)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+	}
+	return err
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,311 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRuntime is a ContainerRuntime without a daemon: the "container" is
+// the host, so exec runs argv directly in dir. Like docker exec without
+// -i, a non-interactive exec gets no stdin.
+type fakeRuntime struct {
+	mu          sync.Mutex
+	runErr      error
+	runs        []ContainerSpec
+	execs       [][]string
+	interactive []bool
+	removed []string
+	listed  []Container // what List filters
+}
+
+func (f *fakeRuntime) Run(ctx context.Context, spec ContainerSpec) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.runs = append(f.runs, spec)
+	if f.runErr != nil {
+		return "", f.runErr
+	}
+	return fmt.Sprintf("container-%d", len(f.runs)), nil
+}
+
+func (f *fakeRuntime) Exec(ctx context.Context, id string, argv []string, dir string, env []string, interactive bool) *exec.Cmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.execs = append(f.execs, append([]string{id}, argv...))
+	f.interactive = append(f.interactive, interactive)
+	if !interactive {
+		argv = append([]string{"sh", "-c", `exec "$@" </dev/null`, "sh"}, argv...)
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	return cmd
+}
+
+func (f *fakeRuntime) Remove(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removed = append(f.removed, id)
+	return nil
+}
+
+func (f *fakeRuntime) List(ctx context.Context, labels map[string]string) ([]Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []Container
+	for _, c := range f.listed {
+		match := true
+		for k, v := range labels {
+			match = match && c.Labels[k] == v
+		}
+		if match {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// newFakeDocker returns a runner on a DockerBackend with a fake runtime.
+// session is read on every command.
+func newFakeDocker(t *testing.T, session *string) (*Runner, *DockerBackend, *fakeRuntime) {
+	t.Helper()
+	rt := &fakeRuntime{}
+	backend, err := NewDockerBackend(DockerConfig{
+		Runtime: rt,
+		Image:   "ubuntu:22.04",
+		WorkDir: t.TempDir(),
+		Env:     map[string]string{"IN_CONTAINER": "1"},
+		CPU:     "2",
+		Memory:  "4g",
+		Network: "none",
+	})
+	if err != nil {
+		t.Fatalf("NewDockerBackend failed: %v", err)
+	}
+	backend.SetSessionFunc(func() string { return *session })
+	t.Cleanup(func() {
+		// The fake runs the PID-recording wrapper on the host.
+		for _, argv := range rt.execs {
+			if len(argv) > 4 && strings.HasPrefix(argv[4], "/tmp/.mscli-exec-") {
+				os.Remove(argv[4])
+			}
+		}
+	})
+	runner := NewRunner(Config{
+		WorkDir: backend.cfg.WorkDir,
+		Env:     map[string]string{"FROM_RUNNER": "yes"},
+		Backend: backend,
+	})
+	return runner, backend, rt
+}
+
+func TestDockerBackendReusesSessionContainer(t *testing.T) {
+	session := "s-1"
+	runner, backend, rt := newFakeDocker(t, &session)
+
+	for i := 0; i < 2; i++ {
+		result, err := runner.Run(context.Background(), "echo $FROM_RUNNER; pwd")
+		if err != nil || result.ExitCode != 0 {
+			t.Fatalf("Run failed: %v %+v", err, result)
+		}
+		if want := "yes\n" + backend.cfg.WorkDir; result.Stdout != want {
+			t.Errorf("stdout = %q, want %q", result.Stdout, want)
+		}
+	}
+	if len(rt.runs) != 1 || len(rt.execs) != 2 {
+		t.Fatalf("expected 1 container and 2 execs, got %d and %d", len(rt.runs), len(rt.execs))
+	}
+	if rt.execs[1][0] != "container-1" {
+		t.Errorf("exec ran in %q", rt.execs[1][0])
+	}
+
+	spec := rt.runs[0]
+	if spec.Image != "ubuntu:22.04" || spec.WorkDir != backend.cfg.WorkDir ||
+		spec.CPU != "2" || spec.Memory != "4g" || spec.Network != "none" ||
+		spec.Env["IN_CONTAINER"] != "1" || spec.Labels["mscli.session"] != "s-1" {
+		t.Errorf("unexpected container spec %+v", spec)
+	}
+	if !strings.HasPrefix(spec.Name, "mscli-s-1-") {
+		t.Errorf("unexpected container name %q", spec.Name)
+	}
+}
+
+func TestDockerBackendNewSessionGetsNewContainer(t *testing.T) {
+	session := ""
+	runner, backend, rt := newFakeDocker(t, &session)
+	run := func() {
+		t.Helper()
+		if _, err := runner.Run(context.Background(), "true"); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	}
+
+	run()
+	session = "first" // the session is created with the first message
+	run()
+	if len(rt.runs) != 1 || len(rt.removed) != 0 {
+		t.Fatalf("container should be kept when the session starts, got %d runs, removed %v", len(rt.runs), rt.removed)
+	}
+
+	session = "second"
+	run()
+	if len(rt.runs) != 2 || strings.Join(rt.removed, ",") != "container-1" {
+		t.Fatalf("expected a new container for the new session, got %d runs, removed %v", len(rt.runs), rt.removed)
+	}
+
+	if err := backend.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := backend.Close(); err != nil {
+		t.Fatalf("second Close failed: %v", err)
+	}
+	if strings.Join(rt.removed, ",") != "container-1,container-2" {
+		t.Errorf("Close should remove the container once, removed %v", rt.removed)
+	}
+}
+
+func TestDockerBackendStartFailure(t *testing.T) {
+	session := "s"
+	runner, _, rt := newFakeDocker(t, &session)
+	rt.runErr = errors.New("Cannot connect to the Docker daemon")
+
+	_, err := runner.Run(context.Background(), "true")
+	if err == nil || !strings.Contains(err.Error(), "start container from ubuntu:22.04") {
+		t.Fatalf("expected a start error, got %v", err)
+	}
+	if len(rt.removed) != 1 || rt.removed[0] != rt.runs[0].Name {
+		t.Errorf("a failed start should remove the container by name, removed %v", rt.removed)
+	}
+}
+
+func TestDockerBackendTimeoutKillsCommandInContainer(t *testing.T) {
+	session := "s"
+	runner, _, rt := newFakeDocker(t, &session)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result, err := runner.Run(ctx, "sleep 5")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.ExitCode == 0 || time.Since(start) > 3*time.Second {
+		t.Fatalf("command should be killed, got exit %d after %s", result.ExitCode, time.Since(start))
+	}
+	last := rt.execs[len(rt.execs)-1]
+	if !strings.Contains(strings.Join(last, " "), "kill -KILL") {
+		t.Errorf("expected a kill exec in the container, got %q", last)
+	}
+}
+
+func TestDockerBackendPersistentSessionIsInteractive(t *testing.T) {
+	session := "s"
+	_, backend, rt := newFakeDocker(t, &session)
+	runner := NewRunner(Config{
+		WorkDir:    backend.cfg.WorkDir,
+		Timeout:    10 * time.Second,
+		Backend:    backend,
+		Persistent: true,
+	})
+	runner.SetSessionFunc(func() string { return session })
+	t.Cleanup(func() { runner.Close() })
+
+	for _, command := range []string{"export GREETING=hello", "echo $GREETING"} {
+		result, err := runner.Run(context.Background(), command)
+		if err != nil || result.ExitCode != 0 || result.Error != nil {
+			t.Fatalf("Run(%q) failed: %v %+v", command, err, result)
+		}
+		if command == "echo $GREETING" && result.Stdout != "hello" {
+			t.Errorf("stdout = %q, want %q", result.Stdout, "hello")
+		}
+	}
+	if len(rt.execs) != 1 || !rt.interactive[0] {
+		t.Fatalf("expected one interactive exec for the session shell, got %d (%v)", len(rt.execs), rt.interactive)
+	}
+
+	args := CLIRuntime{}.Exec(context.Background(), "c1", []string{"sh"}, "/work", nil, true).Args
+	if got := strings.Join(args, " "); got != "docker exec -w /work -i c1 sh" {
+		t.Errorf("unexpected docker exec args %q", got)
+	}
+}
+
+func TestRunArgs(t *testing.T) {
+	got := runArgs(ContainerSpec{
+		Name:    "mscli-s-42",
+		Image:   "ubuntu:22.04",
+		WorkDir: "/work",
+		User:    "1000:1000",
+		Env:     map[string]string{"B": "2", "A": "1"},
+		CPU:     "2",
+		Memory:  "4g",
+		Network: "none",
+		Labels:  map[string]string{"mscli.session": "s"},
+	})
+	want := "run -d --init --name mscli-s-42 --label mscli.session=s --user 1000:1000 " +
+		"-v /work:/work -w /work --cpus 2 --memory 4g --network none -e A=1 -e B=2 " +
+		"--entrypoint tail ubuntu:22.04 -f /dev/null"
+	if strings.Join(got, " ") != want {
+		t.Errorf("runArgs =\n%s\nwant\n%s", strings.Join(got, " "), want)
+	}
+}
+
+func TestDockerBackendRemovesStaleContainers(t *testing.T) {
+	session := ""
+	_, backend, rt := newFakeDocker(t, &session)
+
+	// A process that has exited stands in for a killed ms-cli.
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+	container := func(id, workDir, host string, pid int) Container {
+		return Container{ID: id, Labels: map[string]string{
+			"mscli.workdir": workDir,
+			"mscli.host":    host,
+			"mscli.pid":     strconv.Itoa(pid),
+		}}
+	}
+	dir, host := backend.cfg.WorkDir, hostname()
+	rt.listed = []Container{
+		container("stale", dir, host, exited.Process.Pid),
+		container("own", dir, host, os.Getpid()),
+		container("live", dir, host, os.Getppid()),
+		container("other-host", dir, host+"-other", exited.Process.Pid),
+		container("other-dir", dir+"-other", host, exited.Process.Pid),
+		{ID: "unlabelled", Labels: map[string]string{"mscli.workdir": dir}},
+	}
+
+	n, err := backend.RemoveStale()
+	if err != nil {
+		t.Fatalf("RemoveStale failed: %v", err)
+	}
+	if n != 1 || len(rt.removed) != 1 || rt.removed[0] != "stale" {
+		t.Errorf("removed %d: %v, want only the stale container", n, rt.removed)
+	}
+}
+
+func TestContainerSpecLabels(t *testing.T) {
+	session := ""
+	_, backend, _ := newFakeDocker(t, &session)
+
+	labels := backend.containerSpec("s1").Labels
+	want := map[string]string{
+		"mscli.session": "s1",
+		"mscli.workdir": backend.cfg.WorkDir,
+		"mscli.host":    hostname(),
+		"mscli.pid":     strconv.Itoa(os.Getpid()),
+	}
+	for k, v := range want {
+		if labels[k] != v {
+			t.Errorf("label %s = %q, want %q", k, labels[k], v)
+		}
+	}
+}
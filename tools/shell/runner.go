@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -20,6 +19,18 @@ type Config struct {
 	BlockedCmds    []string // Blacklist
 	RequireConfirm []string // Commands requiring confirmation
 	Env            map[string]string
+
+	// Backend starts the commands (default: LocalBackend).
+	Backend Backend
+
+	// MaxOutputBytes caps stdout and stderr each (default 64KB). Raise it
+	// when large outputs are spilled to artifacts instead of the context.
+	MaxOutputBytes int
+
+	// Persistent runs the commands of each agent session in one long-lived
+	// bash, so the working directory, exported variables and activated
+	// virtualenvs carry over between commands.
+	Persistent bool
 }
 
 // Result is the result of a command execution.
@@ -28,11 +39,22 @@ type Result struct {
 	Stderr   string
 	ExitCode int
 	Error    error
+
+	// Persistent sessions only.
+	Dir         string // working directory after the command
+	Interrupted bool   // the command was interrupted by a timeout or cancel
+	Restarted   bool   // the shell ended; the next command starts a new one
 }
 
 // Runner executes shell commands.
 type Runner struct {
 	config Config
+
+	// sessionLock serializes commands in the persistent session; it is a
+	// channel so that waiting for it respects the command's context.
+	sessionLock chan struct{}
+	sessionFn   func() string
+	session     *shellSession
 }
 
 const (
@@ -45,7 +67,38 @@ func NewRunner(cfg Config) *Runner {
 	if cfg.Timeout == 0 {
 		cfg.Timeout = 60 * time.Second
 	}
-	return &Runner{config: cfg}
+	if cfg.MaxOutputBytes <= 0 {
+		cfg.MaxOutputBytes = maxOutputBytes
+	}
+	if cfg.Backend == nil {
+		cfg.Backend = LocalBackend{}
+	}
+	return &Runner{config: cfg, sessionLock: make(chan struct{}, 1)}
+}
+
+// SetSessionFunc sets the function that returns the current agent session
+// ID. A persistent runner starts a new shell when it changes.
+func (r *Runner) SetSessionFunc(fn func() string) {
+	r.sessionLock <- struct{}{}
+	defer func() { <-r.sessionLock }()
+	r.sessionFn = fn
+}
+
+// RestartSession ends the persistent shell; the next command starts a new
+// one in the work dir with the initial environment.
+func (r *Runner) RestartSession() {
+	r.sessionLock <- struct{}{}
+	defer func() { <-r.sessionLock }()
+	if r.session != nil {
+		r.session.close()
+		r.session = nil
+	}
+}
+
+// Close ends the persistent shell.
+func (r *Runner) Close() error {
+	r.RestartSession()
+	return nil
 }
 
 // Run executes a command and returns the result.
@@ -58,25 +111,23 @@ func (r *Runner) Run(ctx context.Context, command string) (*Result, error) {
 		}, nil
 	}
 
-	buildCmd := func(execCtx context.Context) *exec.Cmd {
-		cmd := exec.CommandContext(execCtx, "sh", "-c", command)
-		cmd.Dir = r.config.WorkDir
-		cmd.Env = os.Environ()
-		for k, v := range r.config.Env {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
-		}
-		return cmd
-	}
-
-	// Create command
-	cmd := buildCmd(ctx)
-
 	// Run with timeout if context doesn't have one
 	if _, hasDeadline := ctx.Deadline(); !hasDeadline && r.config.Timeout > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, r.config.Timeout)
 		defer cancel()
-		cmd = buildCmd(ctx)
+	}
+
+	if r.config.Persistent {
+		return r.runInSession(ctx, command)
+	}
+
+	cmd, cleanup, err := r.config.Backend.Command(ctx, command, r.config.WorkDir, r.env())
+	if err != nil {
+		return nil, fmt.Errorf("prepare %s command: %w", r.config.Backend.Name(), err)
+	}
+	if cleanup != nil {
+		defer cleanup()
 	}
 
 	// Capture output
@@ -101,13 +152,13 @@ func (r *Runner) Run(ctx context.Context, command string) (*Result, error) {
 
 	stdoutDone := make(chan struct{})
 	go func() {
-		stdoutOut, stdoutErr = readCapped(stdout, maxOutputBytes)
+		stdoutOut, stdoutErr = readCapped(stdout, r.config.MaxOutputBytes)
 		close(stdoutDone)
 	}()
 
 	stderrDone := make(chan struct{})
 	go func() {
-		stderrOut, stderrErr = readCapped(stderr, maxOutputBytes)
+		stderrOut, stderrErr = readCapped(stderr, r.config.MaxOutputBytes)
 		close(stderrDone)
 	}()
 
@@ -142,6 +193,50 @@ func (r *Runner) Run(ctx context.Context, command string) (*Result, error) {
 	return result, nil
 }
 
+// runInSession runs command in the persistent shell of the current agent
+// session, starting the shell first if needed.
+func (r *Runner) runInSession(ctx context.Context, command string) (*Result, error) {
+	select {
+	case r.sessionLock <- struct{}{}:
+	case <-ctx.Done():
+		return &Result{ExitCode: -1, Interrupted: true, Error: fmt.Errorf("waiting for the shell: %w", ctx.Err())}, nil
+	}
+	defer func() { <-r.sessionLock }()
+
+	owner := ""
+	if r.sessionFn != nil {
+		owner = r.sessionFn()
+	}
+	if s := r.session; s != nil && (!s.alive() || (owner != s.owner && s.owner != "")) {
+		s.close()
+		r.session = nil
+	}
+	if r.session == nil {
+		s, err := startShellSession(r.config.Backend, owner, r.config.WorkDir, r.env())
+		if err != nil {
+			return nil, fmt.Errorf("start %s shell session: %w", r.config.Backend.Name(), err)
+		}
+		r.session = s
+	}
+	// Before the first message there is no agent session yet.
+	r.session.owner = owner
+
+	result := r.session.run(ctx, command, r.config.MaxOutputBytes)
+	if result.Restarted {
+		r.session = nil
+	}
+	return result, nil
+}
+
+// env returns the extra variables as KEY=VALUE.
+func (r *Runner) env() []string {
+	var env []string
+	for k, v := range r.config.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	return env
+}
+
 func readCapped(r io.Reader, maxBytes int) (string, error) {
 	scanner := bufio.NewScanner(r)
 	scanner.Buffer(make([]byte, 64*1024), maxScannerTokenSize)
@@ -0,0 +1,116 @@
+package shell
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Sandbox isolation tools.
+const (
+	IsolationAuto       = "auto"       // bubblewrap when installed, else namespaces
+	IsolationBubblewrap = "bwrap"      // bubblewrap (bwrap) only
+	IsolationNamespaces = "namespaces" // user, mount and network namespaces set up by ms-cli
+)
+
+// SandboxConfig configures the Linux sandbox backend. Commands can write
+// to the runner's work dir and a private /tmp; the rest of the file system
+// is read-only.
+type SandboxConfig struct {
+	Isolation string // IsolationAuto (default), IsolationBubblewrap or IsolationNamespaces
+	Network   string // "none" gives commands a private network with only loopback
+	CPU       string // number of CPUs, e.g. "2" or "0.5"; empty = no limit
+	Memory    string // e.g. "512m" or "4g"; empty = no limit
+}
+
+// sandboxLimits are the parsed CPU and memory limits.
+type sandboxLimits struct {
+	cpus   float64
+	memory int64
+}
+
+func parseSandboxLimits(cfg SandboxConfig) (sandboxLimits, error) {
+	var limits sandboxLimits
+	if s := strings.TrimSpace(cfg.CPU); s != "" {
+		cpus, err := strconv.ParseFloat(s, 64)
+		if err != nil || cpus <= 0 {
+			return limits, fmt.Errorf("invalid cpu limit %q", cfg.CPU)
+		}
+		limits.cpus = cpus
+	}
+	if s := strings.TrimSpace(cfg.Memory); s != "" {
+		memory, err := ParseSize(s)
+		if err != nil || memory <= 0 {
+			return limits, fmt.Errorf("invalid memory limit %q", cfg.Memory)
+		}
+		limits.memory = memory
+	}
+	return limits, nil
+}
+
+// ParseSize parses a byte size in Docker notation: a number with an
+// optional b, k, m or g suffix, e.g. "512m" or "4g".
+func ParseSize(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	multiplier := int64(1)
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'b':
+			s = s[:len(s)-1]
+		case 'k':
+			multiplier, s = 1<<10, s[:len(s)-1]
+		case 'm':
+			multiplier, s = 1<<20, s[:len(s)-1]
+		case 'g':
+			multiplier, s = 1<<30, s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// sandboxEnv returns env without the variables that may hold credentials,
+// such as MSCLI_API_KEY or GITHUB_TOKEN, and without the agent sockets that
+// would let a command use the user's keys.
+func sandboxEnv(env []string) []string {
+	out := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if !sensitiveEnvName(name) {
+			out = append(out, kv)
+		}
+	}
+	return out
+}
+
+// sensitiveEnvName reports whether an environment variable name looks like
+// it holds a secret: one of its underscore-separated words is KEY, TOKEN,
+// SECRET and the like, or it ends in PASSWORD (PGPASSWORD).
+func sensitiveEnvName(name string) bool {
+	upper := strings.ToUpper(name)
+	switch upper {
+	case "SSH_AUTH_SOCK", "GPG_AGENT_INFO":
+		return true
+	}
+	for _, suffix := range []string{"PASSWORD", "PASSWD", "TOKEN", "SECRET"} {
+		if strings.HasSuffix(upper, suffix) {
+			return true
+		}
+	}
+	for _, word := range strings.Split(upper, "_") {
+		switch word {
+		case "KEY", "KEYS", "APIKEY", "TOKEN", "TOKENS", "SECRET", "SECRETS",
+			"PASSWORD", "PASSWD", "PASS", "CREDENTIAL", "CREDENTIALS", "AUTH":
+			return true
+		}
+	}
+	return false
+}
+
+// isolateNetwork reports whether network mode removes network access.
+func isolateNetwork(network string) bool {
+	return strings.EqualFold(strings.TrimSpace(network), "none")
+}
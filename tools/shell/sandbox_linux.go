@@ -0,0 +1,590 @@
+//go:build linux
+
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// sandboxInitEnv carries a sandboxInit to the helper process: ms-cli
+// re-runs its own executable inside the sandbox, and init below turns that
+// process into the sandboxed sh -c before main runs.
+const sandboxInitEnv = "MSCLI_SANDBOX_INIT"
+
+// sandboxInit tells the helper process how to finish the sandbox.
+type sandboxInit struct {
+	Dir      string `json:"dir"`
+	Command  string `json:"command"`
+	Mount    bool   `json:"mount,omitempty"`    // set up the mounts (bubblewrap did not)
+	Loopback bool   `json:"loopback,omitempty"` // bring up lo in the new network namespace
+	Memory   int64  `json:"memory,omitempty"`   // RLIMIT_DATA, used without a cgroup
+	CPUs     int    `json:"cpus,omitempty"`     // CPU affinity, used without a cgroup
+}
+
+func init() {
+	if spec, ok := os.LookupEnv(sandboxInitEnv); ok {
+		runSandboxInit(spec)
+	}
+}
+
+// SandboxBackend runs commands in Linux user, mount, PID and network
+// namespaces, through bubblewrap when it is installed. The work dir is
+// writable, the rest of the file system read-only, host processes are out
+// of sight, variables that look like credentials are removed from the
+// environment, and CPU and memory are limited with a
+// cgroup when this process may create one, otherwise with CPU affinity and
+// RLIMIT_DATA.
+type SandboxBackend struct {
+	cfg    SandboxConfig
+	limits sandboxLimits
+	self   string // this executable, run as the sandbox helper
+	bwrap  string // bubblewrap binary; empty to set up the namespaces here
+	cgroup string // cgroup v2 dir for per-command cgroups; empty for rlimits
+	seq    atomic.Int64
+}
+
+// NewSandboxBackend checks that the sandbox can be set up on this system.
+func NewSandboxBackend(cfg SandboxConfig) (*SandboxBackend, error) {
+	limits, err := parseSandboxLimits(cfg)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.Network)) {
+	case "", "none", "host":
+	default:
+		return nil, fmt.Errorf("unsupported sandbox network %q (use none or host)", cfg.Network)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("locate executable: %w", err)
+	}
+
+	b := &SandboxBackend{cfg: cfg, limits: limits, self: self}
+	switch cfg.Isolation {
+	case "", IsolationAuto:
+		if path, err := exec.LookPath("bwrap"); err == nil && b.probe(path) == nil {
+			b.bwrap = path
+			break
+		}
+		if err := b.probe(""); err != nil {
+			return nil, fmt.Errorf("no usable isolation: bwrap not found or not working, and user namespaces are unavailable: %w", err)
+		}
+	case IsolationBubblewrap:
+		path, err := exec.LookPath("bwrap")
+		if err != nil {
+			return nil, fmt.Errorf("bubblewrap not found: %w", err)
+		}
+		if err := b.probe(path); err != nil {
+			return nil, fmt.Errorf("bubblewrap does not work here: %w", err)
+		}
+		b.bwrap = path
+	case IsolationNamespaces:
+		if err := b.probe(""); err != nil {
+			return nil, fmt.Errorf("user namespaces are unavailable: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported sandbox isolation %q (use auto, bwrap or namespaces)", cfg.Isolation)
+	}
+	if limits.cpus > 0 || limits.memory > 0 {
+		b.cgroup = sandboxCgroupParent()
+	}
+	return b, nil
+}
+
+// Name returns the backend name.
+func (b *SandboxBackend) Name() string {
+	return "sandbox"
+}
+
+// Isolation returns the tool that sets up the sandbox: IsolationBubblewrap
+// or IsolationNamespaces.
+func (b *SandboxBackend) Isolation() string {
+	if b.bwrap != "" {
+		return IsolationBubblewrap
+	}
+	return IsolationNamespaces
+}
+
+// Command returns the helper process that runs command in the sandbox.
+func (b *SandboxBackend) Command(ctx context.Context, command, dir string, env []string) (*exec.Cmd, func(), error) {
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, nil, err
+		}
+		dir = wd
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	spec := sandboxInit{
+		Dir:      dir,
+		Command:  command,
+		Mount:    b.bwrap == "",
+		Loopback: b.bwrap == "" && isolateNetwork(b.cfg.Network),
+	}
+	cgroupFD, cleanup := b.newCgroup()
+	if cgroupFD < 0 {
+		spec.Memory = b.limits.memory
+		spec.CPUs = int(math.Ceil(b.limits.cpus))
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		if cleanup != nil {
+			cleanup()
+		}
+		return nil, nil, err
+	}
+
+	var cmd *exec.Cmd
+	if b.bwrap != "" {
+		args := append(b.bwrapArgs(dir), "--", b.self)
+		cmd = exec.CommandContext(ctx, b.bwrap, args...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	} else {
+		cmd = exec.CommandContext(ctx, b.self)
+		cmd.Args = []string{"mscli-sandbox"}
+		cmd.SysProcAttr = b.namespaceAttr()
+	}
+	cmd.Dir = dir
+	cmd.Env = append(withoutEnv(append(sandboxEnv(os.Environ()), env...), sandboxInitEnv), sandboxInitEnv+"="+string(data))
+	if cgroupFD >= 0 {
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = cgroupFD
+	}
+	return cmd, cleanup, nil
+}
+
+// probe runs true in the sandbox to check that it can be set up. bwrap is
+// the bubblewrap binary, or empty to probe the namespaces.
+func (b *SandboxBackend) probe(bwrap string) error {
+	truePath, err := exec.LookPath("true")
+	if err != nil {
+		return err
+	}
+	var cmd *exec.Cmd
+	if bwrap != "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		cmd = exec.Command(bwrap, append(b.bwrapArgs(wd), "--", truePath)...)
+	} else {
+		cmd = exec.Command(truePath)
+		cmd.SysProcAttr = b.namespaceAttr()
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// bwrapArgs mounts the root read-only, a fresh /dev, /proc and /tmp, and
+// dir read-write.
+func (b *SandboxBackend) bwrapArgs(dir string) []string {
+	args := []string{
+		"--die-with-parent", "--new-session",
+		"--unshare-user", "--unshare-pid", "--unshare-ipc", "--unshare-uts",
+	}
+	if isolateNetwork(b.cfg.Network) {
+		args = append(args, "--unshare-net")
+	}
+	return append(args,
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+		"--bind", dir, dir,
+		"--chdir", dir,
+	)
+}
+
+// namespaceAttr starts the helper in new namespaces as the current user,
+// with just the capabilities it needs to set up the mounts and loopback.
+// The helper is PID 1 of its PID namespace, so the command it becomes
+// cannot see or signal host processes, and killing it kills everything the
+// command started.
+func (b *SandboxBackend) namespaceAttr() *syscall.SysProcAttr {
+	flags := uintptr(unix.CLONE_NEWUSER | unix.CLONE_NEWNS | unix.CLONE_NEWPID | unix.CLONE_NEWIPC | unix.CLONE_NEWUTS)
+	if isolateNetwork(b.cfg.Network) {
+		flags |= unix.CLONE_NEWNET
+	}
+	uid, gid := os.Getuid(), os.Getgid()
+	return &syscall.SysProcAttr{
+		Cloneflags:                 flags,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}},
+		GidMappingsEnableSetgroups: false,
+		AmbientCaps:                []uintptr{unix.CAP_SYS_ADMIN, unix.CAP_NET_ADMIN, unix.CAP_SETPCAP},
+		Pdeathsig:                  syscall.SIGKILL,
+	}
+}
+
+// newCgroup creates a cgroup with the CPU and memory limits for one
+// command. It returns -1 when there is no cgroup to use.
+func (b *SandboxBackend) newCgroup() (int, func()) {
+	if b.cgroup == "" {
+		return -1, nil
+	}
+	dir := filepath.Join(b.cgroup, fmt.Sprintf("mscli-sandbox-%d-%d", os.Getpid(), b.seq.Add(1)))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return -1, nil
+	}
+	remove := func() {
+		_ = os.WriteFile(filepath.Join(dir, "cgroup.kill"), []byte("1"), 0644)
+		// The kernel removes killed processes asynchronously.
+		for i := 0; i < 50; i++ {
+			if err := os.Remove(dir); err == nil || os.IsNotExist(err) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	const period = 100000
+	if b.limits.cpus > 0 {
+		quota := fmt.Sprintf("%d %d", int64(b.limits.cpus*period), period)
+		if err := os.WriteFile(filepath.Join(dir, "cpu.max"), []byte(quota), 0644); err != nil {
+			remove()
+			return -1, nil
+		}
+	}
+	if b.limits.memory > 0 {
+		limit := strconv.FormatInt(b.limits.memory, 10)
+		if err := os.WriteFile(filepath.Join(dir, "memory.max"), []byte(limit), 0644); err != nil {
+			remove()
+			return -1, nil
+		}
+	}
+	fd, err := unix.Open(dir, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		remove()
+		return -1, nil
+	}
+	return fd, func() {
+		unix.Close(fd)
+		remove()
+	}
+}
+
+// sandboxCgroupParent returns the cgroup v2 directory of this process if
+// it delegates the cpu and memory controllers to child cgroups that this
+// process may create, or "".
+func sandboxCgroupParent() string {
+	var fs unix.Statfs_t
+	if err := unix.Statfs("/sys/fs/cgroup", &fs); err != nil || fs.Type != unix.CGROUP2_SUPER_MAGIC {
+		return ""
+	}
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		path, ok := strings.CutPrefix(line, "0::")
+		if !ok {
+			continue
+		}
+		dir := filepath.Join("/sys/fs/cgroup", path)
+		controllers, err := os.ReadFile(filepath.Join(dir, "cgroup.subtree_control"))
+		if err != nil {
+			return ""
+		}
+		fields := strings.Fields(string(controllers))
+		if !containsString(fields, "cpu") || !containsString(fields, "memory") {
+			return ""
+		}
+		if unix.Access(dir, unix.W_OK) != nil {
+			return ""
+		}
+		return dir
+	}
+	return ""
+}
+
+// runSandboxInit runs in the helper process inside the new namespaces. It
+// sets up what bubblewrap or the parent did not, drops the capabilities
+// and replaces itself with sh -c. It never returns.
+func runSandboxInit(data string) {
+	// Capabilities and CPU affinity are per thread; exec from this one.
+	runtime.LockOSThread()
+	os.Unsetenv(sandboxInitEnv)
+
+	var spec sandboxInit
+	if err := json.Unmarshal([]byte(data), &spec); err != nil {
+		sandboxFail("invalid spec: %v", err)
+	}
+	if spec.Mount {
+		if err := setupSandboxMounts(spec.Dir); err != nil {
+			sandboxFail("set up mounts: %v", err)
+		}
+	}
+	if spec.Loopback {
+		if err := bringUpLoopback(); err != nil {
+			sandboxFail("bring up loopback: %v", err)
+		}
+	}
+	if spec.Memory > 0 {
+		limit := uint64(spec.Memory)
+		if err := unix.Setrlimit(unix.RLIMIT_DATA, &unix.Rlimit{Cur: limit, Max: limit}); err != nil {
+			sandboxFail("limit memory: %v", err)
+		}
+	}
+	if spec.CPUs > 0 {
+		if err := limitCPUs(spec.CPUs); err != nil {
+			sandboxFail("limit CPUs: %v", err)
+		}
+	}
+	if err := os.Chdir(spec.Dir); err != nil {
+		sandboxFail("%v", err)
+	}
+
+	// No way back to the capabilities that could undo the mounts.
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		sandboxFail("set no_new_privs: %v", err)
+	}
+	for _, c := range []uintptr{unix.CAP_SYS_ADMIN, unix.CAP_NET_ADMIN} {
+		// Fails without CAP_SETPCAP, where exec drops all capabilities anyway.
+		_ = unix.Prctl(unix.PR_CAPBSET_DROP, c, 0, 0, 0)
+	}
+	_ = unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0)
+	if err := clearInheritableCaps(); err != nil {
+		sandboxFail("drop capabilities: %v", err)
+	}
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		sandboxFail("%v", err)
+	}
+	err = unix.Exec(sh, []string{"sh", "-c", spec.Command}, os.Environ())
+	sandboxFail("exec sh: %v", err)
+}
+
+// clearInheritableCaps keeps exec from passing on the capabilities the
+// helper was started with.
+func clearInheritableCaps() error {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return err
+	}
+	data[0].Inheritable, data[1].Inheritable = 0, 0
+	return unix.Capset(&hdr, &data[0])
+}
+
+func sandboxFail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "sandbox: "+format+"\n", args...)
+	os.Exit(126)
+}
+
+// setupSandboxMounts gives the mount namespace a private /tmp, binds dir
+// read-write, makes every other mount read-only and mounts a /proc for the
+// new PID namespace. /dev and /sys are left as they are.
+func setupSandboxMounts(dir string) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+
+	// Hold on to dir: it may be under /tmp, which is about to be replaced.
+	fd, err := unix.Open(dir, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("open work dir: %w", err)
+	}
+	defer unix.Close(fd)
+	if err := unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("mount /tmp: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := unix.Mount(fmt.Sprintf("/proc/self/fd/%d", fd), dir, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind work dir: %w", err)
+	}
+
+	mounts, err := readMountInfo()
+	if err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		if m.readOnly() || underAny(m.point, dir, "/tmp", "/dev", "/proc", "/sys") {
+			continue
+		}
+		flags := uintptr(unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY) | m.flags()
+		if err := unix.Mount("", m.point, "", flags, ""); err != nil {
+			return fmt.Errorf("make %s read-only: %w", m.point, err)
+		}
+	}
+
+	// The host's /proc would still list and expose host processes.
+	if err := unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount /proc: %w", err)
+	}
+	return nil
+}
+
+// mountEntry is one line of /proc/self/mountinfo.
+type mountEntry struct {
+	point   string
+	options []string
+}
+
+func (m mountEntry) readOnly() bool {
+	return containsString(m.options, "ro")
+}
+
+// flags returns the per-mount flags a remount must keep: a user namespace
+// may not clear them.
+func (m mountEntry) flags() uintptr {
+	var flags uintptr
+	for _, opt := range m.options {
+		switch opt {
+		case "nosuid":
+			flags |= unix.MS_NOSUID
+		case "nodev":
+			flags |= unix.MS_NODEV
+		case "noexec":
+			flags |= unix.MS_NOEXEC
+		case "noatime":
+			flags |= unix.MS_NOATIME
+		case "nodiratime":
+			flags |= unix.MS_NODIRATIME
+		case "relatime":
+			flags |= unix.MS_RELATIME
+		case "strictatime":
+			flags |= unix.MS_STRICTATIME
+		}
+	}
+	return flags
+}
+
+func readMountInfo() ([]mountEntry, error) {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	var mounts []mountEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		mounts = append(mounts, mountEntry{
+			point:   unescapeMountPath(fields[4]),
+			options: strings.Split(fields[5], ","),
+		})
+	}
+	return mounts, nil
+}
+
+// unescapeMountPath decodes the octal escapes (\040 for a space) in
+// mountinfo paths.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// underAny reports whether path is one of roots or inside one of them.
+func underAny(path string, roots ...string) bool {
+	for _, root := range roots {
+		if root != "/" {
+			root = strings.TrimSuffix(root, "/")
+		}
+		if path == root || strings.HasPrefix(path, strings.TrimSuffix(root, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func bringUpLoopback() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return err
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
+
+// limitCPUs pins the process to n of the CPUs it may run on.
+func limitCPUs(n int) error {
+	var allowed unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &allowed); err != nil {
+		return err
+	}
+	if n >= allowed.Count() {
+		return nil
+	}
+	var limited unix.CPUSet
+	for cpu, set := 0, 0; set < n && cpu < len(allowed)*64; cpu++ {
+		if allowed.IsSet(cpu) {
+			limited.Set(cpu)
+			set++
+		}
+	}
+	return unix.SchedSetaffinity(0, &limited)
+}
+
+// withoutEnv returns env without the variable key.
+func withoutEnv(env []string, key string) []string {
+	out := make([]string, 0, len(env)+1)
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, key+"=") {
+			out = append(out, kv)
+		}
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardsSignals reports whether the session process is the sandboxed
+// shell itself: bubblewrap runs it as a child in a new PID namespace.
+func (b *SandboxBackend) forwardsSignals() bool {
+	return b.bwrap == ""
+}
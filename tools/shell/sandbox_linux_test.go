@@ -0,0 +1,202 @@
+//go:build linux
+
+package shell
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnescapeMountPath(t *testing.T) {
+	tests := map[string]string{
+		"/":                     "/",
+		"/mnt/my\\040disk":      "/mnt/my disk",
+		"/a\\011b\\012c":        "/a\tb\nc",
+		"/back\\134slash":       "/back\\slash",
+		"/short\\04":            "/short\\04",
+		"/not\\999octal":        "/not\\999octal",
+		"/trailing\\":           "/trailing\\",
+		"/two\\040spaces\\040x": "/two spaces x",
+	}
+	for in, want := range tests {
+		if got := unescapeMountPath(in); got != want {
+			t.Errorf("unescapeMountPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestUnderAny(t *testing.T) {
+	tests := []struct {
+		path  string
+		roots []string
+		want  bool
+	}{
+		{"/tmp", []string{"/tmp"}, true},
+		{"/tmp/x/y", []string{"/proc", "/tmp"}, true},
+		{"/tmpfoo", []string{"/tmp"}, false},
+		{"/home/me/project", []string{"/home/me/project/"}, true},
+		{"/home/me/projects", []string{"/home/me/project"}, false},
+		{"/etc", []string{"/tmp", "/dev"}, false},
+		{"/etc", nil, false},
+	}
+	for _, tt := range tests {
+		if got := underAny(tt.path, tt.roots...); got != tt.want {
+			t.Errorf("underAny(%q, %q) = %v, want %v", tt.path, tt.roots, got, tt.want)
+		}
+	}
+}
+
+func TestBwrapArgs(t *testing.T) {
+	dir := "/work/dir"
+	args := (&SandboxBackend{cfg: SandboxConfig{Network: "none"}}).bwrapArgs(dir)
+	joined := " " + strings.Join(args, " ") + " "
+	for _, want := range []string{
+		" --die-with-parent ",
+		" --unshare-user ",
+		" --unshare-pid ",
+		" --unshare-net ",
+		" --ro-bind / / ",
+		" --proc /proc ",
+		" --tmpfs /tmp ",
+		" --bind /work/dir /work/dir ",
+		" --chdir /work/dir ",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("bwrap args %q lack %q", args, strings.TrimSpace(want))
+		}
+	}
+	// The work dir may be under /tmp, so it is bound after the tmpfs.
+	if strings.Index(joined, " --bind ") < strings.Index(joined, " --tmpfs ") {
+		t.Errorf("work dir bound before /tmp: %q", args)
+	}
+
+	args = (&SandboxBackend{cfg: SandboxConfig{Network: "host"}}).bwrapArgs(dir)
+	for _, arg := range args {
+		if arg == "--unshare-net" {
+			t.Errorf("host network args %q unshare the network", args)
+		}
+	}
+}
+
+// newNamespaceSandbox returns a runner on the namespaces sandbox, or skips
+// the test when user namespaces are unavailable.
+func newNamespaceSandbox(t *testing.T) (*Runner, string) {
+	t.Helper()
+	backend, err := NewSandboxBackend(SandboxConfig{Isolation: IsolationNamespaces, Network: "none"})
+	if err != nil {
+		t.Skipf("namespace sandbox unavailable: %v", err)
+	}
+	dir := t.TempDir()
+	return NewRunner(Config{WorkDir: dir, Timeout: 30 * time.Second, Backend: backend}), dir
+}
+
+func runSandboxed(t *testing.T, r *Runner, command string) *Result {
+	t.Helper()
+	res, err := r.Run(context.Background(), command)
+	if err != nil {
+		t.Fatalf("Run(%q): %v", command, err)
+	}
+	return res
+}
+
+func TestSandboxFileSystem(t *testing.T) {
+	r, dir := newNamespaceSandbox(t)
+
+	res := runSandboxed(t, r, "echo hi > inside && cat inside")
+	if res.ExitCode != 0 || res.Stdout != "hi" {
+		t.Fatalf("write in work dir: exit %d, stdout %q, stderr %q", res.ExitCode, res.Stdout, res.Stderr)
+	}
+
+	// The package dir is on the host file system, outside the work dir.
+	outside, err := filepath.Abs("sandbox-write-probe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res = runSandboxed(t, r, "echo hi > "+outside)
+	if res.ExitCode == 0 {
+		t.Errorf("write outside the work dir succeeded")
+	}
+	if _, err := os.Stat(outside); err == nil {
+		os.Remove(outside)
+		t.Errorf("%s was created on the host", outside)
+	}
+
+	// /tmp is private.
+	name := "/tmp/mscli-sandbox-probe-" + filepath.Base(dir)
+	res = runSandboxed(t, r, "echo hi > "+name)
+	if res.ExitCode != 0 {
+		t.Errorf("write to /tmp: exit %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+	if _, err := os.Stat(name); err == nil {
+		os.Remove(name)
+		t.Errorf("%s is visible on the host", name)
+	}
+}
+
+func TestSandboxNoNetwork(t *testing.T) {
+	r, _ := newNamespaceSandbox(t)
+
+	res := runSandboxed(t, r, "cat /proc/net/dev")
+	if res.ExitCode != 0 {
+		t.Fatalf("read /proc/net/dev: exit %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+	for _, line := range strings.Split(res.Stdout, "\n") {
+		name, _, ok := strings.Cut(line, ":")
+		if ok && strings.TrimSpace(name) != "lo" {
+			t.Errorf("interface %q is visible in the sandbox", strings.TrimSpace(name))
+		}
+	}
+
+	res = runSandboxed(t, r, "cat /proc/net/route")
+	if lines := strings.Split(strings.TrimSpace(res.Stdout), "\n"); len(lines) > 1 {
+		t.Errorf("sandbox has routes:\n%s", res.Stdout)
+	}
+}
+
+func TestSandboxHidesHostProcesses(t *testing.T) {
+	r, _ := newNamespaceSandbox(t)
+
+	host := exec.Command("sleep", "30")
+	if err := host.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer host.Process.Kill()
+	exited := make(chan struct{})
+	go func() {
+		host.Wait()
+		close(exited)
+	}()
+
+	pid := strconv.Itoa(host.Process.Pid)
+	res := runSandboxed(t, r, "test -e /proc/"+pid+" && echo visible; kill -9 "+pid)
+	if strings.Contains(res.Stdout, "visible") {
+		t.Errorf("host process %s is listed in the sandbox's /proc", pid)
+	}
+	select {
+	case <-exited:
+		t.Errorf("the sandbox killed host process %s", pid)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	res = runSandboxed(t, r, "echo $$")
+	if res.Stdout != "1" {
+		t.Errorf("sandboxed shell PID = %q, want 1", res.Stdout)
+	}
+}
+
+func TestSandboxDropsCredentials(t *testing.T) {
+	t.Setenv("MSCLI_API_KEY", "secret")
+	t.Setenv("MSCLI_SANDBOX_TEST_VALUE", "kept")
+	r, _ := newNamespaceSandbox(t)
+
+	res := runSandboxed(t, r, `echo "${MSCLI_API_KEY:-unset} $MSCLI_SANDBOX_TEST_VALUE"`)
+	if res.Stdout != "unset kept" {
+		t.Errorf("stdout = %q, want %q", res.Stdout, "unset kept")
+	}
+}
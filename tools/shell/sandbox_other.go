@@ -0,0 +1,34 @@
+//go:build !linux
+
+package shell
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+var errSandboxUnsupported = errors.New("the sandbox backend requires Linux")
+
+// SandboxBackend is only available on Linux.
+type SandboxBackend struct{}
+
+// NewSandboxBackend always fails outside Linux.
+func NewSandboxBackend(cfg SandboxConfig) (*SandboxBackend, error) {
+	return nil, errSandboxUnsupported
+}
+
+// Name returns the backend name.
+func (b *SandboxBackend) Name() string {
+	return "sandbox"
+}
+
+// Isolation returns an empty string: there is no sandbox.
+func (b *SandboxBackend) Isolation() string {
+	return ""
+}
+
+// Command always fails outside Linux.
+func (b *SandboxBackend) Command(ctx context.Context, command, dir string, env []string) (*exec.Cmd, func(), error) {
+	return nil, nil, errSandboxUnsupported
+}
@@ -0,0 +1,92 @@
+package shell
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "1024", want: 1024},
+		{in: "100b", want: 100},
+		{in: "2k", want: 2 << 10},
+		{in: "512m", want: 512 << 20},
+		{in: "4g", want: 4 << 30},
+		{in: " 4G ", want: 4 << 30},
+		{in: "1.5g", want: 3 << 29},
+		{in: "", wantErr: true},
+		{in: "g", wantErr: true},
+		{in: "-1m", wantErr: true},
+		{in: "4t", wantErr: true},
+		{in: "lots", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseSandboxLimits(t *testing.T) {
+	limits, err := parseSandboxLimits(SandboxConfig{CPU: "0.5", Memory: "256m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.cpus != 0.5 || limits.memory != 256<<20 {
+		t.Errorf("limits = %+v, want 0.5 CPUs and 256MB", limits)
+	}
+
+	limits, err = parseSandboxLimits(SandboxConfig{})
+	if err != nil || limits != (sandboxLimits{}) {
+		t.Errorf("empty config = %+v, %v; want no limits", limits, err)
+	}
+
+	for _, cfg := range []SandboxConfig{
+		{CPU: "0"},
+		{CPU: "-1"},
+		{CPU: "two"},
+		{Memory: "0"},
+		{Memory: "big"},
+	} {
+		if _, err := parseSandboxLimits(cfg); err == nil {
+			t.Errorf("parseSandboxLimits(%+v) accepted an invalid limit", cfg)
+		}
+	}
+}
+
+func TestSandboxEnvDropsCredentials(t *testing.T) {
+	env := []string{
+		"PATH=/usr/bin",
+		"HOME=/home/me",
+		"MSCLI_API_KEY=a",
+		"OPENAI_API_KEY=b",
+		"ANTHROPIC_API_KEY=c",
+		"GITHUB_TOKEN=d",
+		"AWS_SECRET_ACCESS_KEY=e",
+		"PGPASSWORD=f",
+		"DB_PASSWORD=g",
+		"SSH_AUTH_SOCK=/tmp/agent",
+		"MSCLI_BASE_URL=https://example.com",
+		"GIT_AUTHOR_NAME=me",
+		"KEYBOARD_LAYOUT=us",
+	}
+	want := []string{
+		"PATH=/usr/bin",
+		"HOME=/home/me",
+		"MSCLI_BASE_URL=https://example.com",
+		"GIT_AUTHOR_NAME=me",
+		"KEYBOARD_LAYOUT=us",
+	}
+	if got := sandboxEnv(env); !reflect.DeepEqual(got, want) {
+		t.Errorf("sandboxEnv =\n%q\nwant\n%q", got, want)
+	}
+}
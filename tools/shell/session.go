@@ -0,0 +1,309 @@
+package shell
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sessionStartScript starts the session shell. It runs under the backend's
+// sh -c and replaces it, so the session process is the shell itself.
+const sessionStartScript = `command -v bash >/dev/null 2>&1 && exec bash --noprofile --norc; exec sh`
+
+// sessionInterruptGrace is how long an interrupted command may take to
+// stop before the session is killed.
+var sessionInterruptGrace = 2 * time.Second
+
+// signalForwarder is implemented by backends whose session process is the
+// shell itself on this host, so that SIGINT sent to its process group
+// reaches the running command. With other backends an interrupt kills the
+// session.
+type signalForwarder interface {
+	forwardsSignals() bool
+}
+
+func (LocalBackend) forwardsSignals() bool {
+	return true
+}
+
+// sessionCommander is implemented by backends that must be told a command
+// reads its stdin, such as docker exec without -i.
+type sessionCommander interface {
+	SessionCommand(ctx context.Context, command, dir string, env []string) (*exec.Cmd, func(), error)
+}
+
+// shellSession is a long-lived shell that runs one command at a time.
+// Each command is passed through a quoted heredoc and eval, so syntax
+// errors cannot leave the shell waiting for more input, and is followed by
+// a sentinel line on stdout (with the exit status and working directory)
+// and on stderr.
+type shellSession struct {
+	owner   string // agent session the shell belongs to
+	cmd     *exec.Cmd
+	cancel  context.CancelFunc
+	stdin   io.WriteCloser
+	stdout  <-chan string
+	stderr  <-chan string
+	exited  chan struct{} // closed once the shell has exited
+	signals bool          // SIGINT reaches the running command
+}
+
+// startShellSession starts a shell through backend in dir.
+func startShellSession(backend Backend, owner, dir string, env []string) (*shellSession, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	command := backend.Command
+	if sc, ok := backend.(sessionCommander); ok {
+		command = sc.SessionCommand
+	}
+	cmd, cleanup, err := command(ctx, sessionStartScript, dir, env)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	setProcessGroup(cmd)
+
+	// Pipes from os.Pipe, unlike StdoutPipe, stay readable after Wait, so
+	// output written just before the shell exits is not lost.
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("create stdin pipe: %w", err)
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("create stdout pipe: %w", err)
+	}
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		stdoutR.Close()
+		stdoutW.Close()
+		cancel()
+		return nil, fmt.Errorf("create stderr pipe: %w", err)
+	}
+	cmd.Stdout, cmd.Stderr = stdoutW, stderrW
+	err = cmd.Start()
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		stdoutR.Close()
+		stderrR.Close()
+		cancel()
+		if cleanup != nil {
+			cleanup()
+		}
+		return nil, fmt.Errorf("start shell: %w", err)
+	}
+
+	s := &shellSession{
+		owner:  owner,
+		cmd:    cmd,
+		cancel: cancel,
+		stdin:  stdin,
+		stdout: readLines(stdoutR),
+		stderr: readLines(stderrR),
+		exited: make(chan struct{}),
+	}
+	if f, ok := backend.(signalForwarder); ok {
+		s.signals = f.forwardsSignals()
+	}
+	go func() {
+		_ = cmd.Wait()
+		if cleanup != nil {
+			cleanup()
+		}
+		close(s.exited)
+	}()
+
+	// Interrupts stop the command, not the shell.
+	if _, err := io.WriteString(stdin, "trap : INT\n"); err != nil {
+		s.close()
+		return nil, fmt.Errorf("set up shell: %w", err)
+	}
+	return s, nil
+}
+
+// readLines sends the lines read from r until EOF, then closes r and the
+// channel.
+func readLines(r io.ReadCloser) <-chan string {
+	lines := make(chan string, 256)
+	go func() {
+		defer close(lines)
+		defer r.Close()
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			if line != "" {
+				lines <- strings.TrimSuffix(line, "\n")
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return lines
+}
+
+// alive reports whether the shell is still running.
+func (s *shellSession) alive() bool {
+	select {
+	case <-s.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// close kills the shell and waits for it to exit.
+func (s *shellSession) close() {
+	s.stdin.Close()
+	s.cancel()
+	<-s.exited
+}
+
+// run runs command and waits for its sentinels. When ctx ends first, the
+// command is interrupted; if it does not stop within
+// sessionInterruptGrace, the shell is killed.
+func (s *shellSession) run(ctx context.Context, command string, maxBytes int) *Result {
+	marker := newSessionMarker()
+	script := fmt.Sprintf("__mscli_cmd=$(cat <<'%[1]s'\n%[2]s\n%[1]s\n)\n"+
+		"eval \"$__mscli_cmd\" < /dev/null\n"+
+		"printf '\\n%[1]s %%d %%s\\n' \"$?\" \"$PWD\"\n"+
+		"printf '\\n%[1]s\\n' >&2\n", marker, command)
+
+	result := &Result{}
+	stdout := &cappedLines{max: maxBytes}
+	stderr := &cappedLines{max: maxBytes}
+	finish := func() *Result {
+		result.Stdout, result.Stderr = stdout.String(), stderr.String()
+		return result
+	}
+	if _, err := io.WriteString(s.stdin, script); err != nil {
+		s.close()
+		result.ExitCode, result.Error, result.Restarted = -1, fmt.Errorf("write to shell: %w", err), true
+		return finish()
+	}
+
+	ended := func() *Result {
+		s.close()
+		result.ExitCode, result.Restarted = s.cmd.ProcessState.ExitCode(), true
+		return finish()
+	}
+
+	stdoutLines, stderrLines := s.stdout, s.stderr
+	done, exited := ctx.Done(), s.exited
+	var grace, drain <-chan time.Time
+	for stdoutLines != nil || stderrLines != nil {
+		select {
+		case line, ok := <-stdoutLines:
+			switch {
+			case !ok:
+				// The shell exited, e.g. the command ran exit.
+				return ended()
+			case strings.HasPrefix(line, marker+" "):
+				status, dir, _ := strings.Cut(strings.TrimPrefix(line, marker+" "), " ")
+				result.ExitCode, _ = strconv.Atoi(status)
+				result.Dir = dir
+				stdoutLines = nil
+			default:
+				stdout.add(line)
+			}
+		case line, ok := <-stderrLines:
+			switch {
+			case !ok:
+				stderrLines = nil
+			case line == marker:
+				stderrLines = nil
+			default:
+				stderr.add(line)
+			}
+		case <-done:
+			done = nil
+			result.Interrupted = true
+			if s.signals && interruptProcessGroup(s.cmd.Process) == nil {
+				grace = time.After(sessionInterruptGrace)
+				continue
+			}
+			s.close()
+			result.ExitCode, result.Restarted = -1, true
+			return finish()
+		case <-grace:
+			s.close()
+			result.ExitCode, result.Restarted = -1, true
+			return finish()
+		case <-exited:
+			// A background process may keep stdout open; read what the
+			// shell wrote and stop.
+			exited = nil
+			drain = time.After(200 * time.Millisecond)
+		case <-drain:
+			return ended()
+		}
+	}
+	return finish()
+}
+
+func newSessionMarker() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("__MSCLI_%d", time.Now().UnixNano())
+	}
+	return "__MSCLI_" + hex.EncodeToString(b)
+}
+
+// cappedLines joins output lines up to max bytes, like readCapped. An empty
+// line is held back because the sentinel is preceded by a newline, which
+// leaves one extra empty line when the output already ended with one.
+type cappedLines struct {
+	max       int
+	b         strings.Builder
+	lines     int
+	blank     bool
+	truncated bool
+}
+
+func (c *cappedLines) add(line string) {
+	if c.blank {
+		c.write("")
+		c.blank = false
+	}
+	if line == "" {
+		c.blank = true
+		return
+	}
+	c.write(line)
+}
+
+func (c *cappedLines) write(line string) {
+	extra := len(line)
+	if c.lines > 0 {
+		extra++
+	}
+	if c.b.Len()+extra > c.max {
+		c.truncated = true
+		return
+	}
+	if c.lines > 0 {
+		c.b.WriteByte('\n')
+	}
+	c.b.WriteString(line)
+	c.lines++
+}
+
+func (c *cappedLines) String() string {
+	if !c.truncated {
+		return c.b.String()
+	}
+	if c.b.Len() > 0 {
+		return c.b.String() + "\n[output truncated]"
+	}
+	return "[output truncated]"
+}
@@ -0,0 +1,23 @@
+//go:build !unix
+
+package shell
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// interruptProcessGroup is not supported; interrupted sessions are killed.
+func interruptProcessGroup(p *os.Process) error {
+	return errors.New("interrupts are not supported on this platform")
+}
+
+// processAlive reports whether a process with pid exists. Where that
+// cannot be told, it reports true.
+func processAlive(pid int) bool {
+	_, err := os.FindProcess(pid)
+	return err == nil
+}
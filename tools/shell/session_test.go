@@ -0,0 +1,279 @@
+//go:build unix
+
+package shell
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newSessionRunner returns a persistent runner in a temp dir.
+func newSessionRunner(t *testing.T, session *string) (*Runner, string) {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRunner(Config{WorkDir: dir, Timeout: 10 * time.Second, Persistent: true})
+	if session != nil {
+		r.SetSessionFunc(func() string { return *session })
+	}
+	t.Cleanup(func() { r.Close() })
+	return r, dir
+}
+
+func mustRun(t *testing.T, r *Runner, command string) *Result {
+	t.Helper()
+	res, err := r.Run(context.Background(), command)
+	if err != nil {
+		t.Fatalf("Run(%q): %v", command, err)
+	}
+	if res.Error != nil {
+		t.Fatalf("Run(%q) result error: %v", command, res.Error)
+	}
+	return res
+}
+
+func TestSessionKeepsDirAndEnv(t *testing.T) {
+	r, dir := newSessionRunner(t, nil)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	res := mustRun(t, r, "cd sub && export GREETING=hello")
+	if res.ExitCode != 0 {
+		t.Fatalf("exit code = %d, stderr %q", res.ExitCode, res.Stderr)
+	}
+	if want := filepath.Join(dir, "sub"); res.Dir != want {
+		t.Errorf("Dir = %q, want %q", res.Dir, want)
+	}
+
+	res = mustRun(t, r, `echo "$GREETING from $(basename "$PWD")"`)
+	if res.Stdout != "hello from sub" {
+		t.Errorf("stdout = %q, want %q", res.Stdout, "hello from sub")
+	}
+}
+
+func TestSessionExitCodeAndOutput(t *testing.T) {
+	r, _ := newSessionRunner(t, nil)
+
+	res := mustRun(t, r, "printf 'a\\n\\nb\\n'; echo oops >&2; false")
+	if res.ExitCode != 1 {
+		t.Errorf("exit code = %d, want 1", res.ExitCode)
+	}
+	if res.Stdout != "a\n\nb" {
+		t.Errorf("stdout = %q, want %q", res.Stdout, "a\n\nb")
+	}
+	if res.Stderr != "oops" {
+		t.Errorf("stderr = %q, want %q", res.Stderr, "oops")
+	}
+
+	// Without a trailing newline.
+	res = mustRun(t, r, "printf done")
+	if res.Stdout != "done" || res.ExitCode != 0 {
+		t.Errorf("got stdout %q exit %d, want %q exit 0", res.Stdout, res.ExitCode, "done")
+	}
+}
+
+func TestSessionSyntaxErrorKeepsShell(t *testing.T) {
+	r, _ := newSessionRunner(t, nil)
+	mustRun(t, r, "export KEEP=1")
+
+	res := mustRun(t, r, `echo "unterminated`)
+	if res.ExitCode == 0 {
+		t.Errorf("exit code = 0, want non-zero for a syntax error")
+	}
+	if res.Restarted {
+		t.Errorf("syntax error restarted the shell")
+	}
+
+	res = mustRun(t, r, "echo $KEEP")
+	if res.Stdout != "1" {
+		t.Errorf("stdout = %q, want 1", res.Stdout)
+	}
+}
+
+func TestSessionStdinIsEmpty(t *testing.T) {
+	r, _ := newSessionRunner(t, nil)
+
+	res := mustRun(t, r, "cat; echo after")
+	if res.Stdout != "after" {
+		t.Errorf("stdout = %q, want %q", res.Stdout, "after")
+	}
+}
+
+func TestSessionExitRestarts(t *testing.T) {
+	r, dir := newSessionRunner(t, nil)
+	mustRun(t, r, "cd / && export GONE=1")
+
+	res := mustRun(t, r, "exit 3")
+	if res.ExitCode != 3 {
+		t.Errorf("exit code = %d, want 3", res.ExitCode)
+	}
+	if !res.Restarted {
+		t.Errorf("Restarted = false after exit")
+	}
+
+	res = mustRun(t, r, `echo "$PWD:$GONE"`)
+	if res.Stdout != dir+":" {
+		t.Errorf("stdout = %q, want %q", res.Stdout, dir+":")
+	}
+}
+
+func TestSessionTimeoutInterrupts(t *testing.T) {
+	r, _ := newSessionRunner(t, nil)
+	mustRun(t, r, "export KEEP=1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	res, err := r.Run(ctx, "echo started; sleep 30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("interrupt took %v", elapsed)
+	}
+	if !res.Interrupted {
+		t.Errorf("Interrupted = false")
+	}
+	if res.Restarted {
+		t.Errorf("interrupting sleep restarted the shell")
+	}
+	if res.ExitCode == 0 {
+		t.Errorf("exit code = 0, want non-zero")
+	}
+	if !strings.Contains(res.Stdout, "started") {
+		t.Errorf("stdout = %q, want output before the interrupt", res.Stdout)
+	}
+
+	res = mustRun(t, r, "echo $KEEP")
+	if res.Stdout != "1" {
+		t.Errorf("stdout = %q, want 1", res.Stdout)
+	}
+}
+
+func TestSessionKilledAfterGrace(t *testing.T) {
+	old := sessionInterruptGrace
+	sessionInterruptGrace = 200 * time.Millisecond
+	defer func() { sessionInterruptGrace = old }()
+
+	r, _ := newSessionRunner(t, nil)
+	mustRun(t, r, "export GONE=1")
+
+	// A builtin loop ignores SIGINT, since the shell traps it.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	res, err := r.Run(ctx, "while :; do :; done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Interrupted || !res.Restarted {
+		t.Errorf("Interrupted = %v, Restarted = %v, want both", res.Interrupted, res.Restarted)
+	}
+
+	res = mustRun(t, r, "echo ${GONE:-unset}")
+	if res.Stdout != "unset" {
+		t.Errorf("stdout = %q, want unset", res.Stdout)
+	}
+}
+
+func TestSessionOutputCap(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRunner(Config{WorkDir: dir, Persistent: true, MaxOutputBytes: 10})
+	defer r.Close()
+
+	// The cap matches the one-shot runner's.
+	command := "echo 12345; echo 67890; echo abc"
+	res := mustRun(t, r, command)
+	want := mustRun(t, NewRunner(Config{WorkDir: dir, MaxOutputBytes: 10}), command)
+	if res.Stdout != want.Stdout || !strings.HasSuffix(res.Stdout, "[output truncated]") {
+		t.Errorf("stdout = %q, want %q", res.Stdout, want.Stdout)
+	}
+}
+
+func TestSessionNewAgentSession(t *testing.T) {
+	session := ""
+	r, dir := newSessionRunner(t, &session)
+
+	// The shell started before the first message is kept.
+	mustRun(t, r, "cd /")
+	session = "s1"
+	if res := mustRun(t, r, "pwd"); res.Stdout != "/" {
+		t.Errorf("stdout = %q, want /", res.Stdout)
+	}
+
+	session = "s2"
+	if res := mustRun(t, r, "pwd"); res.Stdout != dir {
+		t.Errorf("stdout = %q, want %q", res.Stdout, dir)
+	}
+}
+
+func TestSessionRestart(t *testing.T) {
+	r, _ := newSessionRunner(t, nil)
+	mustRun(t, r, "export GONE=1")
+	r.RestartSession()
+
+	if res := mustRun(t, r, "echo ${GONE:-unset}"); res.Stdout != "unset" {
+		t.Errorf("stdout = %q, want unset", res.Stdout)
+	}
+}
+
+func TestSessionKillsCommandIgnoringInterrupt(t *testing.T) {
+	old := sessionInterruptGrace
+	sessionInterruptGrace = 200 * time.Millisecond
+	defer func() { sessionInterruptGrace = old }()
+
+	r, dir := newSessionRunner(t, nil)
+	pidFile := filepath.Join(dir, "pid")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	res, err := r.Run(ctx, `sh -c 'trap "" INT; echo $$ > pid; exec sleep 30'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Interrupted || !res.Restarted {
+		t.Errorf("Interrupted = %v, Restarted = %v, want both", res.Interrupted, res.Restarted)
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for processRunning(pid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("sleep %d survived the timeout", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// processRunning reports whether pid exists and is not a zombie.
+func processRunning(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		// Without /proc, a zombie also counts as running.
+		return os.IsNotExist(err) && !procMounted() && syscall.Kill(pid, 0) == nil
+	}
+	_, rest, _ := strings.Cut(string(stat), ") ")
+	return !strings.HasPrefix(rest, "Z")
+}
+
+func procMounted() bool {
+	_, err := os.Stat("/proc/self/stat")
+	return err == nil
+}
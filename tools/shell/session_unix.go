@@ -0,0 +1,42 @@
+//go:build unix
+
+package shell
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd in a process group of its own, which the
+// commands run by the session shell share, and makes cancelling cmd kill
+// the whole group: killing only the shell would leave a command that
+// ignores SIGINT running.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	// The backend's Cancel may have more to clean up, e.g. in a container.
+	cancel := cmd.Cancel
+	cmd.Cancel = func() error {
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		if cancel != nil {
+			return cancel()
+		}
+		return cmd.Process.Kill()
+	}
+}
+
+// interruptProcessGroup sends SIGINT to the process group led by p.
+func interruptProcessGroup(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGINT)
+}
+
+// processAlive reports whether a process with pid exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -28,7 +28,11 @@ func (t *ShellTool) Name() string {
 
 // Description returns the tool description.
 func (t *ShellTool) Description() string {
-	return "Execute a shell command. Use this for running tests, building, git operations, etc. Commands have a timeout and destructive operations may require confirmation."
+	desc := "Execute a shell command. Use this for running tests, building, git operations, etc. Commands have a timeout and destructive operations may require confirmation."
+	if t.runner.config.Persistent {
+		desc += " Commands run one at a time in a persistent shell: the working directory and exported variables carry over to later commands. A command that times out is interrupted; set restart to start a fresh shell in the project directory."
+	}
+	return desc
 }
 
 // Schema returns the tool parameter schema.
@@ -44,6 +48,10 @@ func (t *ShellTool) Schema() llm.ToolSchema {
 				Type:        "integer",
 				Description: "Timeout in seconds (default: 60, max: 1800)",
 			},
+			"restart": {
+				Type:        "boolean",
+				Description: "Start a fresh shell before running the command, resetting the working directory and environment",
+			},
 		},
 		Required: []string{"command"},
 	}
@@ -52,6 +60,7 @@ func (t *ShellTool) Schema() llm.ToolSchema {
 type shellParams struct {
 	Command string `json:"command"`
 	Timeout int    `json:"timeout"`
+	Restart bool   `json:"restart"`
 }
 
 // Execute executes the shell tool.
@@ -73,6 +82,10 @@ func (t *ShellTool) Execute(ctx context.Context, params json.RawMessage) (*tools
 		defer cancel()
 	}
 
+	if p.Restart {
+		t.runner.RestartSession()
+	}
+
 	// Run command
 	result, err := t.runner.Run(ctx, command)
 	if err != nil {
@@ -91,7 +104,16 @@ func (t *ShellTool) Execute(ctx context.Context, params json.RawMessage) (*tools
 		parts = append(parts, fmt.Sprintf("[stderr]\n%s", result.Stderr))
 	}
 
+	if result.Interrupted {
+		parts = append(parts, "[interrupted]")
+	}
 	parts = append(parts, fmt.Sprintf("exit status %d", result.ExitCode))
+	if result.Dir != "" && result.Dir != t.runner.GetWorkDir() {
+		parts = append(parts, fmt.Sprintf("cwd: %s", result.Dir))
+	}
+	if result.Restarted {
+		parts = append(parts, "[shell session restarted: working directory and environment were reset]")
+	}
 
 	output := strings.Join(parts, "\n")
 
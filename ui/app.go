@@ -33,6 +33,9 @@ type App struct {
 	eventCh       <-chan model.Event
 	userCh        chan<- string // sends user input to the engine bridge
 	lastInterrupt time.Time     // track last ctrl+c for double-press exit
+
+	permission   *model.PermissionPrompt // pending permission prompt, shown as a modal
+	permSelected int
 }
 
 // New creates a new App driven by the given event channel.
@@ -114,6 +117,13 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (a App) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if a.permission != nil {
+		if msg.String() != "ctrl+c" {
+			return a.handlePermissionKey(msg)
+		}
+		a = a.answerPermission(model.PermissionDeny)
+	}
+
 	// Check if we're in slash suggestion mode
 	if a.input.IsSlashMode() {
 		switch msg.String() {
@@ -193,9 +203,58 @@ func (a App) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	}
 }
 
+// handlePermissionKey drives the permission modal. Esc denies the call.
+func (a App) handlePermissionKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	options := panels.PermissionOptions(a.permission)
+	key := msg.String()
+
+	switch key {
+	case "up", "k", "shift+tab":
+		a.permSelected = (a.permSelected + len(options) - 1) % len(options)
+		return a, nil
+	case "down", "j", "tab":
+		a.permSelected = (a.permSelected + 1) % len(options)
+		return a, nil
+	case "enter":
+		return a.answerPermission(options[a.permSelected].Choice), nil
+	case "esc":
+		return a.answerPermission(model.PermissionDeny), nil
+	}
+
+	for _, opt := range options {
+		for _, k := range opt.Keys {
+			if strings.EqualFold(key, k) {
+				return a.answerPermission(opt.Choice), nil
+			}
+		}
+	}
+	return a, nil
+}
+
+// answerPermission sends the choice to the waiting tool call and closes the
+// modal.
+func (a App) answerPermission(choice model.PermissionChoice) App {
+	if a.permission.Reply != nil {
+		select {
+		case a.permission.Reply <- choice:
+		default:
+		}
+	}
+	a.permission = nil
+	a.permSelected = 0
+	return a
+}
+
 func (a App) handleEvent(ev model.Event) (tea.Model, tea.Cmd) {
 	var eventCmd tea.Cmd
 
+	switch ev.Type {
+	case model.CmdStarted, model.ToolRead, model.ToolGrep, model.ToolGlob,
+		model.ToolEdit, model.ToolWrite, model.ToolError:
+		// Text streamed before tool calls gets no final AgentReply.
+		a.state = a.settleStreaming()
+	}
+
 	switch ev.Type {
 	case model.AgentThinking:
 		// Start thinking - set flag and ensure we have a thinking message
@@ -203,10 +262,18 @@ func (a App) handleEvent(ev model.Event) (tea.Model, tea.Cmd) {
 		a.state = a.state.WithMessage(model.Message{Kind: model.MsgThinking})
 
 	case model.AgentReply:
-		// Stop thinking and show result
+		// Stop thinking and show result; a streamed reply is replaced by
+		// the final text.
 		a.state = a.state.WithThinking(false)
+		a.state = a.finishStreaming()
 		a.state = a.replaceThinking(model.Message{Kind: model.MsgAgent, Content: ev.Message})
 
+	case model.AgentReplyDelta:
+		a.state = a.appendReplyDelta(ev.Message)
+
+	case model.AgentThinkingDelta:
+		a.state = a.appendThinkingDelta(ev.Message)
+
 	case model.CmdStarted:
 		// Update command count
 		stats := a.state.Stats
@@ -309,6 +376,7 @@ func (a App) handleEvent(ev model.Event) (tea.Model, tea.Cmd) {
 		mi.CtxUsed = ev.CtxUsed
 		mi.CtxMax = ev.CtxMax
 		mi.TokensUsed = ev.TokensUsed
+		mi.CostUSD = ev.CostUSD
 		a.state = a.state.WithModel(mi)
 
 	case model.TaskUpdated:
@@ -320,6 +388,10 @@ func (a App) handleEvent(ev model.Event) (tea.Model, tea.Cmd) {
 			{Kind: model.MsgAgent, Content: ev.Message},
 		}
 
+	case model.UserMessage:
+		// Replayed user input, e.g. from a resumed session.
+		a.state = a.state.WithMessage(model.Message{Kind: model.MsgUser, Content: ev.Message})
+
 	case model.ModelUpdate:
 		// Update model name in top bar
 		mi := a.state.Model
@@ -343,6 +415,19 @@ func (a App) handleEvent(ev model.Event) (tea.Model, tea.Cmd) {
 			eventCmd = tea.DisableMouse
 		}
 
+	case model.PermissionRequest:
+		if ev.Permission != nil {
+			a.permission = ev.Permission
+			a.permSelected = 0
+		}
+
+	case model.PermissionCancel:
+		// The tool call stopped waiting, e.g. on timeout or interrupt.
+		if a.permission != nil && a.permission == ev.Permission {
+			a.permission = nil
+			a.permSelected = 0
+		}
+
 	case model.Done:
 		return a, tea.Quit
 	}
@@ -367,6 +452,68 @@ func (a App) replaceThinking(m model.Message) model.State {
 	return next
 }
 
+// appendReplyDelta appends streamed text to the reply being streamed, or
+// starts a new one when the last message is something else.
+func (a App) appendReplyDelta(delta string) model.State {
+	msgs := make([]model.Message, len(a.state.Messages))
+	copy(msgs, a.state.Messages)
+
+	if n := len(msgs); n > 0 && msgs[n-1].Kind == model.MsgAgent && msgs[n-1].Streaming {
+		msgs[n-1].Content += delta
+	} else {
+		msgs = append(msgs, model.Message{Kind: model.MsgAgent, Content: delta, Streaming: true})
+	}
+
+	next := a.state
+	next.Messages = msgs
+	return next
+}
+
+// appendThinkingDelta adds streamed reasoning text to the thinking indicator.
+func (a App) appendThinkingDelta(delta string) model.State {
+	msgs := make([]model.Message, len(a.state.Messages))
+	copy(msgs, a.state.Messages)
+
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Kind == model.MsgThinking {
+			msgs[i].Content += delta
+			break
+		}
+	}
+
+	next := a.state
+	next.Messages = msgs
+	return next
+}
+
+// finishStreaming drops a trailing streamed reply so the final AgentReply
+// takes its place.
+func (a App) finishStreaming() model.State {
+	msgs := a.state.Messages
+	if n := len(msgs); n > 0 && msgs[n-1].Kind == model.MsgAgent && msgs[n-1].Streaming {
+		msgs = msgs[:n-1]
+	}
+
+	next := a.state
+	next.Messages = append([]model.Message{}, msgs...)
+	return next
+}
+
+// settleStreaming keeps a trailing streamed reply as a finished message.
+func (a App) settleStreaming() model.State {
+	msgs := a.state.Messages
+	n := len(msgs)
+	if n == 0 || msgs[n-1].Kind != model.MsgAgent || !msgs[n-1].Streaming {
+		return a.state
+	}
+
+	msgs = append([]model.Message{}, msgs...)
+	msgs[n-1].Streaming = false
+	next := a.state
+	next.Messages = msgs
+	return next
+}
+
 func (a App) appendToLastTool(line string) model.State {
 	msgs := make([]model.Message, len(a.state.Messages))
 	copy(msgs, a.state.Messages)
@@ -401,6 +548,9 @@ func (a App) View() string {
 	topBar := panels.RenderTopBar(a.state, a.width)
 	line := a.chatLine()
 	chat := a.viewport.View()
+	if a.permission != nil {
+		chat = panels.RenderPermissionPrompt(a.permission, a.permSelected, a.width, a.chatHeight())
+	}
 	input := "  " + a.input.View()
 	hintBar := panels.RenderHintBar(a.width)
 
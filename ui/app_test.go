@@ -0,0 +1,37 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/vigo999/ms-cli/ui/model"
+)
+
+func TestStreamedReplyIsFinishedByToolCall(t *testing.T) {
+	app := New(nil, nil, "test", ".", "", "model", 1000)
+	for _, ev := range []model.Event{
+		{Type: model.AgentThinking},
+		{Type: model.AgentReplyDelta, Message: "Let me "},
+		{Type: model.AgentReplyDelta, Message: "look."},
+		{Type: model.ToolRead, Message: "main.go", Summary: "10 lines"},
+		{Type: model.AgentReplyDelta, Message: "Done."},
+		{Type: model.AgentReply, Message: "Done."},
+	} {
+		m, _ := app.handleEvent(ev)
+		app = m.(App)
+	}
+
+	var agent []model.Message
+	for _, msg := range app.state.Messages {
+		if msg.Kind == model.MsgAgent {
+			agent = append(agent, msg)
+		}
+	}
+	if len(agent) != 2 || agent[0].Content != "Let me look." || agent[1].Content != "Done." {
+		t.Fatalf("unexpected replies %+v", agent)
+	}
+	for _, msg := range agent {
+		if msg.Streaming {
+			t.Errorf("reply %q is still marked as streaming", msg.Content)
+		}
+	}
+}
@@ -12,6 +12,7 @@ type ModelInfo struct {
 	CtxUsed    int
 	CtxMax     int
 	TokensUsed int
+	CostUSD    float64
 }
 
 // MessageKind distinguishes chat message types.
@@ -40,6 +41,7 @@ type Message struct {
 	ToolName string
 	Display  DisplayMode
 	Summary  string // shown when collapsed, e.g. "5 matches", "23 files"
+	Streaming bool  // agent reply still receiving deltas
 }
 
 // EventType identifies the kind of UI event.
@@ -53,6 +55,8 @@ const (
 	AnalysisReady  EventType = "AnalysisReady"
 	AgentReply     EventType = "AgentReply"
 	AgentThinking  EventType = "AgentThinking"
+	AgentReplyDelta EventType = "AgentReplyDelta"
+	AgentThinkingDelta EventType = "AgentThinkingDelta"
 	TokenUpdate    EventType = "TokenUpdate"
 	ToolRead       EventType = "ToolRead"
 	ToolGrep       EventType = "ToolGrep"
@@ -61,8 +65,11 @@ const (
 	ToolWrite      EventType = "ToolWrite"
 	ToolError      EventType = "ToolError"
 	ClearScreen    EventType = "ClearScreen"
+	UserMessage    EventType = "UserMessage"
 	ModelUpdate    EventType = "ModelUpdate"
 	MouseModeToggle EventType = "MouseModeToggle"
+	PermissionRequest EventType = "PermissionRequest"
+	PermissionCancel EventType = "PermissionCancel"
 	Done           EventType = "Done"
 )
 
@@ -77,6 +84,30 @@ type Event struct {
 	CtxUsed    int
 	CtxMax     int
 	TokensUsed int
+	CostUSD    float64
+	Permission *PermissionPrompt // set for PermissionRequest and PermissionCancel
+}
+
+// PermissionChoice is the user's answer to a permission prompt.
+type PermissionChoice int
+
+const (
+	PermissionAllowOnce PermissionChoice = iota
+	PermissionAllowSession
+	PermissionAllowAlways
+	PermissionDeny
+)
+
+// PermissionPrompt asks the user whether a tool call may run. The answer is
+// sent on Reply, which must be buffered so the UI never blocks.
+type PermissionPrompt struct {
+	Tool        string
+	Target      string // shell command or path
+	Scope       string // what session and permanent grants cover
+	Category    string // dangerous command category, if any
+	Description string
+	Segment     string // part of a compound command that was flagged
+	Reply       chan<- PermissionChoice
 }
 
 // TaskStats tracks execution statistics for the current task.
@@ -84,7 +84,7 @@ func RenderMessages(state model.State, spinnerView string) string {
 		case model.MsgThinking:
 			if isThinking {
 				// Show animated thinking indicator
-				parts = append(parts, renderThinking(spinnerView))
+				parts = append(parts, renderThinking(spinnerView, m.Content))
 			} else {
 				// Show "Done" with summary
 				parts = append(parts, renderDone(stats))
@@ -110,10 +110,27 @@ func renderAgentMsg(content string) string {
 	return strings.Join(styled, "\n")
 }
 
-func renderThinking(thinkingView string) string {
+// maxReasoningLines limits how much streamed reasoning is shown under the spinner.
+const maxReasoningLines = 3
+
+func renderThinking(thinkingView, reasoning string) string {
 	// Animated thinking indicator with Braille spinner
 	// thinkingView already contains the spinner and text from ThinkingSpinner.View()
-	return "  " + thinkingView
+	out := "  " + thinkingView
+
+	// Show the tail of streamed reasoning below the spinner
+	reasoning = strings.TrimSpace(reasoning)
+	if reasoning == "" {
+		return out
+	}
+	lines := strings.Split(reasoning, "\n")
+	if len(lines) > maxReasoningLines {
+		lines = lines[len(lines)-maxReasoningLines:]
+	}
+	for _, line := range lines {
+		out += "\n    " + thinkingStyle.Render(line)
+	}
+	return out
 }
 
 // renderDone shows completed task summary without animation
@@ -0,0 +1,111 @@
+package panels
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/vigo999/ms-cli/ui/model"
+)
+
+var (
+	permBoxStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("214")).
+			Padding(0, 2)
+
+	permDangerBoxStyle = permBoxStyle.
+				BorderForeground(lipgloss.Color("196"))
+
+	permTitleStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("214")).
+			Bold(true)
+
+	permTargetStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("252"))
+
+	permDangerStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("203")).
+			Bold(true)
+
+	permOptionStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("244"))
+
+	permSelectedStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("39")).
+				Bold(true)
+)
+
+// PermissionOption is one answer offered by the permission prompt.
+type PermissionOption struct {
+	Keys   []string // shortcut keys, the first one is shown
+	Label  string
+	Choice model.PermissionChoice
+}
+
+// PermissionOptions returns the answers for a prompt, in display order.
+func PermissionOptions(p *model.PermissionPrompt) []PermissionOption {
+	return []PermissionOption{
+		{Keys: []string{"1", "y"}, Label: "Allow once", Choice: model.PermissionAllowOnce},
+		{Keys: []string{"2", "s"}, Label: fmt.Sprintf("Allow %s for this session", p.Scope), Choice: model.PermissionAllowSession},
+		{Keys: []string{"3", "a"}, Label: fmt.Sprintf("Always allow %s", p.Scope), Choice: model.PermissionAllowAlways},
+		{Keys: []string{"4", "n"}, Label: "Deny", Choice: model.PermissionDeny},
+	}
+}
+
+// RenderPermissionPrompt renders the permission modal centered in a
+// width x height area, with the option at selected highlighted.
+func RenderPermissionPrompt(p *model.PermissionPrompt, selected, width, height int) string {
+	boxWidth := width - 8
+	if boxWidth > 90 {
+		boxWidth = 90
+	}
+	if boxWidth < 20 {
+		boxWidth = 20
+	}
+	textWidth := boxWidth - 6
+
+	var sb strings.Builder
+	sb.WriteString(permTitleStyle.Render(fmt.Sprintf("Allow %s?", p.Tool)))
+	sb.WriteString("\n\n")
+	if p.Target != "" {
+		sb.WriteString(permTargetStyle.Width(textWidth).Render(truncateLines(p.Target, 6)))
+		sb.WriteString("\n\n")
+	}
+	if p.Category != "" {
+		warning := fmt.Sprintf("⚠ %s: %s", p.Category, p.Description)
+		if p.Segment != "" {
+			warning += fmt.Sprintf(" (in »%s«)", truncateLines(p.Segment, 2))
+		}
+		sb.WriteString(permDangerStyle.Width(textWidth).Render(warning))
+		sb.WriteString("\n\n")
+	}
+
+	for i, opt := range PermissionOptions(p) {
+		line := fmt.Sprintf("%s. %s", opt.Keys[0], opt.Label)
+		if i == selected {
+			sb.WriteString(permSelectedStyle.Render("› " + line))
+		} else {
+			sb.WriteString(permOptionStyle.Render("  " + line))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+	sb.WriteString(hintDescStyle.Render("↑/↓ select • enter confirm • esc deny"))
+
+	box := permBoxStyle
+	if p.Category != "" {
+		box = permDangerBoxStyle
+	}
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center,
+		box.Width(boxWidth).Render(sb.String()))
+}
+
+// truncateLines keeps the first n lines of s.
+func truncateLines(s string, n int) string {
+	lines := strings.Split(s, "\n")
+	if len(lines) <= n {
+		return s
+	}
+	return strings.Join(lines[:n], "\n") + fmt.Sprintf("\n… (%d more lines)", len(lines)-n)
+}
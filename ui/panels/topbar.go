@@ -51,6 +51,8 @@ func RenderTopBar(s model.State, width int) string {
 		infoStyle.Render(fmt.Sprintf("ctx: %s/%s", formatTokens(s.Model.CtxUsed), formatTokens(s.Model.CtxMax))),
 		sep,
 		infoStyle.Render(fmt.Sprintf("tokens: %s", formatTokens(s.Model.TokensUsed))),
+		sep,
+		infoStyle.Render(fmt.Sprintf("cost: %s", formatCost(s.Model.CostUSD))),
 	}, " ")
 
 	gap := width - lipgloss.Width(left) - lipgloss.Width(right) - 2
@@ -98,6 +100,13 @@ func formatTokens(n int) string {
 	}
 }
 
+func formatCost(usd float64) string {
+	if usd >= 1 {
+		return fmt.Sprintf("$%.2f", usd)
+	}
+	return fmt.Sprintf("$%.3f", usd)
+}
+
 func repeatChar(ch string, n int) string {
 	return strings.Repeat(ch, n)
 }
@@ -125,7 +125,7 @@ func (r *Registry) registerDefaults() {
 	r.Register(Command{
 		Name:        "/model",
 		Description: "Show or switch model",
-		Usage:       "/model [openai:]model",
+		Usage:       "/model [provider:]model",
 	})
 
 	r.Register(Command{
@@ -155,7 +155,7 @@ func (r *Registry) registerDefaults() {
 	r.Register(Command{
 		Name:        "/permission",
 		Description: "Manage tool permissions",
-		Usage:       "/permission [tool] [level]",
+		Usage:       "/permission [tool] [level] | list|revoke|export|import|expire|explain",
 	})
 
 	r.Register(Command{
@@ -170,6 +170,30 @@ func (r *Registry) registerDefaults() {
 		Usage:       "/mouse [on|off|toggle|status]",
 	})
 
+	r.Register(Command{
+		Name:        "/mode",
+		Description: "Show or switch run mode",
+		Usage:       "/mode [standard|plan|review]",
+	})
+
+	r.Register(Command{
+		Name:        "/plan",
+		Description: "Create, run, resume or edit a plan",
+		Usage:       "/plan <goal> | run | resume [id] | skip <n>",
+	})
+
+	r.Register(Command{
+		Name:        "/session",
+		Description: "List, start, load or manage saved sessions",
+		Usage:       "/session list|new|load|rename|archive|export|import",
+	})
+
+	r.Register(Command{
+		Name:        "/memory",
+		Description: "List, search, forget or pin saved memories",
+		Usage:       "/memory list|search <query>|forget <id>|pin <id>",
+	})
+
 	r.Register(Command{
 		Name:        "/help",
 		Description: "Show available commands",